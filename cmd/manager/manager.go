@@ -5,13 +5,16 @@ import (
 	"fmt"
 	"path/filepath"
 	"plugin"
+	"sort"
 	"strconv"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/infrawatch/apputils/logging"
 	"github.com/openstack-k8s-operators/sg-core/pkg/application"
 	"github.com/openstack-k8s-operators/sg-core/pkg/bus"
+	"github.com/openstack-k8s-operators/sg-core/pkg/data"
 	"github.com/openstack-k8s-operators/sg-core/pkg/handler"
 	"github.com/openstack-k8s-operators/sg-core/pkg/transport"
 	"github.com/pkg/errors"
@@ -185,17 +188,37 @@ func RunTransports(ctx context.Context, wg *sync.WaitGroup, done chan bool, repo
 			}(wg, h)
 		}
 
+		if mp, ok := t.(transport.MetricPublisher); ok {
+			mp.SetMetricPublishFunc(metricPublishFunc)
+		}
+
 		wg.Add(1)
 		go func(wg *sync.WaitGroup, t transport.Transport, name string) {
 			defer wg.Done()
-			t.Run(ctx, func(blob []byte) {
+			handle := func(blob []byte, mpf bus.MetricPublishFunc, epf bus.EventPublishFunc) {
 				for _, h := range handlers[name] {
-					err := h.Handle(blob, report, metricPublishFunc, eventPublishFunc)
+					err := h.Handle(blob, report, mpf, epf)
 					if err != nil {
 						logger.Metadata(logging.Metadata{"error": err, "handler": fmt.Sprintf("%s[%s]", h.Identify(), name)})
 						_ = logger.Debug("failed handling message")
 					}
 				}
+			}
+
+			if mt, ok := t.(transport.MetadataTransport); ok {
+				mt.RunWithMetadata(ctx, func(blob []byte, meta transport.Metadata) {
+					if len(meta) == 0 {
+						handle(blob, metricPublishFunc, eventPublishFunc)
+						return
+					}
+					mpf, epf := withMetadata(meta, metricPublishFunc, eventPublishFunc)
+					handle(blob, mpf, epf)
+				}, done)
+				return
+			}
+
+			t.Run(ctx, func(blob []byte) {
+				handle(blob, metricPublishFunc, eventPublishFunc)
 			}, done)
 		}(wg, t, name)
 	}
@@ -214,6 +237,50 @@ func RunApplications(ctx context.Context, wg *sync.WaitGroup, done chan bool) {
 
 // helper functions
 
+// withMetadata wraps publish functions so that message metadata are attached as labels.
+// Labels already set by the handler take precedence
+func withMetadata(meta transport.Metadata, mpf bus.MetricPublishFunc, epf bus.EventPublishFunc) (bus.MetricPublishFunc, bus.EventPublishFunc) {
+	// sort keys so that labels are always submitted in the same order
+	keys := make([]string, 0, len(meta))
+	for k := range meta {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	metricWithMeta := func(name string, t float64, mType data.MetricType, interval time.Duration, value float64, labelKeys []string, labelVals []string) {
+		newKeys := make([]string, len(labelKeys), len(labelKeys)+len(keys))
+		newVals := make([]string, len(labelVals), len(labelVals)+len(keys))
+		copy(newKeys, labelKeys)
+		copy(newVals, labelVals)
+	outer:
+		for _, k := range keys {
+			for _, existing := range labelKeys {
+				if existing == k {
+					continue outer
+				}
+			}
+			newKeys = append(newKeys, k)
+			newVals = append(newVals, meta[k])
+		}
+		mpf(name, t, mType, interval, value, newKeys, newVals)
+	}
+
+	eventWithMeta := func(e data.Event) {
+		labels := make(map[string]interface{}, len(e.Labels)+len(keys))
+		for k, v := range e.Labels {
+			labels[k] = v
+		}
+		for _, k := range keys {
+			if _, ok := labels[k]; !ok {
+				labels[k] = meta[k]
+			}
+		}
+		e.Labels = labels
+		epf(e)
+	}
+	return metricWithMeta, eventWithMeta
+}
+
 func initPlugin(name string) (plugin.Symbol, error) {
 	bin := strings.Join([]string{name, "so"}, ".")
 	path := filepath.Join(pluginPath, bin)
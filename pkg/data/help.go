@@ -0,0 +1,27 @@
+package data
+
+import "sync"
+
+// metricHelp holds HELP texts of metrics received by handlers which know them. The metric bus
+// carries only samples, so applications look the texts up here
+var metricHelp sync.Map
+
+// SetMetricHelp stores HELP text of metric. The first text is kept, so that all series of metric
+// are described consistently
+func SetMetricHelp(name string, help string) {
+	if help == "" {
+		return
+	}
+	if _, ok := metricHelp.Load(name); !ok {
+		metricHelp.LoadOrStore(name, help)
+	}
+}
+
+// MetricHelp returns HELP text of metric or empty string when it is unknown
+func MetricHelp(name string) string {
+	help, ok := metricHelp.Load(name)
+	if !ok {
+		return ""
+	}
+	return help.(string)
+}
@@ -0,0 +1,328 @@
+// Package fluent decodes messages of the Fluentd Forward protocol v1 shared by the fluent-forward transport and handler
+package fluent
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/vmihailenco/msgpack/v5"
+	"github.com/vmihailenco/msgpack/v5/msgpcode"
+)
+
+// eventTimeExt is msgpack extension type of EventTime
+const eventTimeExt = 0
+
+var (
+	// ErrTooLarge is returned when decompressed entries exceed the limit
+	ErrTooLarge = errors.New("decompressed entries too large")
+	// ErrUnsupportedCompression is returned for compression other than gzip
+	ErrUnsupportedCompression = errors.New("unsupported compression")
+)
+
+// Mode of the message
+type Mode int
+
+// Forward protocol carrier modes
+const (
+	MessageMode Mode = iota
+	ForwardMode
+	PackedForwardMode
+	CompressedPackedForwardMode
+)
+
+// String returns name of the mode
+func (m Mode) String() string {
+	return []string{"Message", "Forward", "PackedForward", "CompressedPackedForward"}[m]
+}
+
+// Options of the message
+type Options struct {
+	Size       int
+	Chunk      string
+	Compressed string
+}
+
+// Entry is single event record
+type Entry struct {
+	Time   time.Time
+	Record map[string]interface{}
+}
+
+// Message is decoded forward protocol message
+type Message struct {
+	Mode    Mode
+	Tag     string
+	Entries []Entry
+	Options Options
+}
+
+// Decode decodes message in any of the carrier modes. Decompressed size of CompressedPackedForward
+// entries is limited to maxSize bytes
+func Decode(blob []byte, maxSize int64) (Message, error) {
+	msg := Message{}
+	d := msgpack.NewDecoder(bytes.NewReader(blob))
+	n, err := d.DecodeArrayLen()
+	if err != nil {
+		return msg, err
+	}
+	if n < 2 || n > 4 {
+		return msg, fmt.Errorf("unexpected message array length %d", n)
+	}
+	msg.Tag, err = d.DecodeString()
+	if err != nil {
+		return msg, fmt.Errorf("tag: %w", err)
+	}
+	c, err := d.PeekCode()
+	if err != nil {
+		return msg, err
+	}
+
+	var packed []byte
+	switch {
+	case msgpcode.IsFixedArray(c) || c == msgpcode.Array16 || c == msgpcode.Array32:
+		msg.Mode = ForwardMode
+		msg.Entries, err = decodeEntries(d, len(blob))
+		if err != nil {
+			return msg, err
+		}
+	case msgpcode.IsBin(c) || msgpcode.IsString(c):
+		msg.Mode = PackedForwardMode
+		packed, err = d.DecodeBytes()
+		if err != nil {
+			return msg, err
+		}
+	default:
+		if n < 3 {
+			return msg, fmt.Errorf("message mode requires time and record")
+		}
+		msg.Mode = MessageMode
+		entry, err := decodeEntry(d)
+		if err != nil {
+			return msg, err
+		}
+		msg.Entries = []Entry{entry}
+		n--
+	}
+	if n > 3 {
+		return msg, fmt.Errorf("unexpected %s message array length %d", msg.Mode, n)
+	}
+
+	if n > 2 {
+		msg.Options, err = decodeOptions(d)
+		if err != nil {
+			return msg, err
+		}
+	}
+
+	if msg.Mode == PackedForwardMode {
+		switch msg.Options.Compressed {
+		case "", "text":
+		case "gzip":
+			msg.Mode = CompressedPackedForwardMode
+			packed, err = gunzip(packed, maxSize)
+			if err != nil {
+				return msg, err
+			}
+		default:
+			return msg, fmt.Errorf("%w: %s", ErrUnsupportedCompression, msg.Options.Compressed)
+		}
+		msg.Entries, err = decodePacked(packed)
+	}
+	return msg, err
+}
+
+// ReadOptions decodes only options of the message, which is enough to acknowledge it
+func ReadOptions(blob []byte) (Options, error) {
+	d := msgpack.NewDecoder(bytes.NewReader(blob))
+	n, err := d.DecodeArrayLen()
+	if err != nil {
+		return Options{}, err
+	}
+	if n < 3 || n > 4 {
+		return Options{}, nil
+	}
+	// skip tag
+	err = d.Skip()
+	if err != nil {
+		return Options{}, err
+	}
+	c, err := d.PeekCode()
+	if err != nil {
+		return Options{}, err
+	}
+	// options are fourth element in Message mode and third in the others
+	forward := msgpcode.IsFixedArray(c) || c == msgpcode.Array16 || c == msgpcode.Array32 || msgpcode.IsBin(c) || msgpcode.IsString(c)
+	if !forward && n == 3 {
+		return Options{}, nil
+	}
+	for i := 1; i < n-1; i++ {
+		err = d.Skip()
+		if err != nil {
+			return Options{}, err
+		}
+	}
+	return decodeOptions(d)
+}
+
+// Ack encodes response acknowledging chunk
+func Ack(chunk string) []byte {
+	blob, _ := msgpack.Marshal(map[string]string{"ack": chunk})
+	return blob
+}
+
+func decodeOptions(d *msgpack.Decoder) (Options, error) {
+	opts := Options{}
+	raw, err := d.DecodeMap()
+	if err != nil {
+		return opts, fmt.Errorf("options: %w", err)
+	}
+	if raw == nil {
+		return opts, nil
+	}
+	var ok bool
+	if chunk, present := raw["chunk"]; present {
+		opts.Chunk, ok = chunk.(string)
+		if !ok {
+			return opts, fmt.Errorf("options: chunk has to be string")
+		}
+	}
+	if compressed, present := raw["compressed"]; present {
+		opts.Compressed, ok = compressed.(string)
+		if !ok {
+			return opts, fmt.Errorf("options: compressed has to be string")
+		}
+	}
+	switch size := raw["size"].(type) {
+	case int8:
+		opts.Size = int(size)
+	case int16:
+		opts.Size = int(size)
+	case int32:
+		opts.Size = int(size)
+	case int64:
+		opts.Size = int(size)
+	case uint8:
+		opts.Size = int(size)
+	case uint16:
+		opts.Size = int(size)
+	case uint32:
+		opts.Size = int(size)
+	case uint64:
+		opts.Size = int(size)
+	}
+	return opts, nil
+}
+
+// decodeEntries decodes array of entries. Array length is not trusted for preallocation beyond size
+// of the message, which bounds the number of entries it may contain
+func decodeEntries(d *msgpack.Decoder, size int) ([]Entry, error) {
+	n, err := d.DecodeArrayLen()
+	if err != nil {
+		return nil, err
+	}
+	entries := make([]Entry, 0, min(n, size))
+	for i := 0; i < n; i++ {
+		entry, err := decodeEntryArray(d)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
+
+func decodePacked(blob []byte) ([]Entry, error) {
+	r := bytes.NewReader(blob)
+	d := msgpack.NewDecoder(r)
+	entries := []Entry{}
+	for r.Len() > 0 {
+		entry, err := decodeEntryArray(d)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
+
+// decodeEntryArray decodes [time, record] array
+func decodeEntryArray(d *msgpack.Decoder) (Entry, error) {
+	n, err := d.DecodeArrayLen()
+	if err != nil {
+		return Entry{}, err
+	}
+	if n != 2 {
+		return Entry{}, fmt.Errorf("unexpected entry array length %d", n)
+	}
+	return decodeEntry(d)
+}
+
+func decodeEntry(d *msgpack.Decoder) (Entry, error) {
+	t, err := decodeTime(d)
+	if err != nil {
+		return Entry{}, fmt.Errorf("time: %w", err)
+	}
+	record, err := d.DecodeMap()
+	if err != nil {
+		return Entry{}, fmt.Errorf("record: %w", err)
+	}
+	return Entry{Time: t, Record: record}, nil
+}
+
+// decodeTime decodes either integer seconds or EventTime with nanosecond precision
+func decodeTime(d *msgpack.Decoder) (time.Time, error) {
+	c, err := d.PeekCode()
+	if err != nil {
+		return time.Time{}, err
+	}
+	switch {
+	case msgpcode.IsExt(c):
+		id, n, err := d.DecodeExtHeader()
+		if err != nil {
+			return time.Time{}, err
+		}
+		if id != eventTimeExt || n != 8 {
+			return time.Time{}, fmt.Errorf("unexpected extension type %d of length %d", id, n)
+		}
+		buf := make([]byte, 8)
+		err = d.ReadFull(buf)
+		if err != nil {
+			return time.Time{}, err
+		}
+		return time.Unix(int64(binary.BigEndian.Uint32(buf[:4])), int64(binary.BigEndian.Uint32(buf[4:]))), nil
+	case c == msgpcode.Float || c == msgpcode.Double:
+		// not in the specification, but sent by some clients
+		f, err := d.DecodeFloat64()
+		if err != nil {
+			return time.Time{}, err
+		}
+		return time.Unix(0, int64(f*float64(time.Second))), nil
+	}
+	s, err := d.DecodeInt64()
+	if err != nil {
+		return time.Time{}, err
+	}
+	return time.Unix(s, 0), nil
+}
+
+// gunzip decompresses all gzip members in blob
+func gunzip(blob []byte, maxSize int64) ([]byte, error) {
+	gz, err := gzip.NewReader(bytes.NewReader(blob))
+	if err != nil {
+		return nil, err
+	}
+	defer gz.Close()
+	out, err := io.ReadAll(io.LimitReader(gz, maxSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(out)) > maxSize {
+		return nil, ErrTooLarge
+	}
+	return out, nil
+}
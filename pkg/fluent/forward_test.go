@@ -0,0 +1,122 @@
+package fluent
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+// eventTime encodes EventTime extension
+func eventTime(sec, nsec uint32) msgpack.RawMessage {
+	b := []byte{0xd7, eventTimeExt, 0, 0, 0, 0, 0, 0, 0, 0}
+	binary.BigEndian.PutUint32(b[2:], sec)
+	binary.BigEndian.PutUint32(b[6:], nsec)
+	return b
+}
+
+func pack(t *testing.T, values ...interface{}) []byte {
+	var buf bytes.Buffer
+	enc := msgpack.NewEncoder(&buf)
+	for _, v := range values {
+		require.NoError(t, enc.Encode(v))
+	}
+	return buf.Bytes()
+}
+
+func TestDecode(t *testing.T) {
+	record := map[string]interface{}{"log": "hello", "nested": map[string]interface{}{"a": int8(1)}}
+	entries := []Entry{
+		{Time: time.Unix(1700000000, 500), Record: record},
+		{Time: time.Unix(1700000001, 0), Record: map[string]interface{}{"log": "world"}},
+	}
+	packed := pack(t,
+		[]interface{}{eventTime(1700000000, 500), record},
+		[]interface{}{uint32(1700000001), map[string]interface{}{"log": "world"}},
+	)
+
+	t.Run("test modes", func(t *testing.T) {
+		var gz bytes.Buffer
+		for _, part := range [][]byte{packed[:len(packed)/2], packed[len(packed)/2:]} {
+			// multiple gzip members are allowed
+			zw := gzip.NewWriter(&gz)
+			_, err := zw.Write(part)
+			require.NoError(t, err)
+			require.NoError(t, zw.Close())
+		}
+
+		for _, tc := range []struct {
+			blob    []byte
+			mode    Mode
+			entries []Entry
+			opts    Options
+		}{
+			{pack(t, []interface{}{"app", eventTime(1700000000, 500), record}), MessageMode, entries[:1], Options{}},
+			{pack(t, []interface{}{"app", 1700000000.5, record, map[string]interface{}{"chunk": "c1"}}), MessageMode,
+				[]Entry{{Time: time.Unix(1700000000, 500000000), Record: record}}, Options{Chunk: "c1"}},
+			{pack(t, []interface{}{"app", []interface{}{
+				[]interface{}{eventTime(1700000000, 500), record},
+				[]interface{}{1700000001, map[string]interface{}{"log": "world"}},
+			}}), ForwardMode, entries, Options{}},
+			{pack(t, []interface{}{"app", packed, map[string]interface{}{"size": 2, "chunk": "c2"}}), PackedForwardMode,
+				entries, Options{Size: 2, Chunk: "c2"}},
+			{pack(t, []interface{}{"app", string(packed)}), PackedForwardMode, entries, Options{}},
+			{pack(t, []interface{}{"app", gz.Bytes(), map[string]interface{}{"compressed": "gzip", "chunk": "c3"}}),
+				CompressedPackedForwardMode, entries, Options{Chunk: "c3", Compressed: "gzip"}},
+		} {
+			msg, err := Decode(tc.blob, 1<<20)
+			require.NoError(t, err, tc.mode.String())
+			assert.Equal(t, Message{Mode: tc.mode, Tag: "app", Entries: tc.entries, Options: tc.opts}, msg, tc.mode.String())
+
+			opts, err := ReadOptions(tc.blob)
+			require.NoError(t, err)
+			assert.Equal(t, tc.opts.Chunk, opts.Chunk)
+		}
+
+		_, err := Decode(pack(t, []interface{}{"app", gz.Bytes(), map[string]interface{}{"compressed": "gzip"}}), 10)
+		assert.ErrorIs(t, err, ErrTooLarge)
+	})
+
+	t.Run("test invalid messages", func(t *testing.T) {
+		for _, blob := range [][]byte{
+			pack(t, "app"),
+			pack(t, []interface{}{"app"}),
+			pack(t, []interface{}{"app", 1}),
+			pack(t, []interface{}{1, 1, record}),
+			pack(t, []interface{}{"app", "x", record}),
+			pack(t, []interface{}{"app", 1, "record"}),
+			pack(t, []interface{}{"app", []interface{}{[]interface{}{1}}}),
+			pack(t, []interface{}{"app", []interface{}{}, map[string]interface{}{}, 1}),
+			pack(t, []interface{}{"app", packed[:5]}),
+			pack(t, []interface{}{"app", packed, map[string]interface{}{"compressed": "zstd"}}),
+			pack(t, []interface{}{"app", packed, map[string]interface{}{"compressed": "gzip"}}),
+			pack(t, []interface{}{"app", packed, map[string]interface{}{"chunk": 1}}),
+			pack(t, []interface{}{"app", msgpack.RawMessage{0xd7, 0x01, 0, 0, 0, 0, 0, 0, 0, 0}, record}),
+		} {
+			_, err := Decode(blob, 1<<20)
+			assert.Error(t, err, "%x", blob)
+		}
+	})
+
+	t.Run("test oversized array header", func(t *testing.T) {
+		// headers claim hundreds of millions of entries, which must not be preallocated
+		for _, blob := range [][]byte{
+			[]byte("\x93\xa10\xdd0000"),
+			{0x92, 0xa3, 'a', 'p', 'p', 0xdd, 0xff, 0xff, 0xff, 0xff, 0x92, 0x01, 0x80},
+		} {
+			_, err := Decode(blob, 1<<20)
+			assert.Error(t, err, "%x", blob)
+		}
+	})
+
+	t.Run("test ack", func(t *testing.T) {
+		var resp map[string]string
+		require.NoError(t, msgpack.Unmarshal(Ack("c1"), &resp))
+		assert.Equal(t, map[string]string{"ack": "c1"}, resp)
+	})
+}
@@ -0,0 +1,202 @@
+// Package framing splits byte streams into messages
+package framing
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+)
+
+// Framing modes
+const (
+	LengthLE64    = "length-le64"
+	LengthBE32    = "length-be32"
+	LengthBE64    = "length-be64"
+	Newline       = "newline"
+	Null          = "null"
+	Varint        = "varint"
+	OctetCounting = "octet-counting"
+	Syslog        = "syslog"
+	JournalExport = "journal-export"
+)
+
+// maximum number of digits of octet-counting MSG-LEN field
+const maxOctetCountDigits = 10
+
+// ErrInvalidFrame is returned when stream data do not conform to the framing
+var ErrInvalidFrame = errors.New("invalid frame")
+
+// Framer extracts first message from a stream buffer. It returns the message and the number of bytes
+// consumed from the buffer. If the buffer does not contain a whole frame yet, consumed is zero. Frames
+// which carry no message (for example empty lines) are consumed and reported with nil message
+type Framer func(buf []byte) (msg []byte, consumed int, err error)
+
+// names lists framing modes in order used in error messages
+var names = []string{LengthLE64, LengthBE32, LengthBE64, Newline, Null, Varint, OctetCounting, Syslog, JournalExport}
+
+var framers = map[string]Framer{
+	LengthLE64:    lengthPrefixed(8, func(b []byte) uint64 { return binary.LittleEndian.Uint64(b) }),
+	LengthBE32:    lengthPrefixed(4, func(b []byte) uint64 { return uint64(binary.BigEndian.Uint32(b)) }),
+	LengthBE64:    lengthPrefixed(8, binary.BigEndian.Uint64),
+	Newline:       newlineFrame,
+	Null:          nullFrame,
+	Varint:        varintFrame,
+	OctetCounting: octetCountingFrame,
+	Syslog:        syslogFrame,
+	JournalExport: journalExportFrame,
+}
+
+// Get returns framer of given framing mode
+func Get(name string) (Framer, bool) {
+	f, ok := framers[name]
+	return f, ok
+}
+
+// Names returns list of supported framing modes
+func Names() []string {
+	return append([]string{}, names...)
+}
+
+// Split writes all complete messages found in buf to w and returns number of bytes consumed.
+// On error, the number of bytes consumed by messages preceding the invalid frame is returned
+func (f Framer) Split(buf []byte, w func([]byte)) (int, error) {
+	pos := 0
+	for pos < len(buf) {
+		msg, consumed, err := f(buf[pos:])
+		if err != nil {
+			return pos, err
+		}
+		if consumed == 0 {
+			break
+		}
+		if msg != nil {
+			w(msg)
+		}
+		pos += consumed
+	}
+	return pos, nil
+}
+
+// frameBody returns message of given length following a header of headerLen bytes
+func frameBody(buf []byte, headerLen int, length uint64) ([]byte, int, error) {
+	if length > math.MaxInt32 {
+		return nil, 0, fmt.Errorf("%w: message length %d exceeds limit", ErrInvalidFrame, length)
+	}
+	end := headerLen + int(length)
+	if end > len(buf) {
+		return nil, 0, nil
+	}
+	return buf[headerLen:end], end, nil
+}
+
+func lengthPrefixed(size int, decode func([]byte) uint64) Framer {
+	return func(buf []byte) ([]byte, int, error) {
+		if len(buf) < size {
+			return nil, 0, nil
+		}
+		return frameBody(buf, size, decode(buf[:size]))
+	}
+}
+
+func newlineFrame(buf []byte) ([]byte, int, error) {
+	idx := bytes.IndexByte(buf, '\n')
+	if idx < 0 {
+		return nil, 0, nil
+	}
+	msg := bytes.TrimSuffix(buf[:idx], []byte("\r"))
+	if len(msg) == 0 {
+		return nil, idx + 1, nil
+	}
+	return msg, idx + 1, nil
+}
+
+// nullFrame splits messages terminated by null byte, as used by GELF over TCP
+func nullFrame(buf []byte) ([]byte, int, error) {
+	idx := bytes.IndexByte(buf, 0)
+	if idx < 0 {
+		return nil, 0, nil
+	}
+	if idx == 0 {
+		return nil, 1, nil
+	}
+	return buf[:idx], idx + 1, nil
+}
+
+func varintFrame(buf []byte) ([]byte, int, error) {
+	length, n := binary.Uvarint(buf)
+	if n == 0 {
+		return nil, 0, nil
+	}
+	if n < 0 {
+		return nil, 0, fmt.Errorf("%w: varint length overflow", ErrInvalidFrame)
+	}
+	return frameBody(buf, n, length)
+}
+
+// octetCountingFrame parses RFC 6587 octet-counting frames: MSG-LEN SP MSG
+func octetCountingFrame(buf []byte) ([]byte, int, error) {
+	var length uint64
+	for i, c := range buf {
+		switch {
+		case c == ' ' && i > 0:
+			return frameBody(buf, i+1, length)
+		case c >= '0' && c <= '9' && i < maxOctetCountDigits && !(i == 0 && c == '0'):
+			length = length*10 + uint64(c-'0')
+		default:
+			return nil, 0, fmt.Errorf("%w: unexpected character %q in octet count", ErrInvalidFrame, c)
+		}
+	}
+	return nil, 0, nil
+}
+
+// syslogFrame accepts both RFC 6587 framing methods. Octet-counted frames start with a digit,
+// while non-transparent frames start with '<' of syslog PRI and end with newline
+func syslogFrame(buf []byte) ([]byte, int, error) {
+	if len(buf) > 0 && buf[0] >= '1' && buf[0] <= '9' {
+		return octetCountingFrame(buf)
+	}
+	return newlineFrame(buf)
+}
+
+// journalExportFrame splits entries of systemd journal export format, which are terminated by empty line.
+// Binary fields are encoded as the field name on its own line followed by 64-bit little-endian length,
+// the data and a newline, so the data may contain empty lines. Entries are returned including
+// the newline terminating their last field
+func journalExportFrame(buf []byte) ([]byte, int, error) {
+	pos := 0
+	for pos < len(buf) {
+		if buf[pos] == '\n' {
+			if pos == 0 {
+				return nil, 1, nil
+			}
+			return buf[:pos], pos + 1, nil
+		}
+		idx := bytes.IndexByte(buf[pos:], '\n')
+		if idx < 0 {
+			return nil, 0, nil
+		}
+		line := buf[pos : pos+idx]
+		pos += idx + 1
+		if bytes.IndexByte(line, '=') >= 0 {
+			continue
+		}
+		if len(buf) < pos+8 {
+			return nil, 0, nil
+		}
+		length := binary.LittleEndian.Uint64(buf[pos:])
+		if length > math.MaxInt32 {
+			return nil, 0, fmt.Errorf("%w: field %q length %d exceeds limit", ErrInvalidFrame, line, length)
+		}
+		end := pos + 8 + int(length)
+		if len(buf) <= end {
+			return nil, 0, nil
+		}
+		if buf[end] != '\n' {
+			return nil, 0, fmt.Errorf("%w: binary field %q not terminated by newline", ErrInvalidFrame, line)
+		}
+		pos = end + 1
+	}
+	return nil, 0, nil
+}
@@ -0,0 +1,159 @@
+package framing
+
+import (
+	"encoding/binary"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type framingTestCase struct {
+	framing string
+	stream  []byte
+	// messages expected to be written and remainder of the stream left for the next read
+	messages []string
+	rest     string
+	invalid  bool
+}
+
+func be32(n int) []byte {
+	b := make([]byte, 4)
+	binary.BigEndian.PutUint32(b, uint32(n))
+	return b
+}
+
+func be64(n int) []byte {
+	b := make([]byte, 8)
+	binary.BigEndian.PutUint64(b, uint64(n))
+	return b
+}
+
+func le64(n int) []byte {
+	b := make([]byte, 8)
+	binary.LittleEndian.PutUint64(b, uint64(n))
+	return b
+}
+
+func join(parts ...[]byte) []byte {
+	out := []byte{}
+	for _, p := range parts {
+		out = append(out, p...)
+	}
+	return out
+}
+
+var framingCases = []framingTestCase{
+	{
+		framing:  LengthLE64,
+		stream:   join(le64(3), []byte("one"), le64(3), []byte("two"), le64(5), []byte("thr")),
+		messages: []string{"one", "two"},
+		rest:     string(join(le64(5), []byte("thr"))),
+	},
+	{
+		framing:  LengthBE32,
+		stream:   join(be32(3), []byte("one"), be32(5), []byte("three"), []byte{0, 0}),
+		messages: []string{"one", "three"},
+		rest:     "\x00\x00",
+	},
+	{
+		framing:  LengthBE64,
+		stream:   join(be64(5), []byte("three"), be64(3), []byte("two")),
+		messages: []string{"three", "two"},
+	},
+	{
+		framing:  LengthBE64,
+		stream:   join([]byte{0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff}, []byte("x")),
+		messages: []string{},
+		invalid:  true,
+	},
+	{
+		framing:  Newline,
+		stream:   []byte("<13>first line\r\n\nsecond line\nincomplete"),
+		messages: []string{"<13>first line", "second line"},
+		rest:     "incomplete",
+	},
+	{
+		framing:  Null,
+		stream:   []byte("{\"a\":1}\x00\x00{\"b\":2}\x00{\"c\""),
+		messages: []string{`{"a":1}`, `{"b":2}`},
+		rest:     `{"c"`,
+	},
+	{
+		framing:  Varint,
+		stream:   join([]byte{3}, []byte("one"), []byte{0xac, 0x02}, make([]byte, 300), []byte{0x80}),
+		messages: []string{"one", string(make([]byte, 300))},
+		rest:     "\x80",
+	},
+	{
+		framing:  Varint,
+		stream:   []byte{0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0x01},
+		messages: []string{},
+		invalid:  true,
+	},
+	{
+		framing:  OctetCounting,
+		stream:   []byte("11 <13>message6 <14>hi17 <1"),
+		messages: []string{"<13>message", "<14>hi"},
+		rest:     "17 <1",
+	},
+	{
+		framing:  OctetCounting,
+		stream:   []byte("<13>not octet counted\n"),
+		messages: []string{},
+		invalid:  true,
+	},
+	{
+		framing:  OctetCounting,
+		stream:   []byte("05 hello"),
+		messages: []string{},
+		invalid:  true,
+	},
+	{
+		framing:  Syslog,
+		stream:   []byte("<13>non-transparent\n11 <13>counted<14>mixed\n6 <1"),
+		messages: []string{"<13>non-transparent", "<13>counted", "<14>mixed"},
+		rest:     "6 <1",
+	},
+	{
+		framing:  JournalExport,
+		stream:   join([]byte("\n__CURSOR=s=1\nMESSAGE=one\n\nMESSAGE\n"), le64(6), []byte("a\n\nb=c\n_PID=1\n\n__CURSOR=s=3\nMESSAGE\n"), le64(3)),
+		messages: []string{"__CURSOR=s=1\nMESSAGE=one\n", string(join([]byte("MESSAGE\n"), le64(6), []byte("a\n\nb=c\n_PID=1\n")))},
+		rest:     string(join([]byte("__CURSOR=s=3\nMESSAGE\n"), le64(3))),
+	},
+	{
+		framing:  JournalExport,
+		stream:   join([]byte("MESSAGE\n"), le64(1), []byte("ab\n\n")),
+		messages: []string{},
+		invalid:  true,
+	},
+}
+
+func TestFraming(t *testing.T) {
+	t.Run("test stream framing", func(t *testing.T) {
+		for _, tc := range framingCases {
+			f, ok := Get(tc.framing)
+			require.True(t, ok, tc.framing)
+			received := []string{}
+			consumed, err := f.Split(tc.stream, func(msg []byte) {
+				received = append(received, string(msg))
+			})
+			assert.Equal(t, tc.messages, received, tc.framing)
+			if tc.invalid {
+				assert.ErrorIs(t, err, ErrInvalidFrame, tc.framing)
+				continue
+			}
+			require.NoError(t, err, tc.framing)
+			assert.Equal(t, tc.rest, string(tc.stream[consumed:]), tc.framing)
+		}
+	})
+}
+
+func TestNames(t *testing.T) {
+	for _, name := range Names() {
+		_, ok := Get(name)
+		assert.True(t, ok, name)
+	}
+	_, ok := Get("length-le16")
+	assert.False(t, ok)
+}
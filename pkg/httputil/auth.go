@@ -0,0 +1,54 @@
+// Package httputil holds helpers shared by plugins serving HTTP requests
+package httputil
+
+import (
+	"crypto/subtle"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// AuthConfig holds HTTP authentication settings shared by plugins that serve or send HTTP requests
+type AuthConfig struct {
+	Type     string `validate:"omitempty,oneof=none basic bearer"`
+	Username string
+	Password string
+	Token    string
+}
+
+// Validate checks that credentials required by the authentication type are set
+func (c *AuthConfig) Validate() error {
+	switch c.Type {
+	case "basic":
+		if c.Username == "" {
+			return fmt.Errorf("the auth.username configuration option is required when using basic authentication")
+		}
+	case "bearer":
+		if c.Token == "" {
+			return fmt.Errorf("the auth.token configuration option is required when using bearer authentication")
+		}
+	}
+	return nil
+}
+
+// Authorized checks credentials of received request. Requests are always authorized without authentication
+func (c *AuthConfig) Authorized(r *http.Request) bool {
+	switch c.Type {
+	case "basic":
+		user, pass, ok := r.BasicAuth()
+		return ok &&
+			subtle.ConstantTimeCompare([]byte(user), []byte(c.Username)) == 1 &&
+			subtle.ConstantTimeCompare([]byte(pass), []byte(c.Password)) == 1
+	case "bearer":
+		token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		return ok && subtle.ConstantTimeCompare([]byte(token), []byte(c.Token)) == 1
+	}
+	return true
+}
+
+// Challenge sets WWW-Authenticate header of response rejecting unauthorized request
+func (c *AuthConfig) Challenge(rw http.ResponseWriter) {
+	if c.Type == "basic" {
+		rw.Header().Set("WWW-Authenticate", `Basic realm="sg-core"`)
+	}
+}
@@ -0,0 +1,43 @@
+package httputil
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAuth(t *testing.T) {
+	request := func(header string) *http.Request {
+		r := httptest.NewRequest(http.MethodPost, "/", nil)
+		if header != "" {
+			r.Header.Set("Authorization", header)
+		}
+		return r
+	}
+
+	none := AuthConfig{Type: "none"}
+	assert.NoError(t, none.Validate())
+	assert.True(t, none.Authorized(request("")))
+
+	basic := AuthConfig{Type: "basic", Username: "sg", Password: "secret"}
+	assert.NoError(t, basic.Validate())
+	r := request("")
+	r.SetBasicAuth("sg", "secret")
+	assert.True(t, basic.Authorized(r))
+	r.SetBasicAuth("sg", "wrong")
+	assert.False(t, basic.Authorized(r))
+	rec := httptest.NewRecorder()
+	basic.Challenge(rec)
+	assert.Equal(t, `Basic realm="sg-core"`, rec.Header().Get("WWW-Authenticate"))
+
+	bearer := AuthConfig{Type: "bearer", Token: "abc"}
+	assert.NoError(t, bearer.Validate())
+	assert.True(t, bearer.Authorized(request("Bearer abc")))
+	assert.False(t, bearer.Authorized(request("Bearer abd")))
+	assert.False(t, bearer.Authorized(request("")))
+
+	assert.Error(t, (&AuthConfig{Type: "basic"}).Validate())
+	assert.Error(t, (&AuthConfig{Type: "bearer"}).Validate())
+}
@@ -0,0 +1,67 @@
+package httputil
+
+import (
+	"compress/gzip"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+)
+
+var (
+	// ErrUnsupportedEncoding is returned for request bodies with unknown content encoding
+	ErrUnsupportedEncoding = errors.New("unsupported content encoding")
+	// ErrBodyTooLarge is returned for request bodies exceeding the size limit
+	ErrBodyTooLarge = errors.New("request body too large")
+)
+
+// ReadBody reads request body, decompressing it according to content encoding. Size of the body after
+// decompression is limited to maxSize bytes
+func ReadBody(r *http.Request, maxSize int64) ([]byte, error) {
+	var body io.Reader = r.Body
+	switch strings.ToLower(r.Header.Get("Content-Encoding")) {
+	case "", "identity":
+	case "gzip", "x-gzip":
+		gz, err := gzip.NewReader(r.Body)
+		if err != nil {
+			return nil, err
+		}
+		defer gz.Close()
+		body = gz
+	default:
+		return nil, ErrUnsupportedEncoding
+	}
+	// limit decompressed size as well to avoid gzip bombs
+	blob, err := io.ReadAll(io.LimitReader(body, maxSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(blob)) > maxSize {
+		return nil, ErrBodyTooLarge
+	}
+	return blob, nil
+}
+
+// Limiter limits number of requests handled concurrently
+type Limiter chan struct{}
+
+// NewLimiter creates limiter allowing n concurrent requests
+func NewLimiter(n int) Limiter {
+	return make(Limiter, n)
+}
+
+// TryAcquire reserves a slot without waiting. Requests over the limit are to be rejected instead of
+// queued when handlers cannot keep up, so that clients retry with backoff
+func (l Limiter) TryAcquire() bool {
+	select {
+	case l <- struct{}{}:
+		return true
+	default:
+		return false
+	}
+}
+
+// Release frees slot reserved by TryAcquire
+func (l Limiter) Release() {
+	<-l
+}
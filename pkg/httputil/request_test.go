@@ -0,0 +1,53 @@
+package httputil
+
+import (
+	"bytes"
+	"compress/gzip"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReadBody(t *testing.T) {
+	request := func(body []byte, encoding string) *http.Request {
+		r := httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(body))
+		if encoding != "" {
+			r.Header.Set("Content-Encoding", encoding)
+		}
+		return r
+	}
+	var gz bytes.Buffer
+	w := gzip.NewWriter(&gz)
+	_, err := w.Write(bytes.Repeat([]byte("a"), 100))
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+
+	blob, err := ReadBody(request([]byte("abc"), ""), 3)
+	require.NoError(t, err)
+	assert.Equal(t, []byte("abc"), blob)
+
+	blob, err = ReadBody(request(gz.Bytes(), "GZIP"), 100)
+	require.NoError(t, err)
+	assert.Len(t, blob, 100)
+
+	// decompressed size is limited
+	_, err = ReadBody(request(gz.Bytes(), "gzip"), 99)
+	assert.ErrorIs(t, err, ErrBodyTooLarge)
+	_, err = ReadBody(request([]byte("abcd"), "identity"), 3)
+	assert.ErrorIs(t, err, ErrBodyTooLarge)
+	_, err = ReadBody(request([]byte("abc"), "br"), 3)
+	assert.ErrorIs(t, err, ErrUnsupportedEncoding)
+	_, err = ReadBody(request([]byte("abc"), "gzip"), 3)
+	assert.Error(t, err)
+}
+
+func TestLimiter(t *testing.T) {
+	l := NewLimiter(1)
+	assert.True(t, l.TryAcquire())
+	assert.False(t, l.TryAcquire())
+	l.Release()
+	assert.True(t, l.TryAcquire())
+}
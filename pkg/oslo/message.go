@@ -0,0 +1,111 @@
+// Package oslo decodes oslo.messaging notification envelopes shared by the ceilometer handlers
+package oslo
+
+import (
+	"bytes"
+	stdjson "encoding/json"
+	"errors"
+	"fmt"
+
+	jsoniter "github.com/json-iterator/go"
+)
+
+// Versions of the envelope
+const (
+	V1 = "1.0"
+	V2 = "2.0"
+)
+
+// maxEncodings limits how many times the oslo.message string may be encoded as JSON string
+const maxEncodings = 3
+
+var (
+	json = jsoniter.ConfigCompatibleWithStandardLibrary
+
+	// ErrUnsupportedVersion is returned for envelopes other than v1 and v2
+	ErrUnsupportedVersion = errors.New("unsupported oslo.messaging envelope version")
+	// ErrNotNotification is returned for JSON which is neither envelope nor notification
+	ErrNotNotification = errors.New("message is not oslo.messaging notification")
+)
+
+// envelope of v2 messages, optionally wrapped in request by the AMQP 1.0 driver
+type envelope struct {
+	Request stdjson.RawMessage `json:"request"`
+	Version *string            `json:"oslo.version"`
+	Message stdjson.RawMessage `json:"oslo.message"`
+}
+
+// Message is oslo.messaging notification. Payload is left encoded for the handlers
+type Message struct {
+	Version   string             `json:"-"`
+	MessageID string             `json:"message_id"`
+	Publisher string             `json:"publisher_id"`
+	EventType string             `json:"event_type"`
+	Priority  string             `json:"priority"`
+	Timestamp string             `json:"timestamp"`
+	Payload   stdjson.RawMessage `json:"payload"`
+}
+
+// Decode decodes notification from v2 envelope, which contains the notification serialized in
+// oslo.message string, or from v1 envelope, which is the notification itself
+func Decode(blob []byte) (*Message, error) {
+	env := envelope{}
+	err := json.Unmarshal(blob, &env)
+	if err != nil {
+		return nil, fmt.Errorf("invalid envelope: %w", err)
+	}
+	if env.Request != nil {
+		blob = env.Request
+		env = envelope{}
+		err = json.Unmarshal(blob, &env)
+		if err != nil {
+			return nil, fmt.Errorf("invalid envelope in request: %w", err)
+		}
+	}
+
+	msg := &Message{Version: V1}
+	if env.Message == nil {
+		if env.Version != nil {
+			return nil, fmt.Errorf("%w: missing oslo.message", ErrNotNotification)
+		}
+		err = json.Unmarshal(blob, msg)
+		if err != nil {
+			return nil, fmt.Errorf("invalid message: %w", err)
+		}
+		if msg.EventType == "" || msg.Payload == nil {
+			return nil, ErrNotNotification
+		}
+		return msg, nil
+	}
+
+	if env.Version == nil || *env.Version != V2 {
+		return nil, ErrUnsupportedVersion
+	}
+	raw := bytes.TrimSpace(env.Message)
+	// some publishers encode the serialized message once more
+	for i := 0; i < maxEncodings && len(raw) > 0 && raw[0] == '"'; i++ {
+		var s string
+		err = json.Unmarshal(raw, &s)
+		if err != nil {
+			return nil, fmt.Errorf("invalid oslo.message: %w", err)
+		}
+		raw = bytes.TrimSpace([]byte(s))
+	}
+	if len(raw) == 0 || raw[0] != '{' {
+		return nil, fmt.Errorf("%w: oslo.message is not an object", ErrNotNotification)
+	}
+	err = json.Unmarshal(raw, msg)
+	if err != nil {
+		return nil, fmt.Errorf("invalid oslo.message: %w", err)
+	}
+	msg.Version = V2
+	return msg, nil
+}
+
+// DecodePayload decodes payload of the message into v
+func (m *Message) DecodePayload(v interface{}) error {
+	if len(m.Payload) == 0 {
+		return fmt.Errorf("message %s has no payload", m.MessageID)
+	}
+	return json.Unmarshal(m.Payload, v)
+}
@@ -0,0 +1,138 @@
+package oslo
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDecodeCorpus(t *testing.T) {
+	for _, tc := range []struct {
+		file     string
+		expected Message
+		check    func(t *testing.T, payload interface{})
+	}{
+		{
+			file: "ceilometer-metering-amqp1.json",
+			expected: Message{
+				Version:   V2,
+				MessageID: "37f64423-db31-4cfb-8c9d-06f9c0fad04a",
+				Publisher: "telemetry.publisher.controller-0.redhat.local",
+				EventType: "metering",
+				Priority:  "SAMPLE",
+				Timestamp: "2020-09-14 16:12:50.364945",
+			},
+			check: func(t *testing.T, payload interface{}) {
+				samples := payload.([]interface{})
+				require.Len(t, samples, 2)
+				assert.Equal(t, "disk.root.size", samples[1].(map[string]interface{})["counter_name"])
+			},
+		},
+		{
+			file: "ceilometer-event-amqp1.json",
+			expected: Message{
+				Version:   V2,
+				MessageID: "4c9fbb58-c82d-4ca5-9f4c-2c61d0693214",
+				Publisher: "telemetry.publisher.controller-0.redhat.local",
+				EventType: "event",
+				Priority:  "SAMPLE",
+				Timestamp: "2020-03-06 14:13:30.057411",
+			},
+			check: func(t *testing.T, payload interface{}) {
+				events := payload.([]interface{})
+				require.Len(t, events, 1)
+				traits := events[0].(map[string]interface{})["traits"].([]interface{})
+				require.Len(t, traits, 7)
+				assert.Equal(t, []interface{}{"name", 1.0, `cirros "0.5.1" [x86_64]`}, traits[3])
+				assert.Equal(t, []interface{}{"description", 1.0, `Image für Tests: {"payload": [1, 2]}`}, traits[4])
+				assert.Equal(t, []interface{}{"size", 2.0, 13287936.0}, traits[6])
+			},
+		},
+		{
+			file: "nova-instance-update-rabbit.json",
+			expected: Message{
+				Version:   V2,
+				MessageID: "c5b2bcf6-1cd2-4d5f-9a3c-7f2e6d1c8b3a",
+				Publisher: "nova-compute:compute-1.redhat.local",
+				EventType: "instance.update",
+				Priority:  "INFO",
+				Timestamp: "2024-03-01 12:00:00.123456",
+			},
+			check: func(t *testing.T, payload interface{}) {
+				instance := payload.(map[string]interface{})["nova_object.data"].(map[string]interface{})
+				assert.Equal(t, `db "primary" [1]`, instance["display_name"])
+				assert.Equal(t, []interface{}{"tag1", "tag2"}, instance["tags"])
+			},
+		},
+		{
+			file: "cinder-volume-v1.json",
+			expected: Message{
+				Version:   V1,
+				MessageID: "a1d3f6f2-66b2-4c5e-8f1a-0b9e2c7d4e3f",
+				Publisher: "volume.controller-0",
+				EventType: "volume.create.end",
+				Priority:  "INFO",
+				Timestamp: "2024-03-01 12:00:01.000001",
+			},
+			check: func(t *testing.T, payload interface{}) {
+				assert.Equal(t, "vol-01", payload.(map[string]interface{})["display_name"])
+			},
+		},
+		{
+			file: "heat-stack-double-encoded.json",
+			expected: Message{
+				Version:   V2,
+				MessageID: "e0b5c8f2-4a1d-4d7e-b6a3-9c2f1e0d8b7a",
+				Publisher: "orchestration.controller-0",
+				EventType: "orchestration.stack.create.end",
+				Priority:  "INFO",
+				Timestamp: "2024-03-01 12:00:02.5",
+			},
+			check: func(t *testing.T, payload interface{}) {
+				assert.Equal(t, "CREATE_COMPLETE", payload.(map[string]interface{})["state"])
+			},
+		},
+	} {
+		t.Run(tc.file, func(t *testing.T) {
+			blob, err := os.ReadFile(filepath.Join("testdata", tc.file))
+			require.NoError(t, err)
+			msg, err := Decode(blob)
+			require.NoError(t, err)
+
+			var payload interface{}
+			require.NoError(t, msg.DecodePayload(&payload))
+			tc.check(t, payload)
+
+			msg.Payload = nil
+			assert.Equal(t, tc.expected, *msg)
+		})
+	}
+}
+
+func TestDecodeInvalid(t *testing.T) {
+	for blob, expected := range map[string]error{
+		`{"oslo.version":"1.0","oslo.message":"{}"}`:           ErrUnsupportedVersion,
+		`{"oslo.message":"{}"}`:                                ErrUnsupportedVersion,
+		`{"oslo.version":"2.0"}`:                               ErrNotNotification,
+		`{"oslo.version":"2.0","oslo.message":"[]"}`:           ErrNotNotification,
+		`{"oslo.version":"2.0","oslo.message":null}`:           ErrNotNotification,
+		`{"request":{"oslo.version":"2.0"},"context":{}}`:      ErrNotNotification,
+		`{"index":"backup_status","labels":{}}`:                ErrNotNotification,
+		`{"event_type":"volume.create.end"}`:                   ErrNotNotification,
+		`[{"labels":{"alertname":"x"},"annotations":{}}]`:      nil,
+		`{"oslo.version":"2.0","oslo.message":"{\"payload\""}`: nil,
+		`not json`: nil,
+	} {
+		_, err := Decode([]byte(blob))
+		require.Error(t, err, blob)
+		if expected != nil {
+			assert.ErrorIs(t, err, expected, blob)
+		}
+	}
+
+	msg := Message{MessageID: "x"}
+	assert.Error(t, msg.DecodePayload(&[]interface{}{}))
+}
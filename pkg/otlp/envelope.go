@@ -0,0 +1,59 @@
+// Package otlp defines how the otlp transport passes OpenTelemetry Protocol payloads to the otlp handler
+package otlp
+
+import (
+	"bytes"
+)
+
+// Signal is kind of telemetry carried by payload
+type Signal string
+
+// Supported signals
+const (
+	Metrics Signal = "metrics"
+	Logs    Signal = "logs"
+)
+
+// Encoding of payload
+type Encoding string
+
+// Supported encodings
+const (
+	Protobuf Encoding = "protobuf"
+	JSON     Encoding = "json"
+)
+
+// magic starts envelope header, which is a single line "otlp <signal> <encoding>"
+var magic = []byte("otlp ")
+
+// Wrap prepends header describing payload, because the signal of protobuf payloads cannot be
+// told from the payload itself
+func Wrap(signal Signal, encoding Encoding, payload []byte) []byte {
+	blob := make([]byte, 0, len(magic)+len(signal)+len(encoding)+2+len(payload))
+	blob = append(blob, magic...)
+	blob = append(blob, signal...)
+	blob = append(blob, ' ')
+	blob = append(blob, encoding...)
+	blob = append(blob, '\n')
+	return append(blob, payload...)
+}
+
+// Unwrap splits message created by Wrap. It returns false when message has no valid header
+func Unwrap(blob []byte) (Signal, Encoding, []byte, bool) {
+	if !bytes.HasPrefix(blob, magic) {
+		return "", "", nil, false
+	}
+	header, payload, found := bytes.Cut(blob[len(magic):], []byte("\n"))
+	if !found {
+		return "", "", nil, false
+	}
+	signal, encoding, found := bytes.Cut(header, []byte(" "))
+	if !found {
+		return "", "", nil, false
+	}
+	s, e := Signal(signal), Encoding(encoding)
+	if (s != Metrics && s != Logs) || (e != Protobuf && e != JSON) {
+		return "", "", nil, false
+	}
+	return s, e, payload, true
+}
@@ -0,0 +1,33 @@
+package otlp
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEnvelope(t *testing.T) {
+	payload := []byte{0x0a, '\n', 0x00, 'x'}
+	signal, encoding, unwrapped, ok := Unwrap(Wrap(Logs, Protobuf, payload))
+	assert.True(t, ok)
+	assert.Equal(t, Logs, signal)
+	assert.Equal(t, Protobuf, encoding)
+	assert.Equal(t, payload, unwrapped)
+
+	signal, encoding, unwrapped, ok = Unwrap(Wrap(Metrics, JSON, nil))
+	assert.True(t, ok)
+	assert.Equal(t, Metrics, signal)
+	assert.Equal(t, JSON, encoding)
+	assert.Empty(t, unwrapped)
+
+	for _, blob := range []string{
+		`{"resourceMetrics":[]}`,
+		"otlp metrics json",
+		"otlp metrics\n{}",
+		"otlp traces json\n{}",
+		"otlp metrics xml\n{}",
+	} {
+		_, _, _, ok := Unwrap([]byte(blob))
+		assert.False(t, ok, blob)
+	}
+}
@@ -3,6 +3,8 @@ package transport
 import (
 	"context"
 	"strings"
+
+	"github.com/openstack-k8s-operators/sg-core/pkg/bus"
 )
 
 // package transport defines the interfaces for interacting with transport
@@ -38,9 +40,30 @@ func (m *Mode) FromString(s string) {
 // WriteFn func type for writing from transport to handlers
 type WriteFn func([]byte)
 
+// Metadata describes origin of a message, for example authenticated identity of the peer which sent it
+type Metadata map[string]string
+
+// WriteWithMetadataFn func type for writing from transport to handlers together with message origin details
+type WriteWithMetadataFn func([]byte, Metadata)
+
 // Transport type listens on one interface and delivers data to core
 // TODO: listen for events internally
 type Transport interface {
 	Config([]byte) error
 	Run(context.Context, WriteFn, chan bool)
 }
+
+// MetadataTransport is implemented by transports able to provide details about message origin.
+// When implemented, RunWithMetadata is used instead of Run and non-empty metadata are attached
+// as labels to all metrics and events produced by handlers from the message
+type MetadataTransport interface {
+	Transport
+	RunWithMetadata(context.Context, WriteWithMetadataFn, chan bool)
+}
+
+// MetricPublisher is implemented by transports reporting internal metrics, such as number of received
+// or dropped messages. The publish function is set before Run is called
+type MetricPublisher interface {
+	Transport
+	SetMetricPublishFunc(bus.MetricPublishFunc)
+}
@@ -185,7 +185,7 @@ func (pc *PromCollector) UpdateMetrics(name string, time float64, typ data.Metri
 				Interval:  interval,
 				Value:     value,
 			},
-			description: prometheus.NewDesc(name, "", labelKeys, nil),
+			description: prometheus.NewDesc(name, data.MetricHelp(name), labelKeys, nil),
 			expiry: &metricExpiry{
 				delete: func() bool {
 					mp, ok := pc.mProc.Load(cacheKey)
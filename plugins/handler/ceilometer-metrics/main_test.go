@@ -116,6 +116,30 @@ func TestCeilometerIncomingMsgpack(t *testing.T) {
 	assert.Equal(t, expectedMsgpackMetric, metricsUT[0])
 }
 
+func TestCeilometerIncomingJSONEscapedValues(t *testing.T) {
+	plugin := New()
+	err := plugin.Config([]byte{})
+	if err != nil {
+		t.Errorf("failed configuring ceilometer handler plugin: %s", err.Error())
+	}
+
+	blob := []byte(`{"request":{"oslo.version":"2.0","oslo.message":"{\"message_id\":\"37f64423-db31-4cfb-8c9d-06f9c0fad04a\",` +
+		`\"publisher_id\":\"telemetry.publisher.controller-0.redhat.local\",\"event_type\":\"metering\",\"priority\":\"SAMPLE\",` +
+		`\"payload\":[{\"source\":\"openstack\",\"counter_name\":\"cpu\",\"counter_type\":\"cumulative\",\"counter_unit\":\"ns\",` +
+		`\"counter_volume\":512,\"resource_id\":\"d8bd99b6-6fd8-4c02-a2e3-efbf596df636\",\"timestamp\":\"2020-09-14T16:12:49.939250+00:00\",` +
+		`\"resource_metadata\":{\"host\":\"compute-0\",\"display_name\":\"db \\\"primary\\\" [1]\",\"name\":\"instance-0000001\"}}],` +
+		`\"timestamp\":\"2020-09-14 16:12:50.364945\"}"},"context":{}}`)
+
+	metricsUT = []data.Metric{}
+	err = plugin.Handle(blob, false, MetricReceive, EventReceive)
+	if err != nil {
+		t.Error(err)
+	}
+
+	assert.Equal(t, 1, len(metricsUT))
+	assert.Equal(t, `db "primary" [1]:instance-0000001`, metricsUT[0].LabelVals[len(metricsUT[0].LabelVals)-1])
+}
+
 func TestGenLabelsSizes(t *testing.T) {
 	t.Run("un-exhaustive labels", func(t *testing.T) {
 		// ensure slices are correct length when the parsed message does not contain all of the noncritical label fields
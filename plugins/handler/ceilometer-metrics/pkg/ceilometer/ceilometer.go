@@ -1,20 +1,10 @@
 package ceilometer
 
 import (
-	"fmt"
-	"regexp"
-	"strings"
-
-	jsoniter "github.com/json-iterator/go"
+	"github.com/openstack-k8s-operators/sg-core/pkg/oslo"
 	"github.com/vmihailenco/msgpack/v5"
 )
 
-var (
-	rexForPayload     = regexp.MustCompile(`\"payload\"\s*:\s*\[(.*)\]`)
-	rexForNestedQuote = regexp.MustCompile(`\\\"`)
-	json              = jsoniter.ConfigCompatibleWithStandardLibrary
-)
-
 // Metedata represents metadataof a metric from ceilometer
 type Flavor struct {
 	ID   string `json:"id" msgpack:"id"`
@@ -56,34 +46,22 @@ type Message struct {
 	Payload   []Metric `json:"payload"`
 }
 
-// OsloSchema initial OsloSchema
-type OsloSchema struct {
-	Request struct {
-		OsloMessage string `json:"oslo.message"`
-	}
-}
-
 // Ceilometer instance for parsing and handling ceilometer metric messages
-type Ceilometer struct {
-	schema OsloSchema
-}
+type Ceilometer struct{}
 
 // New Ceilometer constructor
 func New() *Ceilometer {
-	return &Ceilometer{
-		schema: OsloSchema{},
-	}
+	return &Ceilometer{}
 }
 
 // ParseInputJSON parse blob into list of metrics
 func (c *Ceilometer) ParseInputJSON(blob []byte) (*Message, error) {
-	msg := &Message{}
-	err := json.Unmarshal(blob, &c.schema)
+	osloMsg, err := oslo.Decode(blob)
 	if err != nil {
 		return nil, err
 	}
-	sanitized := c.sanitize()
-	err = json.Unmarshal([]byte(sanitized), &msg)
+	msg := &Message{Publisher: osloMsg.Publisher}
+	err = osloMsg.DecodePayload(&msg.Payload)
 	if err != nil {
 		return nil, err
 	}
@@ -105,15 +83,3 @@ func (c *Ceilometer) ParseInputMsgPack(blob []byte) (*Message, error) {
 	msg.Payload = append(msg.Payload, metric)
 	return msg, nil
 }
-
-// sanitize remove extraneous characters
-func (c *Ceilometer) sanitize() string {
-	sanitized := rexForNestedQuote.ReplaceAllString(c.schema.Request.OsloMessage, `"`)
-
-	item := rexForPayload.FindStringSubmatch(sanitized)
-	if len(item) == 2 {
-		sanitized = rexForPayload.ReplaceAllString(sanitized, fmt.Sprintf(`"payload": [%s]`, strings.Join(item[1:], ",")))
-	}
-
-	return sanitized
-}
@@ -0,0 +1,171 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path"
+	"testing"
+	"time"
+
+	"collectd.org/api"
+	"collectd.org/network"
+	"github.com/openstack-k8s-operators/sg-core/pkg/data"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var (
+	testTime = time.Unix(1700000000, 0)
+
+	testValueLists = []*api.ValueList{
+		{
+			Identifier: api.Identifier{Host: "compute-0", Plugin: "interface", PluginInstance: "eth0", Type: "if_octets"},
+			Time:       testTime,
+			Interval:   10 * time.Second,
+			Values:     []api.Value{api.Derive(1200), api.Derive(3400)},
+		},
+		{
+			Identifier: api.Identifier{Host: "compute-0", Plugin: "load", Type: "load"},
+			Time:       testTime,
+			Interval:   10 * time.Second,
+			Values:     []api.Value{api.Gauge(0.5), api.Gauge(0.25), api.Gauge(0.125)},
+		},
+		{
+			Identifier: api.Identifier{Host: "compute-0", Plugin: "memory", Type: "memory", TypeInstance: "free"},
+			Time:       testTime,
+			Interval:   10 * time.Second,
+			Values:     []api.Value{api.Gauge(2048)},
+		},
+	}
+
+	testTypesDB = `if_octets rx:DERIVE:0:U, tx:DERIVE:0:U
+load shortterm:GAUGE:0:5000, midterm:GAUGE:0:5000, longterm:GAUGE:0:5000
+memory value:GAUGE:0:281474976710656
+`
+)
+
+func binaryMetric(name string, mType data.MetricType, pluginInstance string, typeInstance string, value float64) data.Metric {
+	return data.Metric{
+		Name:      name,
+		Time:      float64(testTime.Unix()),
+		Type:      mType,
+		Interval:  10 * time.Second,
+		Value:     value,
+		LabelKeys: []string{"host", "plugin_instance", "type_instance"},
+		LabelVals: []string{"compute-0", pluginInstance, typeInstance},
+	}
+}
+
+// encodePacket encodes testValueLists, secure enables signing or encryption of the packet
+func encodePacket(t *testing.T, secure func(b *network.Buffer)) []byte {
+	buf := network.NewBuffer(0)
+	if secure != nil {
+		secure(buf)
+	}
+	for _, vl := range testValueLists {
+		require.NoError(t, buf.Write(context.Background(), vl))
+	}
+	packet, err := buf.Bytes()
+	require.NoError(t, err)
+	return packet
+}
+
+func binaryConfig(t *testing.T, extra string) []byte {
+	dir := t.TempDir()
+	typesDB := path.Join(dir, "types.db")
+	require.NoError(t, os.WriteFile(typesDB, []byte(testTypesDB), 0600))
+	authFile := path.Join(dir, "auth")
+	require.NoError(t, os.WriteFile(authFile, []byte("collectd: secret\n"), 0600))
+	return []byte("format: binary\ntypesDB: [" + typesDB + "]\nauthFile: " + authFile + "\n" + extra)
+}
+
+func TestBinaryParsing(t *testing.T) {
+	namedMetrics := []data.Metric{
+		binaryMetric("collectd_interface_if_octets_rx_total", data.COUNTER, "eth0", "base", 1200),
+		binaryMetric("collectd_interface_if_octets_tx_total", data.COUNTER, "eth0", "base", 3400),
+		binaryMetric("collectd_load_shortterm", data.GAUGE, "base", "base", 0.5),
+		binaryMetric("collectd_load_midterm", data.GAUGE, "base", "base", 0.25),
+		binaryMetric("collectd_load_longterm", data.GAUGE, "base", "base", 0.125),
+		binaryMetric("collectd_memory", data.GAUGE, "base", "free", 2048),
+	}
+
+	t.Run("test data source names from types.db", func(t *testing.T) {
+		metricHandler := New().(*collectdMetricsHandler)
+		require.NoError(t, metricHandler.Config(binaryConfig(t, "")))
+
+		metricsUT = []data.Metric{}
+		require.NoError(t, metricHandler.Handle(encodePacket(t, nil), false, MetricReceive, EventReceive))
+		assert.Equal(t, uint64(0), metricHandler.totalDecodeErrors)
+		assert.ElementsMatch(t, namedMetrics, metricsUT)
+	})
+
+	t.Run("test without types.db", func(t *testing.T) {
+		metricHandler := New().(*collectdMetricsHandler)
+		require.NoError(t, metricHandler.Config([]byte("format: binary")))
+
+		metricsUT = []data.Metric{}
+		require.NoError(t, metricHandler.Handle(encodePacket(t, nil), false, MetricReceive, EventReceive))
+		assert.ElementsMatch(t, []data.Metric{
+			binaryMetric("collectd_interface_if_octets_0_total", data.COUNTER, "eth0", "base", 1200),
+			binaryMetric("collectd_interface_if_octets_1_total", data.COUNTER, "eth0", "base", 3400),
+			binaryMetric("collectd_load_0", data.GAUGE, "base", "base", 0.5),
+			binaryMetric("collectd_load_1", data.GAUGE, "base", "base", 0.25),
+			binaryMetric("collectd_load_2", data.GAUGE, "base", "base", 0.125),
+			binaryMetric("collectd_memory", data.GAUGE, "base", "free", 2048),
+		}, metricsUT)
+	})
+
+	t.Run("test signed and encrypted packets", func(t *testing.T) {
+		metricHandler := New().(*collectdMetricsHandler)
+		require.NoError(t, metricHandler.Config(binaryConfig(t, "securityLevel: sign")))
+
+		// unsigned data are ignored
+		metricsUT = []data.Metric{}
+		require.NoError(t, metricHandler.Handle(encodePacket(t, nil), false, MetricReceive, EventReceive))
+		assert.Empty(t, metricsUT)
+
+		for _, secure := range []func(b *network.Buffer){
+			func(b *network.Buffer) { b.Sign("collectd", "secret") },
+			func(b *network.Buffer) { b.Encrypt("collectd", "secret") },
+		} {
+			metricsUT = []data.Metric{}
+			require.NoError(t, metricHandler.Handle(encodePacket(t, secure), false, MetricReceive, EventReceive))
+			assert.ElementsMatch(t, namedMetrics, metricsUT)
+		}
+		assert.Equal(t, uint64(0), metricHandler.totalDecodeErrors)
+
+		metricsUT = []data.Metric{}
+		events := []data.Event{}
+		require.NoError(t, metricHandler.Handle(encodePacket(t, func(b *network.Buffer) { b.Sign("collectd", "wrong") }), true, MetricReceive, func(e data.Event) {
+			events = append(events, e)
+		}))
+		assert.Empty(t, metricsUT)
+		assert.Equal(t, uint64(1), metricHandler.totalDecodeErrors)
+		require.Len(t, events, 1)
+		assert.Equal(t, data.ERROR, events[0].Type)
+	})
+
+	t.Run("test truncated packet", func(t *testing.T) {
+		metricHandler := New().(*collectdMetricsHandler)
+		require.NoError(t, metricHandler.Config(binaryConfig(t, "")))
+
+		packet := encodePacket(t, nil)
+		metricsUT = []data.Metric{}
+		require.NoError(t, metricHandler.Handle(packet[:len(packet)-3], false, MetricReceive, EventReceive))
+		assert.Equal(t, uint64(1), metricHandler.totalDecodeErrors)
+		// value lists preceding the damaged part are still published
+		assert.ElementsMatch(t, namedMetrics[:5], metricsUT)
+	})
+
+	t.Run("test invalid configuration", func(t *testing.T) {
+		for _, conf := range []string{
+			"format: protobuf",
+			"format: binary\nsecurityLevel: encrypt",
+			"format: binary\nsecurityLevel: paranoid\nauthFile: /etc/hosts",
+			"format: binary\nauthFile: /nonexistent/auth",
+			"format: binary\ntypesDB: [/nonexistent/types.db]",
+		} {
+			assert.Error(t, New().Config([]byte(conf)), conf)
+		}
+	})
+}
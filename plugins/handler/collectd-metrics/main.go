@@ -1,11 +1,17 @@
 package main
 
 import (
+	"bytes"
 	"context"
+	"fmt"
+	"os"
 	"time"
 
+	"collectd.org/api"
+	"collectd.org/network"
 	"github.com/go-openapi/errors"
 	"github.com/openstack-k8s-operators/sg-core/pkg/bus"
+	"github.com/openstack-k8s-operators/sg-core/pkg/config"
 	"github.com/openstack-k8s-operators/sg-core/pkg/data"
 	"github.com/openstack-k8s-operators/sg-core/pkg/handler"
 	"github.com/openstack-k8s-operators/sg-core/plugins/handler/collectd-metrics/pkg/collectd"
@@ -18,9 +24,28 @@ var (
 		"derive":   data.COUNTER,
 		"gauge":    data.GAUGE,
 	}
+	strToSecurityLevel map[string]network.SecurityLevel = map[string]network.SecurityLevel{
+		"none":    network.None,
+		"sign":    network.Sign,
+		"encrypt": network.Encrypt,
+	}
+)
+
+const (
+	formatJSON   = "json"
+	formatBinary = "binary"
 )
 
+type configT struct {
+	Format        string   `validate:"oneof=json binary"`
+	SecurityLevel string   `yaml:"securityLevel" validate:"oneof=none sign encrypt"` // minimal security level of binary packets
+	AuthFile      string   `yaml:"authFile"`                                         // usernames and passwords of signed and encrypted packets
+	TypesDB       []string `yaml:"typesDB"`                                          // data source names of binary packets are taken from types.db
+}
+
 type collectdMetricsHandler struct {
+	conf                  configT
+	parseOpts             network.ParseOpts
 	totalMetricsDecoded   uint64 // total number of collectd metrics decoded from messages
 	totalMessagesReceived uint64
 	totalDecodeErrors     uint64
@@ -68,7 +93,11 @@ func (c *collectdMetricsHandler) Handle(blob []byte, reportErrors bool, pf bus.M
 	var err error
 	var cdmetrics *[]collectd.Metric
 
-	cdmetrics, err = collectd.ParseInputByte(blob)
+	if c.conf.Format == formatBinary {
+		cdmetrics, err = collectd.ParseInputBinary(blob, c.parseOpts)
+	} else {
+		cdmetrics, err = collectd.ParseInputByte(blob)
+	}
 
 	if err != nil {
 		c.totalDecodeErrors++
@@ -87,7 +116,9 @@ func (c *collectdMetricsHandler) Handle(blob []byte, reportErrors bool, pf bus.M
 				},
 			})
 		}
-		return nil
+		if cdmetrics == nil {
+			return nil
+		}
 	}
 
 	for _, cdmetric := range *cdmetrics {
@@ -150,12 +181,57 @@ func (c *collectdMetricsHandler) writeMetrics(cdmetric collectd.Metric, pf bus.M
 	return nil
 }
 
-func (c *collectdMetricsHandler) Config(_ []byte) error {
+func (c *collectdMetricsHandler) Config(blob []byte) error {
+	c.conf = configT{
+		Format:        formatJSON,
+		SecurityLevel: "none",
+	}
+	err := config.ParseConfig(bytes.NewReader(blob), &c.conf)
+	if err != nil {
+		return err
+	}
+
+	c.parseOpts = network.ParseOpts{
+		SecurityLevel: strToSecurityLevel[c.conf.SecurityLevel],
+	}
+	if c.conf.AuthFile != "" {
+		if _, err := os.Stat(c.conf.AuthFile); err != nil {
+			return fmt.Errorf("failed to access auth file: %w", err)
+		}
+		c.parseOpts.PasswordLookup = network.NewAuthFile(c.conf.AuthFile)
+	} else if c.parseOpts.SecurityLevel != network.None {
+		return fmt.Errorf("authFile is required with securityLevel %s", c.conf.SecurityLevel)
+	}
+
+	for _, path := range c.conf.TypesDB {
+		db, err := loadTypesDB(path)
+		if err != nil {
+			return err
+		}
+		if c.parseOpts.TypesDB == nil {
+			c.parseOpts.TypesDB = db
+		} else {
+			c.parseOpts.TypesDB.Merge(db)
+		}
+	}
 	return nil
 }
 
 // helper functions
 
+func loadTypesDB(path string) (*api.TypesDB, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open types.db: %w", err)
+	}
+	defer f.Close()
+	db, err := api.NewTypesDB(f)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	return db, nil
+}
+
 func validateMetric(cdmetric *collectd.Metric) bool {
 	if cdmetric.Dsnames == nil ||
 		cdmetric.Dstypes == nil ||
@@ -191,5 +267,10 @@ func genMetricName(cdmetric *collectd.Metric, index int) (name string) {
 
 // New create new collectdMetricsHandler object
 func New() handler.Handler {
-	return &collectdMetricsHandler{}
+	return &collectdMetricsHandler{
+		conf: configT{
+			Format:        formatJSON,
+			SecurityLevel: "none",
+		},
+	}
 }
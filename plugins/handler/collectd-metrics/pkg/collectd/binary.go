@@ -0,0 +1,60 @@
+package collectd
+
+import (
+	"fmt"
+
+	"collectd.org/api"
+	"collectd.org/cdtime"
+	"collectd.org/network"
+)
+
+// ParseInputBinary parses packet in collectd's binary network protocol. Metrics decoded before
+// an error is encountered are returned together with the error
+func ParseInputBinary(packet []byte, opts network.ParseOpts) (*[]Metric, error) {
+	vls, err := network.Parse(packet, opts)
+	collect := make([]Metric, 0, len(vls))
+	for _, vl := range vls {
+		metric, merr := fromValueList(vl)
+		if merr != nil {
+			if err == nil {
+				err = merr
+			}
+			continue
+		}
+		collect = append(collect, metric)
+	}
+	return &collect, err
+}
+
+func fromValueList(vl *api.ValueList) (Metric, error) {
+	metric := Metric{
+		Values:         make([]float64, len(vl.Values)),
+		Dstypes:        make([]string, len(vl.Values)),
+		Dsnames:        make([]string, len(vl.Values)),
+		Time:           cdtime.New(vl.Time),
+		Interval:       vl.Interval.Seconds(),
+		Host:           vl.Host,
+		Plugin:         vl.Plugin,
+		PluginInstance: vl.PluginInstance,
+		Type:           vl.Type,
+		TypeInstance:   vl.TypeInstance,
+	}
+	if vl.DSNames != nil && len(vl.DSNames) != len(vl.Values) {
+		return metric, fmt.Errorf("%s: %d data source names for %d values", vl.Identifier, len(vl.DSNames), len(vl.Values))
+	}
+	for i, value := range vl.Values {
+		switch v := value.(type) {
+		case api.Gauge:
+			metric.Values[i] = float64(v)
+		case api.Derive:
+			metric.Values[i] = float64(v)
+		case api.Counter:
+			metric.Values[i] = float64(v)
+		default:
+			return metric, fmt.Errorf("%s: unsupported value type %T", vl.Identifier, value)
+		}
+		metric.Dstypes[i] = value.Type()
+		metric.Dsnames[i] = vl.DSName(i)
+	}
+	return metric, nil
+}
@@ -2,20 +2,15 @@ package ceilometer
 
 import (
 	"fmt"
-	"regexp"
 	"strings"
 
 	"github.com/openstack-k8s-operators/sg-core/pkg/bus"
 	"github.com/openstack-k8s-operators/sg-core/pkg/data"
+	"github.com/openstack-k8s-operators/sg-core/pkg/oslo"
 	"github.com/openstack-k8s-operators/sg-core/plugins/handler/events/pkg/lib"
-	jsoniter "github.com/json-iterator/go"
 )
 
 var (
-	// Regular expression for sanitizing received data
-	rexForNestedQuote = regexp.MustCompile(`\\\"`)
-	// json parser
-	json = jsoniter.ConfigCompatibleWithStandardLibrary
 	// severity converter. A DNE returns data.INFO
 	ceilometerAlertSeverity = map[string]data.EventSeverity{
 		"audit":    data.INFO,
@@ -40,20 +35,6 @@ const (
 	source        = "ceilometer"
 )
 
-type osloRequest struct {
-	OsloVersion string `json:"oslo.version"`
-	OsloMessage string `json:"oslo.message"`
-}
-
-type rawMessage struct {
-	Request osloRequest
-}
-
-func (rm *rawMessage) sanitizeMessage() {
-	// sets oslomessage to cleaned state
-	rm.Request.OsloMessage = rexForNestedQuote.ReplaceAllLiteralString(rm.Request.OsloMessage, `"`)
-}
-
 type osloPayload struct {
 	MessageID string `json:"message_id"`
 	EventType string `json:"event_type"`
@@ -87,17 +68,13 @@ func (op *osloPayload) traitsFormatted() (map[string]interface{}, error) {
 }
 
 type osloMessage struct {
-	EventType   string `json:"event_type"`
-	PublisherID string `json:"publisher_id"`
+	EventType   string
+	PublisherID string
 	Timestamp   string
 	Priority    string
 	Payload     []osloPayload
 }
 
-func (om *osloMessage) fromBytes(blob []byte) error {
-	return json.Unmarshal(blob, om)
-}
-
 // Ceilometer holds parsed ceilometer event data and provides methods for retrieving that data
 // in a standardizes format
 type Ceilometer struct {
@@ -106,15 +83,18 @@ type Ceilometer struct {
 
 // Parse parse ceilometer message data
 func (c *Ceilometer) Parse(blob []byte) error {
-	rm := rawMessage{}
-	err := json.Unmarshal(blob, &rm)
+	msg, err := oslo.Decode(blob)
 	if err != nil {
 		return err
 	}
-	rm.sanitizeMessage()
 
-	c.osloMessage = osloMessage{}
-	return c.osloMessage.fromBytes([]byte(rm.Request.OsloMessage))
+	c.osloMessage = osloMessage{
+		EventType:   msg.EventType,
+		PublisherID: msg.Publisher,
+		Timestamp:   msg.Timestamp,
+		Priority:    msg.Priority,
+	}
+	return msg.DecodePayload(&c.osloMessage.Payload)
 }
 
 func (c *Ceilometer) name(index int) string {
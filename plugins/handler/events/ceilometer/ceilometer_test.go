@@ -1,7 +1,6 @@
 package ceilometer
 
 import (
-	jsontest "encoding/json"
 	"testing"
 
 	"github.com/openstack-k8s-operators/sg-core/pkg/data"
@@ -11,7 +10,6 @@ import (
 
 type parsingTestCase struct {
 	EventBlob []byte
-	RawMsg    rawMessage
 	Parsed    Ceilometer
 	Name      string
 	Timestamp float64
@@ -31,19 +29,6 @@ var parsingCases = []parsingTestCase{
 			`[\"created_at\",4,\"2020-03-06T14:01:07\"],[\"deleted_at\",4,\"2020-03-06T14:13:29\"],[\"size\",2,13287936]],\"raw\":{},` +
 			`\"message_signature\":\"77e798b842991f9c0c35bda265fdf86075b4a1e58309db1d2adbf89386a3859e\"}],` +
 			`\"timestamp\":\"2020-03-06 14:13:30.057411\"}"},"context": {}}`),
-		RawMsg: rawMessage{
-			Request: osloRequest{
-				OsloVersion: "2.0",
-				OsloMessage: "{\"message_id\":\"4c9fbb58-c82d-4ca5-9f4c-2c61d0693214\",\"publisher_id\":\"telemetry.publisher.controller-0.redhat.local\"," +
-					"\"event_type\":\"event\",\"priority\":\"SAMPLE\",\"payload\":[{\"message_id\":\"084c0bca-0d19-40c0-a724-9916e4815845\"," +
-					"\"event_type\":\"image.delete\",\"generated\":\"2020-03-06T14:13:29.497096\",\"traits\":[[\"service\",1,\"image.localhost\"]," +
-					"[\"project_id\",1,\"0f500647077b47f08a8ca9181e9b7aef\"],[\"user_id\",1,\"0f500647077b47f08a8ca9181e9b7aef\"]," +
-					"[\"resource_id\",1,\"c4f7e00b-df85-4b77-9e1a-26a1de4d5735\"],[\"name\",1,\"cirros\"],[\"status\",1,\"deleted\"]," +
-					"[\"created_at\",4,\"2020-03-06T14:01:07\"],[\"deleted_at\",4,\"2020-03-06T14:13:29\"],[\"size\",2,13287936]]," +
-					"\"raw\":{},\"message_signature\":\"77e798b842991f9c0c35bda265fdf86075b4a1e58309db1d2adbf89386a3859e\"}]," +
-					"\"timestamp\":\"2020-03-06 14:13:30.057411\"}",
-			},
-		},
 		Parsed: Ceilometer{
 			osloMessage: osloMessage{
 				EventType:   "event",
@@ -116,19 +101,6 @@ var parsingCases = []parsingTestCase{
 			`[\"created_at\",4,\"2020-03-06T14:01:07\"],[\"deleted_at\",4,\"2020-03-06T14:13:29\"],[\"size\",2,13287936]],\"raw\":{},` +
 			`\"message_signature\":\"77e798b842991f9c0c35bda265fdf86075b4a1e58309db1d2adbf89386a3859e\"}],` +
 			`\"timestamp\":\"2020-03-06 14:13:30.057411\"}"},"context": {}}`),
-		RawMsg: rawMessage{
-			Request: osloRequest{
-				OsloVersion: "2.0",
-				OsloMessage: "{\"message_id\":\"4c9fbb58-c82d-4ca5-9f4c-2c61d0693214\",\"publisher_id\":\"telemetry.publisher\"," +
-					"\"event_type\":\"wubba\",\"priority\":\"SAMPLE\",\"payload\":[{\"message_id\":\"084c0bca-0d19-40c0-a724-9916e4815845\"," +
-					"\"traits\":[[\"service\",1,\"image.localhost\"]," +
-					"[\"project_id\",1,\"0f500647077b47f08a8ca9181e9b7aef\"],[\"user_id\",1,\"0f500647077b47f08a8ca9181e9b7aef\"]," +
-					"[\"resource_id\",1,\"c4f7e00b-df85-4b77-9e1a-26a1de4d5735\"],[\"name\",1,\"cirros\"],[\"status\",1,\"deleted\"]," +
-					"[\"created_at\",4,\"2020-03-06T14:01:07\"],[\"deleted_at\",4,\"2020-03-06T14:13:29\"],[\"size\",2,13287936]]," +
-					"\"raw\":{},\"message_signature\":\"77e798b842991f9c0c35bda265fdf86075b4a1e58309db1d2adbf89386a3859e\"}]," +
-					"\"timestamp\":\"2020-03-06 14:13:30.057411\"}",
-			},
-		},
 		Parsed: Ceilometer{
 			osloMessage: osloMessage{
 				EventType:   "wubba",
@@ -199,28 +171,14 @@ var parsingCases = []parsingTestCase{
 			`[\"resource_id\",1,\"c4f7e00b-df85-4b77-9e1a-26a1de4d5735\"],[\"name\",1,\"cirros\"],[\"status\",1,\"deleted\"],` +
 			`[\"created_at\",4,\"2020-03-06T14:01:07\"],[\"deleted_at\",4,\"2020-03-06T14:13:29\"],[\"size\",2,13287936]],\"raw\":{},` +
 			`\"message_signature\":\"77e798b842991f9c0c35bda265fdf86075b4a1e58309db1d2adbf89386a3859e\"}]}"},"context": {}}`),
-		RawMsg: rawMessage{
-			Request: osloRequest{
-				OsloVersion: "2.0",
-				OsloMessage: "{\"message_id\":\"4c9fbb58-c82d-4ca5-9f4c-2c61d0693214\",\"publisher_id\":\"telemetry.publisher\"," +
-					"\"priority\":\"SAMPLE\",\"payload\":[{\"message_id\":\"084c0bca-0d19-40c0-a724-9916e4815845\"," +
-					"\"traits\":[[\"service\",1,\"image.localhost\"]," +
-					"[\"project_id\",1,\"0f500647077b47f08a8ca9181e9b7aef\"],[\"user_id\",1,\"0f500647077b47f08a8ca9181e9b7aef\"]," +
-					"[\"resource_id\",1,\"c4f7e00b-df85-4b77-9e1a-26a1de4d5735\"],[\"name\",1,\"cirros\"],[\"status\",1,\"deleted\"]," +
-					"[\"created_at\",4,\"2020-03-06T14:01:07\"],[\"deleted_at\",4,\"2020-03-06T14:13:29\"],[\"size\",2,13287936]]," +
-					"\"raw\":{},\"message_signature\":\"77e798b842991f9c0c35bda265fdf86075b4a1e58309db1d2adbf89386a3859e\"}]}",
-			},
-		},
 		Parsed: Ceilometer{
 			osloMessage: osloMessage{
 				EventType:   "",
 				PublisherID: "telemetry.publisher",
-				Timestamp:   "2020-03-06 14:13:30.057411",
 				Priority:    "SAMPLE",
 				Payload: []osloPayload{
 					{
 						MessageID: "084c0bca-0d19-40c0-a724-9916e4815845",
-						Generated: "2020-03-06T14:13:29.497096",
 						Traits: []interface{}{
 							[]interface{}{"service", float64(1), "image.localhost"},
 							[]interface{}{"project_id", float64(1), "0f500647077b47f08a8ca9181e9b7aef"},
@@ -277,16 +235,11 @@ var parsingCases = []parsingTestCase{
 func TestCeilometerEvents(t *testing.T) {
 	t.Run("Test correct event parsing.", func(t *testing.T) {
 		for _, testCase := range parsingCases {
-			// test sanitizing
-			rm := rawMessage{}
-			err := jsontest.Unmarshal(testCase.EventBlob, &rm)
-			require.NoError(t, err)
-			rm.sanitizeMessage()
-			assert.Equal(t, testCase.RawMsg, rm)
 			// test parsing
 			ceilo := Ceilometer{}
-			err = ceilo.Parse(testCase.EventBlob)
+			err := ceilo.Parse(testCase.EventBlob)
 			require.NoError(t, err)
+			assert.Equal(t, testCase.Parsed, ceilo)
 			// test name
 			assert.Equal(t, testCase.Name, ceilo.name(0))
 			// test traits
@@ -305,3 +258,21 @@ func TestCeilometerEvents(t *testing.T) {
 	})
 
 }
+
+func TestCeilometerEventsEscapedValues(t *testing.T) {
+	blob := []byte(`{"request":{"oslo.version":"2.0","oslo.message":` +
+		`"{\"message_id\":\"4c9fbb58-c82d-4ca5-9f4c-2c61d0693214\",\"publisher_id\":\"telemetry.publisher\",` +
+		`\"event_type\":\"event\",\"priority\":\"SAMPLE\",\"payload\":[{\"message_id\":\"084c0bca-0d19-40c0-a724-9916e4815845\",` +
+		`\"event_type\":\"image.update\",\"generated\":\"2020-03-06T14:13:29.497096\",\"traits\":[[\"name\",1,\"cirros \\\"0.5.1\\\" [x86_64]\"],` +
+		`[\"description\",1,\"{\\\"payload\\\": [1, 2]}\"]],\"raw\":{}}],\"timestamp\":\"2020-03-06 14:13:30.057411\"}"},"context": {}}`)
+
+	ceilo := Ceilometer{}
+	require.NoError(t, ceilo.Parse(blob))
+	traits, err := ceilo.traits(0)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{
+		"name":        `cirros "0.5.1" [x86_64]`,
+		"description": `{"payload": [1, 2]}`,
+	}, traits)
+	assert.Equal(t, "ceilometer_image", ceilo.name(0))
+}
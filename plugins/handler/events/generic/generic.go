@@ -0,0 +1,161 @@
+package generic
+
+import (
+	"bytes"
+	"strings"
+	"time"
+
+	"github.com/infrawatch/apputils/misc"
+	jsoniter "github.com/json-iterator/go"
+	"github.com/openstack-k8s-operators/sg-core/pkg/bus"
+	"github.com/openstack-k8s-operators/sg-core/pkg/data"
+	"github.com/openstack-k8s-operators/sg-core/plugins/handler/events/pkg/lib"
+	"github.com/pkg/errors"
+)
+
+// generic contains objects for handling events in SG generic event format
+
+var (
+	json = jsoniter.Config{
+		EscapeHTML:             true,
+		SortMapKeys:            true,
+		ValidateJsonRawMessage: true,
+		DisallowUnknownFields:  true,
+	}.Froze()
+
+	eventTypes = map[string]data.EventType{
+		"event":  data.EVENT,
+		"log":    data.LOG,
+		"result": data.RESULT,
+		"task":   data.TASK,
+	}
+
+	eventSeverities = map[string]data.EventSeverity{
+		"unknown":  data.UNKNOWN,
+		"debug":    data.DEBUG,
+		"info":     data.INFO,
+		"warning":  data.WARNING,
+		"critical": data.CRITICAL,
+	}
+
+	// time of receiving is used for events without time
+	now = time.Now
+)
+
+const source string = "generic"
+
+type eventMessage struct {
+	Index       *string                `json:"index"`
+	Type        string                 `json:"type"`
+	Severity    string                 `json:"severity"`
+	Publisher   string                 `json:"publisher"`
+	Time        interface{}            `json:"time"`
+	Labels      map[string]interface{} `json:"labels"`
+	Annotations map[string]interface{} `json:"annotations"`
+	Message     string                 `json:"message"`
+}
+
+// Generic type for handling event messages in SG generic event format
+type Generic struct {
+	events []data.Event
+}
+
+// PublishEvents write events to publish func
+func (g *Generic) PublishEvents(epf bus.EventPublishFunc) {
+	for _, e := range g.events {
+		epf(e)
+	}
+}
+
+// Parse parse and validate event message. Message is either single event object or array of them
+func (g *Generic) Parse(blob []byte) error {
+	messages := []eventMessage{}
+	trimmed := bytes.TrimSpace(blob)
+	if len(trimmed) > 0 && trimmed[0] == '{' {
+		messages = append(messages, eventMessage{})
+		err := json.Unmarshal(trimmed, &messages[0])
+		if err != nil {
+			return errors.Wrapf(err, "could not parse message: << %s >>", string(blob))
+		}
+	} else {
+		err := json.Unmarshal(trimmed, &messages)
+		if err != nil {
+			return errors.Wrapf(err, "could not parse message: << %s >>", string(blob))
+		}
+	}
+	if len(messages) == 0 {
+		return errors.Errorf("message contains no events: << %s >>", string(blob))
+	}
+
+	events := make([]data.Event, 0, len(messages))
+	for i, msg := range messages {
+		event, err := msg.toEvent()
+		if err != nil {
+			return errors.Wrapf(err, "invalid event %d", i)
+		}
+		events = append(events, event)
+	}
+	g.events = append(g.events, events...)
+	return nil
+}
+
+func (msg *eventMessage) toEvent() (data.Event, error) {
+	if msg.Index == nil || *msg.Index == "" {
+		return data.Event{}, errors.New("missing index")
+	}
+
+	eType := data.EVENT
+	if msg.Type != "" {
+		var ok bool
+		if eType, ok = eventTypes[strings.ToLower(msg.Type)]; !ok {
+			return data.Event{}, errors.Errorf("unknown type '%s'", msg.Type)
+		}
+	}
+
+	eSeverity := data.UNKNOWN
+	if msg.Severity != "" {
+		var ok bool
+		if eSeverity, ok = eventSeverities[strings.ToLower(msg.Severity)]; !ok {
+			return data.Event{}, errors.Errorf("unknown severity '%s'", msg.Severity)
+		}
+	}
+
+	publisher := msg.Publisher
+	if publisher == "" {
+		publisher = "unknown"
+	}
+
+	var eTime float64
+	switch t := msg.Time.(type) {
+	case nil:
+		eTime = float64(now().Unix())
+	case float64:
+		eTime = t
+	case string:
+		eTime = float64(lib.EpochFromFormat(t))
+		if eTime == 0 {
+			return data.Event{}, errors.Errorf("invalid time '%s'", t)
+		}
+	default:
+		return data.Event{}, errors.Errorf("invalid time '%v'", t)
+	}
+
+	labels := msg.Labels
+	if labels == nil {
+		labels = map[string]interface{}{}
+	}
+
+	return data.Event{
+		Index:     *msg.Index,
+		Type:      eType,
+		Severity:  eSeverity,
+		Publisher: publisher,
+		Time:      eTime,
+		Labels:    labels,
+		Annotations: misc.MergeMaps(msg.Annotations, map[string]interface{}{
+			"source_type":  source,
+			"processed_by": "sg",
+		}),
+		Message: msg.Message,
+	}, nil
+}
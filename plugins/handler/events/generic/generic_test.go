@@ -0,0 +1,107 @@
+package generic
+
+import (
+	"testing"
+	"time"
+
+	"github.com/openstack-k8s-operators/sg-core/pkg/data"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type parsingTestCase struct {
+	EventBlob []byte
+	Events    []data.Event
+}
+
+var parsingCases = []parsingTestCase{
+	{
+		EventBlob: []byte(`{"index":"backup_status","type":"result","severity":"Warning","publisher":"controller-0",` +
+			`"time":1709294400.5,"labels":{"job":"db-backup","attempt":2},"annotations":{"summary":"backup took too long"},` +
+			`"message":"backup finished after 2 attempts"}`),
+		Events: []data.Event{
+			{
+				Index:     "backup_status",
+				Time:      1709294400.5,
+				Type:      data.RESULT,
+				Publisher: "controller-0",
+				Severity:  data.WARNING,
+				Labels: map[string]interface{}{
+					"job":     "db-backup",
+					"attempt": float64(2),
+				},
+				Annotations: map[string]interface{}{
+					"summary":      "backup took too long",
+					"processed_by": "sg",
+					"source_type":  "generic",
+				},
+				Message: "backup finished after 2 attempts",
+			},
+		},
+	},
+	{
+		EventBlob: []byte(` [{"index":"deploy","time":"2024-03-01T12:00:00Z"},{"index":"deploy","severity":"critical","type":"log"}]`),
+		Events: []data.Event{
+			{
+				Index:       "deploy",
+				Time:        1709294400,
+				Type:        data.EVENT,
+				Publisher:   "unknown",
+				Severity:    data.UNKNOWN,
+				Labels:      map[string]interface{}{},
+				Annotations: map[string]interface{}{"processed_by": "sg", "source_type": "generic"},
+			},
+			{
+				Index:       "deploy",
+				Time:        1709294460,
+				Type:        data.LOG,
+				Publisher:   "unknown",
+				Severity:    data.CRITICAL,
+				Labels:      map[string]interface{}{},
+				Annotations: map[string]interface{}{"processed_by": "sg", "source_type": "generic"},
+			},
+		},
+	},
+}
+
+func TestGenericEvents(t *testing.T) {
+	now = func() time.Time { return time.Unix(1709294460, 0) }
+	defer func() { now = time.Now }()
+
+	t.Run("Test correct event parsing.", func(t *testing.T) {
+		for _, testCase := range parsingCases {
+			gnrc := Generic{}
+			err := gnrc.Parse(testCase.EventBlob)
+			require.NoError(t, err)
+			events := []data.Event{}
+			gnrc.PublishEvents(func(evt data.Event) {
+				events = append(events, evt)
+			})
+			assert.Equal(t, testCase.Events, events)
+		}
+	})
+
+	t.Run("Test invalid events.", func(t *testing.T) {
+		for _, blob := range []string{
+			``,
+			`not json`,
+			`[]`,
+			`{"labels":{}}`,
+			`{"index":""}`,
+			`{"index":"a","type":"error"}`,
+			`{"index":"a","severity":"major"}`,
+			`{"index":"a","time":"yesterday"}`,
+			`{"index":"a","time":true}`,
+			`{"index":"a","labels":[]}`,
+			`{"index":"a","unexpected":1}`,
+			`[{"index":"a"},{"type":"event"}]`,
+		} {
+			gnrc := Generic{}
+			err := gnrc.Parse([]byte(blob))
+			assert.Error(t, err, blob)
+			gnrc.PublishEvents(func(evt data.Event) {
+				t.Errorf("unexpected event from invalid message %s", blob)
+			})
+		}
+	})
+}
@@ -4,6 +4,7 @@ import (
 	"github.com/openstack-k8s-operators/sg-core/pkg/bus"
 	"github.com/openstack-k8s-operators/sg-core/plugins/handler/events/ceilometer"
 	"github.com/openstack-k8s-operators/sg-core/plugins/handler/events/collectd"
+	"github.com/openstack-k8s-operators/sg-core/plugins/handler/events/generic"
 )
 
 func ceilometerEventHandler(blob []byte, epf bus.EventPublishFunc) error {
@@ -27,8 +28,20 @@ func collectdEventHandler(blob []byte, epf bus.EventPublishFunc) error {
 	return nil
 }
 
+func genericEventHandler(blob []byte, epf bus.EventPublishFunc) error {
+	gnrc := generic.Generic{}
+	err := gnrc.Parse(blob)
+	if err != nil {
+		return err
+	}
+
+	gnrc.PublishEvents(epf)
+	return nil
+}
+
 // EventHandlers handle messages according to the expected data source and write parsed events to the events bus
 var EventHandlers = map[string]func([]byte, bus.EventPublishFunc) error{
 	"ceilometer": ceilometerEventHandler,
 	"collectd":   collectdEventHandler,
+	"generic":    genericEventHandler,
 }
@@ -36,7 +36,12 @@ func (eh *EventsHandler) Handle(msg []byte, reportErrors bool, _ bus.MetricPubli
 	}
 	eh.eventsReceived[source.String()]++
 
-	err := handlers.EventHandlers[source.String()](msg, sendEvent)
+	var err error
+	if handle, ok := handlers.EventHandlers[source.String()]; ok {
+		err = handle(msg, sendEvent)
+	} else {
+		err = fmt.Errorf("no handler for event source %s", source)
+	}
 	if err != nil {
 		if reportErrors {
 			sendEvent(data.Event{
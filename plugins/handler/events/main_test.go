@@ -0,0 +1,46 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/openstack-k8s-operators/sg-core/pkg/data"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEventsHandler(t *testing.T) {
+	events := []data.Event{}
+	epf := func(e data.Event) { events = append(events, e) }
+
+	t.Run("test generic event", func(t *testing.T) {
+		events = events[:0]
+		eh := New()
+		require.NoError(t, eh.Config(nil))
+		err := eh.Handle([]byte(`{"index":"backup_status","severity":"info","time":1709294400,"message":"done"}`), true, nil, epf)
+		require.NoError(t, err)
+		require.Len(t, events, 1)
+		assert.Equal(t, "backup_status", events[0].Index)
+		assert.Equal(t, data.EVENT, events[0].Type)
+		assert.Equal(t, data.INFO, events[0].Severity)
+		assert.Equal(t, "done", events[0].Message)
+	})
+
+	t.Run("test unrecognized message", func(t *testing.T) {
+		for _, conf := range []string{"", "strictSource: generic"} {
+			events = events[:0]
+			eh := New()
+			require.NoError(t, eh.Config([]byte(conf)))
+			err := eh.Handle([]byte(`{"unknown":"format"}`), true, nil, epf)
+			assert.Error(t, err)
+			require.Len(t, events, 1)
+			assert.Equal(t, "events", events[0].Index)
+			assert.Equal(t, data.ERROR, events[0].Type)
+			assert.Equal(t, "failed to parse event - disregarding", events[0].Labels["message"])
+
+			events = events[:0]
+			err = eh.Handle([]byte(`not json`), false, nil, epf)
+			assert.Error(t, err)
+			assert.Empty(t, events)
+		}
+	})
+}
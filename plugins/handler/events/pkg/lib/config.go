@@ -2,5 +2,5 @@ package lib
 
 // HandlerConfig contains validateable configuration
 type HandlerConfig struct {
-	StrictSource string `yaml:"strictSource" validate:"oneof=generic collectd ceilometer"`
+	StrictSource string `yaml:"strictSource" validate:"omitempty,oneof=generic collectd ceilometer"`
 }
@@ -1,30 +1,26 @@
 package lib
 
 import (
+	"bytes"
+	"encoding/json"
 	"regexp"
+
+	"github.com/openstack-k8s-operators/sg-core/pkg/oslo"
 )
 
 var (
-	// Ceilometer data parsers
-	rexForOsloMessage = regexp.MustCompile(`\\*"oslo.message\\*"\s*:\s*\\*"({.*})\\*"`)
-	rexForPayload     = regexp.MustCompile(`\\+"payload\\+"\s*:\s*\[(.*)\]`)
-	rexForEventType   = regexp.MustCompile(`\\+"event_type\\+"\s*:\s*\\*"`)
 	// collectd data parsers
 	rexForLabelsField     = regexp.MustCompile(`\\?"labels\\?"\w?:\w?\{`)
 	rexForAnnotationField = regexp.MustCompile(`\\?"annotations\\?"\w?:\w?\{`)
 )
 
 func recognizeCeilometer(jsondata []byte) bool {
-	match := rexForOsloMessage.FindSubmatchIndex(jsondata)
-	if match == nil {
-		return false
-	}
-	match = rexForEventType.FindSubmatchIndex(jsondata)
-	if match == nil {
+	msg, err := oslo.Decode(jsondata)
+	if err != nil {
 		return false
 	}
-	match = rexForPayload.FindSubmatchIndex(jsondata)
-	return match != nil
+	payload := bytes.TrimSpace(msg.Payload)
+	return len(payload) > 0 && payload[0] == '['
 }
 
 func recognizeCollectd(jsondata []byte) bool {
@@ -33,9 +29,40 @@ func recognizeCollectd(jsondata []byte) bool {
 	return labels != nil && annots != nil
 }
 
-var recognizers = map[string](func([]byte) bool){
-	"collectd":   recognizeCollectd,
-	"ceilometer": recognizeCeilometer,
+// recognizeGeneric checks that message is an event object or array of event objects, each with index
+func recognizeGeneric(jsondata []byte) bool {
+	trimmed := bytes.TrimSpace(jsondata)
+	if len(trimmed) == 0 {
+		return false
+	}
+	events := []map[string]json.RawMessage{}
+	if trimmed[0] == '{' {
+		events = append(events, nil)
+		if json.Unmarshal(trimmed, &events[0]) != nil {
+			return false
+		}
+	} else if json.Unmarshal(trimmed, &events) != nil {
+		return false
+	}
+	for _, event := range events {
+		var index string
+		if json.Unmarshal(event["index"], &index) != nil {
+			return false
+		}
+	}
+	return len(events) > 0
+}
+
+type recognizer struct {
+	source    string
+	recognize func([]byte) bool
+}
+
+// recognizers are tried in order, generic events may contain labels and annotations similar to collectd events
+var recognizers = []recognizer{
+	{"generic", recognizeGeneric},
+	{"ceilometer", recognizeCeilometer},
+	{"collectd", recognizeCollectd},
 }
 
 // DataSource indentifies a format of incoming data in the message bus channel.
@@ -59,14 +86,13 @@ func (src *DataSource) SetFromString(name string) bool {
 
 // SetFromMessage resets value according to given message data format
 func (src *DataSource) SetFromMessage(jsondata []byte) {
-	for source, rec := range recognizers {
-		if rec(jsondata) {
-			src.SetFromString(source)
+	for _, rec := range recognizers {
+		if rec.recognize(jsondata) {
+			src.SetFromString(rec.source)
 			return
 		}
 	}
-	// TODO: right now generic event message is everything else than collectd or ceilometer event,
-	//      but once we come up with SG generic event format, we need to add it's recognizer
+	// unrecognized messages are handled as generic events and reported as invalid by its parser
 	src.SetFromString("generic")
 }
 
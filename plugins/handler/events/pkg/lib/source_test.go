@@ -32,6 +32,26 @@ var dsCases = []dataSourceTestCase{
 			`"CurrentValue":"43596.2243286703","WarningMin":"nan","WarningMax":"nan","FailureMin":"nan","FailureMax":"0"},` +
 			`"startsAt":"2019-09-18T21:11:19.281603240Z"}]`),
 	},
+	{
+		Source: "ceilometer",
+		EventBlob: []byte(`{"oslo.version":"2.0","oslo.message":"{\"publisher_id\":\"telemetry.publisher\",` +
+			`\"event_type\":\"event\",\"priority\":\"SAMPLE\",\"payload\":[{\"event_type\":\"image.update\",` +
+			`\"traits\":[[\"name\",1,\"labels \\\"annotations\\\" [1]\"]]}]}"}`),
+	},
+	{
+		Source: "generic",
+		EventBlob: []byte(`{"index":"backup_status","severity":"warning","labels":{"job":"db-backup"},` +
+			`"annotations":{"summary":"backup took too long"}}`),
+	},
+	{
+		Source:    "generic",
+		EventBlob: []byte(`[{"index":"deploy"},{"index":"deploy","type":"log"}]`),
+	},
+	{
+		// unrecognized messages are handled as generic events
+		Source:    "generic",
+		EventBlob: []byte(`{"unknown":"format"}`),
+	},
 }
 
 func TestDataSource(t *testing.T) {
@@ -0,0 +1,244 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/openstack-k8s-operators/sg-core/pkg/bus"
+	"github.com/openstack-k8s-operators/sg-core/pkg/config"
+	"github.com/openstack-k8s-operators/sg-core/pkg/data"
+	"github.com/openstack-k8s-operators/sg-core/pkg/fluent"
+	"github.com/openstack-k8s-operators/sg-core/pkg/handler"
+	logslib "github.com/openstack-k8s-operators/sg-core/plugins/handler/logs/pkg/lib"
+)
+
+// severityNames maps common severity names used by log shippers to syslog severities
+var severityNames = map[string]logslib.SyslogSeverity{
+	"emerg":         logslib.EMERGENCY,
+	"emergency":     logslib.EMERGENCY,
+	"fatal":         logslib.EMERGENCY,
+	"alert":         logslib.ALERT,
+	"crit":          logslib.CRITICAL,
+	"critical":      logslib.CRITICAL,
+	"err":           logslib.ERROR,
+	"error":         logslib.ERROR,
+	"warn":          logslib.WARNING,
+	"warning":       logslib.WARNING,
+	"notice":        logslib.NOTICE,
+	"info":          logslib.INFORMATIONAL,
+	"informational": logslib.INFORMATIONAL,
+	"debug":         logslib.DEBUG,
+	"trace":         logslib.DEBUG,
+}
+
+type configT struct {
+	logslib.LogConfig   `yaml:",inline"`
+	MaxDecompressedSize int64 `yaml:"maxDecompressedSize" validate:"min=1"`
+}
+
+type fluentHandler struct {
+	totalMessagesReceived uint64
+	totalLogsDecoded      uint64
+	totalDecodeErrors     uint64
+	statsLock             sync.RWMutex
+	conf                  configT
+}
+
+func defaultConfig() configT {
+	return configT{
+		LogConfig: logslib.LogConfig{
+			MessageField:   "log",
+			TimestampField: "timestamp",
+			HostnameField:  "hostname",
+			SeverityField:  "severity",
+			IndexPrefix:    "sglogs",
+		},
+		MaxDecompressedSize: 64 << 20,
+	}
+}
+
+func (f *fluentHandler) reportError(err error, context string, epf bus.EventPublishFunc) {
+	f.statsLock.Lock()
+	f.totalDecodeErrors++
+	f.statsLock.Unlock()
+	if epf == nil {
+		return
+	}
+	epf(data.Event{
+		Index:    f.Identify(),
+		Type:     data.ERROR,
+		Severity: data.CRITICAL,
+		Time:     0.0,
+		Labels: map[string]interface{}{
+			"error":   err.Error(),
+			"context": context,
+			"message": "failed to parse fluent forward message - disregarding",
+		},
+		Annotations: map[string]interface{}{
+			"description": "internal smartgateway fluent-forward handler error",
+		},
+	})
+}
+
+// severity normalizes severity field to numeric string expected by logs library, names and integers
+// are accepted as well
+func (f *fluentHandler) severity(record map[string]interface{}, msg string) logslib.SyslogSeverity {
+	value := ""
+	switch sev := record[f.conf.SeverityField].(type) {
+	case string:
+		value = sev
+		if s, ok := severityNames[strings.ToLower(sev)]; ok {
+			value = strconv.Itoa(int(s))
+		}
+	case int8, int16, int32, int64, uint8, uint16, uint32, uint64:
+		value = fmt.Sprint(sev)
+	}
+	if n, err := strconv.Atoi(value); err != nil || n < int(logslib.EMERGENCY) || n > int(logslib.UNKNOWN) {
+		value = ""
+	}
+	return logslib.GetSeverityFromLog(map[string]interface{}{
+		f.conf.SeverityField: value,
+		f.conf.MessageField:  msg,
+	}, f.conf.LogConfig)
+}
+
+func (f *fluentHandler) parse(tag string, entry fluent.Entry) (data.Event, error) {
+	record := entry.Record
+	msg, ok := record[f.conf.MessageField].(string)
+	if !ok {
+		return data.Event{}, fmt.Errorf("unable to find a log message under field called: %s", f.conf.MessageField)
+	}
+
+	// hostname is often not part of records, unlike in logs received by the logs handler
+	hostname, ok := record[f.conf.HostnameField].(string)
+	if !ok || hostname == "" {
+		hostname = "unknown"
+	}
+
+	t := entry.Time
+	if timestring, ok := record[f.conf.TimestampField].(string); ok {
+		stamp, err := logslib.TimeFromFormat(timestring)
+		if err != nil {
+			return data.Event{}, err
+		}
+		t = stamp
+	}
+	year, month, day := t.UTC().Date()
+
+	labels := make(map[string]interface{}, len(record)+1)
+	for key, value := range record {
+		if bin, ok := value.([]byte); ok {
+			value = string(bin)
+		}
+		labels[key] = value
+	}
+	delete(labels, f.conf.MessageField)
+	delete(labels, f.conf.TimestampField)
+	if _, ok := labels["tag"]; !ok {
+		labels["tag"] = tag
+	}
+
+	return data.Event{
+		Index:     fmt.Sprintf("%s-%s.%d.%02d.%02d", f.conf.IndexPrefix, strings.ReplaceAll(hostname, "-", "_"), year, month, day),
+		Time:      float64(t.UnixNano()) / float64(time.Second),
+		Type:      data.LOG,
+		Publisher: hostname,
+		Severity:  f.severity(record, msg).ToEventSeverity(),
+		Labels:    labels,
+		Message:   msg,
+	}, nil
+}
+
+// Handle decodes forward protocol message in any mode and publishes each record as log event
+func (f *fluentHandler) Handle(blob []byte, reportErrors bool, _ bus.MetricPublishFunc, epf bus.EventPublishFunc) error {
+	f.statsLock.Lock()
+	f.totalMessagesReceived++
+	f.statsLock.Unlock()
+	errf := epf
+	if !reportErrors {
+		errf = nil
+	}
+
+	msg, err := fluent.Decode(blob, f.conf.MaxDecompressedSize)
+	if err != nil {
+		f.reportError(err, fmt.Sprintf("%d bytes long message", len(blob)), errf)
+		return err
+	}
+
+	var decoded uint64
+	for _, entry := range msg.Entries {
+		event, perr := f.parse(msg.Tag, entry)
+		if perr != nil {
+			err = perr
+			f.reportError(perr, fmt.Sprintf("record with tag %s", msg.Tag), errf)
+			continue
+		}
+		epf(event)
+		decoded++
+	}
+	f.statsLock.Lock()
+	f.totalLogsDecoded += decoded
+	f.statsLock.Unlock()
+	return err
+}
+
+// Run send internal metrics to bus
+func (f *fluentHandler) Run(ctx context.Context, mpf bus.MetricPublishFunc, _ bus.EventPublishFunc) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(time.Second):
+			f.statsLock.RLock()
+			mpf(
+				"sg_total_fluent_forward_msg_received_count",
+				0,
+				data.COUNTER,
+				0,
+				float64(f.totalMessagesReceived),
+				[]string{"source"},
+				[]string{"SG"},
+			)
+			mpf(
+				"sg_total_fluent_forward_log_decode_count",
+				0,
+				data.COUNTER,
+				0,
+				float64(f.totalLogsDecoded),
+				[]string{"source"},
+				[]string{"SG"},
+			)
+			mpf(
+				"sg_total_fluent_forward_decode_error_count",
+				0,
+				data.COUNTER,
+				0,
+				float64(f.totalDecodeErrors),
+				[]string{"source"},
+				[]string{"SG"},
+			)
+			f.statsLock.RUnlock()
+		}
+	}
+}
+
+func (f *fluentHandler) Identify() string {
+	return "fluent-forward"
+}
+
+func (f *fluentHandler) Config(c []byte) error {
+	f.conf = defaultConfig()
+	return config.ParseConfig(bytes.NewReader(c), &f.conf)
+}
+
+// New create new fluentHandler object
+func New() handler.Handler {
+	return &fluentHandler{
+		conf: defaultConfig(),
+	}
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/openstack-k8s-operators/sg-core/pkg/data"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+func TestFluentForward(t *testing.T) {
+	events := []data.Event{}
+	epf := func(e data.Event) { events = append(events, e) }
+
+	t.Run("test records", func(t *testing.T) {
+		f := New().(*fluentHandler)
+		require.NoError(t, f.Config([]byte("messageField: message\nseverityField: level\nindexPrefix: fluent")))
+
+		blob, err := msgpack.Marshal([]interface{}{"nova.api", []interface{}{
+			[]interface{}{1707689655, map[string]interface{}{
+				"message": "instance spawned", "hostname": "compute-0", "level": "WARN", "pid": 42, "raw": []byte("x"),
+			}},
+			[]interface{}{1707689655, map[string]interface{}{
+				"message": "ERROR failed", "level": 6, "timestamp": "2024-02-11T22:14:16.500000", "tag": "own",
+			}},
+			[]interface{}{1707689655, map[string]interface{}{"log": "unmapped"}},
+		}})
+		require.NoError(t, err)
+
+		events = events[:0]
+		assert.Error(t, f.Handle(blob, true, nil, epf))
+		require.Len(t, events, 3)
+		assert.Equal(t, data.Event{
+			Index:     "fluent-compute_0.2024.02.11",
+			Time:      1707689655,
+			Type:      data.LOG,
+			Publisher: "compute-0",
+			Severity:  data.WARNING,
+			Labels: map[string]interface{}{
+				"hostname": "compute-0",
+				"level":    "WARN",
+				"pid":      int8(42),
+				"raw":      "x",
+				"tag":      "nova.api",
+			},
+			Message: "instance spawned",
+		}, events[0])
+		assert.Equal(t, data.Event{
+			Index:     "fluent-unknown.2024.02.11",
+			Time:      1707689656.5,
+			Type:      data.LOG,
+			Publisher: "unknown",
+			Severity:  data.INFO,
+			Labels: map[string]interface{}{
+				"level": int8(6),
+				"tag":   "own",
+			},
+			Message: "ERROR failed",
+		}, events[1])
+		assert.Equal(t, data.ERROR, events[2].Type)
+		assert.Equal(t, "record with tag nova.api", events[2].Labels["context"])
+		assert.Equal(t, uint64(2), f.totalLogsDecoded)
+		assert.Equal(t, uint64(1), f.totalDecodeErrors)
+
+		// severity is corrected from message when requested
+		require.NoError(t, f.Config([]byte("messageField: message\ncorrectSeverity: true")))
+		blob, err = msgpack.Marshal([]interface{}{"nova.api", 1707689655, map[string]interface{}{"message": "ERROR failed", "severity": "6"}})
+		require.NoError(t, err)
+		events = events[:0]
+		require.NoError(t, f.Handle(blob, true, nil, epf))
+		require.Len(t, events, 1)
+		assert.Equal(t, data.CRITICAL, events[0].Severity)
+		assert.Equal(t, "sglogs-unknown.2024.02.11", events[0].Index)
+	})
+
+	t.Run("test invalid payloads", func(t *testing.T) {
+		f := New().(*fluentHandler)
+		require.NoError(t, f.Config([]byte("maxDecompressedSize: 1")))
+
+		events = events[:0]
+		assert.Error(t, f.Handle([]byte("{}"), true, nil, epf))
+		require.Len(t, events, 1)
+		assert.Equal(t, data.ERROR, events[0].Type)
+		assert.Equal(t, "2 bytes long message", events[0].Labels["context"])
+
+		events = events[:0]
+		assert.Error(t, f.Handle([]byte("{}"), false, nil, epf))
+		assert.Empty(t, events)
+		assert.Equal(t, uint64(2), f.totalDecodeErrors)
+		assert.Equal(t, uint64(2), f.totalMessagesReceived)
+
+		assert.Error(t, f.Config([]byte("maxDecompressedSize: 0")))
+		assert.Error(t, f.Config([]byte("messageField: \"\"")))
+	})
+}
@@ -0,0 +1,232 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/openstack-k8s-operators/sg-core/pkg/bus"
+	"github.com/openstack-k8s-operators/sg-core/pkg/config"
+	"github.com/openstack-k8s-operators/sg-core/pkg/data"
+	"github.com/openstack-k8s-operators/sg-core/pkg/handler"
+	"github.com/openstack-k8s-operators/sg-core/plugins/handler/gelf/pkg/lib"
+	logslib "github.com/openstack-k8s-operators/sg-core/plugins/handler/logs/pkg/lib"
+)
+
+var invalidLabelChars = regexp.MustCompile(`[^a-zA-Z0-9_]`)
+
+type configT struct {
+	IndexPrefix        string        `yaml:"indexPrefix"`
+	MaxMessageSize     int           `yaml:"maxMessageSize" validate:"min=1"`     // limit of decompressed and reassembled messages
+	ChunkTimeout       time.Duration `yaml:"chunkTimeout"`                        // incomplete chunked messages are dropped after this long
+	MaxPendingMessages int           `yaml:"maxPendingMessages" validate:"min=1"` // limit of incomplete chunked messages
+}
+
+type gelfHandler struct {
+	totalMessagesReceived uint64
+	totalLogsDecoded      uint64
+	totalDecodeErrors     uint64
+	totalChunksExpired    uint64
+	statsLock             sync.RWMutex
+	conf                  configT
+	// assembler and lastExpire are guarded by chunkLock
+	chunkLock  sync.Mutex
+	assembler  *lib.Assembler
+	lastExpire time.Time
+	now        func() time.Time
+}
+
+func defaultConfig() configT {
+	return configT{
+		IndexPrefix:        "sglogs",
+		MaxMessageSize:     8 << 20,
+		ChunkTimeout:       5 * time.Second,
+		MaxPendingMessages: 1000,
+	}
+}
+
+func (g *gelfHandler) reportError(err error, context string, epf bus.EventPublishFunc) {
+	g.statsLock.Lock()
+	g.totalDecodeErrors++
+	g.statsLock.Unlock()
+	if epf == nil {
+		return
+	}
+	epf(data.Event{
+		Index:    g.Identify(),
+		Type:     data.ERROR,
+		Severity: data.CRITICAL,
+		Time:     0.0,
+		Labels: map[string]interface{}{
+			"error":   err.Error(),
+			"context": context,
+			"message": "failed to parse GELF message - disregarding",
+		},
+		Annotations: map[string]interface{}{
+			"description": "internal smartgateway gelf handler error",
+		},
+	})
+}
+
+// assemble stores chunk and returns whole message once complete
+func (g *gelfHandler) assemble(chunk []byte, now time.Time) ([]byte, error) {
+	g.chunkLock.Lock()
+	defer g.chunkLock.Unlock()
+	if now.Sub(g.lastExpire) >= time.Second {
+		expired := g.assembler.Expire(now.Add(-g.conf.ChunkTimeout))
+		g.lastExpire = now
+		g.statsLock.Lock()
+		g.totalChunksExpired += uint64(expired)
+		g.statsLock.Unlock()
+	}
+	return g.assembler.Add(chunk, now)
+}
+
+func (g *gelfHandler) parse(blob []byte, now time.Time) (data.Event, error) {
+	payload, err := lib.Decompress(blob, g.conf.MaxMessageSize)
+	if err != nil {
+		return data.Event{}, err
+	}
+	m, err := lib.Parse(payload, now)
+	if err != nil {
+		return data.Event{}, err
+	}
+
+	labels := make(map[string]interface{}, len(m.Fields)+3)
+	for name, value := range m.Fields {
+		labels[invalidLabelChars.ReplaceAllString(name, "_")] = value
+	}
+	labels["host"] = m.Host
+	labels["level"] = strconv.Itoa(m.Level)
+	if m.FullMessage != "" {
+		labels["full_message"] = m.FullMessage
+	}
+
+	year, month, day := m.Timestamp.UTC().Date()
+	return data.Event{
+		Index:     fmt.Sprintf("%s-%s.%d.%02d.%02d", g.conf.IndexPrefix, strings.ReplaceAll(m.Host, "-", "_"), year, month, day),
+		Time:      float64(m.Timestamp.UnixNano()) / float64(time.Second),
+		Type:      data.LOG,
+		Publisher: m.Host,
+		Severity:  logslib.SyslogSeverity(m.Level).ToEventSeverity(),
+		Labels:    labels,
+		Message:   m.ShortMessage,
+	}, nil
+}
+
+// Handle implements the data.EventsHandler interface
+func (g *gelfHandler) Handle(msg []byte, reportErrors bool, _ bus.MetricPublishFunc, epf bus.EventPublishFunc) error {
+	g.statsLock.Lock()
+	g.totalMessagesReceived++
+	g.statsLock.Unlock()
+	errf := epf
+	if !reportErrors {
+		errf = nil
+	}
+
+	now := g.now()
+	blob := msg
+	if lib.IsChunk(msg) {
+		var err error
+		blob, err = g.assemble(msg, now)
+		if err != nil {
+			g.reportError(err, fmt.Sprintf("chunk of %d bytes", len(msg)), errf)
+			return err
+		}
+		if blob == nil {
+			return nil
+		}
+	}
+
+	log, err := g.parse(blob, now)
+	if err != nil {
+		g.reportError(err, string(blob), errf)
+		return err
+	}
+	epf(log)
+	g.statsLock.Lock()
+	g.totalLogsDecoded++
+	g.statsLock.Unlock()
+	return nil
+}
+
+// Run send internal metrics to bus
+func (g *gelfHandler) Run(ctx context.Context, mpf bus.MetricPublishFunc, _ bus.EventPublishFunc) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(time.Second):
+			g.statsLock.RLock()
+			mpf(
+				"sg_total_gelf_msg_received_count",
+				0,
+				data.COUNTER,
+				0,
+				float64(g.totalMessagesReceived),
+				[]string{"source"},
+				[]string{"SG"},
+			)
+			mpf(
+				"sg_total_gelf_log_decode_count",
+				0,
+				data.COUNTER,
+				0,
+				float64(g.totalLogsDecoded),
+				[]string{"source"},
+				[]string{"SG"},
+			)
+			mpf(
+				"sg_total_gelf_decode_error_count",
+				0,
+				data.COUNTER,
+				0,
+				float64(g.totalDecodeErrors),
+				[]string{"source"},
+				[]string{"SG"},
+			)
+			mpf(
+				"sg_total_gelf_chunk_expired_count",
+				0,
+				data.COUNTER,
+				0,
+				float64(g.totalChunksExpired),
+				[]string{"source"},
+				[]string{"SG"},
+			)
+			g.statsLock.RUnlock()
+		}
+	}
+}
+
+func (g *gelfHandler) Identify() string {
+	return "gelf"
+}
+
+func (g *gelfHandler) Config(c []byte) error {
+	g.conf = defaultConfig()
+	err := config.ParseConfig(bytes.NewReader(c), &g.conf)
+	if err != nil {
+		return err
+	}
+	if g.conf.ChunkTimeout <= 0 {
+		return fmt.Errorf("chunkTimeout has to be positive")
+	}
+	g.assembler = lib.NewAssembler(g.conf.MaxPendingMessages, g.conf.MaxMessageSize)
+	return nil
+}
+
+// New create new gelfHandler object
+func New() handler.Handler {
+	conf := defaultConfig()
+	return &gelfHandler{
+		conf:      conf,
+		assembler: lib.NewAssembler(conf.MaxPendingMessages, conf.MaxMessageSize),
+		now:       time.Now,
+	}
+}
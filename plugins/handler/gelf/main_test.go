@@ -0,0 +1,134 @@
+package main
+
+import (
+	"bytes"
+	"compress/zlib"
+	"testing"
+	"time"
+
+	"github.com/openstack-k8s-operators/sg-core/pkg/data"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var received = time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+
+const payload = `{"version":"1.1","host":"compute-0","short_message":"A short message","full_message":"Backtrace here\n\nmore stuff",
+"timestamp":1709294400.5,"level":4,"_user_id":9001,"_some.info":"foo","_host":"other"}`
+
+var expected = data.Event{
+	Index:     "sglogs-compute_0.2024.03.01",
+	Time:      1709294400.5,
+	Type:      data.LOG,
+	Publisher: "compute-0",
+	Severity:  data.WARNING,
+	Labels: map[string]interface{}{
+		"host":         "compute-0",
+		"level":        "4",
+		"full_message": "Backtrace here\n\nmore stuff",
+		"user_id":      float64(9001),
+		"some_info":    "foo",
+	},
+	Message: "A short message",
+}
+
+func chunks(id byte, blob []byte, size int) [][]byte {
+	parts := [][]byte{}
+	count := (len(blob) + size - 1) / size
+	for seq := 0; seq < count; seq++ {
+		end := (seq + 1) * size
+		if end > len(blob) {
+			end = len(blob)
+		}
+		header := []byte{0x1e, 0x0f, id, 1, 2, 3, 4, 5, 6, 7, byte(seq), byte(count)}
+		parts = append(parts, append(header, blob[seq*size:end]...))
+	}
+	return parts
+}
+
+func TestGELF(t *testing.T) {
+	events := []data.Event{}
+	epf := func(e data.Event) { events = append(events, e) }
+	newHandler := func(t *testing.T, conf string) *gelfHandler {
+		g := New().(*gelfHandler)
+		require.NoError(t, g.Config([]byte(conf)))
+		g.now = func() time.Time { return received }
+		return g
+	}
+
+	t.Run("test plain and compressed messages", func(t *testing.T) {
+		g := newHandler(t, "")
+		var zl bytes.Buffer
+		zw := zlib.NewWriter(&zl)
+		_, err := zw.Write([]byte(payload))
+		require.NoError(t, err)
+		require.NoError(t, zw.Close())
+
+		events = events[:0]
+		require.NoError(t, g.Handle([]byte(payload), true, nil, epf))
+		require.NoError(t, g.Handle(zl.Bytes(), true, nil, epf))
+		assert.Equal(t, []data.Event{expected, expected}, events)
+
+		events = events[:0]
+		require.NoError(t, g.Handle([]byte(`{"host":"compute-1","short_message":"no level"}`), true, nil, epf))
+		require.Len(t, events, 1)
+		assert.Equal(t, data.CRITICAL, events[0].Severity)
+		assert.Equal(t, float64(received.Unix()), events[0].Time)
+		assert.Equal(t, map[string]interface{}{"host": "compute-1", "level": "1"}, events[0].Labels)
+	})
+
+	t.Run("test chunked messages", func(t *testing.T) {
+		g := newHandler(t, "indexPrefix: gelf")
+		var zl bytes.Buffer
+		zw := zlib.NewWriter(&zl)
+		_, err := zw.Write([]byte(payload))
+		require.NoError(t, err)
+		require.NoError(t, zw.Close())
+
+		events = events[:0]
+		parts := chunks(1, zl.Bytes(), 20)
+		require.Greater(t, len(parts), 2)
+		// reverse order
+		for i := len(parts) - 1; i >= 0; i-- {
+			require.NoError(t, g.Handle(parts[i], true, nil, epf))
+		}
+		require.Len(t, events, 1)
+		assert.Equal(t, "gelf-compute_0.2024.03.01", events[0].Index)
+		assert.Equal(t, expected.Labels, events[0].Labels)
+
+		// incomplete messages expire
+		parts = chunks(2, []byte(payload), 50)
+		require.NoError(t, g.Handle(parts[0], true, nil, epf))
+		g.now = func() time.Time { return received.Add(10 * time.Second) }
+		require.NoError(t, g.Handle(chunks(3, []byte(payload), 50)[0], true, nil, epf))
+		assert.Equal(t, uint64(1), g.totalChunksExpired)
+		assert.Equal(t, 1, g.assembler.Pending())
+		assert.Len(t, events, 1)
+	})
+
+	t.Run("test invalid messages", func(t *testing.T) {
+		g := newHandler(t, "maxMessageSize: 100\nmaxPendingMessages: 1")
+		events = events[:0]
+		for _, msg := range [][]byte{
+			[]byte(`{"short_message":"no host"}`),
+			[]byte("not json"),
+			[]byte(payload),
+			{0x1e, 0x0f, 1, 2},
+		} {
+			assert.Error(t, g.Handle(msg, true, nil, epf))
+		}
+		require.Len(t, events, 4)
+		for _, e := range events {
+			assert.Equal(t, data.ERROR, e.Type)
+		}
+		assert.Equal(t, `{"short_message":"no host"}`, events[0].Labels["context"])
+		assert.Equal(t, uint64(4), g.totalDecodeErrors)
+
+		require.NoError(t, g.Handle(chunks(1, []byte(payload), 50)[0], false, nil, epf))
+		assert.Error(t, g.Handle(chunks(2, []byte(payload), 50)[0], false, nil, epf))
+		assert.Len(t, events, 4)
+
+		assert.Error(t, g.Config([]byte("chunkTimeout: 0s")))
+		assert.Error(t, g.Config([]byte("maxMessageSize: 0")))
+	})
+}
@@ -0,0 +1,110 @@
+package lib
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"time"
+)
+
+const (
+	chunkHeaderLen = 12
+	// MaxChunks is the maximum number of chunks of single message allowed by the specification
+	MaxChunks = 128
+)
+
+var chunkMagic = []byte{0x1e, 0x0f}
+
+// ErrTooManyPending is returned when chunk of new message is received while the limit
+// of incomplete messages is reached
+var ErrTooManyPending = errors.New("too many incomplete chunked messages")
+
+// IsChunk reports whether datagram is a chunk of larger message
+func IsChunk(datagram []byte) bool {
+	return bytes.HasPrefix(datagram, chunkMagic)
+}
+
+type pendingMessage struct {
+	chunks   [][]byte
+	received int
+	size     int
+	first    time.Time
+}
+
+// Assembler reassembles chunked messages. It is not safe for concurrent use
+type Assembler struct {
+	pending    map[[8]byte]*pendingMessage
+	maxPending int
+	maxSize    int
+}
+
+// NewAssembler creates assembler keeping at most maxPending incomplete messages of at most maxSize bytes
+func NewAssembler(maxPending int, maxSize int) *Assembler {
+	return &Assembler{
+		pending:    map[[8]byte]*pendingMessage{},
+		maxPending: maxPending,
+		maxSize:    maxSize,
+	}
+}
+
+// Add stores chunk and returns the whole message once all its chunks were received
+func (a *Assembler) Add(chunk []byte, now time.Time) ([]byte, error) {
+	if len(chunk) < chunkHeaderLen || !IsChunk(chunk) {
+		return nil, fmt.Errorf("invalid chunk header")
+	}
+	var id [8]byte
+	copy(id[:], chunk[2:10])
+	seq, count := int(chunk[10]), int(chunk[11])
+	if count == 0 || count > MaxChunks || seq >= count {
+		return nil, fmt.Errorf("invalid chunk sequence %d of %d", seq, count)
+	}
+
+	msg, ok := a.pending[id]
+	if !ok {
+		if len(a.pending) >= a.maxPending {
+			return nil, ErrTooManyPending
+		}
+		msg = &pendingMessage{chunks: make([][]byte, count), first: now}
+		a.pending[id] = msg
+	}
+	if len(msg.chunks) != count {
+		delete(a.pending, id)
+		return nil, fmt.Errorf("chunk count changed from %d to %d", len(msg.chunks), count)
+	}
+	if msg.chunks[seq] != nil {
+		// duplicate datagram
+		return nil, nil
+	}
+	data := chunk[chunkHeaderLen:]
+	msg.size += len(data)
+	if msg.size > a.maxSize {
+		delete(a.pending, id)
+		return nil, fmt.Errorf("chunked message exceeds %d bytes", a.maxSize)
+	}
+	// datagram buffers are reused by transports
+	msg.chunks[seq] = append([]byte{}, data...)
+	msg.received++
+	if msg.received < count {
+		return nil, nil
+	}
+
+	delete(a.pending, id)
+	return bytes.Join(msg.chunks, nil), nil
+}
+
+// Expire drops incomplete messages whose first chunk was received before deadline and returns their count
+func (a *Assembler) Expire(deadline time.Time) int {
+	expired := 0
+	for id, msg := range a.pending {
+		if msg.first.Before(deadline) {
+			delete(a.pending, id)
+			expired++
+		}
+	}
+	return expired
+}
+
+// Pending returns number of incomplete messages
+func (a *Assembler) Pending() int {
+	return len(a.pending)
+}
@@ -0,0 +1,127 @@
+package lib
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func chunk(id byte, seq, count byte, data string) []byte {
+	return append([]byte{0x1e, 0x0f, id, 0, 0, 0, 0, 0, 0, 0, seq, count}, data...)
+}
+
+func TestAssembler(t *testing.T) {
+	now := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	t.Run("test reassembly", func(t *testing.T) {
+		a := NewAssembler(2, 10)
+		assert.True(t, IsChunk(chunk(1, 0, 1, "")))
+		assert.False(t, IsChunk([]byte("{}")))
+
+		// chunks may arrive out of order and duplicated
+		for _, c := range [][]byte{chunk(1, 2, 3, "ef"), chunk(2, 0, 2, "x"), chunk(1, 0, 3, "ab"), chunk(1, 0, 3, "ab")} {
+			msg, err := a.Add(c, now)
+			require.NoError(t, err)
+			assert.Nil(t, msg)
+		}
+		assert.Equal(t, 2, a.Pending())
+		_, err := a.Add(chunk(3, 0, 2, "y"), now)
+		assert.ErrorIs(t, err, ErrTooManyPending)
+
+		msg, err := a.Add(chunk(1, 1, 3, "cd"), now)
+		require.NoError(t, err)
+		assert.Equal(t, "abcdef", string(msg))
+		assert.Equal(t, 1, a.Pending())
+
+		assert.Equal(t, 0, a.Expire(now))
+		assert.Equal(t, 1, a.Expire(now.Add(time.Second)))
+		assert.Equal(t, 0, a.Pending())
+	})
+
+	t.Run("test invalid chunks", func(t *testing.T) {
+		a := NewAssembler(2, 10)
+		for _, c := range [][]byte{
+			{0x1e, 0x0f, 1},
+			chunk(1, 1, 1, "a"),
+			chunk(1, 0, 0, "a"),
+			chunk(1, 0, 129, "a"),
+			chunk(1, 0, 2, "01234567890"),
+		} {
+			_, err := a.Add(c, now)
+			assert.Error(t, err, "%x", c)
+		}
+		_, err := a.Add(chunk(1, 0, 2, "a"), now)
+		require.NoError(t, err)
+		_, err = a.Add(chunk(1, 1, 3, "b"), now)
+		assert.Error(t, err)
+		assert.Equal(t, 0, a.Pending())
+	})
+}
+
+func TestMessage(t *testing.T) {
+	received := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	payload := []byte(`{"version":"1.1","host":"compute-0","short_message":"short","full_message":"full\ntrace",
+"timestamp":1709294400.123,"level":3,"_user_id":9001,"_some.info":"foo","_id":"x","facility":"nova","unknown":1}`)
+
+	t.Run("test decompression", func(t *testing.T) {
+		var gz, zl bytes.Buffer
+		gw := gzip.NewWriter(&gz)
+		_, err := gw.Write(payload)
+		require.NoError(t, err)
+		require.NoError(t, gw.Close())
+		zw := zlib.NewWriter(&zl)
+		_, err = zw.Write(payload)
+		require.NoError(t, err)
+		require.NoError(t, zw.Close())
+
+		for _, blob := range [][]byte{payload, gz.Bytes(), zl.Bytes()} {
+			out, err := Decompress(blob, len(payload))
+			require.NoError(t, err)
+			assert.Equal(t, payload, out)
+			_, err = Decompress(blob, len(payload)-1)
+			assert.Error(t, err)
+		}
+		_, err = Decompress([]byte{0x1f, 0x8b, 0}, 100)
+		assert.Error(t, err)
+	})
+
+	t.Run("test parsing", func(t *testing.T) {
+		msg, err := Parse(payload, received)
+		require.NoError(t, err)
+		assert.Equal(t, Message{
+			Version:      "1.1",
+			Host:         "compute-0",
+			ShortMessage: "short",
+			FullMessage:  "full\ntrace",
+			Timestamp:    time.UnixMicro(1709294400123000),
+			Level:        3,
+			Fields:       map[string]interface{}{"user_id": float64(9001), "some.info": "foo", "facility": "nova"},
+		}, msg)
+
+		msg, err = Parse([]byte(`{"host":"h","short_message":"m","level":"6"}`), received)
+		require.NoError(t, err)
+		assert.Equal(t, received, msg.Timestamp)
+		assert.Equal(t, 6, msg.Level)
+		msg, err = Parse([]byte(`{"host":"h","short_message":"m"}`), received)
+		require.NoError(t, err)
+		assert.Equal(t, 1, msg.Level)
+
+		for _, blob := range []string{
+			`[]`,
+			`{"short_message":"m"}`,
+			`{"host":"h"}`,
+			`{"host":"h","short_message":"m","timestamp":"now"}`,
+			`{"host":"h","short_message":"m","level":8}`,
+			`{"host":"h","short_message":"m","level":1.5}`,
+			`{"host":"h","short_message":"m","level":"debug"}`,
+		} {
+			_, err := Parse([]byte(blob), received)
+			assert.Error(t, err, blob)
+		}
+	})
+}
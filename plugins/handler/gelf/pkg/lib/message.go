@@ -0,0 +1,122 @@
+package lib
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// defaultLevel is ALERT, as defined by the specification for messages without level
+const defaultLevel = 1
+
+// deprecated fields of GELF 1.0 which are treated as additional fields
+var deprecatedFields = map[string]bool{"facility": true, "line": true, "file": true}
+
+// Message holds parsed GELF message
+type Message struct {
+	Version      string
+	Host         string
+	ShortMessage string
+	FullMessage  string
+	Timestamp    time.Time
+	Level        int
+	// Fields holds additional fields with the leading underscore removed
+	Fields map[string]interface{}
+}
+
+// Decompress inflates zlib or gzip compressed payload, uncompressed payloads are returned unchanged.
+// Output is limited to maxSize bytes
+func Decompress(blob []byte, maxSize int) ([]byte, error) {
+	var r io.ReadCloser
+	var err error
+	switch {
+	case len(blob) > 1 && blob[0] == 0x1f && blob[1] == 0x8b:
+		r, err = gzip.NewReader(bytes.NewReader(blob))
+	case len(blob) > 1 && blob[0] == 0x78 && (uint16(blob[0])<<8|uint16(blob[1]))%31 == 0:
+		r, err = zlib.NewReader(bytes.NewReader(blob))
+	default:
+		if len(blob) > maxSize {
+			return nil, fmt.Errorf("message exceeds %d bytes", maxSize)
+		}
+		return blob, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	out, err := io.ReadAll(io.LimitReader(r, int64(maxSize)+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(out) > maxSize {
+		return nil, fmt.Errorf("decompressed message exceeds %d bytes", maxSize)
+	}
+	return out, nil
+}
+
+// Parse parses uncompressed GELF message. Time of receiving is used for messages lacking timestamp
+func Parse(blob []byte, received time.Time) (Message, error) {
+	fields := map[string]interface{}{}
+	err := json.Unmarshal(blob, &fields)
+	if err != nil {
+		return Message{}, err
+	}
+
+	msg := Message{Timestamp: received, Level: defaultLevel, Fields: map[string]interface{}{}}
+	var ok bool
+	if msg.Host, ok = fields["host"].(string); !ok || msg.Host == "" {
+		return msg, fmt.Errorf("missing host")
+	}
+	if msg.ShortMessage, ok = fields["short_message"].(string); !ok {
+		return msg, fmt.Errorf("missing short_message")
+	}
+	msg.Version, _ = fields["version"].(string)
+	msg.FullMessage, _ = fields["full_message"].(string)
+
+	switch ts := fields["timestamp"].(type) {
+	case nil:
+	case float64:
+		msg.Timestamp = time.UnixMicro(int64(math.Round(ts * 1e6)))
+	default:
+		return msg, fmt.Errorf("invalid timestamp %v", ts)
+	}
+
+	switch level := fields["level"].(type) {
+	case nil:
+	case float64:
+		msg.Level = int(level)
+		if float64(msg.Level) != level {
+			msg.Level = -1
+		}
+	case string:
+		// sent as string by some clients
+		msg.Level, err = strconv.Atoi(level)
+		if err != nil {
+			msg.Level = -1
+		}
+	default:
+		msg.Level = -1
+	}
+	if msg.Level < 0 || msg.Level > 7 {
+		return msg, fmt.Errorf("invalid level %v", fields["level"])
+	}
+
+	for key, value := range fields {
+		if name, additional := strings.CutPrefix(key, "_"); additional {
+			// _id is reserved by the specification
+			if name != "id" && name != "" {
+				msg.Fields[name] = value
+			}
+		} else if deprecatedFields[key] {
+			msg.Fields[key] = value
+		}
+	}
+	return msg, nil
+}
@@ -0,0 +1,187 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"sync"
+	"time"
+
+	"github.com/openstack-k8s-operators/sg-core/pkg/bus"
+	"github.com/openstack-k8s-operators/sg-core/pkg/config"
+	"github.com/openstack-k8s-operators/sg-core/pkg/data"
+	"github.com/openstack-k8s-operators/sg-core/pkg/handler"
+	"github.com/openstack-k8s-operators/sg-core/plugins/handler/graphite/pkg/lib"
+)
+
+const (
+	protocolPlaintext = "plaintext"
+	protocolPickle    = "pickle"
+)
+
+type configT struct {
+	Protocol    string        `validate:"oneof=plaintext pickle"`
+	Interval    time.Duration // expected interval of datapoints, used for expiry of stale metrics
+	StrictMatch bool          `yaml:"strictMatch"` // drop paths not matching any mapping
+	Mappings    []lib.Mapping
+}
+
+type graphiteHandler struct {
+	totalMessagesReceived uint64
+	totalMetricsDecoded   uint64
+	totalMetricsDropped   uint64
+	totalDecodeErrors     uint64
+	statsLock             sync.RWMutex
+	conf                  configT
+	mapper                *lib.Mapper
+	now                   func() time.Time
+}
+
+func (g *graphiteHandler) reportError(err error, context string, epf bus.EventPublishFunc) {
+	g.statsLock.Lock()
+	g.totalDecodeErrors++
+	g.statsLock.Unlock()
+	if epf == nil {
+		return
+	}
+	epf(data.Event{
+		Index:    g.Identify(),
+		Type:     data.ERROR,
+		Severity: data.CRITICAL,
+		Time:     0.0,
+		Labels: map[string]interface{}{
+			"error":   err.Error(),
+			"context": context,
+			"message": "failed to parse graphite metric - disregarding",
+		},
+		Annotations: map[string]interface{}{
+			"description": "internal smartgateway graphite handler error",
+		},
+	})
+}
+
+// Handle parses Carbon plaintext lines or pickle payload
+func (g *graphiteHandler) Handle(blob []byte, reportErrors bool, mpf bus.MetricPublishFunc, epf bus.EventPublishFunc) error {
+	g.statsLock.Lock()
+	g.totalMessagesReceived++
+	g.statsLock.Unlock()
+	if !reportErrors {
+		epf = nil
+	}
+
+	var samples []lib.Sample
+	if g.conf.Protocol == protocolPickle {
+		var err error
+		samples, err = lib.ParsePickle(blob)
+		if err != nil {
+			g.reportError(err, "pickle payload", epf)
+		}
+	} else {
+		now := g.now()
+		for _, line := range bytes.Split(blob, []byte("\n")) {
+			line = bytes.TrimSpace(line)
+			if len(line) == 0 {
+				continue
+			}
+			sample, err := lib.ParsePlaintext(line, now)
+			if err != nil {
+				g.reportError(err, string(line), epf)
+				continue
+			}
+			samples = append(samples, sample)
+		}
+	}
+
+	for _, sample := range samples {
+		name, keys, vals, ok := g.mapper.Map(sample.Path)
+		g.statsLock.Lock()
+		if ok {
+			g.totalMetricsDecoded++
+		} else {
+			g.totalMetricsDropped++
+		}
+		g.statsLock.Unlock()
+		if ok {
+			mpf(name, sample.Timestamp, data.GAUGE, g.conf.Interval, sample.Value, keys, vals)
+		}
+	}
+	return nil
+}
+
+// Run send internal metrics to bus
+func (g *graphiteHandler) Run(ctx context.Context, mpf bus.MetricPublishFunc, _ bus.EventPublishFunc) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(time.Second):
+			g.statsLock.RLock()
+			mpf(
+				"sg_total_graphite_msg_received_count",
+				0,
+				data.COUNTER,
+				0,
+				float64(g.totalMessagesReceived),
+				[]string{"source"},
+				[]string{"SG"},
+			)
+			mpf(
+				"sg_total_graphite_metric_decode_count",
+				0,
+				data.COUNTER,
+				0,
+				float64(g.totalMetricsDecoded),
+				[]string{"source"},
+				[]string{"SG"},
+			)
+			mpf(
+				"sg_total_graphite_metric_dropped_count",
+				0,
+				data.COUNTER,
+				0,
+				float64(g.totalMetricsDropped),
+				[]string{"source"},
+				[]string{"SG"},
+			)
+			mpf(
+				"sg_total_graphite_decode_error_count",
+				0,
+				data.COUNTER,
+				0,
+				float64(g.totalDecodeErrors),
+				[]string{"source"},
+				[]string{"SG"},
+			)
+			g.statsLock.RUnlock()
+		}
+	}
+}
+
+func (g *graphiteHandler) Identify() string {
+	return "graphite"
+}
+
+func (g *graphiteHandler) Config(c []byte) error {
+	g.conf = configT{
+		Protocol: protocolPlaintext,
+		Interval: time.Minute,
+	}
+	err := config.ParseConfig(bytes.NewReader(c), &g.conf)
+	if err != nil {
+		return err
+	}
+	g.mapper, err = lib.NewMapper(g.conf.Mappings, g.conf.StrictMatch)
+	return err
+}
+
+// New create new graphiteHandler object
+func New() handler.Handler {
+	mapper, _ := lib.NewMapper(nil, false)
+	return &graphiteHandler{
+		conf: configT{
+			Protocol: protocolPlaintext,
+			Interval: time.Minute,
+		},
+		mapper: mapper,
+		now:    time.Now,
+	}
+}
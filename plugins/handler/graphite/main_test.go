@@ -0,0 +1,140 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/openstack-k8s-operators/sg-core/pkg/data"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var received = time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+
+const testMappings = `
+mappings:
+  - match: ceph.osd.*.op_*
+    name: ceph_osd_ops_total
+    labels:
+      osd: $1
+      op: $2
+  - match: '^nova\.([^.]+)\.api\.(get|post)$'
+    matchType: regex
+    name: nova_api_${2}_requests
+    labels:
+      host: $1
+  - match: debug.*
+    action: drop
+`
+
+func newTestHandler(t *testing.T, conf string) *graphiteHandler {
+	g := New().(*graphiteHandler)
+	require.NoError(t, g.Config([]byte(conf)))
+	g.now = func() time.Time {
+		return received
+	}
+	return g
+}
+
+func handle(t *testing.T, g *graphiteHandler, blob string) []data.Metric {
+	metrics := []data.Metric{}
+	err := g.Handle([]byte(blob), false, func(name string, mTime float64, mType data.MetricType, interval time.Duration, value float64, labelKeys []string, labelVals []string) {
+		metrics = append(metrics, data.Metric{
+			Name:      name,
+			Time:      mTime,
+			Type:      mType,
+			Interval:  interval,
+			Value:     value,
+			LabelKeys: labelKeys,
+			LabelVals: labelVals,
+		})
+	}, func(data.Event) {})
+	require.NoError(t, err)
+	return metrics
+}
+
+func metric(name string, mTime float64, value float64, labels ...string) data.Metric {
+	m := data.Metric{
+		Name:      name,
+		Time:      mTime,
+		Type:      data.GAUGE,
+		Interval:  time.Minute,
+		Value:     value,
+		LabelKeys: []string{},
+		LabelVals: []string{},
+	}
+	for i := 0; i < len(labels); i += 2 {
+		m.LabelKeys = append(m.LabelKeys, labels[i])
+		m.LabelVals = append(m.LabelVals, labels[i+1])
+	}
+	return m
+}
+
+func TestGraphite(t *testing.T) {
+	t.Run("test plaintext with mappings", func(t *testing.T) {
+		g := newTestHandler(t, testMappings)
+		metrics := handle(t, g, "ceph.osd.3.op_w 120 1700000000\n"+
+			"nova.compute-0.api.get 5 1700000001\r\n"+
+			"nova.compute-0.api.delete 1 1700000002\n"+
+			"debug.heap 1 1700000003\n"+
+			"\n"+
+			"disk.used;host=node-1;mount=/var 0.75 -1\n")
+		assert.Equal(t, []data.Metric{
+			metric("ceph_osd_ops_total", 1700000000, 120, "op", "w", "osd", "3"),
+			metric("nova_api_get_requests", 1700000001, 5, "host", "compute-0"),
+			metric("nova_compute_0_api_delete", 1700000002, 1),
+			metric("disk_used", float64(received.Unix()), 0.75, "host", "node-1", "mount", "/var"),
+		}, metrics)
+		assert.Equal(t, uint64(4), g.totalMetricsDecoded)
+		assert.Equal(t, uint64(1), g.totalMetricsDropped)
+	})
+
+	t.Run("test strict match", func(t *testing.T) {
+		g := newTestHandler(t, testMappings+"strictMatch: true\ninterval: 10s\n")
+		metrics := handle(t, g, "ceph.osd.3.op_w 120 1700000000\nnova.compute-0.api.delete 1 1700000002\n")
+		expected := metric("ceph_osd_ops_total", 1700000000, 120, "op", "w", "osd", "3")
+		expected.Interval = 10 * time.Second
+		assert.Equal(t, []data.Metric{expected}, metrics)
+		assert.Equal(t, uint64(1), g.totalMetricsDropped)
+	})
+
+	t.Run("test pickle protocol", func(t *testing.T) {
+		g := newTestHandler(t, testMappings+"protocol: pickle\n")
+		// pickle.dumps([("ceph.osd.0.op_r", (1700000000, 12.5)), ("debug.x", (1700000000, 1))], protocol=2)
+		payload := "\x80\x02]q\x00(X\x0f\x00\x00\x00ceph.osd.0.op_rq\x01J\x00\xf1SeG@)\x00\x00\x00\x00\x00\x00\x86q\x02\x86q\x03X\x07\x00\x00\x00debug.xq\x04J\x00\xf1SeK\x01\x86q\x05\x86q\x06e."
+		assert.Equal(t, []data.Metric{
+			metric("ceph_osd_ops_total", 1700000000, 12.5, "op", "r", "osd", "0"),
+		}, handle(t, g, payload))
+
+		assert.Empty(t, handle(t, g, "ceph.osd.0.op_r 1 1700000000\n"))
+		assert.Equal(t, uint64(1), g.totalDecodeErrors)
+	})
+
+	t.Run("test invalid lines", func(t *testing.T) {
+		g := newTestHandler(t, "")
+		events := []data.Event{}
+		err := g.Handle([]byte("a.b 1\na.b one 1700000000\na.b 1 yesterday\na.b 1 1700000000\n"), true,
+			func(string, float64, data.MetricType, time.Duration, float64, []string, []string) {},
+			func(e data.Event) {
+				events = append(events, e)
+			})
+		require.NoError(t, err)
+		assert.Equal(t, uint64(3), g.totalDecodeErrors)
+		assert.Equal(t, uint64(1), g.totalMetricsDecoded)
+		require.Len(t, events, 3)
+		assert.Equal(t, data.ERROR, events[0].Type)
+	})
+
+	t.Run("test invalid configuration", func(t *testing.T) {
+		for _, conf := range []string{
+			"protocol: json",
+			"mappings:\n  - match: a.*\n",
+			"mappings:\n  - match: a.*\n    name: a\n    matchType: fuzzy\n",
+			"mappings:\n  - match: '(a'\n    name: a\n    matchType: regex\n",
+			"mappings:\n  - match: a.*\n    name: a\n    action: rename\n",
+			"mappings:\n  - match: a.*\n    name: a\n    labels:\n      1st: $1\n",
+		} {
+			assert.Error(t, New().Config([]byte(conf)), conf)
+		}
+	})
+}
@@ -0,0 +1,155 @@
+package lib
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+var (
+	invalidNameChars = regexp.MustCompile(`[^a-zA-Z0-9_]`)
+	validLabelName   = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
+)
+
+// Mapping actions and match types
+const (
+	ActionMap  = "map"
+	ActionDrop = "drop"
+	MatchGlob  = "glob"
+	MatchRegex = "regex"
+)
+
+// Mapping converts Graphite paths matching pattern to metric name and labels. Name and label
+// values can reference captured parts of the path as $1 or ${1}
+type Mapping struct {
+	Match     string
+	MatchType string `yaml:"matchType"` // glob or regex. Default: glob
+	Name      string
+	Labels    map[string]string
+	Action    string // map or drop. Default: map
+	re        *regexp.Regexp
+	labelKeys []string
+}
+
+// Mapper maps Graphite paths using the first matching mapping
+type Mapper struct {
+	mappings    []Mapping
+	strictMatch bool
+}
+
+// NewMapper validates and compiles mappings. With strictMatch, paths not matching any mapping are dropped
+func NewMapper(mappings []Mapping, strictMatch bool) (*Mapper, error) {
+	m := &Mapper{
+		mappings:    make([]Mapping, len(mappings)),
+		strictMatch: strictMatch,
+	}
+	for i, mapping := range mappings {
+		if mapping.Action == "" {
+			mapping.Action = ActionMap
+		}
+		if mapping.MatchType == "" {
+			mapping.MatchType = MatchGlob
+		}
+
+		pattern := mapping.Match
+		switch mapping.MatchType {
+		case MatchGlob:
+			// * matches any characters except dot separating path nodes
+			pattern = "^" + strings.ReplaceAll(regexp.QuoteMeta(mapping.Match), `\*`, `([^.]*)`) + "$"
+		case MatchRegex:
+		default:
+			return nil, fmt.Errorf("mapping %q: unknown matchType %q", mapping.Match, mapping.MatchType)
+		}
+		var err error
+		if mapping.re, err = regexp.Compile(pattern); err != nil {
+			return nil, fmt.Errorf("mapping %q: %w", mapping.Match, err)
+		}
+
+		switch mapping.Action {
+		case ActionDrop:
+		case ActionMap:
+			if mapping.Name == "" {
+				return nil, fmt.Errorf("mapping %q: name is required", mapping.Match)
+			}
+		default:
+			return nil, fmt.Errorf("mapping %q: unknown action %q", mapping.Match, mapping.Action)
+		}
+
+		for key := range mapping.Labels {
+			if !validLabelName.MatchString(key) {
+				return nil, fmt.Errorf("mapping %q: invalid label name %q", mapping.Match, key)
+			}
+			mapping.labelKeys = append(mapping.labelKeys, key)
+		}
+		sort.Strings(mapping.labelKeys)
+		m.mappings[i] = mapping
+	}
+	return m, nil
+}
+
+// Map returns metric name and labels for path. Returns false when metric should be dropped.
+// Label keys are sorted
+func (m *Mapper) Map(path string) (string, []string, []string, bool) {
+	path, tags := splitTags(path)
+
+	name := ""
+	labels := tags
+	matched := false
+	for _, mapping := range m.mappings {
+		match := mapping.re.FindStringSubmatchIndex(path)
+		if match == nil {
+			continue
+		}
+		if mapping.Action == ActionDrop {
+			return "", nil, nil, false
+		}
+		name = string(mapping.re.ExpandString(nil, mapping.Name, path, match))
+		for _, key := range mapping.labelKeys {
+			labels[key] = string(mapping.re.ExpandString(nil, mapping.Labels[key], path, match))
+		}
+		matched = true
+		break
+	}
+	if !matched {
+		if m.strictMatch {
+			return "", nil, nil, false
+		}
+		name = path
+	}
+
+	name = sanitize(name)
+	keys := make([]string, 0, len(labels))
+	for key := range labels {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	vals := make([]string, len(keys))
+	for i, key := range keys {
+		vals[i] = labels[key]
+	}
+	return name, keys, vals, true
+}
+
+// splitTags splits Graphite tagged path (path;tag=value;...) to path and tags
+func splitTags(path string) (string, map[string]string) {
+	tags := map[string]string{}
+	parts := strings.Split(path, ";")
+	for _, tag := range parts[1:] {
+		key, value, ok := strings.Cut(tag, "=")
+		if !ok || key == "" {
+			continue
+		}
+		tags[sanitize(key)] = value
+	}
+	return parts[0], tags
+}
+
+// sanitize converts name to valid Prometheus metric or label name
+func sanitize(name string) string {
+	name = invalidNameChars.ReplaceAllString(name, "_")
+	if name == "" || (name[0] >= '0' && name[0] <= '9') {
+		name = "_" + name
+	}
+	return name
+}
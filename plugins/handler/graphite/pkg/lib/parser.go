@@ -0,0 +1,38 @@
+package lib
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// Sample is single Graphite datapoint. Path can contain Graphite tags (path;tag=value)
+type Sample struct {
+	Path      string
+	Value     float64
+	Timestamp float64
+}
+
+// ParsePlaintext parses Carbon plaintext line in format <path> <value> <timestamp>.
+// Timestamp -1 means time of receiving
+func ParsePlaintext(line []byte, now time.Time) (Sample, error) {
+	s := Sample{}
+	fields := bytes.Fields(line)
+	if len(fields) != 3 {
+		return s, fmt.Errorf("expected 3 fields, received %d", len(fields))
+	}
+	s.Path = string(fields[0])
+
+	var err error
+	if s.Value, err = strconv.ParseFloat(string(fields[1]), 64); err != nil {
+		return s, fmt.Errorf("invalid value %q", fields[1])
+	}
+	if s.Timestamp, err = strconv.ParseFloat(string(fields[2]), 64); err != nil {
+		return s, fmt.Errorf("invalid timestamp %q", fields[2])
+	}
+	if s.Timestamp == -1 {
+		s.Timestamp = float64(now.UnixNano()) / float64(time.Second)
+	}
+	return s, nil
+}
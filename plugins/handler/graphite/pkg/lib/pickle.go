@@ -0,0 +1,387 @@
+package lib
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+var errPickleTruncated = errors.New("pickle data truncated")
+
+type pickleMark struct{}
+
+// pickleList is pointer, so that APPEND modifies list shared through memo
+type pickleList struct {
+	items []interface{}
+}
+
+// pickleDecoder supports only opcodes needed to transfer lists of tuples with strings and numbers,
+// as sent by Carbon clients. Opcodes constructing arbitrary objects are rejected
+type pickleDecoder struct {
+	data  []byte
+	pos   int
+	stack []interface{}
+	memo  map[int]interface{}
+}
+
+func (d *pickleDecoder) read(n int) ([]byte, error) {
+	if n < 0 || n > len(d.data)-d.pos {
+		return nil, errPickleTruncated
+	}
+	b := d.data[d.pos : d.pos+n]
+	d.pos += n
+	return b, nil
+}
+
+func (d *pickleDecoder) readByte() (int, error) {
+	b, err := d.read(1)
+	if err != nil {
+		return 0, err
+	}
+	return int(b[0]), nil
+}
+
+func (d *pickleDecoder) readUint32() (int, error) {
+	b, err := d.read(4)
+	if err != nil {
+		return 0, err
+	}
+	return int(binary.LittleEndian.Uint32(b)), nil
+}
+
+func (d *pickleDecoder) readLine() (string, error) {
+	end := bytes.IndexByte(d.data[d.pos:], '\n')
+	if end < 0 {
+		return "", errPickleTruncated
+	}
+	line := string(d.data[d.pos : d.pos+end])
+	d.pos += end + 1
+	return line, nil
+}
+
+func (d *pickleDecoder) push(v interface{}) {
+	d.stack = append(d.stack, v)
+}
+
+func (d *pickleDecoder) pop() (interface{}, error) {
+	if len(d.stack) == 0 {
+		return nil, errors.New("pickle stack underflow")
+	}
+	v := d.stack[len(d.stack)-1]
+	d.stack = d.stack[:len(d.stack)-1]
+	return v, nil
+}
+
+func (d *pickleDecoder) top() (interface{}, error) {
+	if len(d.stack) == 0 {
+		return nil, errors.New("pickle stack underflow")
+	}
+	return d.stack[len(d.stack)-1], nil
+}
+
+// popMark pops items pushed after the last mark
+func (d *pickleDecoder) popMark() ([]interface{}, error) {
+	for i := len(d.stack) - 1; i >= 0; i-- {
+		if _, ok := d.stack[i].(pickleMark); ok {
+			items := append([]interface{}{}, d.stack[i+1:]...)
+			d.stack = d.stack[:i]
+			return items, nil
+		}
+	}
+	return nil, errors.New("pickle mark not found")
+}
+
+func (d *pickleDecoder) popN(n int) ([]interface{}, error) {
+	if len(d.stack) < n {
+		return nil, errors.New("pickle stack underflow")
+	}
+	items := append([]interface{}{}, d.stack[len(d.stack)-n:]...)
+	d.stack = d.stack[:len(d.stack)-n]
+	return items, nil
+}
+
+func (d *pickleDecoder) memoGet(idx int) error {
+	v, ok := d.memo[idx]
+	if !ok {
+		return fmt.Errorf("pickle memo %d not found", idx)
+	}
+	d.push(v)
+	return nil
+}
+
+func (d *pickleDecoder) memoPut(idx int) error {
+	v, err := d.top()
+	if err != nil {
+		return err
+	}
+	d.memo[idx] = v
+	return nil
+}
+
+func (d *pickleDecoder) appendItems(items []interface{}) error {
+	v, err := d.top()
+	if err != nil {
+		return err
+	}
+	list, ok := v.(*pickleList)
+	if !ok {
+		return errors.New("pickle append to non-list")
+	}
+	list.items = append(list.items, items...)
+	return nil
+}
+
+func (d *pickleDecoder) pushString(n int, err error) error {
+	if err != nil {
+		return err
+	}
+	b, err := d.read(n)
+	if err != nil {
+		return err
+	}
+	d.push(string(b))
+	return nil
+}
+
+// decodeLong decodes little-endian two's complement integer
+func decodeLong(b []byte) float64 {
+	var v float64
+	for i := len(b) - 1; i >= 0; i-- {
+		v = v*256 + float64(b[i])
+	}
+	if len(b) > 0 && b[len(b)-1]&0x80 != 0 {
+		v -= math.Pow(2, float64(8*len(b)))
+	}
+	return v
+}
+
+// step executes single opcode, returns true on STOP
+func (d *pickleDecoder) step(op byte) (bool, error) {
+	var err error
+	switch op {
+	case 0x80: // PROTO
+		_, err = d.readByte()
+	case 0x95: // FRAME
+		_, err = d.read(8)
+	case '.': // STOP
+		return true, nil
+	case '(': // MARK
+		d.push(pickleMark{})
+	case ']': // EMPTY_LIST
+		d.push(&pickleList{})
+	case 'l': // LIST
+		var items []interface{}
+		if items, err = d.popMark(); err == nil {
+			d.push(&pickleList{items: items})
+		}
+	case ')': // EMPTY_TUPLE
+		d.push([]interface{}{})
+	case 't': // TUPLE
+		var items []interface{}
+		if items, err = d.popMark(); err == nil {
+			d.push(items)
+		}
+	case 0x85, 0x86, 0x87: // TUPLE1, TUPLE2, TUPLE3
+		var items []interface{}
+		if items, err = d.popN(int(op - 0x84)); err == nil {
+			d.push(items)
+		}
+	case 'a': // APPEND
+		var v interface{}
+		if v, err = d.pop(); err == nil {
+			err = d.appendItems([]interface{}{v})
+		}
+	case 'e': // APPENDS
+		var items []interface{}
+		if items, err = d.popMark(); err == nil {
+			err = d.appendItems(items)
+		}
+	case 'U', 'C', 0x8c: // SHORT_BINSTRING, SHORT_BINBYTES, SHORT_BINUNICODE
+		err = d.pushString(d.readByte())
+	case 'T', 'B', 'X': // BINSTRING, BINBYTES, BINUNICODE
+		err = d.pushString(d.readUint32())
+	case 'S': // STRING
+		var line string
+		if line, err = d.readLine(); err == nil {
+			var s string
+			if s, err = strconv.Unquote(line); err != nil {
+				// repr of python string can be single-quoted
+				s = strings.Trim(line, "'")
+				err = nil
+			}
+			d.push(s)
+		}
+	case 'V': // UNICODE
+		var line string
+		if line, err = d.readLine(); err == nil {
+			d.push(line)
+		}
+	case 'J': // BININT
+		var b []byte
+		if b, err = d.read(4); err == nil {
+			d.push(float64(int32(binary.LittleEndian.Uint32(b))))
+		}
+	case 'K': // BININT1
+		var v int
+		if v, err = d.readByte(); err == nil {
+			d.push(float64(v))
+		}
+	case 'M': // BININT2
+		var b []byte
+		if b, err = d.read(2); err == nil {
+			d.push(float64(binary.LittleEndian.Uint16(b)))
+		}
+	case 'I', 'L', 'F': // INT, LONG, FLOAT
+		var line string
+		if line, err = d.readLine(); err == nil {
+			var v float64
+			if v, err = strconv.ParseFloat(strings.TrimSuffix(line, "L"), 64); err == nil {
+				d.push(v)
+			}
+		}
+	case 0x8a: // LONG1
+		var b []byte
+		if b, err = d.read(1); err == nil {
+			if b, err = d.read(int(b[0])); err == nil {
+				d.push(decodeLong(b))
+			}
+		}
+	case 0x8b: // LONG4
+		var n int
+		if n, err = d.readUint32(); err == nil {
+			var b []byte
+			if b, err = d.read(n); err == nil {
+				d.push(decodeLong(b))
+			}
+		}
+	case 'G': // BINFLOAT
+		var b []byte
+		if b, err = d.read(8); err == nil {
+			d.push(math.Float64frombits(binary.BigEndian.Uint64(b)))
+		}
+	case 'N': // NONE
+		d.push(nil)
+	case 0x88: // NEWTRUE
+		d.push(float64(1))
+	case 0x89: // NEWFALSE
+		d.push(float64(0))
+	case 'p', 'g': // PUT, GET
+		var line string
+		if line, err = d.readLine(); err == nil {
+			var idx int
+			if idx, err = strconv.Atoi(line); err == nil {
+				if op == 'p' {
+					err = d.memoPut(idx)
+				} else {
+					err = d.memoGet(idx)
+				}
+			}
+		}
+	case 'q', 'h': // BINPUT, BINGET
+		var idx int
+		if idx, err = d.readByte(); err == nil {
+			if op == 'q' {
+				err = d.memoPut(idx)
+			} else {
+				err = d.memoGet(idx)
+			}
+		}
+	case 'r', 'j': // LONG_BINPUT, LONG_BINGET
+		var idx int
+		if idx, err = d.readUint32(); err == nil {
+			if op == 'r' {
+				err = d.memoPut(idx)
+			} else {
+				err = d.memoGet(idx)
+			}
+		}
+	case 0x94: // MEMOIZE
+		err = d.memoPut(len(d.memo))
+	default:
+		err = fmt.Errorf("unsupported pickle opcode 0x%02x", op)
+	}
+	return false, err
+}
+
+// unpickle decodes pickled object built from lists, tuples, strings and numbers. Lists are
+// returned as *pickleList, tuples as []interface{} and numbers as float64
+func unpickle(data []byte) (interface{}, error) {
+	d := pickleDecoder{data: data, memo: map[int]interface{}{}}
+	for {
+		op, err := d.readByte()
+		if err != nil {
+			return nil, err
+		}
+		stop, err := d.step(byte(op))
+		if err != nil {
+			return nil, err
+		}
+		if stop {
+			return d.pop()
+		}
+	}
+}
+
+// ParsePickle parses Carbon pickle payload: list of (path, (timestamp, value)) tuples
+func ParsePickle(data []byte) ([]Sample, error) {
+	obj, err := unpickle(data)
+	if err != nil {
+		return nil, err
+	}
+	var items []interface{}
+	switch v := obj.(type) {
+	case *pickleList:
+		items = v.items
+	case []interface{}:
+		items = v
+	default:
+		return nil, errors.New("pickle payload is not a list")
+	}
+
+	samples := make([]Sample, 0, len(items))
+	for _, item := range items {
+		sample, err := pickleSample(item)
+		if err != nil {
+			return samples, err
+		}
+		samples = append(samples, sample)
+	}
+	return samples, nil
+}
+
+func pickleSample(item interface{}) (Sample, error) {
+	s := Sample{}
+	tuple, ok := item.([]interface{})
+	if !ok || len(tuple) != 2 {
+		return s, errors.New("pickle item is not (path, (timestamp, value)) tuple")
+	}
+	if s.Path, ok = tuple[0].(string); !ok || s.Path == "" {
+		return s, errors.New("pickle item path is not a string")
+	}
+	datapoint, ok := tuple[1].([]interface{})
+	if !ok || len(datapoint) != 2 {
+		return s, fmt.Errorf("%s: datapoint is not (timestamp, value) tuple", s.Path)
+	}
+	if s.Timestamp, ok = pickleNumber(datapoint[0]); !ok {
+		return s, fmt.Errorf("%s: invalid timestamp", s.Path)
+	}
+	if s.Value, ok = pickleNumber(datapoint[1]); !ok {
+		return s, fmt.Errorf("%s: invalid value", s.Path)
+	}
+	return s, nil
+}
+
+func pickleNumber(v interface{}) (float64, bool) {
+	switch n := v.(type) {
+	case float64:
+		return n, true
+	case string:
+		f, err := strconv.ParseFloat(n, 64)
+		return f, err == nil
+	}
+	return 0, false
+}
@@ -0,0 +1,53 @@
+package lib
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// payloads created by python pickle.dumps of
+// [("ceph.osd.0.op_r", (1700000000, 12.5)), ("ceph.osd.1.op_r", (1700000000, 3)), ("disk.used;host=node-1", (1700000060.5, 2**40))]
+var pickles = map[string]string{
+	"protocol 0": "(lp0\n(Vceph.osd.0.op_r\np1\n(I1700000000\nF12.5\ntp2\ntp3\na(Vceph.osd.1.op_r\np4\n(I1700000000\nI3\ntp5\ntp6\na(Vdisk.used;host=node-1\np7\n(F1700000060.5\nL1099511627776L\ntp8\ntp9\na.",
+	"protocol 2": "\x80\x02]q\x00(X\x0f\x00\x00\x00ceph.osd.0.op_rq\x01J\x00\xf1SeG@)\x00\x00\x00\x00\x00\x00\x86q\x02\x86q\x03X\x0f\x00\x00\x00ceph.osd.1.op_rq\x04J\x00\xf1SeK\x03\x86q\x05\x86q\x06X\x15\x00\x00\x00disk.used;host=node-1q\x07GA\xd9T\xfcO \x00\x00\x8a\x06\x00\x00\x00\x00\x00\x01\x86q\x08\x86q\x09e.",
+	"protocol 4": "\x80\x04\x95s\x00\x00\x00\x00\x00\x00\x00]\x94(\x8c\x0fceph.osd.0.op_r\x94J\x00\xf1SeG@)\x00\x00\x00\x00\x00\x00\x86\x94\x86\x94\x8c\x0fceph.osd.1.op_r\x94J\x00\xf1SeK\x03\x86\x94\x86\x94\x8c\x15disk.used;host=node-1\x94GA\xd9T\xfcO \x00\x00\x8a\x06\x00\x00\x00\x00\x00\x01\x86\x94\x86\x94e.",
+}
+
+func TestParsePickle(t *testing.T) {
+	t.Run("test pickle protocols", func(t *testing.T) {
+		expected := []Sample{
+			{Path: "ceph.osd.0.op_r", Timestamp: 1700000000, Value: 12.5},
+			{Path: "ceph.osd.1.op_r", Timestamp: 1700000000, Value: 3},
+			{Path: "disk.used;host=node-1", Timestamp: 1700000060.5, Value: 1099511627776},
+		}
+		for protocol, payload := range pickles {
+			samples, err := ParsePickle([]byte(payload))
+			require.NoError(t, err, protocol)
+			assert.Equal(t, expected, samples, protocol)
+		}
+
+		// python 2 str in protocol 0
+		samples, err := ParsePickle([]byte("(lp0\n(S'a.b'\np1\n(I1\nI-2\ntp2\ntp3\na."))
+		require.NoError(t, err)
+		assert.Equal(t, []Sample{{Path: "a.b", Timestamp: 1, Value: -2}}, samples)
+	})
+
+	t.Run("test invalid pickles", func(t *testing.T) {
+		for _, payload := range []string{
+			"",
+			pickles["protocol 2"][:50],
+			// os.system("true") - GLOBAL and REDUCE are not supported
+			"cos\nsystem\n(S'true'\ntR.",
+			// list of strings
+			"\x80\x02]q\x00X\x01\x00\x00\x00aa.",
+			// not a list
+			"K\x01.",
+			"\x80\x02h\x05.",
+		} {
+			_, err := ParsePickle([]byte(payload))
+			assert.Error(t, err, payload)
+		}
+	})
+}
@@ -0,0 +1,205 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/openstack-k8s-operators/sg-core/pkg/bus"
+	"github.com/openstack-k8s-operators/sg-core/pkg/config"
+	"github.com/openstack-k8s-operators/sg-core/pkg/data"
+	"github.com/openstack-k8s-operators/sg-core/pkg/handler"
+	"github.com/openstack-k8s-operators/sg-core/plugins/handler/influxdb/pkg/lib"
+)
+
+var (
+	invalidNameChars = regexp.MustCompile(`[^a-zA-Z0-9_]`)
+
+	precisionToUnit = map[string]time.Duration{
+		"ns": time.Nanosecond,
+		"us": time.Microsecond,
+		"ms": time.Millisecond,
+		"s":  time.Second,
+	}
+)
+
+type configT struct {
+	Precision string        `validate:"oneof=ns us ms s"` // precision of timestamps
+	Interval  time.Duration // expected interval of points, used for expiry of stale metrics
+	Counters  []string      // glob patterns of field keys published as counters
+}
+
+type influxdbHandler struct {
+	totalMessagesReceived uint64
+	totalMetricsDecoded   uint64
+	totalDecodeErrors     uint64
+	statsLock             sync.RWMutex
+	conf                  configT
+	now                   func() time.Time
+}
+
+// metricName joins measurement and field key, field "value" is omitted
+func metricName(measurement string, field string) string {
+	name := measurement
+	if field != "value" {
+		name += "_" + field
+	}
+	name = invalidNameChars.ReplaceAllString(name, "_")
+	if name[0] >= '0' && name[0] <= '9' {
+		name = "_" + name
+	}
+	return name
+}
+
+func (h *influxdbHandler) metricType(field string) data.MetricType {
+	for _, pattern := range h.conf.Counters {
+		if ok, _ := filepath.Match(pattern, field); ok {
+			return data.COUNTER
+		}
+	}
+	return data.GAUGE
+}
+
+func (h *influxdbHandler) publish(p lib.Point, now time.Time, mpf bus.MetricPublishFunc) {
+	var ts float64
+	if p.HasTimestamp {
+		ts = float64(p.Timestamp) * float64(precisionToUnit[h.conf.Precision]) / float64(time.Second)
+	} else {
+		ts = float64(now.UnixNano()) / float64(time.Second)
+	}
+
+	keys := make([]string, len(p.Tags))
+	vals := make([]string, len(p.Tags))
+	for i, tag := range p.Tags {
+		keys[i] = invalidNameChars.ReplaceAllString(tag.Key, "_")
+		vals[i] = tag.Value
+	}
+
+	for _, field := range p.Fields {
+		mpf(metricName(p.Measurement, field.Key), ts, h.metricType(field.Key), h.conf.Interval, field.Value, keys, vals)
+	}
+	h.statsLock.Lock()
+	h.totalMetricsDecoded += uint64(len(p.Fields))
+	h.statsLock.Unlock()
+}
+
+// Handle parses lines of InfluxDB line protocol
+func (h *influxdbHandler) Handle(blob []byte, reportErrors bool, mpf bus.MetricPublishFunc, epf bus.EventPublishFunc) error {
+	h.statsLock.Lock()
+	h.totalMessagesReceived++
+	h.statsLock.Unlock()
+
+	now := h.now()
+	for _, line := range strings.Split(string(blob), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || line[0] == '#' {
+			continue
+		}
+		p, err := lib.ParseLine(line)
+		if errors.Is(err, lib.ErrNoNumericFields) {
+			continue
+		}
+		if err != nil {
+			h.statsLock.Lock()
+			h.totalDecodeErrors++
+			h.statsLock.Unlock()
+			if reportErrors {
+				epf(data.Event{
+					Index:    h.Identify(),
+					Type:     data.ERROR,
+					Severity: data.CRITICAL,
+					Time:     0.0,
+					Labels: map[string]interface{}{
+						"error":   err.Error(),
+						"context": line,
+						"message": "failed to parse influxdb line - disregarding",
+					},
+					Annotations: map[string]interface{}{
+						"description": "internal smartgateway influxdb handler error",
+					},
+				})
+			}
+			continue
+		}
+		h.publish(p, now, mpf)
+	}
+	return nil
+}
+
+// Run send internal metrics to bus
+func (h *influxdbHandler) Run(ctx context.Context, mpf bus.MetricPublishFunc, _ bus.EventPublishFunc) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(time.Second):
+			h.statsLock.RLock()
+			mpf(
+				"sg_total_influxdb_msg_received_count",
+				0,
+				data.COUNTER,
+				0,
+				float64(h.totalMessagesReceived),
+				[]string{"source"},
+				[]string{"SG"},
+			)
+			mpf(
+				"sg_total_influxdb_metric_decode_count",
+				0,
+				data.COUNTER,
+				0,
+				float64(h.totalMetricsDecoded),
+				[]string{"source"},
+				[]string{"SG"},
+			)
+			mpf(
+				"sg_total_influxdb_decode_error_count",
+				0,
+				data.COUNTER,
+				0,
+				float64(h.totalDecodeErrors),
+				[]string{"source"},
+				[]string{"SG"},
+			)
+			h.statsLock.RUnlock()
+		}
+	}
+}
+
+func (h *influxdbHandler) Identify() string {
+	return "influxdb"
+}
+
+func (h *influxdbHandler) Config(c []byte) error {
+	h.conf = configT{
+		Precision: "ns",
+		Interval:  10 * time.Second,
+	}
+	err := config.ParseConfig(bytes.NewReader(c), &h.conf)
+	if err != nil {
+		return err
+	}
+	for _, pattern := range h.conf.Counters {
+		if _, err := filepath.Match(pattern, ""); err != nil {
+			return fmt.Errorf("invalid counter pattern %q: %w", pattern, err)
+		}
+	}
+	return nil
+}
+
+// New create new influxdbHandler object
+func New() handler.Handler {
+	return &influxdbHandler{
+		conf: configT{
+			Precision: "ns",
+			Interval:  10 * time.Second,
+		},
+		now: time.Now,
+	}
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/openstack-k8s-operators/sg-core/pkg/data"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var received = time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+
+func newTestHandler(t *testing.T, conf string) *influxdbHandler {
+	h := New().(*influxdbHandler)
+	require.NoError(t, h.Config([]byte(conf)))
+	h.now = func() time.Time {
+		return received
+	}
+	return h
+}
+
+func handle(t *testing.T, h *influxdbHandler, blob string) ([]data.Metric, []data.Event) {
+	metrics := []data.Metric{}
+	events := []data.Event{}
+	err := h.Handle([]byte(blob), true, func(name string, mTime float64, mType data.MetricType, interval time.Duration, value float64, labelKeys []string, labelVals []string) {
+		metrics = append(metrics, data.Metric{
+			Name:      name,
+			Time:      mTime,
+			Type:      mType,
+			Interval:  interval,
+			Value:     value,
+			LabelKeys: labelKeys,
+			LabelVals: labelVals,
+		})
+	}, func(e data.Event) {
+		events = append(events, e)
+	})
+	require.NoError(t, err)
+	return metrics, events
+}
+
+func TestInfluxDB(t *testing.T) {
+	t.Run("test metrics from points", func(t *testing.T) {
+		h := newTestHandler(t, "counters: [\"bytes_*\", packets]\n")
+		metrics, events := handle(t, h, "# telegraf\n"+
+			"net,host=compute-0,interface=eth0 bytes_recv=1024i,packets=8i,err_in=0i 1700000000500000000\n"+
+			"\n"+
+			"temperature,sensor=1-a value=21.5\n"+
+			"syslog,host=compute-0 message=\"skipped\" 1700000000000000000\n")
+		assert.Empty(t, events)
+		labels := []string{"host", "interface"}
+		values := []string{"compute-0", "eth0"}
+		assert.Equal(t, []data.Metric{
+			{Name: "net_bytes_recv", Time: 1700000000.5, Type: data.COUNTER, Interval: 10 * time.Second, Value: 1024, LabelKeys: labels, LabelVals: values},
+			{Name: "net_packets", Time: 1700000000.5, Type: data.COUNTER, Interval: 10 * time.Second, Value: 8, LabelKeys: labels, LabelVals: values},
+			{Name: "net_err_in", Time: 1700000000.5, Type: data.GAUGE, Interval: 10 * time.Second, Value: 0, LabelKeys: labels, LabelVals: values},
+			{Name: "temperature", Time: float64(received.Unix()), Type: data.GAUGE, Interval: 10 * time.Second, Value: 21.5,
+				LabelKeys: []string{"sensor"}, LabelVals: []string{"1-a"}},
+		}, metrics)
+		assert.Equal(t, uint64(4), h.totalMetricsDecoded)
+	})
+
+	t.Run("test precision and naming", func(t *testing.T) {
+		h := newTestHandler(t, "precision: s\ninterval: 1m\n")
+		metrics, _ := handle(t, h, "disk\\ io,dev.name=sda 2xx=5 1700000000")
+		assert.Equal(t, []data.Metric{
+			{Name: "disk_io_2xx", Time: 1700000000, Type: data.GAUGE, Interval: time.Minute, Value: 5,
+				LabelKeys: []string{"dev_name"}, LabelVals: []string{"sda"}},
+		}, metrics)
+
+		metrics, _ = handle(t, h, "5xx,code=500 value=1 1700000000")
+		require.Len(t, metrics, 1)
+		assert.Equal(t, "_5xx", metrics[0].Name)
+	})
+
+	t.Run("test invalid lines", func(t *testing.T) {
+		h := newTestHandler(t, "")
+		metrics, events := handle(t, h, "cpu value=1\ncpu value=one\ncpu\n")
+		assert.Len(t, metrics, 1)
+		require.Len(t, events, 2)
+		assert.Equal(t, data.ERROR, events[0].Type)
+		assert.Equal(t, uint64(2), h.totalDecodeErrors)
+	})
+
+	t.Run("test invalid configuration", func(t *testing.T) {
+		for _, conf := range []string{
+			"precision: h",
+			"counters: [\"[\"]",
+		} {
+			assert.Error(t, New().Config([]byte(conf)), conf)
+		}
+	})
+}
@@ -0,0 +1,167 @@
+package lib
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Tag of InfluxDB point
+type Tag struct {
+	Key   string
+	Value string
+}
+
+// Field of InfluxDB point. Integer, unsigned and boolean values are converted to float
+type Field struct {
+	Key   string
+	Value float64
+}
+
+// Point is single line of InfluxDB line protocol
+type Point struct {
+	Measurement string
+	// Tags are sorted by key
+	Tags []Tag
+	// Fields contain only numeric and boolean fields, string fields are skipped
+	Fields []Field
+	// Timestamp in precision of the sender, valid only when HasTimestamp is true
+	Timestamp    int64
+	HasTimestamp bool
+}
+
+// ErrNoNumericFields is returned for valid lines containing only string fields
+var ErrNoNumericFields = errors.New("no numeric fields")
+
+// scan reads token until one of unescaped stop characters. Backslash escapes any of ",= \"\\"
+func scan(line string, i int, stops string) (string, int) {
+	var b strings.Builder
+	for i < len(line) {
+		c := line[i]
+		if c == '\\' && i+1 < len(line) && strings.IndexByte(",= \"\\", line[i+1]) >= 0 {
+			b.WriteByte(line[i+1])
+			i += 2
+			continue
+		}
+		if strings.IndexByte(stops, c) >= 0 {
+			break
+		}
+		b.WriteByte(c)
+		i++
+	}
+	return b.String(), i
+}
+
+// scanString reads double-quoted string field value starting at i
+func scanString(line string, i int) (string, int, error) {
+	var b strings.Builder
+	for i++; i < len(line); i++ {
+		c := line[i]
+		if c == '\\' && i+1 < len(line) && (line[i+1] == '"' || line[i+1] == '\\') {
+			i++
+			b.WriteByte(line[i])
+			continue
+		}
+		if c == '"' {
+			return b.String(), i + 1, nil
+		}
+		b.WriteByte(c)
+	}
+	return "", i, errors.New("unterminated string field")
+}
+
+func parseFieldValue(raw string) (float64, error) {
+	switch raw {
+	case "t", "T", "true", "True", "TRUE":
+		return 1, nil
+	case "f", "F", "false", "False", "FALSE":
+		return 0, nil
+	}
+	switch {
+	case strings.HasSuffix(raw, "i"):
+		v, err := strconv.ParseInt(raw[:len(raw)-1], 10, 64)
+		return float64(v), err
+	case strings.HasSuffix(raw, "u"):
+		v, err := strconv.ParseUint(raw[:len(raw)-1], 10, 64)
+		return float64(v), err
+	}
+	return strconv.ParseFloat(raw, 64)
+}
+
+// ParseLine parses line in format <measurement>[,<tag>=<value>...] <field>=<value>[,<field>=<value>...] [<timestamp>]
+func ParseLine(line string) (Point, error) {
+	p := Point{}
+	var i int
+	p.Measurement, i = scan(line, 0, ", ")
+	if p.Measurement == "" {
+		return p, errors.New("missing measurement")
+	}
+
+	for i < len(line) && line[i] == ',' {
+		var tag Tag
+		tag.Key, i = scan(line, i+1, ",= ")
+		if i >= len(line) || line[i] != '=' || tag.Key == "" {
+			return p, fmt.Errorf("invalid tag %q", tag.Key)
+		}
+		tag.Value, i = scan(line, i+1, ", ")
+		if tag.Value == "" {
+			return p, fmt.Errorf("missing value of tag %q", tag.Key)
+		}
+		p.Tags = append(p.Tags, tag)
+	}
+	sort.SliceStable(p.Tags, func(a, b int) bool {
+		return p.Tags[a].Key < p.Tags[b].Key
+	})
+
+	if i >= len(line) || line[i] != ' ' {
+		return p, errors.New("missing fields")
+	}
+	for i < len(line) && line[i] == ' ' {
+		i++
+	}
+
+	for {
+		var key string
+		key, i = scan(line, i, ",= ")
+		if i >= len(line) || line[i] != '=' || key == "" {
+			return p, fmt.Errorf("invalid field %q", key)
+		}
+		i++
+		if i < len(line) && line[i] == '"' {
+			var err error
+			if _, i, err = scanString(line, i); err != nil {
+				return p, err
+			}
+		} else {
+			var raw string
+			raw, i = scan(line, i, ", ")
+			value, err := parseFieldValue(raw)
+			if err != nil {
+				return p, fmt.Errorf("invalid value of field %q: %q", key, raw)
+			}
+			p.Fields = append(p.Fields, Field{Key: key, Value: value})
+		}
+		if i >= len(line) || line[i] != ',' {
+			break
+		}
+		i++
+	}
+
+	rest := strings.TrimSpace(line[i:])
+	if i < len(line) && line[i] != ' ' {
+		return p, fmt.Errorf("unexpected characters after fields: %q", rest)
+	}
+	if rest != "" {
+		var err error
+		if p.Timestamp, err = strconv.ParseInt(rest, 10, 64); err != nil {
+			return p, fmt.Errorf("invalid timestamp %q", rest)
+		}
+		p.HasTimestamp = true
+	}
+	if len(p.Fields) == 0 {
+		return p, ErrNoNumericFields
+	}
+	return p, nil
+}
@@ -0,0 +1,62 @@
+package lib
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseLine(t *testing.T) {
+	t.Run("test valid lines", func(t *testing.T) {
+		for line, expected := range map[string]Point{
+			"cpu,host=compute-0,cpu=cpu0 usage_idle=98.5,usage_user=1.2 1700000000000000000": {
+				Measurement: "cpu",
+				Tags:        []Tag{{Key: "cpu", Value: "cpu0"}, {Key: "host", Value: "compute-0"}},
+				Fields:      []Field{{Key: "usage_idle", Value: 98.5}, {Key: "usage_user", Value: 1.2}},
+				Timestamp:   1700000000000000000, HasTimestamp: true,
+			},
+			"net bytes_recv=123i,drop_in=7u,up=true,down=F": {
+				Measurement: "net",
+				Fields: []Field{
+					{Key: "bytes_recv", Value: 123}, {Key: "drop_in", Value: 7}, {Key: "up", Value: 1}, {Key: "down", Value: 0},
+				},
+			},
+			`disk\ io,path=/var/lib\,x,label=a\=b\ c state="ok, \"fine\"",used=-1.5e3  42`: {
+				Measurement: "disk io",
+				Tags:        []Tag{{Key: "label", Value: "a=b c"}, {Key: "path", Value: "/var/lib,x"}},
+				Fields:      []Field{{Key: "used", Value: -1500}},
+				Timestamp:   42, HasTimestamp: true,
+			},
+		} {
+			p, err := ParseLine(line)
+			require.NoError(t, err, line)
+			assert.Equal(t, expected, p, line)
+		}
+	})
+
+	t.Run("test line with string fields only", func(t *testing.T) {
+		_, err := ParseLine(`syslog,host=a message="started" 1`)
+		assert.ErrorIs(t, err, ErrNoNumericFields)
+	})
+
+	t.Run("test invalid lines", func(t *testing.T) {
+		for _, line := range []string{
+			"cpu",
+			",host=a value=1",
+			"cpu,host value=1",
+			"cpu,host= value=1",
+			"cpu,host=a",
+			"cpu value",
+			"cpu =1",
+			"cpu value=abc",
+			"cpu value=1x",
+			`cpu message="unterminated`,
+			"cpu value=1 yesterday",
+			`cpu message="a"b 1`,
+		} {
+			_, err := ParseLine(line)
+			assert.Error(t, err, line)
+		}
+	})
+}
@@ -0,0 +1,225 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/openstack-k8s-operators/sg-core/pkg/bus"
+	"github.com/openstack-k8s-operators/sg-core/pkg/config"
+	"github.com/openstack-k8s-operators/sg-core/pkg/data"
+	"github.com/openstack-k8s-operators/sg-core/pkg/framing"
+	"github.com/openstack-k8s-operators/sg-core/pkg/handler"
+	"github.com/openstack-k8s-operators/sg-core/plugins/handler/journal/pkg/lib"
+	logslib "github.com/openstack-k8s-operators/sg-core/plugins/handler/logs/pkg/lib"
+)
+
+var invalidLabelChars = regexp.MustCompile(`[^a-zA-Z0-9_]`)
+
+// labelFields are journal fields always published as labels
+var labelFields = map[string]string{
+	"SYSLOG_IDENTIFIER": "syslog_identifier",
+	"_SYSTEMD_UNIT":     "systemd_unit",
+}
+
+type configT struct {
+	IndexPrefix string   `yaml:"indexPrefix"`
+	Fields      []string `yaml:"fields"` // additional journal fields published as labels
+}
+
+type journalHandler struct {
+	totalMessagesReceived uint64
+	totalLogsDecoded      uint64
+	totalDecodeErrors     uint64
+	statsLock             sync.RWMutex
+	conf                  configT
+	framer                framing.Framer
+	now                   func() time.Time
+}
+
+func (j *journalHandler) reportError(err error, context string, epf bus.EventPublishFunc) {
+	j.statsLock.Lock()
+	j.totalDecodeErrors++
+	j.statsLock.Unlock()
+	if epf == nil {
+		return
+	}
+	epf(data.Event{
+		Index:    j.Identify(),
+		Type:     data.ERROR,
+		Severity: data.CRITICAL,
+		Time:     0.0,
+		Labels: map[string]interface{}{
+			"error":   err.Error(),
+			"context": context,
+			"message": "failed to parse journal entry - disregarding",
+		},
+		Annotations: map[string]interface{}{
+			"description": "internal smartgateway journal handler error",
+		},
+	})
+}
+
+// fieldLabel creates label name from journal field name, e.g. _SYSTEMD_SLICE becomes systemd_slice
+func fieldLabel(field string) string {
+	return invalidLabelChars.ReplaceAllString(strings.ToLower(strings.TrimLeft(field, "_")), "_")
+}
+
+func (j *journalHandler) parse(blob []byte) (data.Event, error) {
+	entry, err := lib.ParseEntry(blob)
+	if err != nil {
+		return data.Event{}, err
+	}
+	msg, ok := entry["MESSAGE"]
+	if !ok {
+		return data.Event{}, fmt.Errorf("entry has no MESSAGE field")
+	}
+
+	hostname := entry["_HOSTNAME"]
+	if hostname == "" {
+		hostname = "unknown"
+	}
+	labels := map[string]interface{}{"host": hostname}
+	for _, field := range j.conf.Fields {
+		if value, ok := entry[field]; ok {
+			labels[fieldLabel(field)] = value
+		}
+	}
+	for field, label := range labelFields {
+		if value, ok := entry[field]; ok {
+			labels[label] = value
+		}
+	}
+
+	severity := logslib.UNKNOWN
+	if priority, err := strconv.Atoi(entry["PRIORITY"]); err == nil && priority >= 0 && priority < int(logslib.UNKNOWN) {
+		severity = logslib.SyslogSeverity(priority)
+		labels["priority"] = entry["PRIORITY"]
+	}
+
+	t, ok := entry.Time()
+	if !ok {
+		t = j.now()
+	}
+	year, month, day := t.UTC().Date()
+	return data.Event{
+		Index:     fmt.Sprintf("%s-%s.%d.%02d.%02d", j.conf.IndexPrefix, strings.ReplaceAll(hostname, "-", "_"), year, month, day),
+		Time:      float64(t.UnixNano()) / float64(time.Second),
+		Type:      data.LOG,
+		Publisher: hostname,
+		Severity:  severity.ToEventSeverity(),
+		Labels:    labels,
+		Message:   msg,
+	}, nil
+}
+
+func (j *journalHandler) handleEntry(blob []byte, errf bus.EventPublishFunc, epf bus.EventPublishFunc) error {
+	log, err := j.parse(blob)
+	if err != nil {
+		j.reportError(err, string(blob), errf)
+		return err
+	}
+	epf(log)
+	j.statsLock.Lock()
+	j.totalLogsDecoded++
+	j.statsLock.Unlock()
+	return nil
+}
+
+// Handle parses one or more entries of journal export format. Entries are separated by empty line,
+// the last one does not need to be terminated
+func (j *journalHandler) Handle(msg []byte, reportErrors bool, _ bus.MetricPublishFunc, epf bus.EventPublishFunc) error {
+	j.statsLock.Lock()
+	j.totalMessagesReceived++
+	j.statsLock.Unlock()
+	errf := epf
+	if !reportErrors {
+		errf = nil
+	}
+
+	var lastErr error
+	consumed, err := j.framer.Split(msg, func(entry []byte) {
+		if err := j.handleEntry(entry, errf, epf); err != nil {
+			lastErr = err
+		}
+	})
+	if err != nil {
+		// entries following invalid one cannot be located
+		j.reportError(err, string(msg[consumed:]), errf)
+		return err
+	}
+	if rest := msg[consumed:]; len(rest) > 0 {
+		if err := j.handleEntry(rest, errf, epf); err != nil {
+			lastErr = err
+		}
+	}
+	return lastErr
+}
+
+// Run send internal metrics to bus
+func (j *journalHandler) Run(ctx context.Context, mpf bus.MetricPublishFunc, _ bus.EventPublishFunc) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(time.Second):
+			j.statsLock.RLock()
+			mpf(
+				"sg_total_journal_msg_received_count",
+				0,
+				data.COUNTER,
+				0,
+				float64(j.totalMessagesReceived),
+				[]string{"source"},
+				[]string{"SG"},
+			)
+			mpf(
+				"sg_total_journal_log_decode_count",
+				0,
+				data.COUNTER,
+				0,
+				float64(j.totalLogsDecoded),
+				[]string{"source"},
+				[]string{"SG"},
+			)
+			mpf(
+				"sg_total_journal_decode_error_count",
+				0,
+				data.COUNTER,
+				0,
+				float64(j.totalDecodeErrors),
+				[]string{"source"},
+				[]string{"SG"},
+			)
+			j.statsLock.RUnlock()
+		}
+	}
+}
+
+func (j *journalHandler) Identify() string {
+	return "journal"
+}
+
+func (j *journalHandler) Config(c []byte) error {
+	j.conf = configT{
+		IndexPrefix: "sglogs",
+	}
+	return config.ParseConfig(bytes.NewReader(c), &j.conf)
+}
+
+// New create new journalHandler object
+func New() handler.Handler {
+	framer, _ := framing.Get(framing.JournalExport)
+	return &journalHandler{
+		conf: configT{
+			IndexPrefix: "sglogs",
+		},
+		framer: framer,
+		now:    time.Now,
+	}
+}
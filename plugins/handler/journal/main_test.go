@@ -0,0 +1,109 @@
+package main
+
+import (
+	"encoding/binary"
+	"testing"
+	"time"
+
+	"github.com/openstack-k8s-operators/sg-core/pkg/data"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var received = time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+
+func export(entries ...[]byte) []byte {
+	blob := []byte{}
+	for _, e := range entries {
+		blob = append(append(blob, e...), '\n')
+	}
+	return blob
+}
+
+func binaryField(name string, value string) []byte {
+	b := append([]byte(name), '\n')
+	b = binary.LittleEndian.AppendUint64(b, uint64(len(value)))
+	return append(append(b, value...), '\n')
+}
+
+func TestJournal(t *testing.T) {
+	events := []data.Event{}
+	epf := func(e data.Event) { events = append(events, e) }
+
+	first := []byte(`__CURSOR=s=739ad463348b4ceca5a9e69c95a3c93f;i=4ece7;b=6c7c6013a8
+__REALTIME_TIMESTAMP=1709294400500000
+__MONOTONIC_TIMESTAMP=7620391
+_BOOT_ID=6c7c6013a8
+PRIORITY=4
+_HOSTNAME=compute-0
+SYSLOG_IDENTIFIER=nova-compute
+_SYSTEMD_UNIT=tripleo_nova_compute.service
+_PID=2048
+_TRANSPORT=stdout
+MESSAGE=disk almost full
+`)
+	second := append([]byte("__REALTIME_TIMESTAMP=1709294401000000\n_HOSTNAME=compute-0\nPRIORITY=7\n"),
+		binaryField("MESSAGE", "multi\n\nline")...)
+
+	t.Run("test entries", func(t *testing.T) {
+		j := New().(*journalHandler)
+		require.NoError(t, j.Config([]byte("fields: [_PID, _SYSTEMD_SLICE]")))
+
+		events = events[:0]
+		// last entry may lack terminating empty line, as do entries split by socket framing
+		require.NoError(t, j.Handle(append(export(first), second...), true, nil, epf))
+		require.Len(t, events, 2)
+		assert.Equal(t, data.Event{
+			Index:     "sglogs-compute_0.2024.03.01",
+			Time:      1709294400.5,
+			Type:      data.LOG,
+			Publisher: "compute-0",
+			Severity:  data.WARNING,
+			Labels: map[string]interface{}{
+				"host":              "compute-0",
+				"syslog_identifier": "nova-compute",
+				"systemd_unit":      "tripleo_nova_compute.service",
+				"priority":          "4",
+				"pid":               "2048",
+			},
+			Message: "disk almost full",
+		}, events[0])
+		assert.Equal(t, "multi\n\nline", events[1].Message)
+		assert.Equal(t, data.DEBUG, events[1].Severity)
+		assert.Equal(t, uint64(2), j.totalLogsDecoded)
+
+		j.now = func() time.Time { return received }
+		events = events[:0]
+		require.NoError(t, j.Handle([]byte("MESSAGE=kernel: oops\n"), true, nil, epf))
+		require.Len(t, events, 1)
+		assert.Equal(t, data.Event{
+			Index:     "sglogs-unknown.2024.03.01",
+			Time:      float64(received.Unix()),
+			Type:      data.LOG,
+			Publisher: "unknown",
+			Severity:  data.UNKNOWN,
+			Labels:    map[string]interface{}{"host": "unknown"},
+			Message:   "kernel: oops",
+		}, events[0])
+	})
+
+	t.Run("test invalid entries", func(t *testing.T) {
+		j := New().(*journalHandler)
+		require.NoError(t, j.Config([]byte("indexPrefix: journal")))
+
+		events = events[:0]
+		// entry without message does not prevent publishing the others
+		assert.Error(t, j.Handle(export([]byte("_HOSTNAME=compute-0\n"), first), true, nil, epf))
+		require.Len(t, events, 2)
+		assert.Equal(t, data.ERROR, events[0].Type)
+		assert.Equal(t, "_HOSTNAME=compute-0\n", events[0].Labels["context"])
+		assert.Equal(t, "journal-compute_0.2024.03.01", events[1].Index)
+
+		events = events[:0]
+		assert.Error(t, j.Handle(export([]byte("MESSAGE\n\xff\xff\xff\xff\xff\xff\xff\xff\n")), true, nil, epf))
+		assert.Error(t, j.Handle([]byte("MESSAGE\n\x05\x00"), false, nil, epf))
+		require.Len(t, events, 1)
+		assert.Equal(t, data.ERROR, events[0].Type)
+		assert.Equal(t, uint64(3), j.totalDecodeErrors)
+	})
+}
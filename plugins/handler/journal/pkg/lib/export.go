@@ -0,0 +1,71 @@
+package lib
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// Entry holds fields of single journal entry. Only the first value of fields repeated in the entry is kept
+type Entry map[string]string
+
+// ParseEntry parses single entry of journal export format. Text fields have form NAME=value, binary
+// fields are the name on its own line followed by 64-bit little-endian length, the data and a newline
+func ParseEntry(blob []byte) (Entry, error) {
+	entry := Entry{}
+	pos := 0
+	for pos < len(blob) {
+		idx := bytes.IndexByte(blob[pos:], '\n')
+		if idx < 0 {
+			// last field may lack newline
+			idx = len(blob) - pos
+		}
+		line := blob[pos : pos+idx]
+		pos += idx + 1
+		if len(line) == 0 {
+			return nil, fmt.Errorf("unexpected empty line in entry")
+		}
+
+		name, value, text := bytes.Cut(line, []byte("="))
+		if !text {
+			if len(blob) < pos+8 {
+				return nil, fmt.Errorf("truncated length of binary field %q", name)
+			}
+			length := binary.LittleEndian.Uint64(blob[pos:])
+			pos += 8
+			if length > uint64(len(blob)-pos) {
+				return nil, fmt.Errorf("truncated binary field %q", name)
+			}
+			value = blob[pos : pos+int(length)]
+			pos += int(length)
+			if pos < len(blob) {
+				if blob[pos] != '\n' {
+					return nil, fmt.Errorf("binary field %q not terminated by newline", name)
+				}
+				pos++
+			}
+		}
+		if len(name) == 0 {
+			return nil, fmt.Errorf("empty field name")
+		}
+		if _, ok := entry[string(name)]; !ok {
+			entry[string(name)] = string(value)
+		}
+	}
+	if len(entry) == 0 {
+		return nil, fmt.Errorf("empty entry")
+	}
+	return entry, nil
+}
+
+// Time returns time of the entry from __REALTIME_TIMESTAMP, or _SOURCE_REALTIME_TIMESTAMP, in microseconds
+func (e Entry) Time() (time.Time, bool) {
+	for _, field := range []string{"__REALTIME_TIMESTAMP", "_SOURCE_REALTIME_TIMESTAMP"} {
+		if usec, err := strconv.ParseInt(e[field], 10, 64); err == nil {
+			return time.UnixMicro(usec), true
+		}
+	}
+	return time.Time{}, false
+}
@@ -0,0 +1,59 @@
+package lib
+
+import (
+	"encoding/binary"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func binaryField(name string, value string) []byte {
+	b := append([]byte(name), '\n')
+	b = binary.LittleEndian.AppendUint64(b, uint64(len(value)))
+	return append(append(b, value...), '\n')
+}
+
+func TestParseEntry(t *testing.T) {
+	blob := []byte("__CURSOR=s=abc;i=1\n__REALTIME_TIMESTAMP=1709294400123456\n_HOSTNAME=compute-0\nEMPTY=\nPRIORITY=6\nPRIORITY=3\n")
+	blob = append(blob, binaryField("MESSAGE", "line one\n\nline=two\x00")...)
+	blob = append(blob, binaryField("_CMDLINE", "")...)
+
+	entry, err := ParseEntry(blob)
+	require.NoError(t, err)
+	assert.Equal(t, Entry{
+		"__CURSOR":             "s=abc;i=1",
+		"__REALTIME_TIMESTAMP": "1709294400123456",
+		"_HOSTNAME":            "compute-0",
+		"EMPTY":                "",
+		"PRIORITY":             "6",
+		"MESSAGE":              "line one\n\nline=two\x00",
+		"_CMDLINE":             "",
+	}, entry)
+	ts, ok := entry.Time()
+	assert.True(t, ok)
+	assert.Equal(t, time.UnixMicro(1709294400123456), ts)
+
+	// trailing newline is optional
+	entry, err = ParseEntry([]byte("_SOURCE_REALTIME_TIMESTAMP=1000000\nMESSAGE=hi"))
+	require.NoError(t, err)
+	assert.Equal(t, "hi", entry["MESSAGE"])
+	ts, ok = entry.Time()
+	assert.True(t, ok)
+	assert.Equal(t, time.Unix(1, 0), ts)
+	_, ok = Entry{"__REALTIME_TIMESTAMP": "x"}.Time()
+	assert.False(t, ok)
+
+	for _, invalid := range [][]byte{
+		{},
+		[]byte("A=1\n\nB=2\n"),
+		[]byte("=value\n"),
+		[]byte("MESSAGE\n\x05\x00"),
+		[]byte("MESSAGE\n\x05\x00\x00\x00\x00\x00\x00\x00abc"),
+		append(binaryField("MESSAGE", "abc")[:19], 'x'),
+	} {
+		_, err := ParseEntry(invalid)
+		assert.Error(t, err, "%q", invalid)
+	}
+}
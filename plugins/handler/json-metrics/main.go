@@ -0,0 +1,378 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/openstack-k8s-operators/sg-core/pkg/bus"
+	"github.com/openstack-k8s-operators/sg-core/pkg/config"
+	"github.com/openstack-k8s-operators/sg-core/pkg/data"
+	"github.com/openstack-k8s-operators/sg-core/pkg/handler"
+	"github.com/openstack-k8s-operators/sg-core/plugins/handler/json-metrics/pkg/lib"
+)
+
+var (
+	invalidNameChars = regexp.MustCompile(`[^a-zA-Z0-9_:]`)
+	validLabelName   = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
+
+	timestampUnits = map[string]time.Duration{
+		"ns": time.Nanosecond,
+		"us": time.Microsecond,
+		"ms": time.Millisecond,
+		"s":  time.Second,
+	}
+
+	metricTypes = map[string]data.MetricType{
+		"gauge":   data.GAUGE,
+		"counter": data.COUNTER,
+		"untyped": data.UNTYPED,
+	}
+)
+
+type configT struct {
+	Samples         string            // path to samples, the message itself or its elements when empty
+	Name            string            `validate:"required"` // template of metric name
+	Value           string            `validate:"required"` // path to value
+	Timestamp       string            // path to timestamp, time of receiving is used when empty
+	TimestampUnit   string            `yaml:"timestampUnit" validate:"oneof=ns us ms s"` // unit of numeric timestamps
+	Type            string            // path to metric type
+	DefaultType     string            `yaml:"defaultType" validate:"oneof=gauge counter untyped"`
+	Interval        string            // path to interval in seconds or as duration string
+	DefaultInterval time.Duration     `yaml:"defaultInterval"`
+	Labels          map[string]string // label names to templates of values
+}
+
+// mapping is compiled configuration
+type mapping struct {
+	samples   *lib.Path
+	name      lib.Template
+	value     lib.Path
+	timestamp *lib.Path
+	mType     *lib.Path
+	interval  *lib.Path
+	labelKeys []string
+	labels    []lib.Template
+}
+
+type jsonMetricsHandler struct {
+	totalMessagesReceived uint64
+	totalMetricsDecoded   uint64
+	totalMetricsDropped   uint64
+	totalDecodeErrors     uint64
+	statsLock             sync.RWMutex
+	conf                  configT
+	mapping               mapping
+	now                   func() time.Time
+}
+
+func defaultConfig() configT {
+	return configT{
+		Name:            "{name}",
+		Value:           "value",
+		TimestampUnit:   "s",
+		DefaultType:     "gauge",
+		DefaultInterval: 10 * time.Second,
+	}
+}
+
+func (j *jsonMetricsHandler) reportError(err error, context string, epf bus.EventPublishFunc) {
+	if epf == nil {
+		return
+	}
+	epf(data.Event{
+		Index:    j.Identify(),
+		Type:     data.ERROR,
+		Severity: data.CRITICAL,
+		Time:     0.0,
+		Labels: map[string]interface{}{
+			"error":   err.Error(),
+			"context": context,
+			"message": "failed to map JSON metric - disregarding",
+		},
+		Annotations: map[string]interface{}{
+			"description": "internal smartgateway json-metrics handler error",
+		},
+	})
+}
+
+// metricName replaces characters not allowed in Prometheus metric names
+func metricName(name string) string {
+	name = invalidNameChars.ReplaceAllString(name, "_")
+	if name != "" && name[0] >= '0' && name[0] <= '9' {
+		name = "_" + name
+	}
+	return name
+}
+
+// seconds converts time to fractional seconds without losing precision of the fraction
+func seconds(t time.Time) float64 {
+	return float64(t.Unix()) + float64(t.Nanosecond())/float64(time.Second)
+}
+
+func (j *jsonMetricsHandler) timestamp(root interface{}, sample interface{}, now time.Time) (float64, error) {
+	if j.mapping.timestamp == nil {
+		return seconds(now), nil
+	}
+	v, ok := j.mapping.timestamp.First(root, sample)
+	if !ok {
+		return seconds(now), nil
+	}
+	if s, ok := v.(string); ok {
+		if t, err := time.Parse(time.RFC3339Nano, s); err == nil {
+			return seconds(t), nil
+		}
+	}
+	ts, err := lib.ToFloat(v)
+	if err != nil {
+		return 0, fmt.Errorf("timestamp: %w", err)
+	}
+	return ts / float64(time.Second/timestampUnits[j.conf.TimestampUnit]), nil
+}
+
+func (j *jsonMetricsHandler) metricType(root interface{}, sample interface{}) (data.MetricType, error) {
+	name := j.conf.DefaultType
+	if j.mapping.mType != nil {
+		if v, ok := j.mapping.mType.First(root, sample); ok {
+			s, _ := v.(string)
+			name = strings.ToLower(s)
+		}
+	}
+	mType, ok := metricTypes[name]
+	if !ok {
+		return data.UNTYPED, fmt.Errorf("unknown metric type %q", name)
+	}
+	return mType, nil
+}
+
+func (j *jsonMetricsHandler) interval(root interface{}, sample interface{}) (time.Duration, error) {
+	if j.mapping.interval == nil {
+		return j.conf.DefaultInterval, nil
+	}
+	v, ok := j.mapping.interval.First(root, sample)
+	if !ok {
+		return j.conf.DefaultInterval, nil
+	}
+	if s, ok := v.(string); ok {
+		if d, err := time.ParseDuration(s); err == nil {
+			return d, nil
+		}
+	}
+	seconds, err := lib.ToFloat(v)
+	if err != nil {
+		return 0, fmt.Errorf("interval: %w", err)
+	}
+	return time.Duration(seconds * float64(time.Second)), nil
+}
+
+// publish maps single sample to metric
+func (j *jsonMetricsHandler) publish(root interface{}, sample interface{}, now time.Time, mpf bus.MetricPublishFunc) error {
+	name, err := j.mapping.name.Execute(root, sample)
+	if err != nil {
+		return fmt.Errorf("name: %w", err)
+	}
+	name = metricName(name)
+	if name == "" {
+		return fmt.Errorf("empty metric name")
+	}
+	v, ok := j.mapping.value.First(root, sample)
+	if !ok {
+		return fmt.Errorf("value not found under path %q", j.conf.Value)
+	}
+	value, err := lib.ToFloat(v)
+	if err != nil {
+		return fmt.Errorf("value: %w", err)
+	}
+	ts, err := j.timestamp(root, sample, now)
+	if err != nil {
+		return err
+	}
+	mType, err := j.metricType(root, sample)
+	if err != nil {
+		return err
+	}
+	interval, err := j.interval(root, sample)
+	if err != nil {
+		return err
+	}
+
+	vals := make([]string, len(j.mapping.labels))
+	for i, tmpl := range j.mapping.labels {
+		// missing label values are published empty to keep label sets consistent
+		vals[i], _ = tmpl.Execute(root, sample)
+	}
+	mpf(name, ts, mType, interval, value, append([]string{}, j.mapping.labelKeys...), vals)
+	return nil
+}
+
+// Handle maps samples of JSON message to metrics
+func (j *jsonMetricsHandler) Handle(blob []byte, reportErrors bool, mpf bus.MetricPublishFunc, epf bus.EventPublishFunc) error {
+	j.statsLock.Lock()
+	j.totalMessagesReceived++
+	j.statsLock.Unlock()
+	errf := epf
+	if !reportErrors {
+		errf = nil
+	}
+
+	var root interface{}
+	err := json.Unmarshal(blob, &root)
+	if err != nil {
+		j.statsLock.Lock()
+		j.totalDecodeErrors++
+		j.statsLock.Unlock()
+		j.reportError(err, string(blob), errf)
+		return err
+	}
+
+	var samples []interface{}
+	if j.mapping.samples != nil {
+		samples = j.mapping.samples.Get(root, root)
+	} else if arr, ok := root.([]interface{}); ok {
+		samples = arr
+	} else {
+		samples = []interface{}{root}
+	}
+
+	now := j.now()
+	var decoded, dropped uint64
+	for _, sample := range samples {
+		err = j.publish(root, sample, now, mpf)
+		if err != nil {
+			dropped++
+			context, _ := json.Marshal(sample)
+			j.reportError(err, string(context), errf)
+			continue
+		}
+		decoded++
+	}
+	j.statsLock.Lock()
+	j.totalMetricsDecoded += decoded
+	j.totalMetricsDropped += dropped
+	j.statsLock.Unlock()
+	return nil
+}
+
+// Run send internal metrics to bus
+func (j *jsonMetricsHandler) Run(ctx context.Context, mpf bus.MetricPublishFunc, _ bus.EventPublishFunc) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(time.Second):
+			j.statsLock.RLock()
+			mpf(
+				"sg_total_json_metrics_msg_received_count",
+				0,
+				data.COUNTER,
+				0,
+				float64(j.totalMessagesReceived),
+				[]string{"source"},
+				[]string{"SG"},
+			)
+			mpf(
+				"sg_total_json_metrics_metric_decode_count",
+				0,
+				data.COUNTER,
+				0,
+				float64(j.totalMetricsDecoded),
+				[]string{"source"},
+				[]string{"SG"},
+			)
+			mpf(
+				"sg_total_json_metrics_metric_dropped_count",
+				0,
+				data.COUNTER,
+				0,
+				float64(j.totalMetricsDropped),
+				[]string{"source"},
+				[]string{"SG"},
+			)
+			mpf(
+				"sg_total_json_metrics_decode_error_count",
+				0,
+				data.COUNTER,
+				0,
+				float64(j.totalDecodeErrors),
+				[]string{"source"},
+				[]string{"SG"},
+			)
+			j.statsLock.RUnlock()
+		}
+	}
+}
+
+func (j *jsonMetricsHandler) Identify() string {
+	return "json-metrics"
+}
+
+// optionalPath compiles path unless expression is empty
+func optionalPath(expr string) (*lib.Path, error) {
+	if expr == "" {
+		return nil, nil
+	}
+	p, err := lib.CompilePath(expr)
+	if err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+func (j *jsonMetricsHandler) Config(c []byte) error {
+	j.conf = defaultConfig()
+	err := config.ParseConfig(bytes.NewReader(c), &j.conf)
+	if err != nil {
+		return err
+	}
+
+	m := mapping{}
+	if m.samples, err = optionalPath(j.conf.Samples); err != nil {
+		return fmt.Errorf("samples: %w", err)
+	}
+	if m.name, err = lib.CompileTemplate(j.conf.Name); err != nil {
+		return fmt.Errorf("name: %w", err)
+	}
+	if m.value, err = lib.CompilePath(j.conf.Value); err != nil {
+		return fmt.Errorf("value: %w", err)
+	}
+	if m.timestamp, err = optionalPath(j.conf.Timestamp); err != nil {
+		return fmt.Errorf("timestamp: %w", err)
+	}
+	if m.mType, err = optionalPath(j.conf.Type); err != nil {
+		return fmt.Errorf("type: %w", err)
+	}
+	if m.interval, err = optionalPath(j.conf.Interval); err != nil {
+		return fmt.Errorf("interval: %w", err)
+	}
+	for key := range j.conf.Labels {
+		if !validLabelName.MatchString(key) {
+			return fmt.Errorf("invalid label name %q", key)
+		}
+		m.labelKeys = append(m.labelKeys, key)
+	}
+	sort.Strings(m.labelKeys)
+	for _, key := range m.labelKeys {
+		tmpl, err := lib.CompileTemplate(j.conf.Labels[key])
+		if err != nil {
+			return fmt.Errorf("label %s: %w", key, err)
+		}
+		m.labels = append(m.labels, tmpl)
+	}
+	j.mapping = m
+	return nil
+}
+
+// New create new jsonMetricsHandler object
+func New() handler.Handler {
+	j := &jsonMetricsHandler{
+		now: time.Now,
+	}
+	_ = j.Config(nil)
+	return j
+}
@@ -0,0 +1,137 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/openstack-k8s-operators/sg-core/pkg/data"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var received = time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+
+type capture struct {
+	metrics []data.Metric
+	events  []data.Event
+}
+
+func (c *capture) handle(j *jsonMetricsHandler, blob string) error {
+	c.metrics = c.metrics[:0]
+	c.events = c.events[:0]
+	return j.Handle([]byte(blob), true, func(name string, mTime float64, mType data.MetricType, interval time.Duration, value float64, labelKeys []string, labelVals []string) {
+		c.metrics = append(c.metrics, data.Metric{
+			Name:      name,
+			Time:      mTime,
+			Type:      mType,
+			Interval:  interval,
+			Value:     value,
+			LabelKeys: labelKeys,
+			LabelVals: labelVals,
+		})
+	}, func(e data.Event) {
+		c.events = append(c.events, e)
+	})
+}
+
+func newHandler(t *testing.T, conf string) *jsonMetricsHandler {
+	j := New().(*jsonMetricsHandler)
+	require.NoError(t, j.Config([]byte(conf)))
+	j.now = func() time.Time { return received }
+	return j
+}
+
+func TestJSONMetrics(t *testing.T) {
+	c := &capture{}
+
+	t.Run("test default mapping", func(t *testing.T) {
+		j := newHandler(t, "")
+		require.NoError(t, c.handle(j, `[{"name":"queue.depth","value":3},{"name":"workers","value":"2"}]`))
+		assert.Equal(t, []data.Metric{
+			{Name: "queue_depth", Time: float64(received.Unix()), Type: data.GAUGE, Interval: 10 * time.Second, Value: 3,
+				LabelKeys: []string{}, LabelVals: []string{}},
+			{Name: "workers", Time: float64(received.Unix()), Type: data.GAUGE, Interval: 10 * time.Second, Value: 2,
+				LabelKeys: []string{}, LabelVals: []string{}},
+		}, c.metrics)
+		assert.Empty(t, c.events)
+	})
+
+	t.Run("test declarative mapping", func(t *testing.T) {
+		j := newHandler(t, `
+samples: checks[*].results[*]
+name: "agent_{$.agent}_{metric}"
+value: data.current
+timestamp: $.time
+timestampUnit: ms
+type: kind
+defaultType: untyped
+interval: $.every
+labels:
+    host: "{$.host}"
+    device: "{tags.device}"
+    check: "{name}"
+`)
+		require.NoError(t, c.handle(j, `{"agent":"probe","host":"compute-0","time":1709294400500,"every":"30s","checks":[
+ {"results":[
+  {"metric":"disk.used","kind":"Gauge","data":{"current":0.5},"tags":{"device":"sda"},"name":"disk"},
+  {"metric":"io","kind":"counter","data":{"current":true},"name":"disk"}
+ ]},
+ {"results":[
+  {"metric":"errors","data":{"current":7},"name":"net"},
+  {"metric":"broken","data":{},"name":"net"},
+  {"metric":"bad","kind":"histogram","data":{"current":1},"name":"net"}
+ ]}
+]}`))
+		metric := func(name string, mType data.MetricType, value float64, device string, check string) data.Metric {
+			return data.Metric{Name: name, Time: 1709294400.5, Type: mType, Interval: 30 * time.Second, Value: value,
+				LabelKeys: []string{"check", "device", "host"}, LabelVals: []string{check, device, "compute-0"}}
+		}
+		assert.Equal(t, []data.Metric{
+			metric("agent_probe_disk_used", data.GAUGE, 0.5, "sda", "disk"),
+			metric("agent_probe_io", data.COUNTER, 1, "", "disk"),
+			metric("agent_probe_errors", data.UNTYPED, 7, "", "net"),
+		}, c.metrics)
+		require.Len(t, c.events, 2)
+		assert.Equal(t, data.ERROR, c.events[0].Type)
+		assert.Equal(t, `{"data":{},"metric":"broken","name":"net"}`, c.events[0].Labels["context"])
+		assert.Equal(t, uint64(3), j.totalMetricsDecoded)
+		assert.Equal(t, uint64(2), j.totalMetricsDropped)
+	})
+
+	t.Run("test timestamps and intervals", func(t *testing.T) {
+		j := newHandler(t, "timestamp: ts\ninterval: every")
+		require.NoError(t, c.handle(j, `[
+{"name":"a","value":1,"ts":"2024-03-01T12:00:01.25Z","every":60},
+{"name":"b","value":1,"ts":1709294402}]`))
+		require.Len(t, c.metrics, 2)
+		assert.Equal(t, 1709294401.25, c.metrics[0].Time)
+		assert.Equal(t, time.Minute, c.metrics[0].Interval)
+		assert.Equal(t, 1709294402.0, c.metrics[1].Time)
+		assert.Equal(t, 10*time.Second, c.metrics[1].Interval)
+
+		require.NoError(t, c.handle(j, `{"name":"a","value":1,"ts":"yesterday"}`))
+		assert.Empty(t, c.metrics)
+		assert.Len(t, c.events, 1)
+	})
+
+	t.Run("test invalid messages and configuration", func(t *testing.T) {
+		j := newHandler(t, "")
+		assert.Error(t, c.handle(j, `{"name":`))
+		require.Len(t, c.events, 1)
+		assert.Equal(t, `{"name":`, c.events[0].Labels["context"])
+		assert.Equal(t, uint64(1), j.totalDecodeErrors)
+
+		for _, conf := range []string{
+			"name: \"\"",
+			"name: \"{a\"",
+			"value: \"a[\"",
+			"samples: \"..\"",
+			"timestampUnit: h",
+			"defaultType: histogram",
+			"labels:\n    0bad: \"{a}\"",
+			"labels:\n    ok: \"{a\"",
+		} {
+			assert.Error(t, j.Config([]byte(conf)), conf)
+		}
+	})
+}
@@ -0,0 +1,130 @@
+package lib
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// wildcard index selects all elements of an array
+const wildcard = -1
+
+type segment struct {
+	key     string
+	indexes []int
+}
+
+// Path selects values from decoded JSON document. Paths are dot separated object keys, each optionally
+// followed by array indexes, e.g. "metrics[*].value" or "values[0]". Paths are relative to the current
+// sample, unless they start with "$", which refers to the whole message
+type Path struct {
+	expr     string
+	absolute bool
+	segments []segment
+}
+
+// CompilePath parses path expression
+func CompilePath(expr string) (Path, error) {
+	p := Path{expr: expr}
+	rest := expr
+	if after, ok := strings.CutPrefix(rest, "$"); ok {
+		p.absolute = true
+		rest = strings.TrimPrefix(after, ".")
+	}
+	if rest == "" {
+		if !p.absolute {
+			return p, fmt.Errorf("empty path")
+		}
+		return p, nil
+	}
+	for _, part := range strings.Split(rest, ".") {
+		key, indexes := part, ""
+		if i := strings.IndexByte(part, '['); i >= 0 {
+			key, indexes = part[:i], part[i:]
+		}
+		if strings.ContainsRune(key, ']') {
+			return p, fmt.Errorf("invalid key %q in path %q", key, expr)
+		}
+		seg := segment{key: key}
+		for indexes != "" {
+			inner, after, found := strings.Cut(indexes[1:], "]")
+			if indexes[0] != '[' || !found {
+				return p, fmt.Errorf("invalid index in path %q", expr)
+			}
+			if inner == "*" {
+				seg.indexes = append(seg.indexes, wildcard)
+			} else {
+				idx, err := strconv.Atoi(inner)
+				if err != nil || idx < 0 {
+					return p, fmt.Errorf("invalid index %q in path %q", inner, expr)
+				}
+				seg.indexes = append(seg.indexes, idx)
+			}
+			indexes = after
+		}
+		if seg.key == "" && len(seg.indexes) == 0 {
+			return p, fmt.Errorf("empty key in path %q", expr)
+		}
+		p.segments = append(p.segments, seg)
+	}
+	return p, nil
+}
+
+// String returns path expression
+func (p Path) String() string {
+	return p.expr
+}
+
+// Get returns all values selected by the path, wildcards may select multiple values
+func (p Path) Get(root interface{}, current interface{}) []interface{} {
+	values := []interface{}{current}
+	if p.absolute {
+		values[0] = root
+	}
+	for _, seg := range p.segments {
+		next := []interface{}{}
+		for _, v := range values {
+			if seg.key != "" {
+				obj, ok := v.(map[string]interface{})
+				if !ok {
+					continue
+				}
+				if v, ok = obj[seg.key]; !ok {
+					continue
+				}
+			}
+			next = append(next, index(v, seg.indexes)...)
+		}
+		values = next
+	}
+	return values
+}
+
+// First returns the first value selected by the path
+func (p Path) First(root interface{}, current interface{}) (interface{}, bool) {
+	values := p.Get(root, current)
+	if len(values) == 0 {
+		return nil, false
+	}
+	return values[0], true
+}
+
+func index(v interface{}, indexes []int) []interface{} {
+	values := []interface{}{v}
+	for _, idx := range indexes {
+		next := []interface{}{}
+		for _, v := range values {
+			arr, ok := v.([]interface{})
+			if !ok {
+				continue
+			}
+			if idx == wildcard {
+				next = append(next, arr...)
+			} else if idx < len(arr) {
+				next = append(next, arr[idx])
+			}
+		}
+		values = next
+	}
+	return values
+}
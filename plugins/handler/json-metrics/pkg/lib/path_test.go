@@ -0,0 +1,88 @@
+package lib
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPath(t *testing.T) {
+	var doc interface{}
+	require.NoError(t, json.Unmarshal([]byte(`{"host":"compute-0","metrics":[
+{"name":"cpu","values":[1,2],"tags":{"core":"0"}},
+{"name":"mem","values":[[3],[4]]}
+]}`), &doc))
+	sample := doc.(map[string]interface{})["metrics"].([]interface{})[0]
+
+	for _, tc := range []struct {
+		expr     string
+		expected []interface{}
+	}{
+		{"$", []interface{}{doc}},
+		{"$.host", []interface{}{"compute-0"}},
+		{"metrics[*].name", []interface{}{"cpu", "mem"}},
+		{"metrics[1].values[*][0]", []interface{}{3.0, 4.0}},
+		{"metrics[*].values[1]", []interface{}{2.0, []interface{}{4.0}}},
+		{"metrics[2].name", []interface{}{}},
+		{"metrics.name", []interface{}{}},
+		{"missing", []interface{}{}},
+	} {
+		p, err := CompilePath(tc.expr)
+		require.NoError(t, err, tc.expr)
+		assert.Equal(t, tc.expected, p.Get(doc, doc), tc.expr)
+	}
+
+	p, err := CompilePath("tags.core")
+	require.NoError(t, err)
+	v, ok := p.First(doc, sample)
+	assert.True(t, ok)
+	assert.Equal(t, "0", v)
+	_, ok = p.First(doc, doc)
+	assert.False(t, ok)
+
+	for _, expr := range []string{"", "a..b", "a[", "a[x]", "a[-1]", "a]b", "a[0]x"} {
+		_, err := CompilePath(expr)
+		assert.Error(t, err, expr)
+	}
+}
+
+func TestTemplate(t *testing.T) {
+	var doc interface{}
+	require.NoError(t, json.Unmarshal([]byte(`{"host":"compute-0","plugin":"cpu","idx":3,"ok":true,"obj":{}}`), &doc))
+
+	for text, expected := range map[string]string{
+		"literal":                "literal",
+		"{plugin}_{idx}":         "cpu_3",
+		"{$.host}/{ok}/{plugin}": "compute-0/true/cpu",
+		"":                       "",
+	} {
+		tmpl, err := CompileTemplate(text)
+		require.NoError(t, err, text)
+		s, err := tmpl.Execute(doc, doc)
+		require.NoError(t, err, text)
+		assert.Equal(t, expected, s)
+	}
+
+	for _, text := range []string{"{plugin", "plugin}", "{}", "{a[x]}"} {
+		_, err := CompileTemplate(text)
+		assert.Error(t, err, text)
+	}
+	for _, text := range []string{"{missing}", "{obj}"} {
+		tmpl, err := CompileTemplate(text)
+		require.NoError(t, err)
+		_, err = tmpl.Execute(doc, doc)
+		assert.Error(t, err, text)
+	}
+
+	for v, expected := range map[interface{}]float64{1.5: 1.5, true: 1, false: 0, "2e3": 2000} {
+		f, err := ToFloat(v)
+		require.NoError(t, err)
+		assert.Equal(t, expected, f)
+	}
+	_, err := ToFloat("x")
+	assert.Error(t, err)
+	_, err = ToFloat(nil)
+	assert.Error(t, err)
+}
@@ -0,0 +1,91 @@
+package lib
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Template is text with path expressions in braces, e.g. "{plugin}_{type}", substituted by selected values
+type Template struct {
+	literals []string
+	paths    []Path
+}
+
+// CompileTemplate parses template text
+func CompileTemplate(text string) (Template, error) {
+	t := Template{}
+	rest := text
+	for {
+		before, after, found := strings.Cut(rest, "{")
+		if strings.Contains(before, "}") {
+			return t, fmt.Errorf("unexpected '}' in template %q", text)
+		}
+		t.literals = append(t.literals, before)
+		if !found {
+			return t, nil
+		}
+		expr, after, found := strings.Cut(after, "}")
+		if !found {
+			return t, fmt.Errorf("unterminated path in template %q", text)
+		}
+		p, err := CompilePath(expr)
+		if err != nil {
+			return t, err
+		}
+		t.paths = append(t.paths, p)
+		rest = after
+	}
+}
+
+// Execute substitutes paths with values selected from the sample. Paths have to select scalar values
+func (t Template) Execute(root interface{}, current interface{}) (string, error) {
+	var sb strings.Builder
+	for i, literal := range t.literals {
+		sb.WriteString(literal)
+		if i == len(t.paths) {
+			break
+		}
+		v, ok := t.paths[i].First(root, current)
+		if !ok {
+			return "", fmt.Errorf("path %q not found", t.paths[i])
+		}
+		s, err := ToString(v)
+		if err != nil {
+			return "", fmt.Errorf("path %q: %w", t.paths[i], err)
+		}
+		sb.WriteString(s)
+	}
+	return sb.String(), nil
+}
+
+// ToString converts scalar JSON value to string
+func ToString(v interface{}) (string, error) {
+	switch value := v.(type) {
+	case string:
+		return value, nil
+	case float64:
+		return strconv.FormatFloat(value, 'f', -1, 64), nil
+	case bool:
+		return strconv.FormatBool(value), nil
+	case nil:
+		return "", nil
+	}
+	return "", fmt.Errorf("value of type %T is not scalar", v)
+}
+
+// ToFloat converts JSON number, boolean or numeric string to float
+func ToFloat(v interface{}) (float64, error) {
+	switch value := v.(type) {
+	case float64:
+		return value, nil
+	case bool:
+		if value {
+			return 1, nil
+		}
+		return 0, nil
+	case string:
+		return strconv.ParseFloat(value, 64)
+	}
+	return 0, fmt.Errorf("value of type %T is not numeric", v)
+}
@@ -0,0 +1,520 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/openstack-k8s-operators/sg-core/pkg/bus"
+	"github.com/openstack-k8s-operators/sg-core/pkg/config"
+	"github.com/openstack-k8s-operators/sg-core/pkg/data"
+	"github.com/openstack-k8s-operators/sg-core/pkg/handler"
+	"github.com/openstack-k8s-operators/sg-core/pkg/otlp"
+	"github.com/openstack-k8s-operators/sg-core/plugins/handler/otlp/pkg/lib"
+)
+
+var (
+	invalidNameChars  = regexp.MustCompile(`[^a-zA-Z0-9_:]`)
+	invalidLabelChars = regexp.MustCompile(`[^a-zA-Z0-9_]`)
+
+	errUnknownPayload = errors.New("message is neither OTLP envelope nor OTLP JSON")
+)
+
+type configT struct {
+	Interval           time.Duration // expected export interval, used for expiry of stale metrics
+	IndexPrefix        string        `yaml:"indexPrefix"`
+	ResourceAttributes []string      `yaml:"resourceAttributes"` // resource attributes used as labels, all when empty
+}
+
+// deltaSeries accumulates delta temporality data points into cumulative counter
+type deltaSeries struct {
+	value float64
+	seen  time.Time
+}
+
+type otlpHandler struct {
+	totalMessagesReceived uint64
+	totalMetricsDecoded   uint64
+	totalMetricsDropped   uint64
+	totalLogsDecoded      uint64
+	totalDecodeErrors     uint64
+	statsLock             sync.RWMutex
+	conf                  configT
+	resourceAttributes    map[string]bool
+	deltas                map[string]*deltaSeries
+	lastPurge             time.Time
+	now                   func() time.Time
+}
+
+func (o *otlpHandler) reportError(err error, context string, epf bus.EventPublishFunc) {
+	o.statsLock.Lock()
+	o.totalDecodeErrors++
+	o.statsLock.Unlock()
+	if epf == nil {
+		return
+	}
+	epf(data.Event{
+		Index:    o.Identify(),
+		Type:     data.ERROR,
+		Severity: data.CRITICAL,
+		Time:     0.0,
+		Labels: map[string]interface{}{
+			"error":   err.Error(),
+			"context": context,
+			"message": "failed to decode OTLP payload - disregarding",
+		},
+		Annotations: map[string]interface{}{
+			"description": "internal smartgateway otlp handler error",
+		},
+	})
+}
+
+// attrString converts attribute value to label value
+func attrString(v interface{}) string {
+	switch value := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return value
+	case bool:
+		return strconv.FormatBool(value)
+	case int64:
+		return strconv.FormatInt(value, 10)
+	case float64:
+		return strconv.FormatFloat(value, 'g', -1, 64)
+	case []byte:
+		return base64.StdEncoding.EncodeToString(value)
+	}
+	raw, _ := json.Marshal(v)
+	return string(raw)
+}
+
+func labelName(key string) string {
+	return invalidLabelChars.ReplaceAllString(key, "_")
+}
+
+func metricName(name string) string {
+	name = invalidNameChars.ReplaceAllString(name, "_")
+	if name != "" && name[0] >= '0' && name[0] <= '9' {
+		name = "_" + name
+	}
+	return name
+}
+
+// resourceLabels returns labels of resource attributes allowed by configuration
+func (o *otlpHandler) resourceLabels(attrs []lib.Attribute) map[string]string {
+	labels := map[string]string{}
+	for _, a := range attrs {
+		if len(o.resourceAttributes) == 0 || o.resourceAttributes[a.Key] {
+			labels[labelName(a.Key)] = attrString(a.Value)
+		}
+	}
+	return labels
+}
+
+// series merges resource labels with data point attributes and extra label. Data point attributes
+// override resource attributes
+func series(resource map[string]string, attrs []lib.Attribute, extra ...string) ([]string, []string) {
+	labels := make(map[string]string, len(resource)+len(attrs)+1)
+	for k, v := range resource {
+		labels[k] = v
+	}
+	for _, a := range attrs {
+		labels[labelName(a.Key)] = attrString(a.Value)
+	}
+	for i := 0; i+1 < len(extra); i += 2 {
+		labels[extra[i]] = extra[i+1]
+	}
+	keys := make([]string, 0, len(labels))
+	for k := range labels {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	vals := make([]string, len(keys))
+	for i, k := range keys {
+		vals[i] = labels[k]
+	}
+	return keys, vals
+}
+
+// cumulative converts delta value of counter series into cumulative one
+func (o *otlpHandler) cumulative(name string, keys []string, vals []string, delta float64) float64 {
+	id := name + "\xff" + strings.Join(keys, "\xff") + "\xff" + strings.Join(vals, "\xff")
+	ds, ok := o.deltas[id]
+	if !ok {
+		ds = &deltaSeries{}
+		o.deltas[id] = ds
+	}
+	ds.value += delta
+	ds.seen = o.now()
+	return ds.value
+}
+
+// purgeDeltas forgets accumulated series which were not updated for a long time
+func (o *otlpHandler) purgeDeltas() {
+	now := o.now()
+	expiry := 10 * o.conf.Interval
+	if now.Sub(o.lastPurge) < o.conf.Interval {
+		return
+	}
+	o.lastPurge = now
+	for id, ds := range o.deltas {
+		if now.Sub(ds.seen) > expiry {
+			delete(o.deltas, id)
+		}
+	}
+}
+
+// publishMetric publishes data points of metric and returns number of published and dropped values
+func (o *otlpHandler) publishMetric(m lib.Metric, resource map[string]string, mpf bus.MetricPublishFunc) (uint64, uint64) {
+	name := metricName(m.Name)
+	if name == "" {
+		return 0, uint64(len(m.Points) + len(m.Histograms) + len(m.Summaries))
+	}
+	delta := m.Temporality == lib.Delta
+	var published, dropped uint64
+	publish := func(name string, ts uint64, mType data.MetricType, value float64, keys []string, vals []string) {
+		if delta && (mType == data.COUNTER || m.Kind == lib.Sum) {
+			value = o.cumulative(name, keys, vals, value)
+		}
+		data.SetMetricHelp(name, m.Description)
+		mpf(name, float64(ts)/1e9, mType, o.conf.Interval, value, keys, vals)
+		published++
+	}
+
+	switch m.Kind {
+	case lib.Gauge, lib.Sum:
+		mType := data.GAUGE
+		if m.Kind == lib.Sum && m.Monotonic {
+			mType = data.COUNTER
+			if !strings.HasSuffix(name, "_total") {
+				name += "_total"
+			}
+		}
+		for _, p := range m.Points {
+			if p.Flags&lib.FlagNoRecordedValue != 0 {
+				dropped++
+				continue
+			}
+			keys, vals := series(resource, p.Attributes)
+			publish(name, p.Time, mType, p.Value, keys, vals)
+		}
+	case lib.Histogram:
+		for _, p := range m.Histograms {
+			// histograms without buckets have neither counts nor bounds
+			hasBuckets := len(p.BucketCounts) > 0 || len(p.Bounds) > 0
+			if p.Flags&lib.FlagNoRecordedValue != 0 || (hasBuckets && len(p.BucketCounts) != len(p.Bounds)+1) {
+				dropped++
+				continue
+			}
+			cumulative := uint64(0)
+			for i, bound := range p.Bounds {
+				cumulative += p.BucketCounts[i]
+				keys, vals := series(resource, p.Attributes, "le", strconv.FormatFloat(bound, 'g', -1, 64))
+				publish(name+"_bucket", p.Time, data.COUNTER, float64(cumulative), keys, vals)
+			}
+			keys, vals := series(resource, p.Attributes, "le", "+Inf")
+			publish(name+"_bucket", p.Time, data.COUNTER, float64(p.Count), keys, vals)
+			keys, vals = series(resource, p.Attributes)
+			publish(name+"_count", p.Time, data.COUNTER, float64(p.Count), keys, vals)
+			if p.HasSum {
+				publish(name+"_sum", p.Time, data.COUNTER, p.Sum, keys, vals)
+			}
+		}
+	case lib.Summary:
+		for _, p := range m.Summaries {
+			if p.Flags&lib.FlagNoRecordedValue != 0 {
+				dropped++
+				continue
+			}
+			for _, q := range p.Quantiles {
+				keys, vals := series(resource, p.Attributes, "quantile", strconv.FormatFloat(q.Quantile, 'g', -1, 64))
+				publish(name, p.Time, data.GAUGE, q.Value, keys, vals)
+			}
+			keys, vals := series(resource, p.Attributes)
+			publish(name+"_count", p.Time, data.COUNTER, float64(p.Count), keys, vals)
+			publish(name+"_sum", p.Time, data.COUNTER, p.Sum, keys, vals)
+		}
+	default:
+		// exponential histograms are not supported
+		dropped++
+	}
+	return published, dropped
+}
+
+// severity maps OTLP severity number to event severity
+func severity(number int32) data.EventSeverity {
+	switch {
+	case number >= 17:
+		return data.CRITICAL
+	case number >= 13:
+		return data.WARNING
+	case number >= 9:
+		return data.INFO
+	case number >= 1:
+		return data.DEBUG
+	}
+	return data.UNKNOWN
+}
+
+// labelValue converts attribute value to value of event label
+func labelValue(v interface{}) interface{} {
+	switch value := v.(type) {
+	case []byte:
+		return base64.StdEncoding.EncodeToString(value)
+	case float64:
+		if math.IsNaN(value) || math.IsInf(value, 0) {
+			return attrString(value)
+		}
+	}
+	return v
+}
+
+func (o *otlpHandler) logEvent(resource []lib.Attribute, scope lib.Scope, r lib.LogRecord) data.Event {
+	labels := map[string]interface{}{}
+	for _, a := range resource {
+		if len(o.resourceAttributes) == 0 || o.resourceAttributes[a.Key] {
+			labels[labelName(a.Key)] = labelValue(a.Value)
+		}
+	}
+	for _, a := range r.Attributes {
+		labels[labelName(a.Key)] = labelValue(a.Value)
+	}
+	extra := map[string]string{
+		"severity":   r.SeverityText,
+		"scope":      scope.Name,
+		"event_name": r.EventName,
+		"trace_id":   hex.EncodeToString(r.TraceID),
+		"span_id":    hex.EncodeToString(r.SpanID),
+	}
+	for k, v := range extra {
+		if v != "" {
+			labels[k] = v
+		}
+	}
+
+	host := "unknown"
+	for _, a := range resource {
+		if a.Key == "host.name" && attrString(a.Value) != "" {
+			host = attrString(a.Value)
+			break
+		}
+		if a.Key == "service.name" && attrString(a.Value) != "" {
+			host = attrString(a.Value)
+		}
+	}
+
+	t := o.now()
+	if r.Time != 0 {
+		t = time.Unix(0, int64(r.Time))
+	} else if r.ObservedTime != 0 {
+		t = time.Unix(0, int64(r.ObservedTime))
+	}
+	year, month, day := t.UTC().Date()
+
+	message, ok := r.Body.(string)
+	if !ok && r.Body != nil {
+		message = attrString(r.Body)
+	}
+	return data.Event{
+		Index:     fmt.Sprintf("%s-%s.%d.%02d.%02d", o.conf.IndexPrefix, strings.ReplaceAll(host, "-", "_"), year, month, day),
+		Time:      float64(t.UnixNano()) / float64(time.Second),
+		Type:      data.LOG,
+		Publisher: host,
+		Severity:  severity(r.SeverityNumber),
+		Labels:    labels,
+		Message:   message,
+	}
+}
+
+func (o *otlpHandler) handleMetrics(resources []lib.ResourceMetrics, mpf bus.MetricPublishFunc) {
+	var published, dropped uint64
+	o.purgeDeltas()
+	for _, rm := range resources {
+		labels := o.resourceLabels(rm.Resource)
+		for _, sm := range rm.Scopes {
+			for _, m := range sm.Metrics {
+				p, d := o.publishMetric(m, labels, mpf)
+				published += p
+				dropped += d
+			}
+		}
+	}
+	o.statsLock.Lock()
+	o.totalMetricsDecoded += published
+	o.totalMetricsDropped += dropped
+	o.statsLock.Unlock()
+}
+
+func (o *otlpHandler) handleLogs(resources []lib.ResourceLogs, epf bus.EventPublishFunc) {
+	count := uint64(0)
+	for _, rl := range resources {
+		for _, sl := range rl.Scopes {
+			for _, r := range sl.Records {
+				epf(o.logEvent(rl.Resource, sl.Scope, r))
+				count++
+			}
+		}
+	}
+	o.statsLock.Lock()
+	o.totalLogsDecoded += count
+	o.statsLock.Unlock()
+}
+
+// Handle decodes OTLP payloads wrapped by the otlp transport or OTLP JSON received by other transports
+func (o *otlpHandler) Handle(blob []byte, reportErrors bool, mpf bus.MetricPublishFunc, epf bus.EventPublishFunc) error {
+	o.statsLock.Lock()
+	o.totalMessagesReceived++
+	o.statsLock.Unlock()
+	errf := epf
+	if !reportErrors {
+		errf = nil
+	}
+
+	signal, encoding, payload, ok := otlp.Unwrap(blob)
+	if !ok {
+		metrics, logs := lib.DetectJSONSignal(blob)
+		switch {
+		case metrics:
+			signal = otlp.Metrics
+		case logs:
+			signal = otlp.Logs
+		default:
+			o.reportError(errUnknownPayload, string(blob), errf)
+			return nil
+		}
+		encoding, payload = otlp.JSON, blob
+	}
+
+	var err error
+	switch signal {
+	case otlp.Metrics:
+		var resources []lib.ResourceMetrics
+		if encoding == otlp.Protobuf {
+			resources, err = lib.DecodeMetricsProtobuf(payload)
+		} else {
+			resources, err = lib.DecodeMetricsJSON(payload)
+		}
+		if err == nil {
+			o.handleMetrics(resources, mpf)
+		}
+	case otlp.Logs:
+		var resources []lib.ResourceLogs
+		if encoding == otlp.Protobuf {
+			resources, err = lib.DecodeLogsProtobuf(payload)
+		} else {
+			resources, err = lib.DecodeLogsJSON(payload)
+		}
+		if err == nil {
+			o.handleLogs(resources, epf)
+		}
+	}
+	if err != nil {
+		o.reportError(err, fmt.Sprintf("%s %s", signal, encoding), errf)
+	}
+	return nil
+}
+
+// Run send internal metrics to bus
+func (o *otlpHandler) Run(ctx context.Context, mpf bus.MetricPublishFunc, _ bus.EventPublishFunc) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(time.Second):
+			o.statsLock.RLock()
+			mpf(
+				"sg_total_otlp_msg_received_count",
+				0,
+				data.COUNTER,
+				0,
+				float64(o.totalMessagesReceived),
+				[]string{"source"},
+				[]string{"SG"},
+			)
+			mpf(
+				"sg_total_otlp_metric_decode_count",
+				0,
+				data.COUNTER,
+				0,
+				float64(o.totalMetricsDecoded),
+				[]string{"source"},
+				[]string{"SG"},
+			)
+			mpf(
+				"sg_total_otlp_metric_dropped_count",
+				0,
+				data.COUNTER,
+				0,
+				float64(o.totalMetricsDropped),
+				[]string{"source"},
+				[]string{"SG"},
+			)
+			mpf(
+				"sg_total_otlp_log_decode_count",
+				0,
+				data.COUNTER,
+				0,
+				float64(o.totalLogsDecoded),
+				[]string{"source"},
+				[]string{"SG"},
+			)
+			mpf(
+				"sg_total_otlp_decode_error_count",
+				0,
+				data.COUNTER,
+				0,
+				float64(o.totalDecodeErrors),
+				[]string{"source"},
+				[]string{"SG"},
+			)
+			o.statsLock.RUnlock()
+		}
+	}
+}
+
+func (o *otlpHandler) Identify() string {
+	return "otlp"
+}
+
+func (o *otlpHandler) Config(c []byte) error {
+	o.conf = configT{
+		Interval:    time.Minute,
+		IndexPrefix: "sglogs",
+	}
+	err := config.ParseConfig(bytes.NewReader(c), &o.conf)
+	if err != nil {
+		return err
+	}
+	if o.conf.Interval <= 0 {
+		return errors.New("interval has to be positive")
+	}
+	o.resourceAttributes = map[string]bool{}
+	for _, attr := range o.conf.ResourceAttributes {
+		o.resourceAttributes[attr] = true
+	}
+	return nil
+}
+
+// New create new otlpHandler object
+func New() handler.Handler {
+	return &otlpHandler{
+		conf: configT{
+			Interval:    time.Minute,
+			IndexPrefix: "sglogs",
+		},
+		deltas: map[string]*deltaSeries{},
+		now:    time.Now,
+	}
+}
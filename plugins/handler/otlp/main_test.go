@@ -0,0 +1,164 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/openstack-k8s-operators/sg-core/pkg/data"
+	"github.com/openstack-k8s-operators/sg-core/pkg/otlp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+const metricsJSON = `{"resourceMetrics":[{"resource":{"attributes":[
+ {"key":"service.name","value":{"stringValue":"cinder-volume"}},
+ {"key":"process.pid","value":{"intValue":"42"}}]},
+"scopeMetrics":[{"metrics":[
+ {"name":"test.otlp.volumes","description":"Volumes.","gauge":{"dataPoints":[{"asInt":"3","timeUnixNano":"1700000000000000000","attributes":[{"key":"pool.name","value":{"stringValue":"ceph"}}]}]}},
+ {"name":"test.otlp.requests","sum":{"aggregationTemporality":1,"isMonotonic":true,"dataPoints":[{"asInt":"2","timeUnixNano":"1700000000000000000"}]}},
+ {"name":"test.otlp.latency","histogram":{"aggregationTemporality":2,"dataPoints":[{"count":"3","sum":0.5,"bucketCounts":["1","2"],"explicitBounds":[0.1],"timeUnixNano":"1700000000000000000"}]}},
+ {"name":"test.otlp.gone","gauge":{"dataPoints":[{"flags":1}]}}
+]}]}]}`
+
+type capture struct {
+	metrics []data.Metric
+	events  []data.Event
+}
+
+func (c *capture) handle(t *testing.T, o *otlpHandler, blob []byte) {
+	c.metrics = c.metrics[:0]
+	c.events = c.events[:0]
+	require.NoError(t, o.Handle(blob, true, func(name string, mTime float64, mType data.MetricType, interval time.Duration, value float64, labelKeys []string, labelVals []string) {
+		c.metrics = append(c.metrics, data.Metric{
+			Name:      name,
+			Time:      mTime,
+			Type:      mType,
+			Interval:  interval,
+			Value:     value,
+			LabelKeys: labelKeys,
+			LabelVals: labelVals,
+		})
+	}, func(e data.Event) {
+		c.events = append(c.events, e)
+	}))
+}
+
+func TestOTLP(t *testing.T) {
+	c := &capture{}
+
+	t.Run("test metrics", func(t *testing.T) {
+		o := New().(*otlpHandler)
+		require.NoError(t, o.Config([]byte("interval: 30s\nresourceAttributes: [service.name]")))
+
+		// OTLP JSON is accepted without envelope
+		c.handle(t, o, []byte(metricsJSON))
+		assert.Empty(t, c.events)
+		keys := []string{"service_name"}
+		vals := []string{"cinder-volume"}
+		metric := func(name string, mType data.MetricType, value float64, extra ...string) data.Metric {
+			m := data.Metric{Name: name, Time: 1700000000, Type: mType, Interval: 30 * time.Second, Value: value,
+				LabelKeys: append([]string{}, keys...), LabelVals: append([]string{}, vals...)}
+			if len(extra) == 2 {
+				m.LabelKeys = append([]string{extra[0]}, m.LabelKeys...)
+				m.LabelVals = append([]string{extra[1]}, m.LabelVals...)
+			}
+			return m
+		}
+		assert.Equal(t, []data.Metric{
+			{Name: "test_otlp_volumes", Time: 1700000000, Type: data.GAUGE, Interval: 30 * time.Second, Value: 3,
+				LabelKeys: []string{"pool_name", "service_name"}, LabelVals: []string{"ceph", "cinder-volume"}},
+			metric("test_otlp_requests_total", data.COUNTER, 2),
+			metric("test_otlp_latency_bucket", data.COUNTER, 1, "le", "0.1"),
+			metric("test_otlp_latency_bucket", data.COUNTER, 3, "le", "+Inf"),
+			metric("test_otlp_latency_count", data.COUNTER, 3),
+			metric("test_otlp_latency_sum", data.COUNTER, 0.5),
+		}, c.metrics)
+		assert.Equal(t, "Volumes.", data.MetricHelp("test_otlp_volumes"))
+		assert.Equal(t, uint64(1), o.totalMetricsDropped)
+
+		// delta sums are accumulated
+		c.handle(t, o, otlp.Wrap(otlp.Metrics, otlp.JSON, []byte(metricsJSON)))
+		assert.Equal(t, metric("test_otlp_requests_total", data.COUNTER, 4), c.metrics[1])
+		assert.Equal(t, metric("test_otlp_latency_count", data.COUNTER, 3), c.metrics[4])
+
+		// accumulated series are forgotten after a long time without updates
+		o.now = func() time.Time { return time.Now().Add(time.Hour) }
+		o.purgeDeltas()
+		assert.Empty(t, o.deltas)
+	})
+
+	t.Run("test protobuf logs", func(t *testing.T) {
+		o := New().(*otlpHandler)
+		require.NoError(t, o.Config([]byte("indexPrefix: otel")))
+
+		field := func(num protowire.Number, v []byte) []byte {
+			return protowire.AppendBytes(protowire.AppendTag(nil, num, protowire.BytesType), v)
+		}
+		concat := func(parts ...[]byte) []byte {
+			b := []byte{}
+			for _, p := range parts {
+				b = append(b, p...)
+			}
+			return b
+		}
+		attr := func(key, value string) []byte {
+			return field(1, concat(field(1, []byte(key)), field(2, field(1, []byte(value)))))
+		}
+		record := protowire.AppendFixed64(protowire.AppendTag(nil, 1, protowire.Fixed64Type), 1700000000000000000)
+		record = protowire.AppendVarint(protowire.AppendTag(record, 2, protowire.VarintType), 13)
+		record = concat(record, field(5, field(1, []byte("disk almost full"))), field(10, []byte{0x01, 0x02}))
+		record = concat(record, protowire.AppendBytes(protowire.AppendTag(nil, 6, protowire.BytesType), concat(field(1, []byte("mount")), field(2, field(1, []byte("/var"))))))
+		blob := field(1, concat(
+			field(1, concat(attr("host.name", "compute-0"), attr("service.name", "nova"))),
+			field(2, concat(field(1, field(1, []byte("logger"))), field(2, record))),
+		))
+
+		c.handle(t, o, otlp.Wrap(otlp.Logs, otlp.Protobuf, blob))
+		require.Len(t, c.events, 1)
+		assert.Equal(t, data.Event{
+			Index:     "otel-compute_0.2023.11.14",
+			Time:      1700000000,
+			Type:      data.LOG,
+			Publisher: "compute-0",
+			Severity:  data.WARNING,
+			Labels: map[string]interface{}{
+				"host_name":    "compute-0",
+				"service_name": "nova",
+				"mount":        "/var",
+				"scope":        "logger",
+				"span_id":      "0102",
+			},
+			Message: "disk almost full",
+		}, c.events[0])
+		assert.Equal(t, uint64(1), o.totalLogsDecoded)
+	})
+
+	t.Run("test invalid payloads", func(t *testing.T) {
+		o := New().(*otlpHandler)
+		c.handle(t, o, []byte(`{"resourceSpans":[]}`))
+		require.Len(t, c.events, 1)
+		assert.Equal(t, data.ERROR, c.events[0].Type)
+
+		c.handle(t, o, otlp.Wrap(otlp.Metrics, otlp.Protobuf, []byte{0x0a, 0x05}))
+		require.Len(t, c.events, 1)
+		assert.Equal(t, "metrics protobuf", c.events[0].Labels["context"])
+		assert.Equal(t, uint64(2), o.totalDecodeErrors)
+
+		// points with bounds but without bucket counts are dropped
+		c.handle(t, o, []byte(`{"resourceMetrics":[{"scopeMetrics":[{"metrics":[{"name":"x","histogram":`+
+			`{"aggregationTemporality":2,"dataPoints":[{"count":"3","explicitBounds":[0.1,1]}]}}]}]}]}`))
+		assert.Empty(t, c.metrics)
+		assert.Empty(t, c.events)
+		assert.Equal(t, uint64(1), o.totalMetricsDropped)
+
+		// histograms without buckets are published as count
+		c.handle(t, o, []byte(`{"resourceMetrics":[{"scopeMetrics":[{"metrics":[{"name":"x","histogram":`+
+			`{"aggregationTemporality":2,"dataPoints":[{"count":"3"}]}}]}]}]}`))
+		require.Len(t, c.metrics, 2)
+		assert.Equal(t, "x_bucket", c.metrics[0].Name)
+		assert.Equal(t, "x_count", c.metrics[1].Name)
+
+		assert.Error(t, o.Config([]byte("interval: 0s")))
+	})
+}
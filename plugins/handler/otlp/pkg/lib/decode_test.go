@@ -0,0 +1,243 @@
+package lib
+
+import (
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+type field func([]byte) []byte
+
+func msg(fields ...field) []byte {
+	b := []byte{}
+	for _, f := range fields {
+		b = f(b)
+	}
+	return b
+}
+
+func bytesF(num protowire.Number, v []byte) field {
+	return func(b []byte) []byte {
+		return protowire.AppendBytes(protowire.AppendTag(b, num, protowire.BytesType), v)
+	}
+}
+
+func strF(num protowire.Number, v string) field {
+	return bytesF(num, []byte(v))
+}
+
+func varintF(num protowire.Number, v uint64) field {
+	return func(b []byte) []byte {
+		return protowire.AppendVarint(protowire.AppendTag(b, num, protowire.VarintType), v)
+	}
+}
+
+func fixed64F(num protowire.Number, v uint64) field {
+	return func(b []byte) []byte {
+		return protowire.AppendFixed64(protowire.AppendTag(b, num, protowire.Fixed64Type), v)
+	}
+}
+
+func doubleF(num protowire.Number, v float64) field {
+	return fixed64F(num, math.Float64bits(v))
+}
+
+func kv(num protowire.Number, key string, value field) field {
+	return bytesF(num, msg(strF(1, key), bytesF(2, msg(value))))
+}
+
+func TestDecodeProtobuf(t *testing.T) {
+	resource := bytesF(1, msg(
+		kv(1, "service.name", strF(1, "nova-api")),
+		kv(1, "host.cpus", varintF(3, 8)),
+		kv(1, "tags", bytesF(5, msg(bytesF(1, msg(strF(1, "a"))), bytesF(1, msg(varintF(2, 1)))))),
+		kv(1, "build", bytesF(6, msg(kv(1, "ratio", doubleF(4, 0.5))))),
+		kv(1, "raw", bytesF(7, []byte{1, 2})),
+	))
+	scope := bytesF(1, msg(strF(1, "meter"), strF(2, "1.0")))
+
+	t.Run("test metrics", func(t *testing.T) {
+		packedCounts := []byte{}
+		for _, c := range []uint64{1, 2, 3} {
+			packedCounts = protowire.AppendFixed64(packedCounts, c)
+		}
+		blob := msg(bytesF(1, msg(
+			resource,
+			bytesF(2, msg(
+				scope,
+				bytesF(2, msg(strF(1, "cpu.load"), strF(2, "Load."), strF(3, "1"), bytesF(5, msg(
+					bytesF(1, msg(fixed64F(3, 1700000000000000000), doubleF(4, 0.25), kv(7, "core", strF(1, "0")))),
+				)))),
+				bytesF(2, msg(strF(1, "requests"), bytesF(7, msg(
+					bytesF(1, msg(fixed64F(3, 5), fixed64F(6, uint64(42)), varintF(8, 1))),
+					varintF(2, uint64(Delta)),
+					varintF(3, 1),
+				)))),
+				bytesF(2, msg(strF(1, "latency"), bytesF(9, msg(
+					bytesF(1, msg(fixed64F(4, 6), doubleF(5, 1.5), bytesF(6, packedCounts), doubleF(7, 0.1), doubleF(7, 1))),
+					varintF(2, uint64(Cumulative)),
+				)))),
+				bytesF(2, msg(strF(1, "rpc"), bytesF(11, msg(
+					bytesF(1, msg(fixed64F(4, 2), doubleF(5, 3), bytesF(6, msg(doubleF(1, 0.5), doubleF(2, 1.25))))),
+				)))),
+				bytesF(2, msg(strF(1, "exp"), bytesF(10, msg()))),
+			)),
+		)))
+
+		resources, err := DecodeMetricsProtobuf(blob)
+		require.NoError(t, err)
+		require.Len(t, resources, 1)
+		assert.Equal(t, []Attribute{
+			{"service.name", "nova-api"},
+			{"host.cpus", int64(8)},
+			{"tags", []interface{}{"a", true}},
+			{"build", map[string]interface{}{"ratio": 0.5}},
+			{"raw", []byte{1, 2}},
+		}, resources[0].Resource)
+		require.Len(t, resources[0].Scopes, 1)
+		sm := resources[0].Scopes[0]
+		assert.Equal(t, Scope{Name: "meter", Version: "1.0"}, sm.Scope)
+		require.Len(t, sm.Metrics, 5)
+
+		assert.Equal(t, Metric{
+			Name: "cpu.load", Description: "Load.", Unit: "1", Kind: Gauge,
+			Points: []NumberPoint{{Attributes: []Attribute{{"core", "0"}}, Time: 1700000000000000000, Value: 0.25}},
+		}, sm.Metrics[0])
+		assert.Equal(t, Metric{
+			Name: "requests", Kind: Sum, Temporality: Delta, Monotonic: true,
+			Points: []NumberPoint{{Time: 5, Value: 42, Flags: 1}},
+		}, sm.Metrics[1])
+		assert.Equal(t, Metric{
+			Name: "latency", Kind: Histogram, Temporality: Cumulative,
+			Histograms: []HistogramPoint{{Count: 6, Sum: 1.5, HasSum: true, BucketCounts: []uint64{1, 2, 3}, Bounds: []float64{0.1, 1}}},
+		}, sm.Metrics[2])
+		assert.Equal(t, Metric{
+			Name: "rpc", Kind: Summary,
+			Summaries: []SummaryPoint{{Count: 2, Sum: 3, Quantiles: []Quantile{{0.5, 1.25}}}},
+		}, sm.Metrics[3])
+		assert.Equal(t, ExponentialHistogram, sm.Metrics[4].Kind)
+	})
+
+	t.Run("test logs", func(t *testing.T) {
+		blob := msg(bytesF(1, msg(
+			resource,
+			bytesF(2, msg(
+				scope,
+				bytesF(2, msg(
+					fixed64F(1, 1700000000000000000),
+					fixed64F(11, 1700000001000000000),
+					varintF(2, 17),
+					strF(3, "ERROR"),
+					bytesF(5, msg(strF(1, "boom"))),
+					kv(6, "code", varintF(3, 500)),
+					bytesF(9, []byte{0xab, 0xcd}),
+					bytesF(10, []byte{0xef}),
+					strF(12, "request.failed"),
+				)),
+			)),
+		)))
+		resources, err := DecodeLogsProtobuf(blob)
+		require.NoError(t, err)
+		require.Len(t, resources, 1)
+		require.Len(t, resources[0].Scopes, 1)
+		assert.Equal(t, []LogRecord{{
+			Time:           1700000000000000000,
+			ObservedTime:   1700000001000000000,
+			SeverityNumber: 17,
+			SeverityText:   "ERROR",
+			Body:           "boom",
+			Attributes:     []Attribute{{"code", int64(500)}},
+			TraceID:        []byte{0xab, 0xcd},
+			SpanID:         []byte{0xef},
+			EventName:      "request.failed",
+		}}, resources[0].Scopes[0].Records)
+	})
+
+	t.Run("test invalid payloads", func(t *testing.T) {
+		for _, blob := range [][]byte{
+			{0x0a, 0x05},
+			msg(varintF(1, 1)),
+			msg(bytesF(1, msg(bytesF(2, msg(bytesF(2, msg(varintF(1, 1)))))))),
+		} {
+			_, err := DecodeMetricsProtobuf(blob)
+			assert.Error(t, err, "%x", blob)
+			_, err = DecodeLogsProtobuf(blob)
+			assert.Error(t, err, "%x", blob)
+		}
+		// packed bucket counts of invalid length
+		_, err := DecodeMetricsProtobuf(msg(bytesF(1, msg(bytesF(2, msg(bytesF(2, msg(bytesF(9, msg(bytesF(1, msg(bytesF(6, []byte{1, 2, 3})))))))))))))
+		assert.Error(t, err)
+	})
+}
+
+func TestDecodeJSON(t *testing.T) {
+	t.Run("test metrics", func(t *testing.T) {
+		blob := []byte(`{"resourceMetrics":[{"resource":{"attributes":[{"key":"service.name","value":{"stringValue":"cinder"}}]},
+"scopeMetrics":[{"scope":{"name":"meter"},"metrics":[
+ {"name":"volumes","gauge":{"dataPoints":[{"asInt":"3","timeUnixNano":"1700000000000000000"}]}},
+ {"name":"bytes","unit":"By","sum":{"aggregationTemporality":2,"isMonotonic":true,"dataPoints":[{"asDouble":"Infinity","timeUnixNano":1,"attributes":[{"key":"ok","value":{"boolValue":true}}]}]}},
+ {"name":"latency","histogram":{"aggregationTemporality":1,"dataPoints":[{"count":"3","sum":0.5,"bucketCounts":["1","2"],"explicitBounds":[0.1]}]}},
+ {"name":"rpc","summary":{"dataPoints":[{"count":"2","sum":3,"quantileValues":[{"quantile":0.99,"value":2}]}]}},
+ {"name":"exp","exponentialHistogram":{}}
+]}]}]}`)
+		metrics, logs := DetectJSONSignal(blob)
+		assert.True(t, metrics)
+		assert.False(t, logs)
+		resources, err := DecodeMetricsJSON(blob)
+		require.NoError(t, err)
+		assert.Equal(t, []ResourceMetrics{{
+			Resource: []Attribute{{"service.name", "cinder"}},
+			Scopes: []ScopeMetrics{{
+				Scope: Scope{Name: "meter"},
+				Metrics: []Metric{
+					{Name: "volumes", Kind: Gauge, Points: []NumberPoint{{Attributes: []Attribute{}, Time: 1700000000000000000, Value: 3}}},
+					{Name: "bytes", Unit: "By", Kind: Sum, Temporality: Cumulative, Monotonic: true,
+						Points: []NumberPoint{{Attributes: []Attribute{{"ok", true}}, Time: 1, Value: math.Inf(1)}}},
+					{Name: "latency", Kind: Histogram, Temporality: Delta, Histograms: []HistogramPoint{{
+						Attributes: []Attribute{}, Count: 3, Sum: 0.5, HasSum: true, BucketCounts: []uint64{1, 2}, Bounds: []float64{0.1},
+					}}},
+					{Name: "rpc", Kind: Summary, Summaries: []SummaryPoint{{
+						Attributes: []Attribute{}, Count: 2, Sum: 3, Quantiles: []Quantile{{0.99, 2}},
+					}}},
+					{Name: "exp", Kind: ExponentialHistogram},
+				},
+			}},
+		}}, resources)
+	})
+
+	t.Run("test logs", func(t *testing.T) {
+		blob := []byte(`{"resourceLogs":[{"resource":{},"scopeLogs":[{"logRecords":[
+ {"timeUnixNano":"1700000000000000000","severityNumber":9,"severityText":"INFO","traceId":"5b8efff798038103d269b633813fc60c",
+  "body":{"kvlistValue":{"values":[{"key":"msg","value":{"stringValue":"started"}},{"key":"list","value":{"arrayValue":{"values":[{"doubleValue":1.5},{"bytesValue":"AQI="}]}}}]}}}
+]}]}]}`)
+		metrics, logs := DetectJSONSignal(blob)
+		assert.False(t, metrics)
+		assert.True(t, logs)
+		resources, err := DecodeLogsJSON(blob)
+		require.NoError(t, err)
+		require.Len(t, resources, 1)
+		require.Len(t, resources[0].Scopes, 1)
+		r := resources[0].Scopes[0].Records[0]
+		assert.Equal(t, uint64(1700000000000000000), r.Time)
+		assert.Equal(t, int32(9), r.SeverityNumber)
+		assert.Equal(t, []byte{0x5b, 0x8e, 0xff, 0xf7, 0x98, 0x03, 0x81, 0x03, 0xd2, 0x69, 0xb6, 0x33, 0x81, 0x3f, 0xc6, 0x0c}, r.TraceID)
+		assert.Equal(t, map[string]interface{}{"msg": "started", "list": []interface{}{1.5, []byte{1, 2}}}, r.Body)
+	})
+
+	t.Run("test invalid payloads", func(t *testing.T) {
+		for _, blob := range []string{
+			`[]`,
+			`{"resourceMetrics":[{"scopeMetrics":[{"metrics":[{"gauge":{"dataPoints":[{"asInt":"x"}]}}]}]}]}`,
+			`{"resourceLogs":[{"scopeLogs":[{"logRecords":[{"traceId":"xyz"}]}]}]}`,
+		} {
+			_, errM := DecodeMetricsJSON([]byte(blob))
+			_, errL := DecodeLogsJSON([]byte(blob))
+			assert.True(t, errM != nil || errL != nil, blob)
+		}
+		metrics, logs := DetectJSONSignal([]byte("not json"))
+		assert.False(t, metrics || logs)
+	})
+}
@@ -0,0 +1,340 @@
+package lib
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+)
+
+// OTLP JSON encodes 64 bit integers as strings, but numbers are accepted as well
+type jsonUint64 uint64
+
+func (u *jsonUint64) UnmarshalJSON(b []byte) error {
+	if string(b) == "null" {
+		return nil
+	}
+	v, err := strconv.ParseUint(string(bytes.Trim(b, `"`)), 10, 64)
+	*u = jsonUint64(v)
+	return err
+}
+
+type jsonInt64 int64
+
+func (i *jsonInt64) UnmarshalJSON(b []byte) error {
+	if string(b) == "null" {
+		return nil
+	}
+	v, err := strconv.ParseInt(string(bytes.Trim(b, `"`)), 10, 64)
+	*i = jsonInt64(v)
+	return err
+}
+
+// doubles are numbers or strings "NaN", "Infinity" and "-Infinity"
+type jsonDouble float64
+
+func (d *jsonDouble) UnmarshalJSON(b []byte) error {
+	switch string(b) {
+	case "null":
+	case `"NaN"`:
+		*d = jsonDouble(math.NaN())
+	case `"Infinity"`:
+		*d = jsonDouble(math.Inf(1))
+	case `"-Infinity"`:
+		*d = jsonDouble(math.Inf(-1))
+	default:
+		v, err := strconv.ParseFloat(string(bytes.Trim(b, `"`)), 64)
+		*d = jsonDouble(v)
+		return err
+	}
+	return nil
+}
+
+// trace and span IDs are hex encoded
+type jsonID []byte
+
+func (id *jsonID) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil {
+		return err
+	}
+	v, err := hex.DecodeString(s)
+	*id = v
+	return err
+}
+
+type jsonAnyValue struct {
+	StringValue *string     `json:"stringValue"`
+	BoolValue   *bool       `json:"boolValue"`
+	IntValue    *jsonInt64  `json:"intValue"`
+	DoubleValue *jsonDouble `json:"doubleValue"`
+	ArrayValue  *struct {
+		Values []jsonAnyValue `json:"values"`
+	} `json:"arrayValue"`
+	KvlistValue *struct {
+		Values []jsonKeyValue `json:"values"`
+	} `json:"kvlistValue"`
+	BytesValue []byte `json:"bytesValue"`
+}
+
+func (v *jsonAnyValue) value() interface{} {
+	switch {
+	case v == nil:
+		return nil
+	case v.StringValue != nil:
+		return *v.StringValue
+	case v.BoolValue != nil:
+		return *v.BoolValue
+	case v.IntValue != nil:
+		return int64(*v.IntValue)
+	case v.DoubleValue != nil:
+		return float64(*v.DoubleValue)
+	case v.ArrayValue != nil:
+		values := make([]interface{}, len(v.ArrayValue.Values))
+		for i := range v.ArrayValue.Values {
+			values[i] = v.ArrayValue.Values[i].value()
+		}
+		return values
+	case v.KvlistValue != nil:
+		values := map[string]interface{}{}
+		for i := range v.KvlistValue.Values {
+			values[v.KvlistValue.Values[i].Key] = v.KvlistValue.Values[i].Value.value()
+		}
+		return values
+	case v.BytesValue != nil:
+		return v.BytesValue
+	}
+	return nil
+}
+
+type jsonKeyValue struct {
+	Key   string        `json:"key"`
+	Value *jsonAnyValue `json:"value"`
+}
+
+func attributes(kvs []jsonKeyValue) []Attribute {
+	attrs := make([]Attribute, len(kvs))
+	for i, kv := range kvs {
+		attrs[i] = Attribute{Key: kv.Key, Value: kv.Value.value()}
+	}
+	return attrs
+}
+
+type jsonResource struct {
+	Attributes []jsonKeyValue `json:"attributes"`
+}
+
+type jsonScope struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+}
+
+type jsonNumberPoint struct {
+	Attributes   []jsonKeyValue `json:"attributes"`
+	TimeUnixNano jsonUint64     `json:"timeUnixNano"`
+	AsDouble     *jsonDouble    `json:"asDouble"`
+	AsInt        *jsonInt64     `json:"asInt"`
+	Flags        uint32         `json:"flags"`
+}
+
+type jsonHistogramPoint struct {
+	Attributes     []jsonKeyValue `json:"attributes"`
+	TimeUnixNano   jsonUint64     `json:"timeUnixNano"`
+	Count          jsonUint64     `json:"count"`
+	Sum            *jsonDouble    `json:"sum"`
+	BucketCounts   []jsonUint64   `json:"bucketCounts"`
+	ExplicitBounds []jsonDouble   `json:"explicitBounds"`
+	Flags          uint32         `json:"flags"`
+}
+
+type jsonSummaryPoint struct {
+	Attributes     []jsonKeyValue `json:"attributes"`
+	TimeUnixNano   jsonUint64     `json:"timeUnixNano"`
+	Count          jsonUint64     `json:"count"`
+	Sum            jsonDouble     `json:"sum"`
+	QuantileValues []struct {
+		Quantile jsonDouble `json:"quantile"`
+		Value    jsonDouble `json:"value"`
+	} `json:"quantileValues"`
+	Flags uint32 `json:"flags"`
+}
+
+type jsonMetric struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	Unit        string `json:"unit"`
+	Gauge       *struct {
+		DataPoints []jsonNumberPoint `json:"dataPoints"`
+	} `json:"gauge"`
+	Sum *struct {
+		DataPoints             []jsonNumberPoint `json:"dataPoints"`
+		AggregationTemporality Temporality       `json:"aggregationTemporality"`
+		IsMonotonic            bool              `json:"isMonotonic"`
+	} `json:"sum"`
+	Histogram *struct {
+		DataPoints             []jsonHistogramPoint `json:"dataPoints"`
+		AggregationTemporality Temporality          `json:"aggregationTemporality"`
+	} `json:"histogram"`
+	ExponentialHistogram json.RawMessage `json:"exponentialHistogram"`
+	Summary              *struct {
+		DataPoints []jsonSummaryPoint `json:"dataPoints"`
+	} `json:"summary"`
+}
+
+func numberPoints(points []jsonNumberPoint) []NumberPoint {
+	result := make([]NumberPoint, len(points))
+	for i, p := range points {
+		result[i] = NumberPoint{Attributes: attributes(p.Attributes), Time: uint64(p.TimeUnixNano), Flags: p.Flags}
+		if p.AsDouble != nil {
+			result[i].Value = float64(*p.AsDouble)
+		} else if p.AsInt != nil {
+			result[i].Value = float64(*p.AsInt)
+		}
+	}
+	return result
+}
+
+func (jm *jsonMetric) metric() Metric {
+	m := Metric{Name: jm.Name, Description: jm.Description, Unit: jm.Unit}
+	switch {
+	case jm.Gauge != nil:
+		m.Kind = Gauge
+		m.Points = numberPoints(jm.Gauge.DataPoints)
+	case jm.Sum != nil:
+		m.Kind = Sum
+		m.Points = numberPoints(jm.Sum.DataPoints)
+		m.Temporality = jm.Sum.AggregationTemporality
+		m.Monotonic = jm.Sum.IsMonotonic
+	case jm.Histogram != nil:
+		m.Kind = Histogram
+		m.Temporality = jm.Histogram.AggregationTemporality
+		for _, p := range jm.Histogram.DataPoints {
+			hp := HistogramPoint{
+				Attributes:   attributes(p.Attributes),
+				Time:         uint64(p.TimeUnixNano),
+				Count:        uint64(p.Count),
+				BucketCounts: make([]uint64, len(p.BucketCounts)),
+				Bounds:       make([]float64, len(p.ExplicitBounds)),
+				Flags:        p.Flags,
+			}
+			if p.Sum != nil {
+				hp.HasSum, hp.Sum = true, float64(*p.Sum)
+			}
+			for i, c := range p.BucketCounts {
+				hp.BucketCounts[i] = uint64(c)
+			}
+			for i, b := range p.ExplicitBounds {
+				hp.Bounds[i] = float64(b)
+			}
+			m.Histograms = append(m.Histograms, hp)
+		}
+	case jm.ExponentialHistogram != nil:
+		m.Kind = ExponentialHistogram
+	case jm.Summary != nil:
+		m.Kind = Summary
+		for _, p := range jm.Summary.DataPoints {
+			sp := SummaryPoint{
+				Attributes: attributes(p.Attributes),
+				Time:       uint64(p.TimeUnixNano),
+				Count:      uint64(p.Count),
+				Sum:        float64(p.Sum),
+				Flags:      p.Flags,
+			}
+			for _, q := range p.QuantileValues {
+				sp.Quantiles = append(sp.Quantiles, Quantile{Quantile: float64(q.Quantile), Value: float64(q.Value)})
+			}
+			m.Summaries = append(m.Summaries, sp)
+		}
+	}
+	return m
+}
+
+// DecodeMetricsJSON decodes ExportMetricsServiceRequest in OTLP JSON encoding
+func DecodeMetricsJSON(blob []byte) ([]ResourceMetrics, error) {
+	var req struct {
+		ResourceMetrics []struct {
+			Resource     jsonResource `json:"resource"`
+			ScopeMetrics []struct {
+				Scope   jsonScope    `json:"scope"`
+				Metrics []jsonMetric `json:"metrics"`
+			} `json:"scopeMetrics"`
+		} `json:"resourceMetrics"`
+	}
+	if err := json.Unmarshal(blob, &req); err != nil {
+		return nil, fmt.Errorf("invalid OTLP JSON: %w", err)
+	}
+	resources := make([]ResourceMetrics, len(req.ResourceMetrics))
+	for i, jrm := range req.ResourceMetrics {
+		resources[i].Resource = attributes(jrm.Resource.Attributes)
+		for _, jsm := range jrm.ScopeMetrics {
+			sm := ScopeMetrics{Scope: Scope(jsm.Scope)}
+			for j := range jsm.Metrics {
+				sm.Metrics = append(sm.Metrics, jsm.Metrics[j].metric())
+			}
+			resources[i].Scopes = append(resources[i].Scopes, sm)
+		}
+	}
+	return resources, nil
+}
+
+// DecodeLogsJSON decodes ExportLogsServiceRequest in OTLP JSON encoding
+func DecodeLogsJSON(blob []byte) ([]ResourceLogs, error) {
+	var req struct {
+		ResourceLogs []struct {
+			Resource  jsonResource `json:"resource"`
+			ScopeLogs []struct {
+				Scope      jsonScope `json:"scope"`
+				LogRecords []struct {
+					TimeUnixNano         jsonUint64     `json:"timeUnixNano"`
+					ObservedTimeUnixNano jsonUint64     `json:"observedTimeUnixNano"`
+					SeverityNumber       int32          `json:"severityNumber"`
+					SeverityText         string         `json:"severityText"`
+					Body                 *jsonAnyValue  `json:"body"`
+					Attributes           []jsonKeyValue `json:"attributes"`
+					TraceID              jsonID         `json:"traceId"`
+					SpanID               jsonID         `json:"spanId"`
+					EventName            string         `json:"eventName"`
+				} `json:"logRecords"`
+			} `json:"scopeLogs"`
+		} `json:"resourceLogs"`
+	}
+	if err := json.Unmarshal(blob, &req); err != nil {
+		return nil, fmt.Errorf("invalid OTLP JSON: %w", err)
+	}
+	resources := make([]ResourceLogs, len(req.ResourceLogs))
+	for i, jrl := range req.ResourceLogs {
+		resources[i].Resource = attributes(jrl.Resource.Attributes)
+		for _, jsl := range jrl.ScopeLogs {
+			sl := ScopeLogs{Scope: Scope(jsl.Scope)}
+			for _, r := range jsl.LogRecords {
+				sl.Records = append(sl.Records, LogRecord{
+					Time:           uint64(r.TimeUnixNano),
+					ObservedTime:   uint64(r.ObservedTimeUnixNano),
+					SeverityNumber: r.SeverityNumber,
+					SeverityText:   r.SeverityText,
+					Body:           r.Body.value(),
+					Attributes:     attributes(r.Attributes),
+					TraceID:        r.TraceID,
+					SpanID:         r.SpanID,
+					EventName:      r.EventName,
+				})
+			}
+			resources[i].Scopes = append(resources[i].Scopes, sl)
+		}
+	}
+	return resources, nil
+}
+
+// DetectJSONSignal reports whether OTLP JSON payload holds metrics or logs, so that payloads
+// received by generic transports can be decoded
+func DetectJSONSignal(blob []byte) (metrics bool, logs bool) {
+	var keys map[string]json.RawMessage
+	if json.Unmarshal(blob, &keys) != nil {
+		return false, false
+	}
+	_, metrics = keys["resourceMetrics"]
+	_, logs = keys["resourceLogs"]
+	return metrics, logs
+}
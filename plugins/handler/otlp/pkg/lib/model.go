@@ -0,0 +1,126 @@
+package lib
+
+// MetricKind is kind of data held by OTLP metric
+type MetricKind int
+
+// metric kinds
+const (
+	Empty MetricKind = iota
+	Gauge
+	Sum
+	Histogram
+	ExponentialHistogram
+	Summary
+)
+
+// Temporality is aggregation temporality of sums and histograms
+type Temporality int
+
+// aggregation temporalities
+const (
+	TemporalityUnspecified Temporality = iota
+	Delta
+	Cumulative
+)
+
+// FlagNoRecordedValue marks data points which replace value of series that disappeared
+const FlagNoRecordedValue = 1
+
+// Attribute is key/value pair. Value is string, bool, int64, float64, []byte, []interface{}
+// or map[string]interface{}
+type Attribute struct {
+	Key   string
+	Value interface{}
+}
+
+// Scope is instrumentation scope which produced the data
+type Scope struct {
+	Name    string
+	Version string
+}
+
+// NumberPoint is data point of gauge or sum
+type NumberPoint struct {
+	Attributes []Attribute
+	Time       uint64 // nanoseconds since epoch
+	Value      float64
+	Flags      uint32
+}
+
+// HistogramPoint is data point of explicit bucket histogram
+type HistogramPoint struct {
+	Attributes   []Attribute
+	Time         uint64
+	Count        uint64
+	Sum          float64
+	HasSum       bool
+	BucketCounts []uint64 // count of each bucket, not cumulative
+	Bounds       []float64
+	Flags        uint32
+}
+
+// Quantile is value of summary at given quantile
+type Quantile struct {
+	Quantile float64
+	Value    float64
+}
+
+// SummaryPoint is data point of summary
+type SummaryPoint struct {
+	Attributes []Attribute
+	Time       uint64
+	Count      uint64
+	Sum        float64
+	Quantiles  []Quantile
+	Flags      uint32
+}
+
+// Metric is single OTLP metric with its data points
+type Metric struct {
+	Name        string
+	Description string
+	Unit        string
+	Kind        MetricKind
+	Temporality Temporality
+	Monotonic   bool
+	Points      []NumberPoint
+	Histograms  []HistogramPoint
+	Summaries   []SummaryPoint
+}
+
+// ScopeMetrics groups metrics of single instrumentation scope
+type ScopeMetrics struct {
+	Scope   Scope
+	Metrics []Metric
+}
+
+// ResourceMetrics groups metrics produced by single resource
+type ResourceMetrics struct {
+	Resource []Attribute
+	Scopes   []ScopeMetrics
+}
+
+// LogRecord is single OTLP log record
+type LogRecord struct {
+	Time           uint64
+	ObservedTime   uint64
+	SeverityNumber int32
+	SeverityText   string
+	Body           interface{}
+	Attributes     []Attribute
+	TraceID        []byte
+	SpanID         []byte
+	EventName      string
+}
+
+// ScopeLogs groups log records of single instrumentation scope
+type ScopeLogs struct {
+	Scope   Scope
+	Records []LogRecord
+}
+
+// ResourceLogs groups log records produced by single resource
+type ResourceLogs struct {
+	Resource []Attribute
+	Scopes   []ScopeLogs
+}
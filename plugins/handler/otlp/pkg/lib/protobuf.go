@@ -0,0 +1,532 @@
+package lib
+
+import (
+	"errors"
+	"fmt"
+	"math"
+
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+var errInvalidWireType = errors.New("unexpected wire type")
+
+// fields iterates over fields of protobuf message and calls fn with field number, wire type and
+// remaining buffer. fn returns length of consumed value, zero skips value of unknown field
+func fields(buf []byte, fn func(protowire.Number, protowire.Type, []byte) (int, error)) error {
+	for len(buf) > 0 {
+		num, typ, n := protowire.ConsumeTag(buf)
+		if n < 0 {
+			return protowire.ParseError(n)
+		}
+		buf = buf[n:]
+		n, err := fn(num, typ, buf)
+		if err != nil {
+			return fmt.Errorf("field %d: %w", num, err)
+		}
+		if n == 0 {
+			n = protowire.ConsumeFieldValue(num, typ, buf)
+		}
+		if n < 0 {
+			return fmt.Errorf("field %d: %w", num, protowire.ParseError(n))
+		}
+		buf = buf[n:]
+	}
+	return nil
+}
+
+// embedded decodes length delimited field with decode
+func embedded(typ protowire.Type, buf []byte, decode func([]byte) error) (int, error) {
+	if typ != protowire.BytesType {
+		return 0, errInvalidWireType
+	}
+	v, n := protowire.ConsumeBytes(buf)
+	if n < 0 {
+		return 0, protowire.ParseError(n)
+	}
+	return n, decode(v)
+}
+
+func str(typ protowire.Type, buf []byte, dst *string) (int, error) {
+	return embedded(typ, buf, func(v []byte) error {
+		*dst = string(v)
+		return nil
+	})
+}
+
+func fixed64(typ protowire.Type, buf []byte, dst *uint64) (int, error) {
+	if typ != protowire.Fixed64Type {
+		return 0, errInvalidWireType
+	}
+	v, n := protowire.ConsumeFixed64(buf)
+	*dst = v
+	return n, nil
+}
+
+func double(typ protowire.Type, buf []byte, dst *float64) (int, error) {
+	var v uint64
+	n, err := fixed64(typ, buf, &v)
+	*dst = math.Float64frombits(v)
+	return n, err
+}
+
+func varint(typ protowire.Type, buf []byte, dst *uint64) (int, error) {
+	if typ != protowire.VarintType {
+		return 0, errInvalidWireType
+	}
+	v, n := protowire.ConsumeVarint(buf)
+	*dst = v
+	return n, nil
+}
+
+// repeatedFixed64 decodes packed or unpacked repeated fixed64 or double field
+func repeatedFixed64(typ protowire.Type, buf []byte, add func(uint64)) (int, error) {
+	if typ == protowire.Fixed64Type {
+		v, n := protowire.ConsumeFixed64(buf)
+		add(v)
+		return n, nil
+	}
+	return embedded(typ, buf, func(v []byte) error {
+		if len(v)%8 != 0 {
+			return errors.New("invalid length of packed field")
+		}
+		for len(v) > 0 {
+			x, n := protowire.ConsumeFixed64(v)
+			add(x)
+			v = v[n:]
+		}
+		return nil
+	})
+}
+
+func decodeAnyValue(buf []byte) (interface{}, error) {
+	var value interface{}
+	err := fields(buf, func(num protowire.Number, typ protowire.Type, buf []byte) (int, error) {
+		switch num {
+		case 1:
+			var s string
+			n, err := str(typ, buf, &s)
+			value = s
+			return n, err
+		case 2, 3:
+			var v uint64
+			n, err := varint(typ, buf, &v)
+			if num == 2 {
+				value = v != 0
+			} else {
+				value = int64(v)
+			}
+			return n, err
+		case 4:
+			var f float64
+			n, err := double(typ, buf, &f)
+			value = f
+			return n, err
+		case 5:
+			values := []interface{}{}
+			n, err := embedded(typ, buf, func(v []byte) error {
+				return fields(v, func(num protowire.Number, typ protowire.Type, buf []byte) (int, error) {
+					if num != 1 {
+						return 0, nil
+					}
+					return embedded(typ, buf, func(v []byte) error {
+						item, err := decodeAnyValue(v)
+						values = append(values, item)
+						return err
+					})
+				})
+			})
+			value = values
+			return n, err
+		case 6:
+			values := map[string]interface{}{}
+			n, err := embedded(typ, buf, func(v []byte) error {
+				attrs, err := decodeKeyValues(v, 1)
+				for _, a := range attrs {
+					values[a.Key] = a.Value
+				}
+				return err
+			})
+			value = values
+			return n, err
+		case 7:
+			return embedded(typ, buf, func(v []byte) error {
+				value = append([]byte{}, v...)
+				return nil
+			})
+		}
+		return 0, nil
+	})
+	return value, err
+}
+
+func decodeKeyValue(buf []byte) (Attribute, error) {
+	a := Attribute{}
+	err := fields(buf, func(num protowire.Number, typ protowire.Type, buf []byte) (int, error) {
+		switch num {
+		case 1:
+			return str(typ, buf, &a.Key)
+		case 2:
+			return embedded(typ, buf, func(v []byte) error {
+				var err error
+				a.Value, err = decodeAnyValue(v)
+				return err
+			})
+		}
+		return 0, nil
+	})
+	return a, err
+}
+
+// decodeKeyValues decodes repeated KeyValue field with given number of message
+func decodeKeyValues(buf []byte, field protowire.Number) ([]Attribute, error) {
+	attrs := []Attribute{}
+	err := fields(buf, func(num protowire.Number, typ protowire.Type, buf []byte) (int, error) {
+		if num != field {
+			return 0, nil
+		}
+		return embedded(typ, buf, func(v []byte) error {
+			a, err := decodeKeyValue(v)
+			attrs = append(attrs, a)
+			return err
+		})
+	})
+	return attrs, err
+}
+
+func decodeScope(buf []byte) (Scope, error) {
+	s := Scope{}
+	err := fields(buf, func(num protowire.Number, typ protowire.Type, buf []byte) (int, error) {
+		switch num {
+		case 1:
+			return str(typ, buf, &s.Name)
+		case 2:
+			return str(typ, buf, &s.Version)
+		}
+		return 0, nil
+	})
+	return s, err
+}
+
+func decodeNumberPoint(buf []byte) (NumberPoint, error) {
+	p := NumberPoint{}
+	err := fields(buf, func(num protowire.Number, typ protowire.Type, buf []byte) (int, error) {
+		switch num {
+		case 3:
+			return fixed64(typ, buf, &p.Time)
+		case 4:
+			return double(typ, buf, &p.Value)
+		case 6:
+			var v uint64
+			n, err := fixed64(typ, buf, &v)
+			p.Value = float64(int64(v))
+			return n, err
+		case 7:
+			return embedded(typ, buf, func(v []byte) error {
+				a, err := decodeKeyValue(v)
+				p.Attributes = append(p.Attributes, a)
+				return err
+			})
+		case 8:
+			var v uint64
+			n, err := varint(typ, buf, &v)
+			p.Flags = uint32(v)
+			return n, err
+		}
+		return 0, nil
+	})
+	return p, err
+}
+
+func decodeHistogramPoint(buf []byte) (HistogramPoint, error) {
+	p := HistogramPoint{}
+	err := fields(buf, func(num protowire.Number, typ protowire.Type, buf []byte) (int, error) {
+		switch num {
+		case 3:
+			return fixed64(typ, buf, &p.Time)
+		case 4:
+			return fixed64(typ, buf, &p.Count)
+		case 5:
+			p.HasSum = true
+			return double(typ, buf, &p.Sum)
+		case 6:
+			return repeatedFixed64(typ, buf, func(v uint64) { p.BucketCounts = append(p.BucketCounts, v) })
+		case 7:
+			return repeatedFixed64(typ, buf, func(v uint64) { p.Bounds = append(p.Bounds, math.Float64frombits(v)) })
+		case 9:
+			return embedded(typ, buf, func(v []byte) error {
+				a, err := decodeKeyValue(v)
+				p.Attributes = append(p.Attributes, a)
+				return err
+			})
+		case 10:
+			var v uint64
+			n, err := varint(typ, buf, &v)
+			p.Flags = uint32(v)
+			return n, err
+		}
+		return 0, nil
+	})
+	return p, err
+}
+
+func decodeSummaryPoint(buf []byte) (SummaryPoint, error) {
+	p := SummaryPoint{}
+	err := fields(buf, func(num protowire.Number, typ protowire.Type, buf []byte) (int, error) {
+		switch num {
+		case 3:
+			return fixed64(typ, buf, &p.Time)
+		case 4:
+			return fixed64(typ, buf, &p.Count)
+		case 5:
+			return double(typ, buf, &p.Sum)
+		case 6:
+			return embedded(typ, buf, func(v []byte) error {
+				q := Quantile{}
+				err := fields(v, func(num protowire.Number, typ protowire.Type, buf []byte) (int, error) {
+					switch num {
+					case 1:
+						return double(typ, buf, &q.Quantile)
+					case 2:
+						return double(typ, buf, &q.Value)
+					}
+					return 0, nil
+				})
+				p.Quantiles = append(p.Quantiles, q)
+				return err
+			})
+		case 7:
+			return embedded(typ, buf, func(v []byte) error {
+				a, err := decodeKeyValue(v)
+				p.Attributes = append(p.Attributes, a)
+				return err
+			})
+		case 8:
+			var v uint64
+			n, err := varint(typ, buf, &v)
+			p.Flags = uint32(v)
+			return n, err
+		}
+		return 0, nil
+	})
+	return p, err
+}
+
+// decodeData decodes Gauge, Sum, Histogram or Summary message into m
+func decodeData(buf []byte, m *Metric) error {
+	return fields(buf, func(num protowire.Number, typ protowire.Type, buf []byte) (int, error) {
+		switch num {
+		case 1:
+			return embedded(typ, buf, func(v []byte) error {
+				switch m.Kind {
+				case Gauge, Sum:
+					p, err := decodeNumberPoint(v)
+					m.Points = append(m.Points, p)
+					return err
+				case Histogram:
+					p, err := decodeHistogramPoint(v)
+					m.Histograms = append(m.Histograms, p)
+					return err
+				case Summary:
+					p, err := decodeSummaryPoint(v)
+					m.Summaries = append(m.Summaries, p)
+					return err
+				}
+				return nil
+			})
+		case 2:
+			if m.Kind != Sum && m.Kind != Histogram {
+				return 0, nil
+			}
+			var v uint64
+			n, err := varint(typ, buf, &v)
+			m.Temporality = Temporality(v)
+			return n, err
+		case 3:
+			if m.Kind != Sum {
+				return 0, nil
+			}
+			var v uint64
+			n, err := varint(typ, buf, &v)
+			m.Monotonic = v != 0
+			return n, err
+		}
+		return 0, nil
+	})
+}
+
+func decodeMetric(buf []byte) (Metric, error) {
+	m := Metric{}
+	err := fields(buf, func(num protowire.Number, typ protowire.Type, buf []byte) (int, error) {
+		kind := Empty
+		switch num {
+		case 1:
+			return str(typ, buf, &m.Name)
+		case 2:
+			return str(typ, buf, &m.Description)
+		case 3:
+			return str(typ, buf, &m.Unit)
+		case 5:
+			kind = Gauge
+		case 7:
+			kind = Sum
+		case 9:
+			kind = Histogram
+		case 10:
+			m.Kind = ExponentialHistogram
+			return 0, nil
+		case 11:
+			kind = Summary
+		default:
+			return 0, nil
+		}
+		m.Kind = kind
+		return embedded(typ, buf, func(v []byte) error {
+			return decodeData(v, &m)
+		})
+	})
+	return m, err
+}
+
+// DecodeMetricsProtobuf decodes ExportMetricsServiceRequest
+func DecodeMetricsProtobuf(buf []byte) ([]ResourceMetrics, error) {
+	resources := []ResourceMetrics{}
+	err := fields(buf, func(num protowire.Number, typ protowire.Type, buf []byte) (int, error) {
+		if num != 1 {
+			return 0, nil
+		}
+		return embedded(typ, buf, func(v []byte) error {
+			rm := ResourceMetrics{}
+			err := fields(v, func(num protowire.Number, typ protowire.Type, buf []byte) (int, error) {
+				switch num {
+				case 1:
+					return embedded(typ, buf, func(v []byte) error {
+						var err error
+						rm.Resource, err = decodeKeyValues(v, 1)
+						return err
+					})
+				case 2:
+					return embedded(typ, buf, func(v []byte) error {
+						sm := ScopeMetrics{}
+						err := fields(v, func(num protowire.Number, typ protowire.Type, buf []byte) (int, error) {
+							switch num {
+							case 1:
+								return embedded(typ, buf, func(v []byte) error {
+									var err error
+									sm.Scope, err = decodeScope(v)
+									return err
+								})
+							case 2:
+								return embedded(typ, buf, func(v []byte) error {
+									m, err := decodeMetric(v)
+									sm.Metrics = append(sm.Metrics, m)
+									return err
+								})
+							}
+							return 0, nil
+						})
+						rm.Scopes = append(rm.Scopes, sm)
+						return err
+					})
+				}
+				return 0, nil
+			})
+			resources = append(resources, rm)
+			return err
+		})
+	})
+	return resources, err
+}
+
+func decodeLogRecord(buf []byte) (LogRecord, error) {
+	r := LogRecord{}
+	err := fields(buf, func(num protowire.Number, typ protowire.Type, buf []byte) (int, error) {
+		switch num {
+		case 1:
+			return fixed64(typ, buf, &r.Time)
+		case 11:
+			return fixed64(typ, buf, &r.ObservedTime)
+		case 2:
+			var v uint64
+			n, err := varint(typ, buf, &v)
+			r.SeverityNumber = int32(v)
+			return n, err
+		case 3:
+			return str(typ, buf, &r.SeverityText)
+		case 5:
+			return embedded(typ, buf, func(v []byte) error {
+				var err error
+				r.Body, err = decodeAnyValue(v)
+				return err
+			})
+		case 6:
+			return embedded(typ, buf, func(v []byte) error {
+				a, err := decodeKeyValue(v)
+				r.Attributes = append(r.Attributes, a)
+				return err
+			})
+		case 9, 10:
+			return embedded(typ, buf, func(v []byte) error {
+				if num == 9 {
+					r.TraceID = append([]byte{}, v...)
+				} else {
+					r.SpanID = append([]byte{}, v...)
+				}
+				return nil
+			})
+		case 12:
+			return str(typ, buf, &r.EventName)
+		}
+		return 0, nil
+	})
+	return r, err
+}
+
+// DecodeLogsProtobuf decodes ExportLogsServiceRequest
+func DecodeLogsProtobuf(buf []byte) ([]ResourceLogs, error) {
+	resources := []ResourceLogs{}
+	err := fields(buf, func(num protowire.Number, typ protowire.Type, buf []byte) (int, error) {
+		if num != 1 {
+			return 0, nil
+		}
+		return embedded(typ, buf, func(v []byte) error {
+			rl := ResourceLogs{}
+			err := fields(v, func(num protowire.Number, typ protowire.Type, buf []byte) (int, error) {
+				switch num {
+				case 1:
+					return embedded(typ, buf, func(v []byte) error {
+						var err error
+						rl.Resource, err = decodeKeyValues(v, 1)
+						return err
+					})
+				case 2:
+					return embedded(typ, buf, func(v []byte) error {
+						sl := ScopeLogs{}
+						err := fields(v, func(num protowire.Number, typ protowire.Type, buf []byte) (int, error) {
+							switch num {
+							case 1:
+								return embedded(typ, buf, func(v []byte) error {
+									var err error
+									sl.Scope, err = decodeScope(v)
+									return err
+								})
+							case 2:
+								return embedded(typ, buf, func(v []byte) error {
+									r, err := decodeLogRecord(v)
+									sl.Records = append(sl.Records, r)
+									return err
+								})
+							}
+							return 0, nil
+						})
+						rl.Scopes = append(rl.Scopes, sl)
+						return err
+					})
+				}
+				return 0, nil
+			})
+			resources = append(resources, rl)
+			return err
+		})
+	})
+	return resources, err
+}
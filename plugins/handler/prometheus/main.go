@@ -0,0 +1,131 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"sync"
+	"time"
+
+	"github.com/openstack-k8s-operators/sg-core/pkg/bus"
+	"github.com/openstack-k8s-operators/sg-core/pkg/config"
+	"github.com/openstack-k8s-operators/sg-core/pkg/data"
+	"github.com/openstack-k8s-operators/sg-core/pkg/handler"
+	"github.com/openstack-k8s-operators/sg-core/plugins/handler/prometheus/pkg/lib"
+)
+
+const (
+	formatAuto        = "auto"
+	formatText        = "text"
+	formatOpenMetrics = "openmetrics"
+)
+
+type configT struct {
+	Format   string        `validate:"oneof=auto text openmetrics"`
+	Interval time.Duration // expected interval of expositions, used for expiry of stale metrics
+}
+
+type prometheusHandler struct {
+	totalMessagesReceived uint64
+	totalMetricsDecoded   uint64
+	totalDecodeErrors     uint64
+	statsLock             sync.RWMutex
+	conf                  configT
+}
+
+// Handle parses metrics exposition
+func (p *prometheusHandler) Handle(blob []byte, reportErrors bool, mpf bus.MetricPublishFunc, epf bus.EventPublishFunc) error {
+	openMetrics := p.conf.Format == formatOpenMetrics || (p.conf.Format == formatAuto && lib.IsOpenMetrics(blob))
+	samples, errs := lib.Parse(blob, openMetrics)
+
+	for _, s := range samples {
+		data.SetMetricHelp(s.Name, s.Help)
+		mpf(s.Name, s.Timestamp, s.Type, p.conf.Interval, s.Value, s.LabelKeys, s.LabelVals)
+	}
+
+	p.statsLock.Lock()
+	p.totalMessagesReceived++
+	p.totalMetricsDecoded += uint64(len(samples))
+	p.totalDecodeErrors += uint64(len(errs))
+	p.statsLock.Unlock()
+
+	if reportErrors {
+		for _, err := range errs {
+			epf(data.Event{
+				Index:    p.Identify(),
+				Type:     data.ERROR,
+				Severity: data.CRITICAL,
+				Time:     0.0,
+				Labels: map[string]interface{}{
+					"error":   err.Error(),
+					"message": "failed to parse metric - disregarding",
+				},
+				Annotations: map[string]interface{}{
+					"description": "internal smartgateway prometheus handler error",
+				},
+			})
+		}
+	}
+	return nil
+}
+
+// Run send internal metrics to bus
+func (p *prometheusHandler) Run(ctx context.Context, mpf bus.MetricPublishFunc, _ bus.EventPublishFunc) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(time.Second):
+			p.statsLock.RLock()
+			mpf(
+				"sg_total_prometheus_msg_received_count",
+				0,
+				data.COUNTER,
+				0,
+				float64(p.totalMessagesReceived),
+				[]string{"source"},
+				[]string{"SG"},
+			)
+			mpf(
+				"sg_total_prometheus_metric_decode_count",
+				0,
+				data.COUNTER,
+				0,
+				float64(p.totalMetricsDecoded),
+				[]string{"source"},
+				[]string{"SG"},
+			)
+			mpf(
+				"sg_total_prometheus_decode_error_count",
+				0,
+				data.COUNTER,
+				0,
+				float64(p.totalDecodeErrors),
+				[]string{"source"},
+				[]string{"SG"},
+			)
+			p.statsLock.RUnlock()
+		}
+	}
+}
+
+func (p *prometheusHandler) Identify() string {
+	return "prometheus"
+}
+
+func (p *prometheusHandler) Config(c []byte) error {
+	p.conf = configT{
+		Format:   formatAuto,
+		Interval: 30 * time.Second,
+	}
+	return config.ParseConfig(bytes.NewReader(c), &p.conf)
+}
+
+// New create new prometheusHandler object
+func New() handler.Handler {
+	return &prometheusHandler{
+		conf: configT{
+			Format:   formatAuto,
+			Interval: 30 * time.Second,
+		},
+	}
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/openstack-k8s-operators/sg-core/pkg/data"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func handle(t *testing.T, p *prometheusHandler, blob string) ([]data.Metric, []data.Event) {
+	metrics := []data.Metric{}
+	events := []data.Event{}
+	err := p.Handle([]byte(blob), true, func(name string, mTime float64, mType data.MetricType, interval time.Duration, value float64, labelKeys []string, labelVals []string) {
+		metrics = append(metrics, data.Metric{
+			Name:      name,
+			Time:      mTime,
+			Type:      mType,
+			Interval:  interval,
+			Value:     value,
+			LabelKeys: labelKeys,
+			LabelVals: labelVals,
+		})
+	}, func(e data.Event) {
+		events = append(events, e)
+	})
+	require.NoError(t, err)
+	return metrics, events
+}
+
+func TestPrometheus(t *testing.T) {
+	t.Run("test format detection", func(t *testing.T) {
+		p := New().(*prometheusHandler)
+		require.NoError(t, p.Config([]byte("interval: 15s")))
+
+		metrics, events := handle(t, p, "# HELP test_sg_up Whether target is up.\n# TYPE test_sg_up gauge\ntest_sg_up{job=\"node\"} 1 1700000000000\n")
+		assert.Empty(t, events)
+		assert.Equal(t, []data.Metric{{
+			Name: "test_sg_up", Time: 1700000000, Type: data.GAUGE, Interval: 15 * time.Second, Value: 1,
+			LabelKeys: []string{"job"}, LabelVals: []string{"node"},
+		}}, metrics)
+		assert.Equal(t, "Whether target is up.", data.MetricHelp("test_sg_up"))
+
+		// timestamps of OpenMetrics are in seconds
+		metrics, _ = handle(t, p, "# TYPE test_sg_jobs counter\ntest_sg_jobs_total 3 1700000000\n# EOF\n")
+		assert.Equal(t, []data.Metric{{
+			Name: "test_sg_jobs_total", Time: 1700000000, Type: data.COUNTER, Interval: 15 * time.Second, Value: 3,
+			LabelKeys: []string{}, LabelVals: []string{},
+		}}, metrics)
+		assert.Equal(t, "", data.MetricHelp("test_sg_jobs_total"))
+	})
+
+	t.Run("test forced format", func(t *testing.T) {
+		p := New().(*prometheusHandler)
+		require.NoError(t, p.Config([]byte("format: text")))
+		_, events := handle(t, p, "# TYPE test_sg_info info\ntest_sg_info 1\n# EOF\n")
+		require.Len(t, events, 1)
+		assert.Equal(t, data.ERROR, events[0].Type)
+
+		require.NoError(t, p.Config([]byte("format: openmetrics")))
+		metrics, events := handle(t, p, "# TYPE test_sg_info info\ntest_sg_info 1\n")
+		assert.Empty(t, events)
+		require.Len(t, metrics, 1)
+		assert.Equal(t, data.GAUGE, metrics[0].Type)
+		assert.Equal(t, uint64(2), p.totalMessagesReceived)
+	})
+
+	t.Run("test invalid configuration", func(t *testing.T) {
+		assert.Error(t, New().Config([]byte("format: json")))
+	})
+}
@@ -0,0 +1,305 @@
+package lib
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/openstack-k8s-operators/sg-core/pkg/data"
+)
+
+// Sample is single sample of exposed metric family
+type Sample struct {
+	Name      string
+	LabelKeys []string // sorted
+	LabelVals []string
+	Value     float64
+	Timestamp float64 // seconds, 0 when not exposed
+	Type      data.MetricType
+	Help      string
+}
+
+type family struct {
+	typ  string
+	help string
+}
+
+// suffixes of sample names belonging to metric family
+var suffixes = []string{"_total", "_created", "_bucket", "_count", "_sum", "_gcount", "_gsum", "_info"}
+
+// IsOpenMetrics reports whether exposition is in OpenMetrics format, which is terminated by # EOF
+func IsOpenMetrics(blob []byte) bool {
+	return bytes.HasSuffix(bytes.TrimRight(blob, "\n"), []byte("# EOF"))
+}
+
+// Parse parses Prometheus text exposition format or OpenMetrics. Invalid lines are skipped
+// and returned as errors
+func Parse(blob []byte, openMetrics bool) ([]Sample, []error) {
+	p := parser{
+		openMetrics: openMetrics,
+		families:    map[string]*family{},
+	}
+	samples := []Sample{}
+	errs := []error{}
+	for i, line := range strings.Split(string(blob), "\n") {
+		if !openMetrics {
+			line = strings.TrimSpace(line)
+		}
+		if line == "" {
+			continue
+		}
+		var err error
+		if strings.HasPrefix(line, "#") {
+			err = p.comment(line)
+		} else {
+			var s Sample
+			var skip bool
+			if s, skip, err = p.sample(line); err == nil && !skip {
+				samples = append(samples, s)
+			}
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("line %d: %w", i+1, err))
+		}
+	}
+	return samples, errs
+}
+
+type parser struct {
+	openMetrics bool
+	families    map[string]*family
+}
+
+func (p *parser) family(name string) *family {
+	f, ok := p.families[name]
+	if !ok {
+		f = &family{}
+		p.families[name] = f
+	}
+	return f
+}
+
+func (p *parser) comment(line string) error {
+	fields := strings.SplitN(strings.TrimPrefix(line, "#"), " ", 4)
+	if len(fields) < 3 || fields[0] != "" {
+		// other comments are ignored
+		return nil
+	}
+	switch fields[1] {
+	case "HELP":
+		help := ""
+		if len(fields) == 4 {
+			help = unescape(fields[3], p.openMetrics)
+		}
+		p.family(fields[2]).help = help
+	case "TYPE":
+		if len(fields) != 4 {
+			return errors.New("missing metric type")
+		}
+		switch fields[3] {
+		case "counter", "gauge", "histogram", "summary", "untyped":
+		case "gaugehistogram", "stateset", "info", "unknown":
+			if !p.openMetrics {
+				return fmt.Errorf("unknown metric type %q", fields[3])
+			}
+		default:
+			return fmt.Errorf("unknown metric type %q", fields[3])
+		}
+		p.family(fields[2]).typ = fields[3]
+	}
+	return nil
+}
+
+// sampleType finds family of sample and returns its type. Returns false for samples which are not published
+func (p *parser) sampleType(name string) (data.MetricType, *family, bool) {
+	f, suffix := p.families[name], ""
+	if f == nil {
+		for _, s := range suffixes {
+			if base, ok := strings.CutSuffix(name, s); ok && p.families[base] != nil {
+				f, suffix = p.families[base], s
+				break
+			}
+		}
+	}
+	if f == nil {
+		return data.UNTYPED, nil, true
+	}
+	if suffix == "_created" {
+		return data.UNTYPED, f, false
+	}
+	switch f.typ {
+	case "counter":
+		return data.COUNTER, f, true
+	case "histogram", "summary":
+		if suffix == "" {
+			// quantiles of summary
+			return data.GAUGE, f, true
+		}
+		return data.COUNTER, f, true
+	case "gauge", "gaugehistogram", "stateset", "info":
+		return data.GAUGE, f, true
+	}
+	return data.UNTYPED, f, true
+}
+
+func isNameChar(c byte, first bool) bool {
+	return c == '_' || c == ':' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (!first && c >= '0' && c <= '9')
+}
+
+func (p *parser) sample(line string) (Sample, bool, error) {
+	s := Sample{}
+	i := 0
+	for i < len(line) && isNameChar(line[i], i == 0) {
+		i++
+	}
+	if i == 0 {
+		return s, false, fmt.Errorf("invalid metric name in %q", line)
+	}
+	s.Name = line[:i]
+
+	labels := map[string]string{}
+	if i < len(line) && line[i] == '{' {
+		var err error
+		if i, err = p.labels(line, i+1, labels); err != nil {
+			return s, false, err
+		}
+	}
+
+	rest := line[i:]
+	if p.openMetrics {
+		// exemplar is not stored
+		if idx := strings.Index(rest, " # "); idx >= 0 {
+			rest = rest[:idx]
+		}
+	}
+	fields := strings.Fields(rest)
+	if len(fields) < 1 || len(fields) > 2 || (p.openMetrics && rest[0] != ' ') {
+		return s, false, fmt.Errorf("invalid value and timestamp of %s", s.Name)
+	}
+	var err error
+	if s.Value, err = strconv.ParseFloat(fields[0], 64); err != nil {
+		return s, false, fmt.Errorf("invalid value %q of %s", fields[0], s.Name)
+	}
+	if len(fields) == 2 {
+		if s.Timestamp, err = strconv.ParseFloat(fields[1], 64); err != nil {
+			return s, false, fmt.Errorf("invalid timestamp %q of %s", fields[1], s.Name)
+		}
+		if !p.openMetrics {
+			// milliseconds in text format
+			s.Timestamp /= 1000
+		}
+	}
+
+	var f *family
+	var publish bool
+	if s.Type, f, publish = p.sampleType(s.Name); !publish {
+		return s, true, nil
+	}
+	if f != nil {
+		s.Help = f.help
+	}
+
+	s.LabelKeys = make([]string, 0, len(labels))
+	for key := range labels {
+		s.LabelKeys = append(s.LabelKeys, key)
+	}
+	sort.Strings(s.LabelKeys)
+	s.LabelVals = make([]string, len(s.LabelKeys))
+	for idx, key := range s.LabelKeys {
+		s.LabelVals[idx] = labels[key]
+	}
+	return s, false, nil
+}
+
+// labels parses label set after opening brace at i, returns position after closing brace
+func (p *parser) labels(line string, i int, labels map[string]string) (int, error) {
+	skipSpaces := func() {
+		for !p.openMetrics && i < len(line) && (line[i] == ' ' || line[i] == '\t') {
+			i++
+		}
+	}
+	for {
+		skipSpaces()
+		if i < len(line) && line[i] == '}' {
+			return i + 1, nil
+		}
+		start := i
+		for i < len(line) && isNameChar(line[i], i == start) && line[i] != ':' {
+			i++
+		}
+		key := line[start:i]
+		skipSpaces()
+		if key == "" || i+1 >= len(line) || line[i] != '=' {
+			return i, fmt.Errorf("invalid label in %q", line)
+		}
+		i++
+		skipSpaces()
+		if i >= len(line) || line[i] != '"' {
+			return i, fmt.Errorf("label value of %s is not quoted", key)
+		}
+		var value strings.Builder
+		for i++; i < len(line) && line[i] != '"'; i++ {
+			if line[i] == '\\' && i+1 < len(line) {
+				i++
+				switch line[i] {
+				case 'n':
+					value.WriteByte('\n')
+				default:
+					value.WriteByte(line[i])
+				}
+				continue
+			}
+			value.WriteByte(line[i])
+		}
+		if i >= len(line) {
+			return i, fmt.Errorf("unterminated value of label %s", key)
+		}
+		if _, ok := labels[key]; ok {
+			return i, fmt.Errorf("duplicate label %s", key)
+		}
+		labels[key] = value.String()
+		i++
+		skipSpaces()
+		if i < len(line) && line[i] == ',' {
+			i++
+			continue
+		}
+		if i < len(line) && line[i] == '}' {
+			return i + 1, nil
+		}
+		return i, fmt.Errorf("invalid label set in %q", line)
+	}
+}
+
+// unescape unescapes HELP text. Text format escapes only backslash and new line
+func unescape(s string, openMetrics bool) string {
+	if !strings.Contains(s, `\`) {
+		return s
+	}
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+1 < len(s) {
+			switch s[i+1] {
+			case 'n':
+				b.WriteByte('\n')
+				i++
+				continue
+			case '\\':
+				b.WriteByte('\\')
+				i++
+				continue
+			case '"':
+				if openMetrics {
+					b.WriteByte('"')
+					i++
+					continue
+				}
+			}
+		}
+		b.WriteByte(s[i])
+	}
+	return b.String()
+}
@@ -0,0 +1,114 @@
+package lib
+
+import (
+	"math"
+	"testing"
+
+	"github.com/openstack-k8s-operators/sg-core/pkg/data"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const textExposition = `# HELP node_cpu_seconds_total Seconds the CPUs spent in each mode.
+# TYPE node_cpu_seconds_total counter
+node_cpu_seconds_total{cpu="0",mode="idle"} 1234.5 1700000000123
+node_cpu_seconds_total{mode="user", cpu="0",} 56
+# HELP node_load1 1m load average.\nSecond line with \\ backslash.
+# TYPE node_load1 gauge
+node_load1 0.25
+# TYPE http_request_duration_seconds histogram
+http_request_duration_seconds_bucket{le="0.1"} 10
+http_request_duration_seconds_bucket{le="+Inf"} 12
+http_request_duration_seconds_sum 3.5
+http_request_duration_seconds_count 12
+# TYPE rpc_duration_seconds summary
+rpc_duration_seconds{quantile="0.5"} NaN
+rpc_duration_seconds_sum 0
+rpc_duration_seconds_count 0
+# a comment
+untyped_metric{path="C:\\dir \"x\"\nnext"} -Inf
+`
+
+const openMetricsExposition = `# TYPE process_cpu_seconds counter
+# UNIT process_cpu_seconds seconds
+# HELP process_cpu_seconds Total user and system CPU time spent in \"seconds\".
+process_cpu_seconds_total 4.2 1700000000.5
+process_cpu_seconds_created 1690000000.0
+# TYPE queue_depth gaugehistogram
+queue_depth_bucket{le="1"} 3
+queue_depth_bucket{le="+Inf"} 5
+queue_depth_gcount 5
+queue_depth_gsum 4
+# TYPE build info
+build_info{version="1.2"} 1
+# TYPE requests unknown
+requests{path="/"} 7 # {trace_id="abc"} 1 1700000000
+# EOF
+`
+
+func sample(name string, mType data.MetricType, value float64, help string, labels ...string) Sample {
+	s := Sample{Name: name, Type: mType, Value: value, Help: help, LabelKeys: []string{}, LabelVals: []string{}}
+	for i := 0; i < len(labels); i += 2 {
+		s.LabelKeys = append(s.LabelKeys, labels[i])
+		s.LabelVals = append(s.LabelVals, labels[i+1])
+	}
+	return s
+}
+
+func TestParse(t *testing.T) {
+	t.Run("test text format", func(t *testing.T) {
+		assert.False(t, IsOpenMetrics([]byte(textExposition)))
+		samples, errs := Parse([]byte(textExposition), false)
+		require.Empty(t, errs)
+		require.Len(t, samples, 11)
+
+		cpuHelp := "Seconds the CPUs spent in each mode."
+		idle := sample("node_cpu_seconds_total", data.COUNTER, 1234.5, cpuHelp, "cpu", "0", "mode", "idle")
+		idle.Timestamp = 1700000000.123
+		assert.Equal(t, idle, samples[0])
+		assert.Equal(t, sample("node_cpu_seconds_total", data.COUNTER, 56, cpuHelp, "cpu", "0", "mode", "user"), samples[1])
+		assert.Equal(t, sample("node_load1", data.GAUGE, 0.25, "1m load average.\nSecond line with \\ backslash."), samples[2])
+		assert.Equal(t, sample("http_request_duration_seconds_bucket", data.COUNTER, 10, "", "le", "0.1"), samples[3])
+		assert.Equal(t, sample("http_request_duration_seconds_count", data.COUNTER, 12, ""), samples[6])
+		assert.Equal(t, data.GAUGE, samples[7].Type)
+		assert.True(t, math.IsNaN(samples[7].Value))
+		assert.Equal(t, data.COUNTER, samples[8].Type)
+		assert.Equal(t, []string{"C:\\dir \"x\"\nnext"}, samples[10].LabelVals)
+		assert.Equal(t, data.UNTYPED, samples[10].Type)
+		assert.True(t, math.IsInf(samples[10].Value, -1))
+	})
+
+	t.Run("test OpenMetrics", func(t *testing.T) {
+		assert.True(t, IsOpenMetrics([]byte(openMetricsExposition)))
+		samples, errs := Parse([]byte(openMetricsExposition), true)
+		require.Empty(t, errs)
+
+		cpu := sample("process_cpu_seconds_total", data.COUNTER, 4.2, `Total user and system CPU time spent in "seconds".`)
+		cpu.Timestamp = 1700000000.5
+		assert.Equal(t, []Sample{
+			cpu,
+			sample("queue_depth_bucket", data.GAUGE, 3, "", "le", "1"),
+			sample("queue_depth_bucket", data.GAUGE, 5, "", "le", "+Inf"),
+			sample("queue_depth_gcount", data.GAUGE, 5, ""),
+			sample("queue_depth_gsum", data.GAUGE, 4, ""),
+			sample("build_info", data.GAUGE, 1, "", "version", "1.2"),
+			sample("requests", data.UNTYPED, 7, "", "path", "/"),
+		}, samples)
+	})
+
+	t.Run("test invalid lines are skipped", func(t *testing.T) {
+		samples, errs := Parse([]byte(`# TYPE a gaugehistogram
+# TYPE b
+{x="1"} 1
+c{x=1} 1
+c{x="1" 1
+c{x="1",x="2"} 1
+c one
+c 1 2 3
+c 1 yesterday
+d 4
+`), false)
+		assert.Len(t, errs, 9)
+		assert.Equal(t, []Sample{sample("d", data.UNTYPED, 4, "")}, samples)
+	})
+}
@@ -0,0 +1,232 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/openstack-k8s-operators/sg-core/pkg/bus"
+	"github.com/openstack-k8s-operators/sg-core/pkg/config"
+	"github.com/openstack-k8s-operators/sg-core/pkg/data"
+	"github.com/openstack-k8s-operators/sg-core/pkg/handler"
+	"github.com/openstack-k8s-operators/sg-core/plugins/handler/remote-write/pkg/lib"
+)
+
+var errMissingName = errors.New("series without __name__ label")
+
+// suffixes of series names belonging to metric family
+var suffixes = []string{"_total", "_bucket", "_count", "_sum", "_gcount", "_gsum", "_info"}
+
+type configT struct {
+	Interval time.Duration // expected interval of samples, used for expiry of stale metrics
+}
+
+type remoteWriteHandler struct {
+	totalMessagesReceived uint64
+	totalMetricsDecoded   uint64
+	totalMetricsDropped   uint64
+	totalDecodeErrors     uint64
+	statsLock             sync.RWMutex
+	conf                  configT
+	// metadata are sent in separate requests, so they are kept between requests
+	metadata map[string]lib.Metadata
+}
+
+func (rw *remoteWriteHandler) reportError(err error, context string, epf bus.EventPublishFunc) {
+	rw.statsLock.Lock()
+	rw.totalDecodeErrors++
+	rw.statsLock.Unlock()
+	if epf == nil {
+		return
+	}
+	epf(data.Event{
+		Index:    rw.Identify(),
+		Type:     data.ERROR,
+		Severity: data.CRITICAL,
+		Time:     0.0,
+		Labels: map[string]interface{}{
+			"error":   err.Error(),
+			"context": context,
+			"message": "failed to decode remote_write request - disregarding",
+		},
+		Annotations: map[string]interface{}{
+			"description": "internal smartgateway remote-write handler error",
+		},
+	})
+}
+
+// metricType finds metadata of series family and returns type of series
+func (rw *remoteWriteHandler) metricType(name string) (data.MetricType, string) {
+	md, ok := rw.metadata[name]
+	suffix := ""
+	if !ok {
+		for _, s := range suffixes {
+			if base, found := strings.CutSuffix(name, s); found {
+				if md, ok = rw.metadata[base]; ok {
+					suffix = s
+					break
+				}
+			}
+		}
+	}
+	if !ok {
+		return data.UNTYPED, ""
+	}
+	switch md.Type {
+	case lib.Counter:
+		return data.COUNTER, md.Help
+	case lib.Histogram, lib.Summary:
+		if suffix == "" {
+			// quantiles of summary
+			return data.GAUGE, md.Help
+		}
+		return data.COUNTER, md.Help
+	case lib.Gauge, lib.GaugeHistogram, lib.Info, lib.Stateset:
+		return data.GAUGE, md.Help
+	}
+	return data.UNTYPED, md.Help
+}
+
+func (rw *remoteWriteHandler) publish(ts lib.TimeSeries, mpf bus.MetricPublishFunc) (uint64, uint64, error) {
+	dropped := uint64(ts.Histograms)
+	name := ""
+	labels := make([]lib.Label, 0, len(ts.Labels))
+	for _, l := range ts.Labels {
+		if l.Name == "__name__" {
+			name = l.Value
+			continue
+		}
+		labels = append(labels, l)
+	}
+	if name == "" {
+		return 0, dropped + uint64(len(ts.Samples)), errMissingName
+	}
+	sort.Slice(labels, func(i, j int) bool { return labels[i].Name < labels[j].Name })
+	keys := make([]string, len(labels))
+	vals := make([]string, len(labels))
+	for i, l := range labels {
+		keys[i] = l.Name
+		vals[i] = l.Value
+	}
+
+	mType, help := rw.metricType(name)
+	data.SetMetricHelp(name, help)
+	published := uint64(0)
+	for _, s := range ts.Samples {
+		if lib.IsStale(s.Value) {
+			dropped++
+			continue
+		}
+		mpf(name, float64(s.Timestamp)/1000, mType, rw.conf.Interval, s.Value, keys, vals)
+		published++
+	}
+	return published, dropped, nil
+}
+
+// Handle decodes uncompressed remote_write request
+func (rw *remoteWriteHandler) Handle(blob []byte, reportErrors bool, mpf bus.MetricPublishFunc, epf bus.EventPublishFunc) error {
+	rw.statsLock.Lock()
+	rw.totalMessagesReceived++
+	rw.statsLock.Unlock()
+	if !reportErrors {
+		epf = nil
+	}
+
+	req, err := lib.DecodeWriteRequest(blob)
+	if err != nil {
+		rw.reportError(err, "WriteRequest", epf)
+		return nil
+	}
+	for _, md := range req.Metadata {
+		rw.metadata[md.Family] = md
+	}
+
+	var published, dropped uint64
+	for _, ts := range req.Series {
+		p, d, err := rw.publish(ts, mpf)
+		published += p
+		dropped += d
+		if err != nil {
+			rw.reportError(err, "TimeSeries", epf)
+		}
+	}
+	rw.statsLock.Lock()
+	rw.totalMetricsDecoded += published
+	rw.totalMetricsDropped += dropped
+	rw.statsLock.Unlock()
+	return nil
+}
+
+// Run send internal metrics to bus
+func (rw *remoteWriteHandler) Run(ctx context.Context, mpf bus.MetricPublishFunc, _ bus.EventPublishFunc) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(time.Second):
+			rw.statsLock.RLock()
+			mpf(
+				"sg_total_remote_write_msg_received_count",
+				0,
+				data.COUNTER,
+				0,
+				float64(rw.totalMessagesReceived),
+				[]string{"source"},
+				[]string{"SG"},
+			)
+			mpf(
+				"sg_total_remote_write_metric_decode_count",
+				0,
+				data.COUNTER,
+				0,
+				float64(rw.totalMetricsDecoded),
+				[]string{"source"},
+				[]string{"SG"},
+			)
+			mpf(
+				"sg_total_remote_write_metric_dropped_count",
+				0,
+				data.COUNTER,
+				0,
+				float64(rw.totalMetricsDropped),
+				[]string{"source"},
+				[]string{"SG"},
+			)
+			mpf(
+				"sg_total_remote_write_decode_error_count",
+				0,
+				data.COUNTER,
+				0,
+				float64(rw.totalDecodeErrors),
+				[]string{"source"},
+				[]string{"SG"},
+			)
+			rw.statsLock.RUnlock()
+		}
+	}
+}
+
+func (rw *remoteWriteHandler) Identify() string {
+	return "remote-write"
+}
+
+func (rw *remoteWriteHandler) Config(c []byte) error {
+	rw.conf = configT{
+		Interval: time.Minute,
+	}
+	return config.ParseConfig(bytes.NewReader(c), &rw.conf)
+}
+
+// New create new remoteWriteHandler object
+func New() handler.Handler {
+	return &remoteWriteHandler{
+		conf: configT{
+			Interval: time.Minute,
+		},
+		metadata: map[string]lib.Metadata{},
+	}
+}
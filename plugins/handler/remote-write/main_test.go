@@ -0,0 +1,128 @@
+package main
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/openstack-k8s-operators/sg-core/pkg/data"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+type field func([]byte) []byte
+
+func message(fields ...field) []byte {
+	b := []byte{}
+	for _, f := range fields {
+		b = f(b)
+	}
+	return b
+}
+
+func bytesField(num protowire.Number, v []byte) field {
+	return func(b []byte) []byte {
+		return protowire.AppendBytes(protowire.AppendTag(b, num, protowire.BytesType), v)
+	}
+}
+
+func series(name string, value float64, labels ...string) field {
+	fields := []field{}
+	if name != "" {
+		labels = append([]string{"__name__", name}, labels...)
+	}
+	for i := 0; i < len(labels); i += 2 {
+		fields = append(fields, bytesField(1, message(bytesField(1, []byte(labels[i])), bytesField(2, []byte(labels[i+1])))))
+	}
+	fields = append(fields, bytesField(2, message(func(b []byte) []byte {
+		b = protowire.AppendTag(b, 1, protowire.Fixed64Type)
+		b = protowire.AppendFixed64(b, math.Float64bits(value))
+		b = protowire.AppendTag(b, 2, protowire.VarintType)
+		return protowire.AppendVarint(b, 1700000000500)
+	})))
+	return bytesField(1, message(fields...))
+}
+
+func metadata(family string, mType uint64, help string) field {
+	return bytesField(3, message(func(b []byte) []byte {
+		b = protowire.AppendTag(b, 1, protowire.VarintType)
+		return protowire.AppendVarint(b, mType)
+	}, bytesField(2, []byte(family)), bytesField(4, []byte(help))))
+}
+
+func TestRemoteWrite(t *testing.T) {
+	rw := New().(*remoteWriteHandler)
+	require.NoError(t, rw.Config([]byte("interval: 15s")))
+
+	metrics := []data.Metric{}
+	events := []data.Event{}
+	handle := func(blob []byte) {
+		metrics = metrics[:0]
+		events = events[:0]
+		require.NoError(t, rw.Handle(blob, true, func(name string, mTime float64, mType data.MetricType, interval time.Duration, value float64, labelKeys []string, labelVals []string) {
+			metrics = append(metrics, data.Metric{
+				Name:      name,
+				Time:      mTime,
+				Type:      mType,
+				Interval:  interval,
+				Value:     value,
+				LabelKeys: labelKeys,
+				LabelVals: labelVals,
+			})
+		}, func(e data.Event) {
+			events = append(events, e)
+		}))
+	}
+
+	t.Run("test series are published with metadata of earlier requests", func(t *testing.T) {
+		handle(message(
+			metadata("test_rw_requests_total", 1, "Total requests."),
+			metadata("test_rw_latency_seconds", 5, "Request latency."),
+		))
+		assert.Empty(t, metrics)
+
+		handle(message(
+			series("test_rw_requests_total", 3, "path", "/", "code", "200"),
+			series("test_rw_latency_seconds", 0.2, "quantile", "0.9"),
+			series("test_rw_latency_seconds_count", 3),
+			series("test_rw_temperature", 21.5),
+			series("test_rw_gone", math.Float64frombits(0x7ff0000000000002)),
+		))
+		assert.Empty(t, events)
+		assert.Equal(t, []data.Metric{
+			{
+				Name: "test_rw_requests_total", Time: 1700000000.5, Type: data.COUNTER, Interval: 15 * time.Second, Value: 3,
+				LabelKeys: []string{"code", "path"}, LabelVals: []string{"200", "/"},
+			},
+			{
+				Name: "test_rw_latency_seconds", Time: 1700000000.5, Type: data.GAUGE, Interval: 15 * time.Second, Value: 0.2,
+				LabelKeys: []string{"quantile"}, LabelVals: []string{"0.9"},
+			},
+			{
+				Name: "test_rw_latency_seconds_count", Time: 1700000000.5, Type: data.COUNTER, Interval: 15 * time.Second, Value: 3,
+				LabelKeys: []string{}, LabelVals: []string{},
+			},
+			{
+				Name: "test_rw_temperature", Time: 1700000000.5, Type: data.UNTYPED, Interval: 15 * time.Second, Value: 21.5,
+				LabelKeys: []string{}, LabelVals: []string{},
+			},
+		}, metrics)
+		assert.Equal(t, "Total requests.", data.MetricHelp("test_rw_requests_total"))
+		assert.Equal(t, "Request latency.", data.MetricHelp("test_rw_latency_seconds_count"))
+		assert.Equal(t, uint64(4), rw.totalMetricsDecoded)
+		assert.Equal(t, uint64(1), rw.totalMetricsDropped)
+	})
+
+	t.Run("test invalid requests", func(t *testing.T) {
+		handle(message(series("", 1, "job", "node"), series("test_rw_valid", 1)))
+		require.Len(t, events, 1)
+		assert.Equal(t, data.ERROR, events[0].Type)
+		require.Len(t, metrics, 1)
+
+		handle([]byte{0x0a, 0x05})
+		require.Len(t, events, 1)
+		assert.Empty(t, metrics)
+		assert.Equal(t, uint64(2), rw.totalDecodeErrors)
+	})
+}
@@ -0,0 +1,228 @@
+package lib
+
+import (
+	"errors"
+	"fmt"
+	"math"
+
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// MetricType is type of metric family in remote_write metadata
+type MetricType int32
+
+// metric types of prometheus.MetricMetadata
+const (
+	Unknown MetricType = iota
+	Counter
+	Gauge
+	Histogram
+	GaugeHistogram
+	Summary
+	Info
+	Stateset
+)
+
+// staleNaN is value Prometheus uses to mark series which disappeared
+const staleNaN uint64 = 0x7ff0000000000002
+
+// Label is name/value pair of series
+type Label struct {
+	Name  string
+	Value string
+}
+
+// Sample is single value of series
+type Sample struct {
+	Value     float64
+	Timestamp int64 // milliseconds since epoch
+}
+
+// TimeSeries is series with its samples
+type TimeSeries struct {
+	Labels     []Label
+	Samples    []Sample
+	Histograms int // number of native histogram samples, which are not decoded
+}
+
+// Metadata describes metric family
+type Metadata struct {
+	Type   MetricType
+	Family string
+	Help   string
+	Unit   string
+}
+
+// WriteRequest is decoded remote_write 1.0 request
+type WriteRequest struct {
+	Series   []TimeSeries
+	Metadata []Metadata
+}
+
+var errInvalidWireType = errors.New("unexpected wire type")
+
+// IsStale reports whether value is staleness marker
+func IsStale(value float64) bool {
+	return math.Float64bits(value) == staleNaN
+}
+
+// fields iterates over fields of protobuf message and calls fn with field number, wire type and
+// remaining buffer. fn returns length of consumed value, zero skips value of unknown field
+func fields(buf []byte, fn func(protowire.Number, protowire.Type, []byte) (int, error)) error {
+	for len(buf) > 0 {
+		num, typ, n := protowire.ConsumeTag(buf)
+		if n < 0 {
+			return protowire.ParseError(n)
+		}
+		buf = buf[n:]
+		n, err := fn(num, typ, buf)
+		if err != nil {
+			return fmt.Errorf("field %d: %w", num, err)
+		}
+		if n == 0 {
+			n = protowire.ConsumeFieldValue(num, typ, buf)
+		}
+		if n < 0 {
+			return fmt.Errorf("field %d: %w", num, protowire.ParseError(n))
+		}
+		buf = buf[n:]
+	}
+	return nil
+}
+
+// consumeBytes returns length delimited value and number of consumed bytes
+func consumeBytes(typ protowire.Type, buf []byte) ([]byte, int, error) {
+	if typ != protowire.BytesType {
+		return nil, 0, errInvalidWireType
+	}
+	v, n := protowire.ConsumeBytes(buf)
+	if n < 0 {
+		return nil, 0, protowire.ParseError(n)
+	}
+	return v, n, nil
+}
+
+func decodeLabel(buf []byte) (Label, error) {
+	l := Label{}
+	err := fields(buf, func(num protowire.Number, typ protowire.Type, buf []byte) (int, error) {
+		if num != 1 && num != 2 {
+			return 0, nil
+		}
+		v, n, err := consumeBytes(typ, buf)
+		if num == 1 {
+			l.Name = string(v)
+		} else {
+			l.Value = string(v)
+		}
+		return n, err
+	})
+	return l, err
+}
+
+func decodeSample(buf []byte) (Sample, error) {
+	s := Sample{}
+	err := fields(buf, func(num protowire.Number, typ protowire.Type, buf []byte) (int, error) {
+		switch num {
+		case 1:
+			if typ != protowire.Fixed64Type {
+				return 0, errInvalidWireType
+			}
+			v, n := protowire.ConsumeFixed64(buf)
+			s.Value = math.Float64frombits(v)
+			return n, nil
+		case 2:
+			if typ != protowire.VarintType {
+				return 0, errInvalidWireType
+			}
+			v, n := protowire.ConsumeVarint(buf)
+			s.Timestamp = int64(v)
+			return n, nil
+		}
+		return 0, nil
+	})
+	return s, err
+}
+
+func decodeTimeSeries(buf []byte) (TimeSeries, error) {
+	ts := TimeSeries{}
+	err := fields(buf, func(num protowire.Number, typ protowire.Type, buf []byte) (int, error) {
+		switch num {
+		case 1:
+			v, n, err := consumeBytes(typ, buf)
+			if err != nil {
+				return 0, err
+			}
+			l, err := decodeLabel(v)
+			ts.Labels = append(ts.Labels, l)
+			return n, err
+		case 2:
+			v, n, err := consumeBytes(typ, buf)
+			if err != nil {
+				return 0, err
+			}
+			s, err := decodeSample(v)
+			ts.Samples = append(ts.Samples, s)
+			return n, err
+		case 4:
+			ts.Histograms++
+		}
+		return 0, nil
+	})
+	return ts, err
+}
+
+func decodeMetadata(buf []byte) (Metadata, error) {
+	m := Metadata{}
+	err := fields(buf, func(num protowire.Number, typ protowire.Type, buf []byte) (int, error) {
+		switch num {
+		case 1:
+			if typ != protowire.VarintType {
+				return 0, errInvalidWireType
+			}
+			v, n := protowire.ConsumeVarint(buf)
+			m.Type = MetricType(v)
+			return n, nil
+		case 2, 4, 5:
+			v, n, err := consumeBytes(typ, buf)
+			switch num {
+			case 2:
+				m.Family = string(v)
+			case 4:
+				m.Help = string(v)
+			default:
+				m.Unit = string(v)
+			}
+			return n, err
+		}
+		return 0, nil
+	})
+	return m, err
+}
+
+// DecodeWriteRequest decodes uncompressed protobuf prometheus.WriteRequest. Exemplars and native
+// histograms are not decoded
+func DecodeWriteRequest(buf []byte) (*WriteRequest, error) {
+	req := &WriteRequest{}
+	err := fields(buf, func(num protowire.Number, typ protowire.Type, buf []byte) (int, error) {
+		switch num {
+		case 1:
+			v, n, err := consumeBytes(typ, buf)
+			if err != nil {
+				return 0, err
+			}
+			ts, err := decodeTimeSeries(v)
+			req.Series = append(req.Series, ts)
+			return n, err
+		case 3:
+			v, n, err := consumeBytes(typ, buf)
+			if err != nil {
+				return 0, err
+			}
+			m, err := decodeMetadata(v)
+			req.Metadata = append(req.Metadata, m)
+			return n, err
+		}
+		return 0, nil
+	})
+	return req, err
+}
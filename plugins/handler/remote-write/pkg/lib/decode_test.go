@@ -0,0 +1,96 @@
+package lib
+
+import (
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+func message(fields ...func([]byte) []byte) []byte {
+	b := []byte{}
+	for _, f := range fields {
+		b = f(b)
+	}
+	return b
+}
+
+func bytesField(num protowire.Number, v []byte) func([]byte) []byte {
+	return func(b []byte) []byte {
+		b = protowire.AppendTag(b, num, protowire.BytesType)
+		return protowire.AppendBytes(b, v)
+	}
+}
+
+func varintField(num protowire.Number, v uint64) func([]byte) []byte {
+	return func(b []byte) []byte {
+		b = protowire.AppendTag(b, num, protowire.VarintType)
+		return protowire.AppendVarint(b, v)
+	}
+}
+
+func doubleField(num protowire.Number, v float64) func([]byte) []byte {
+	return func(b []byte) []byte {
+		b = protowire.AppendTag(b, num, protowire.Fixed64Type)
+		return protowire.AppendFixed64(b, math.Float64bits(v))
+	}
+}
+
+func label(name, value string) func([]byte) []byte {
+	return bytesField(1, message(bytesField(1, []byte(name)), bytesField(2, []byte(value))))
+}
+
+func sample(value float64, ts int64) func([]byte) []byte {
+	return bytesField(2, message(doubleField(1, value), varintField(2, uint64(ts))))
+}
+
+func TestDecodeWriteRequest(t *testing.T) {
+	t.Run("test series and metadata", func(t *testing.T) {
+		blob := message(
+			bytesField(1, message(
+				label("__name__", "up"),
+				label("job", "node"),
+				sample(1, 1700000000000),
+				sample(math.Float64frombits(staleNaN), 1700000015000),
+				bytesField(3, []byte{}),        // exemplar
+				bytesField(4, []byte{0x08, 1}), // native histogram
+			)),
+			bytesField(1, message(label("__name__", "negative_ts"), sample(-2.5, -1000))),
+			bytesField(3, message(
+				varintField(1, uint64(Counter)),
+				bytesField(2, []byte("http_requests_total")),
+				bytesField(4, []byte("Total requests.")),
+				bytesField(5, []byte("")),
+			)),
+			varintField(15, 7), // unknown field
+		)
+
+		req, err := DecodeWriteRequest(blob)
+		require.NoError(t, err)
+		require.Len(t, req.Series, 2)
+		assert.Equal(t, []Label{{"__name__", "up"}, {"job", "node"}}, req.Series[0].Labels)
+		require.Len(t, req.Series[0].Samples, 2)
+		assert.Equal(t, Sample{Value: 1, Timestamp: 1700000000000}, req.Series[0].Samples[0])
+		assert.True(t, IsStale(req.Series[0].Samples[1].Value))
+		assert.False(t, IsStale(math.NaN()))
+		assert.Equal(t, 1, req.Series[0].Histograms)
+		assert.Equal(t, []Sample{{Value: -2.5, Timestamp: -1000}}, req.Series[1].Samples)
+		assert.Equal(t, []Metadata{{Type: Counter, Family: "http_requests_total", Help: "Total requests."}}, req.Metadata)
+	})
+
+	t.Run("test invalid requests", func(t *testing.T) {
+		for _, blob := range [][]byte{
+			{0x0a},       // truncated length
+			{0x0a, 0x05}, // length beyond buffer
+			{0x08, 0x01}, // time series as varint
+			message(bytesField(1, message(varintField(2, 1)))),                         // sample as varint
+			message(bytesField(1, message(bytesField(2, message(varintField(1, 1)))))), // value as varint
+			message(bytesField(3, message(bytesField(1, []byte("counter"))))),          // type as string
+		} {
+			_, err := DecodeWriteRequest(blob)
+			assert.Error(t, err, "%x", blob)
+		}
+	})
+}
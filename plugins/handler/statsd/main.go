@@ -0,0 +1,191 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/openstack-k8s-operators/sg-core/pkg/bus"
+	"github.com/openstack-k8s-operators/sg-core/pkg/config"
+	"github.com/openstack-k8s-operators/sg-core/pkg/data"
+	"github.com/openstack-k8s-operators/sg-core/pkg/handler"
+	"github.com/openstack-k8s-operators/sg-core/plugins/handler/statsd/pkg/lib"
+)
+
+type configT struct {
+	Prefix        string        // prefix of metric names
+	FlushInterval time.Duration `yaml:"flushInterval"`
+	TimerType     string        `yaml:"timerType" validate:"oneof=summary histogram"`
+	Quantiles     []float64     // quantiles of timer summaries
+	Buckets       []float64     // upper bounds of timer histogram buckets
+	SeriesExpiry  time.Duration `yaml:"seriesExpiry"` // aggregation state of series not updated for this long is dropped
+}
+
+func defaultConfig() configT {
+	return configT{
+		Prefix:        "statsd",
+		FlushInterval: 10 * time.Second,
+		TimerType:     lib.TimerSummary,
+		Quantiles:     []float64{0.5, 0.9, 0.99},
+		Buckets:       []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10},
+		SeriesExpiry:  5 * time.Minute,
+	}
+}
+
+type statsdHandler struct {
+	totalMessagesReceived uint64
+	totalSamplesReceived  uint64
+	totalDecodeErrors     uint64
+	statsLock             sync.RWMutex
+	conf                  configT
+	aggregator            *lib.Aggregator
+	now                   func() time.Time
+}
+
+// Handle parses StatsD lines and aggregates them until the next flush
+func (s *statsdHandler) Handle(blob []byte, reportErrors bool, _ bus.MetricPublishFunc, epf bus.EventPublishFunc) error {
+	s.statsLock.Lock()
+	s.totalMessagesReceived++
+	s.statsLock.Unlock()
+
+	now := s.now()
+	for _, line := range bytes.Split(blob, []byte("\n")) {
+		line = bytes.TrimSpace(line)
+		if len(line) == 0 {
+			continue
+		}
+		sample, err := lib.ParseLine(line)
+		if errors.Is(err, lib.ErrSkipped) {
+			continue
+		}
+		if err != nil {
+			s.statsLock.Lock()
+			s.totalDecodeErrors++
+			s.statsLock.Unlock()
+			if reportErrors {
+				epf(data.Event{
+					Index:    s.Identify(),
+					Type:     data.ERROR,
+					Severity: data.CRITICAL,
+					Time:     0.0,
+					Labels: map[string]interface{}{
+						"error":   err.Error(),
+						"context": string(line),
+						"message": "failed to parse statsd line - disregarding",
+					},
+					Annotations: map[string]interface{}{
+						"description": "internal smartgateway statsd handler error",
+					},
+				})
+			}
+			continue
+		}
+		s.aggregator.Add(sample, now)
+		s.statsLock.Lock()
+		s.totalSamplesReceived++
+		s.statsLock.Unlock()
+	}
+	return nil
+}
+
+// flush publishes metrics aggregated since the last flush
+func (s *statsdHandler) flush(mpf bus.MetricPublishFunc) {
+	now := s.now()
+	ts := float64(now.UnixNano()) / float64(time.Second)
+	s.aggregator.Flush(now, func(name string, mType data.MetricType, value float64, labelKeys []string, labelVals []string) {
+		mpf(name, ts, mType, s.conf.FlushInterval, value, labelKeys, labelVals)
+	})
+}
+
+// Run flushes aggregated metrics and sends internal metrics to bus
+func (s *statsdHandler) Run(ctx context.Context, mpf bus.MetricPublishFunc, _ bus.EventPublishFunc) {
+	flushTicker := time.NewTicker(s.conf.FlushInterval)
+	defer flushTicker.Stop()
+	statsTicker := time.NewTicker(time.Second)
+	defer statsTicker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-flushTicker.C:
+			s.flush(mpf)
+		case <-statsTicker.C:
+			s.statsLock.RLock()
+			mpf(
+				"sg_total_statsd_msg_received_count",
+				0,
+				data.COUNTER,
+				0,
+				float64(s.totalMessagesReceived),
+				[]string{"source"},
+				[]string{"SG"},
+			)
+			mpf(
+				"sg_total_statsd_sample_received_count",
+				0,
+				data.COUNTER,
+				0,
+				float64(s.totalSamplesReceived),
+				[]string{"source"},
+				[]string{"SG"},
+			)
+			mpf(
+				"sg_total_statsd_decode_error_count",
+				0,
+				data.COUNTER,
+				0,
+				float64(s.totalDecodeErrors),
+				[]string{"source"},
+				[]string{"SG"},
+			)
+			s.statsLock.RUnlock()
+		}
+	}
+}
+
+func (s *statsdHandler) Identify() string {
+	return "statsd"
+}
+
+func (s *statsdHandler) Config(c []byte) error {
+	s.conf = defaultConfig()
+	err := config.ParseConfig(bytes.NewReader(c), &s.conf)
+	if err != nil {
+		return err
+	}
+	if s.conf.FlushInterval <= 0 {
+		return fmt.Errorf("flushInterval has to be positive")
+	}
+	for _, q := range s.conf.Quantiles {
+		if q < 0 || q > 1 {
+			return fmt.Errorf("invalid quantile %g, has to be between 0 and 1", q)
+		}
+	}
+	sort.Float64s(s.conf.Buckets)
+	s.aggregator = newAggregator(s.conf)
+	return nil
+}
+
+func newAggregator(conf configT) *lib.Aggregator {
+	return lib.NewAggregator(lib.AggregatorConfig{
+		Prefix:    conf.Prefix,
+		TimerType: conf.TimerType,
+		Quantiles: conf.Quantiles,
+		Buckets:   conf.Buckets,
+		Expiry:    conf.SeriesExpiry,
+	})
+}
+
+// New create new statsdHandler object
+func New() handler.Handler {
+	conf := defaultConfig()
+	return &statsdHandler{
+		conf:       conf,
+		aggregator: newAggregator(conf),
+		now:        time.Now,
+	}
+}
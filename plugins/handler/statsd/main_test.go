@@ -0,0 +1,156 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/openstack-k8s-operators/sg-core/pkg/data"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var flushTime = time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+
+func newTestHandler(t *testing.T, conf string) *statsdHandler {
+	s := New().(*statsdHandler)
+	require.NoError(t, s.Config([]byte(conf)))
+	s.now = func() time.Time {
+		return flushTime
+	}
+	return s
+}
+
+// flushMetrics flushes handler and returns published metrics
+func flushMetrics(s *statsdHandler) []data.Metric {
+	metrics := []data.Metric{}
+	s.flush(func(name string, mTime float64, mType data.MetricType, interval time.Duration, value float64, labelKeys []string, labelVals []string) {
+		metrics = append(metrics, data.Metric{
+			Name:      name,
+			Time:      mTime,
+			Type:      mType,
+			Interval:  interval,
+			Value:     value,
+			LabelKeys: labelKeys,
+			LabelVals: labelVals,
+		})
+	})
+	return metrics
+}
+
+func metric(name string, mType data.MetricType, value float64, labels ...string) data.Metric {
+	m := data.Metric{
+		Name:      name,
+		Time:      float64(flushTime.Unix()),
+		Type:      mType,
+		Interval:  time.Second,
+		Value:     value,
+		LabelKeys: []string{},
+		LabelVals: []string{},
+	}
+	for i := 0; i < len(labels); i += 2 {
+		m.LabelKeys = append(m.LabelKeys, labels[i])
+		m.LabelVals = append(m.LabelVals, labels[i+1])
+	}
+	return m
+}
+
+func handle(t *testing.T, s *statsdHandler, lines string) {
+	require.NoError(t, s.Handle([]byte(lines), false, nil, func(data.Event) {}))
+}
+
+func TestStatsd(t *testing.T) {
+	t.Run("test counters, gauges and sets", func(t *testing.T) {
+		s := newTestHandler(t, "flushInterval: 1s")
+		handle(t, s, "api.requests:1|c|#env:prod,code:200\napi.requests:2|c|@0.5|#code:200,env:prod\n"+
+			"queue-size:10|g\nqueue-size:-3|g\nqueue-size:+1|g\n"+
+			"users:alice|s\nusers:bob|s\nusers:alice|s\n"+
+			"_e{5,4}:title|text\n")
+		assert.ElementsMatch(t, []data.Metric{
+			metric("statsd_api_requests_total", data.COUNTER, 5, "code", "200", "env", "prod"),
+			metric("statsd_queue_size", data.GAUGE, 8),
+			metric("statsd_users", data.GAUGE, 2),
+		}, flushMetrics(s))
+
+		// counters are cumulative, only updated series are published
+		handle(t, s, "api.requests:1|c|#env:prod,code:200\nusers:carol|s")
+		assert.ElementsMatch(t, []data.Metric{
+			metric("statsd_api_requests_total", data.COUNTER, 6, "code", "200", "env", "prod"),
+			metric("statsd_users", data.GAUGE, 1),
+		}, flushMetrics(s))
+		assert.Empty(t, flushMetrics(s))
+		assert.Equal(t, uint64(0), s.totalDecodeErrors)
+		assert.Equal(t, uint64(10), s.totalSamplesReceived)
+	})
+
+	t.Run("test timer summary", func(t *testing.T) {
+		s := newTestHandler(t, "flushInterval: 1s\nprefix: \"\"\nquantiles: [0.5, 0.9]")
+		handle(t, s, "db.query:100|ms|#db:nova\ndb.query:300|ms|#db:nova\ndb.query:200:400|ms|#db:nova\ndb.query:500|ms|@0.5|#db:nova")
+		assert.ElementsMatch(t, []data.Metric{
+			metric("db_query", data.GAUGE, 0.3, "db", "nova", "quantile", "0.5"),
+			metric("db_query", data.GAUGE, 0.5, "db", "nova", "quantile", "0.9"),
+			metric("db_query_sum", data.COUNTER, 2, "db", "nova"),
+			metric("db_query_count", data.COUNTER, 6, "db", "nova"),
+		}, flushMetrics(s))
+	})
+
+	t.Run("test timer histogram", func(t *testing.T) {
+		s := newTestHandler(t, "flushInterval: 1s\ntimerType: histogram\nbuckets: [10, 1]")
+		handle(t, s, "payload:0.5:2|h\npayload:20|d")
+		assert.ElementsMatch(t, []data.Metric{
+			metric("statsd_payload_bucket", data.COUNTER, 1, "le", "1"),
+			metric("statsd_payload_bucket", data.COUNTER, 2, "le", "10"),
+			metric("statsd_payload_bucket", data.COUNTER, 3, "le", "+Inf"),
+			metric("statsd_payload_sum", data.COUNTER, 22.5),
+			metric("statsd_payload_count", data.COUNTER, 3),
+		}, flushMetrics(s))
+
+		handle(t, s, "payload:5|h")
+		assert.ElementsMatch(t, []data.Metric{
+			metric("statsd_payload_bucket", data.COUNTER, 1, "le", "1"),
+			metric("statsd_payload_bucket", data.COUNTER, 3, "le", "10"),
+			metric("statsd_payload_bucket", data.COUNTER, 4, "le", "+Inf"),
+			metric("statsd_payload_sum", data.COUNTER, 27.5),
+			metric("statsd_payload_count", data.COUNTER, 4),
+		}, flushMetrics(s))
+	})
+
+	t.Run("test series expiry", func(t *testing.T) {
+		s := newTestHandler(t, "flushInterval: 1s\nseriesExpiry: 1m")
+		handle(t, s, "jobs:2|c")
+		assert.Equal(t, []data.Metric{metric("statsd_jobs_total", data.COUNTER, 2)}, flushMetrics(s))
+
+		later := flushTime.Add(2 * time.Minute)
+		s.now = func() time.Time {
+			return later
+		}
+		assert.Empty(t, flushMetrics(s))
+		// counter starts from zero after its state expired
+		handle(t, s, "jobs:1|c")
+		expected := metric("statsd_jobs_total", data.COUNTER, 1)
+		expected.Time = float64(later.Unix())
+		assert.Equal(t, []data.Metric{expected}, flushMetrics(s))
+	})
+
+	t.Run("test invalid lines", func(t *testing.T) {
+		s := newTestHandler(t, "flushInterval: 1s")
+		events := []data.Event{}
+		err := s.Handle([]byte("valid:1|g\ninvalid:1|x\ninvalid\n"), true, nil, func(e data.Event) {
+			events = append(events, e)
+		})
+		require.NoError(t, err)
+		assert.Equal(t, uint64(2), s.totalDecodeErrors)
+		require.Len(t, events, 2)
+		assert.Equal(t, data.ERROR, events[0].Type)
+		assert.Equal(t, []data.Metric{metric("statsd_valid", data.GAUGE, 1)}, flushMetrics(s))
+	})
+
+	t.Run("test invalid configuration", func(t *testing.T) {
+		for _, conf := range []string{
+			"flushInterval: 0s",
+			"timerType: average",
+			"quantiles: [0.5, 1.5]",
+		} {
+			assert.Error(t, New().Config([]byte(conf)), conf)
+		}
+	})
+}
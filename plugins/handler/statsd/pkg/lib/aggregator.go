@@ -0,0 +1,225 @@
+package lib
+
+import (
+	"math"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/openstack-k8s-operators/sg-core/pkg/data"
+)
+
+var invalidNameChars = regexp.MustCompile(`[^a-zA-Z0-9_]`)
+
+// Timer aggregation types
+const (
+	TimerSummary   = "summary"
+	TimerHistogram = "histogram"
+)
+
+// AggregatorConfig configures aggregation of samples
+type AggregatorConfig struct {
+	Prefix    string
+	TimerType string    // TimerSummary or TimerHistogram
+	Quantiles []float64 // quantiles of summaries
+	Buckets   []float64 // upper bounds of histogram buckets
+	Expiry    time.Duration
+}
+
+// PublishFn receives aggregated metrics
+type PublishFn func(name string, mType data.MetricType, value float64, labelKeys []string, labelVals []string)
+
+type series struct {
+	name      string
+	typ       MetricType
+	labelKeys []string
+	labelVals []string
+	updated   bool
+	lastSeen  time.Time
+	value     float64             // total of counter, value of gauge
+	members   map[string]struct{} // set members received during flush interval
+	samples   []float64           // timer values received during flush interval
+	count     float64             // cumulative timer count
+	sum       float64             // cumulative timer sum
+	buckets   []float64           // cumulative histogram bucket counts
+}
+
+// Aggregator aggregates samples between flushes
+type Aggregator struct {
+	conf   AggregatorConfig
+	lock   sync.Mutex
+	series map[string]*series
+}
+
+// NewAggregator creates aggregator, buckets are expected to be sorted
+func NewAggregator(conf AggregatorConfig) *Aggregator {
+	return &Aggregator{
+		conf:   conf,
+		series: map[string]*series{},
+	}
+}
+
+// Add aggregates sample
+func (a *Aggregator) Add(s Sample, now time.Time) {
+	key := seriesKey(s)
+	a.lock.Lock()
+	defer a.lock.Unlock()
+
+	ser, ok := a.series[key]
+	if !ok {
+		ser = a.newSeries(s)
+		a.series[key] = ser
+	}
+	ser.updated = true
+	ser.lastSeen = now
+
+	switch s.Type {
+	case Counter:
+		for _, v := range s.Values {
+			ser.value += v / s.Rate
+		}
+	case Gauge:
+		for _, v := range s.Values {
+			if s.Delta {
+				ser.value += v
+			} else {
+				ser.value = v
+			}
+		}
+	case Set:
+		ser.members[s.SetValue] = struct{}{}
+	case Timer, Histogram:
+		for _, v := range s.Values {
+			if s.Type == Timer {
+				// timers are in milliseconds
+				v /= 1000
+			}
+			ser.samples = append(ser.samples, v)
+			ser.count += 1 / s.Rate
+			ser.sum += v / s.Rate
+			for i, bound := range a.conf.Buckets {
+				if v <= bound {
+					ser.buckets[i] += 1 / s.Rate
+				}
+			}
+		}
+	}
+}
+
+// Flush publishes series updated since the last flush and forgets series not updated within expiry
+func (a *Aggregator) Flush(now time.Time, publish PublishFn) {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+
+	for key, ser := range a.series {
+		if !ser.updated {
+			if now.Sub(ser.lastSeen) > a.conf.Expiry {
+				delete(a.series, key)
+			}
+			continue
+		}
+		ser.updated = false
+
+		switch ser.typ {
+		case Counter:
+			publish(ser.name, data.COUNTER, ser.value, ser.labelKeys, ser.labelVals)
+		case Gauge:
+			publish(ser.name, data.GAUGE, ser.value, ser.labelKeys, ser.labelVals)
+		case Set:
+			publish(ser.name, data.GAUGE, float64(len(ser.members)), ser.labelKeys, ser.labelVals)
+			ser.members = map[string]struct{}{}
+		case Timer, Histogram:
+			a.flushTimer(ser, publish)
+			ser.samples = ser.samples[:0]
+		}
+	}
+}
+
+func (a *Aggregator) flushTimer(ser *series, publish PublishFn) {
+	keys := append(append([]string{}, ser.labelKeys...), "")
+	vals := append(append([]string{}, ser.labelVals...), "")
+	last := len(keys) - 1
+
+	if a.conf.TimerType == TimerHistogram {
+		keys[last] = "le"
+		for i, bound := range a.conf.Buckets {
+			vals[last] = strconv.FormatFloat(bound, 'g', -1, 64)
+			publish(ser.name+"_bucket", data.COUNTER, ser.buckets[i], keys, append([]string{}, vals...))
+		}
+		vals[last] = "+Inf"
+		publish(ser.name+"_bucket", data.COUNTER, ser.count, keys, vals)
+	} else {
+		sort.Float64s(ser.samples)
+		keys[last] = "quantile"
+		for _, q := range a.conf.Quantiles {
+			vals[last] = strconv.FormatFloat(q, 'g', -1, 64)
+			publish(ser.name, data.GAUGE, quantile(ser.samples, q), keys, append([]string{}, vals...))
+		}
+	}
+	publish(ser.name+"_sum", data.COUNTER, ser.sum, ser.labelKeys, ser.labelVals)
+	publish(ser.name+"_count", data.COUNTER, ser.count, ser.labelKeys, ser.labelVals)
+}
+
+func (a *Aggregator) newSeries(s Sample) *series {
+	name := s.Name
+	if a.conf.Prefix != "" {
+		name = a.conf.Prefix + "_" + name
+	}
+	name = sanitize(name)
+	if s.Type == Counter && !strings.HasSuffix(name, "_total") {
+		name += "_total"
+	}
+
+	ser := &series{
+		name:      name,
+		typ:       s.Type,
+		labelKeys: make([]string, 0, len(s.Tags)),
+		labelVals: make([]string, 0, len(s.Tags)),
+		members:   map[string]struct{}{},
+		buckets:   make([]float64, len(a.conf.Buckets)),
+	}
+	for _, tag := range s.Tags {
+		ser.labelKeys = append(ser.labelKeys, sanitize(tag.Key))
+		ser.labelVals = append(ser.labelVals, tag.Value)
+	}
+	return ser
+}
+
+// seriesKey identifies series by name, type and tags
+func seriesKey(s Sample) string {
+	var b strings.Builder
+	b.WriteString(s.Name)
+	b.WriteByte('|')
+	b.WriteString(strconv.Itoa(int(s.Type)))
+	for _, tag := range s.Tags {
+		b.WriteByte('|')
+		b.WriteString(tag.Key)
+		b.WriteByte('=')
+		b.WriteString(tag.Value)
+	}
+	return b.String()
+}
+
+// sanitize converts StatsD name to valid Prometheus metric or label name
+func sanitize(name string) string {
+	name = invalidNameChars.ReplaceAllString(name, "_")
+	if name == "" || (name[0] >= '0' && name[0] <= '9') {
+		name = "_" + name
+	}
+	return name
+}
+
+// quantile returns value of q-quantile of sorted samples using nearest-rank method
+func quantile(sorted []float64, q float64) float64 {
+	if len(sorted) == 0 {
+		return math.NaN()
+	}
+	idx := int(math.Ceil(q*float64(len(sorted)))) - 1
+	if idx < 0 {
+		idx = 0
+	}
+	return sorted[min(idx, len(sorted)-1)]
+}
@@ -0,0 +1,136 @@
+package lib
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// MetricType is type of StatsD metric
+type MetricType int
+
+// StatsD metric types. Histograms and distributions of DogStatsD are aggregated same as timers
+const (
+	Counter MetricType = iota
+	Gauge
+	Timer
+	Histogram
+	Set
+)
+
+var strToType = map[string]MetricType{
+	"c":  Counter,
+	"g":  Gauge,
+	"ms": Timer,
+	"h":  Histogram,
+	"d":  Histogram,
+	"s":  Set,
+}
+
+// Tag is DogStatsD tag. Tags without value have empty Value
+type Tag struct {
+	Key   string
+	Value string
+}
+
+// Sample is single StatsD line
+type Sample struct {
+	Name string
+	Type MetricType
+	// Values holds numeric values, DogStatsD allows more values in one line
+	Values []float64
+	// SetValue holds member of Set
+	SetValue string
+	// Delta is true for gauges with explicit sign, which modify the previous value
+	Delta bool
+	Rate  float64
+	// Tags are sorted by key
+	Tags []Tag
+}
+
+// ErrSkipped is returned for DogStatsD events and service checks, which are not metrics
+var ErrSkipped = errors.New("not a metric")
+
+// ParseLine parses single StatsD or DogStatsD line in format <name>:<value>|<type>[|@<rate>][|#<tags>]
+func ParseLine(line []byte) (Sample, error) {
+	s := Sample{Rate: 1}
+	if bytes.HasPrefix(line, []byte("_e{")) || bytes.HasPrefix(line, []byte("_sc|")) {
+		return s, ErrSkipped
+	}
+
+	fields := strings.Split(string(line), "|")
+	if len(fields) < 2 {
+		return s, fmt.Errorf("missing metric type: %q", line)
+	}
+
+	sep := strings.IndexByte(fields[0], ':')
+	if sep < 1 {
+		return s, fmt.Errorf("missing metric name or value: %q", line)
+	}
+	s.Name = fields[0][:sep]
+	rawValues := strings.Split(fields[0][sep+1:], ":")
+
+	var ok bool
+	if s.Type, ok = strToType[fields[1]]; !ok {
+		return s, fmt.Errorf("unknown metric type %q", fields[1])
+	}
+
+	for _, field := range fields[2:] {
+		switch {
+		case strings.HasPrefix(field, "@"):
+			rate, err := strconv.ParseFloat(field[1:], 64)
+			if err != nil || rate <= 0 || rate > 1 {
+				return s, fmt.Errorf("invalid sample rate %q", field)
+			}
+			s.Rate = rate
+		case strings.HasPrefix(field, "#"):
+			s.Tags = parseTags(field[1:])
+		}
+		// other DogStatsD extensions (container ID, timestamp) are ignored
+	}
+
+	if s.Type == Set {
+		if len(rawValues) != 1 || rawValues[0] == "" {
+			return s, fmt.Errorf("invalid set value: %q", line)
+		}
+		s.SetValue = rawValues[0]
+		return s, nil
+	}
+	if s.Type == Gauge && len(rawValues) == 1 && (rawValues[0] != "" && (rawValues[0][0] == '+' || rawValues[0][0] == '-')) {
+		s.Delta = true
+	}
+	for _, raw := range rawValues {
+		value, err := strconv.ParseFloat(raw, 64)
+		if err != nil {
+			return s, fmt.Errorf("invalid value %q", raw)
+		}
+		s.Values = append(s.Values, value)
+	}
+	return s, nil
+}
+
+func parseTags(raw string) []Tag {
+	tags := []Tag{}
+	for _, tag := range strings.Split(raw, ",") {
+		if tag == "" {
+			continue
+		}
+		key, value, _ := strings.Cut(tag, ":")
+		tags = append(tags, Tag{Key: key, Value: value})
+	}
+	sort.SliceStable(tags, func(i, j int) bool {
+		return tags[i].Key < tags[j].Key
+	})
+	// the last value of repeated key wins
+	unique := tags[:0]
+	for i, tag := range tags {
+		if i+1 < len(tags) && tags[i+1].Key == tag.Key {
+			continue
+		}
+		unique = append(unique, tag)
+	}
+	return unique
+}
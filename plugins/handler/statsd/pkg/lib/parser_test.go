@@ -0,0 +1,71 @@
+package lib
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseLine(t *testing.T) {
+	t.Run("test valid lines", func(t *testing.T) {
+		for line, expected := range map[string]Sample{
+			"api.requests:1|c": {
+				Name: "api.requests", Type: Counter, Values: []float64{1}, Rate: 1,
+			},
+			"api.requests:3|c|@0.1|#region:east,env:prod": {
+				Name: "api.requests", Type: Counter, Values: []float64{3}, Rate: 0.1,
+				Tags: []Tag{{Key: "env", Value: "prod"}, {Key: "region", Value: "east"}},
+			},
+			"queue.size:-4|g": {
+				Name: "queue.size", Type: Gauge, Values: []float64{-4}, Delta: true, Rate: 1,
+			},
+			"queue.size:42.5|g|#canary,env:a,env:b": {
+				Name: "queue.size", Type: Gauge, Values: []float64{42.5}, Rate: 1,
+				Tags: []Tag{{Key: "canary"}, {Key: "env", Value: "b"}},
+			},
+			"db.query:12.5|ms": {
+				Name: "db.query", Type: Timer, Values: []float64{12.5}, Rate: 1,
+			},
+			"payload:1:2:3|h|c:83a1b0|T1700000000": {
+				Name: "payload", Type: Histogram, Values: []float64{1, 2, 3}, Rate: 1,
+			},
+			"latency:7|d": {
+				Name: "latency", Type: Histogram, Values: []float64{7}, Rate: 1,
+			},
+			"users.unique:alice|s": {
+				Name: "users.unique", Type: Set, SetValue: "alice", Rate: 1,
+			},
+		} {
+			sample, err := ParseLine([]byte(line))
+			require.NoError(t, err, line)
+			assert.Equal(t, expected, sample, line)
+		}
+	})
+
+	t.Run("test events and service checks are skipped", func(t *testing.T) {
+		for _, line := range []string{
+			"_e{5,4}:title|text|#env:prod",
+			"_sc|redis.can_connect|0",
+		} {
+			_, err := ParseLine([]byte(line))
+			assert.ErrorIs(t, err, ErrSkipped, line)
+		}
+	})
+
+	t.Run("test invalid lines", func(t *testing.T) {
+		for _, line := range []string{
+			"api.requests",
+			"api.requests:1",
+			":1|c",
+			"api.requests:one|c",
+			"api.requests:1|x",
+			"api.requests:1|c|@2",
+			"api.requests:1|c|@zero",
+			"users.unique:|s",
+		} {
+			_, err := ParseLine([]byte(line))
+			assert.Error(t, err, line)
+		}
+	})
+}
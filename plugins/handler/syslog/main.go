@@ -0,0 +1,176 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/openstack-k8s-operators/sg-core/pkg/bus"
+	"github.com/openstack-k8s-operators/sg-core/pkg/config"
+	"github.com/openstack-k8s-operators/sg-core/pkg/data"
+	"github.com/openstack-k8s-operators/sg-core/pkg/handler"
+	logslib "github.com/openstack-k8s-operators/sg-core/plugins/handler/logs/pkg/lib"
+	"github.com/openstack-k8s-operators/sg-core/plugins/handler/syslog/pkg/lib"
+)
+
+var invalidLabelChars = regexp.MustCompile(`[^a-zA-Z0-9_]`)
+
+type configT struct {
+	IndexPrefix string `yaml:"indexPrefix"`
+	Timezone    string `yaml:"timezone"` // time zone of RFC 3164 timestamps
+}
+
+type syslogHandler struct {
+	totalLogsReceived uint64
+	totalLogsFailed   uint64
+	statsLock         sync.RWMutex
+	conf              configT
+	location          *time.Location
+	now               func() time.Time
+}
+
+func (s *syslogHandler) parse(msg []byte) (data.Event, error) {
+	m, err := lib.Parse(msg, s.now(), s.location)
+	if err != nil {
+		return data.Event{}, err
+	}
+
+	hostname := m.Hostname
+	if hostname == "" {
+		hostname = "unknown"
+	}
+	labels := map[string]interface{}{
+		"host":     hostname,
+		"facility": lib.FacilityName(m.Facility),
+		"severity": strconv.Itoa(m.Severity),
+	}
+	for key, value := range map[string]string{"appname": m.AppName, "procid": m.ProcID, "msgid": m.MsgID} {
+		if value != "" {
+			labels[key] = value
+		}
+	}
+	for id, params := range m.StructuredData {
+		for name, value := range params {
+			labels[sdLabel(id, name)] = value
+		}
+	}
+
+	t := m.Timestamp
+	year, month, day := t.UTC().Date()
+	return data.Event{
+		Index:     fmt.Sprintf("%s-%s.%d.%02d.%02d", s.conf.IndexPrefix, strings.ReplaceAll(hostname, "-", "_"), year, month, day),
+		Time:      float64(t.UnixNano()) / float64(time.Second),
+		Type:      data.LOG,
+		Publisher: hostname,
+		Severity:  logslib.SyslogSeverity(m.Severity).ToEventSeverity(),
+		Labels:    labels,
+		Message:   m.Message,
+	}, nil
+}
+
+// sdLabel creates label name from structured data element ID and parameter name
+func sdLabel(id string, name string) string {
+	return invalidLabelChars.ReplaceAllString(fmt.Sprintf("sd_%s_%s", id, name), "_")
+}
+
+// Handle implements the data.EventsHandler interface
+func (s *syslogHandler) Handle(msg []byte, reportErrors bool, _ bus.MetricPublishFunc, epf bus.EventPublishFunc) error {
+	s.statsLock.Lock()
+	s.totalLogsReceived++
+	s.statsLock.Unlock()
+
+	log, err := s.parse(msg)
+	if err == nil {
+		epf(log)
+		return nil
+	}
+
+	s.statsLock.Lock()
+	s.totalLogsFailed++
+	s.statsLock.Unlock()
+	if reportErrors {
+		epf(data.Event{
+			Index:    s.Identify(),
+			Type:     data.ERROR,
+			Severity: data.CRITICAL,
+			Time:     0.0,
+			Labels: map[string]interface{}{
+				"error":   err.Error(),
+				"context": string(msg),
+				"message": "failed to parse syslog message - disregarding",
+			},
+			Annotations: map[string]interface{}{
+				"description": "internal smartgateway syslog handler error",
+			},
+		})
+	}
+	return err
+}
+
+// Run send internal metrics to bus
+func (s *syslogHandler) Run(ctx context.Context, mpf bus.MetricPublishFunc, _ bus.EventPublishFunc) {
+	for {
+		select {
+		case <-ctx.Done():
+			goto done
+		case <-time.After(time.Second):
+			s.statsLock.RLock()
+			mpf(
+				"sg_total_syslog_received",
+				0,
+				data.COUNTER,
+				0,
+				float64(s.totalLogsReceived),
+				[]string{"source"},
+				[]string{"SG"},
+			)
+			mpf(
+				"sg_total_syslog_decode_error",
+				0,
+				data.COUNTER,
+				0,
+				float64(s.totalLogsFailed),
+				[]string{"source"},
+				[]string{"SG"},
+			)
+			s.statsLock.RUnlock()
+		}
+	}
+done:
+}
+
+func (s *syslogHandler) Identify() string {
+	return "syslog"
+}
+
+// New create new syslogHandler object
+func New() handler.Handler {
+	return &syslogHandler{
+		conf: configT{
+			IndexPrefix: "sglogs",
+		},
+		location: time.Local,
+		now:      time.Now,
+	}
+}
+
+func (s *syslogHandler) Config(c []byte) error {
+	s.conf = configT{
+		IndexPrefix: "sglogs",
+		Timezone:    "Local",
+	}
+	err := config.ParseConfig(bytes.NewReader(c), &s.conf)
+	if err != nil {
+		return err
+	}
+	s.location, err = time.LoadLocation(s.conf.Timezone)
+	if err != nil {
+		return fmt.Errorf("invalid timezone: %w", err)
+	}
+	return nil
+}
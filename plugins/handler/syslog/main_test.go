@@ -0,0 +1,208 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/openstack-k8s-operators/sg-core/pkg/data"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type parsingTestCase struct {
+	Message   string
+	ParsedLog data.Event
+}
+
+var received = time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+
+var parsingCases = []parsingTestCase{
+	{
+		// RFC 5424 example with structured data
+		Message: `<165>1 2024-02-11T22:14:15.003Z mymachine.example.com evntslog - ID47 [exampleSDID@32473 iut="3" eventSource="Application" eventID="1011"][examplePriority@32473 class="high \"x\\y\"\]"] ` + "\xef\xbb\xbf" + `An application event log entry...`,
+		ParsedLog: data.Event{
+			Index:     "sglogs-mymachine.example.com.2024.02.11",
+			Time:      1707689655.003,
+			Type:      data.LOG,
+			Publisher: "mymachine.example.com",
+			Severity:  data.INFO,
+			Labels: map[string]interface{}{
+				"host":                             "mymachine.example.com",
+				"facility":                         "local4",
+				"severity":                         "5",
+				"appname":                          "evntslog",
+				"msgid":                            "ID47",
+				"sd_exampleSDID_32473_iut":         "3",
+				"sd_exampleSDID_32473_eventSource": "Application",
+				"sd_exampleSDID_32473_eventID":     "1011",
+				"sd_examplePriority_32473_class":   `high "x\y"]`,
+			},
+			Message: "An application event log entry...",
+		},
+	},
+	{
+		// RFC 5424 with nil values and no message
+		Message: `<34>1 2024-02-11T22:14:15+01:00 compute-0 su 1234 - -`,
+		ParsedLog: data.Event{
+			Index:     "sglogs-compute_0.2024.02.11",
+			Time:      1707686055,
+			Type:      data.LOG,
+			Publisher: "compute-0",
+			Severity:  data.CRITICAL,
+			Labels: map[string]interface{}{
+				"host":     "compute-0",
+				"facility": "auth",
+				"severity": "2",
+				"appname":  "su",
+				"procid":   "1234",
+			},
+		},
+	},
+	{
+		// RFC 5424 with nil timestamp gets time of receiving
+		Message: `<14>1 - compute-0 nova - - - instance started`,
+		ParsedLog: data.Event{
+			Index:     "sglogs-compute_0.2024.03.01",
+			Time:      1709294400,
+			Type:      data.LOG,
+			Publisher: "compute-0",
+			Severity:  data.INFO,
+			Labels: map[string]interface{}{
+				"host":     "compute-0",
+				"facility": "user",
+				"severity": "6",
+				"appname":  "nova",
+			},
+			Message: "instance started",
+		},
+	},
+	{
+		// RFC 3164 with trailing newline
+		Message: "<13>Feb  5 17:32:18 switch-1 sshd[4123]: Accepted publickey for admin\n",
+		ParsedLog: data.Event{
+			Index:     "sglogs-switch_1.2024.02.05",
+			Time:      1707154338,
+			Type:      data.LOG,
+			Publisher: "switch-1",
+			Severity:  data.INFO,
+			Labels: map[string]interface{}{
+				"host":     "switch-1",
+				"facility": "user",
+				"severity": "5",
+				"appname":  "sshd",
+				"procid":   "4123",
+			},
+			Message: "Accepted publickey for admin",
+		},
+	},
+	{
+		// RFC 3164 from previous year and without hostname
+		Message: "<28>Dec 31 23:59:59 kernel: link down",
+		ParsedLog: data.Event{
+			Index:     "sglogs-unknown.2023.12.31",
+			Time:      1704067199,
+			Type:      data.LOG,
+			Publisher: "unknown",
+			Severity:  data.WARNING,
+			Labels: map[string]interface{}{
+				"host":     "unknown",
+				"facility": "daemon",
+				"severity": "4",
+				"appname":  "kernel",
+			},
+			Message: "link down",
+		},
+	},
+	{
+		// rsyslog forwarding format with RFC 3339 timestamp
+		Message: "<191>2024-02-29T10:00:00.5Z hypervisor libvirtd: domain started",
+		ParsedLog: data.Event{
+			Index:     "sglogs-hypervisor.2024.02.29",
+			Time:      1709200800.5,
+			Type:      data.LOG,
+			Publisher: "hypervisor",
+			Severity:  data.DEBUG,
+			Labels: map[string]interface{}{
+				"host":     "hypervisor",
+				"facility": "local7",
+				"severity": "7",
+				"appname":  "libvirtd",
+			},
+			Message: "domain started",
+		},
+	},
+	{
+		// RFC 3164 without timestamp uses time of receiving
+		Message: "<14>just a message",
+		ParsedLog: data.Event{
+			Index:     "sglogs-unknown.2024.03.01",
+			Time:      1709294400,
+			Type:      data.LOG,
+			Publisher: "unknown",
+			Severity:  data.INFO,
+			Labels: map[string]interface{}{
+				"host":     "unknown",
+				"facility": "user",
+				"severity": "6",
+			},
+			Message: "just a message",
+		},
+	},
+}
+
+var invalidMessages = []string{
+	"no pri",
+	"<192>1 - - - - - -",
+	"<13>1 yesterday host app - - -",
+	`<13>1 - host app - - [id param="unterminated]`,
+	`<13>1 - host app - - [id param=unquoted]`,
+}
+
+func newTestHandler() *syslogHandler {
+	return &syslogHandler{
+		conf:     configT{IndexPrefix: "sglogs"},
+		location: time.UTC,
+		now: func() time.Time {
+			return received
+		},
+	}
+}
+
+func TestSyslog(t *testing.T) {
+	t.Run("test correct syslog parsing", func(t *testing.T) {
+		for _, testCase := range parsingCases {
+			s := newTestHandler()
+			parsed, err := s.parse([]byte(testCase.Message))
+			require.NoError(t, err, testCase.Message)
+			assert.Equal(t, testCase.ParsedLog, parsed, testCase.Message)
+
+			expected := testCase.ParsedLog
+			err = s.Handle([]byte(testCase.Message), true, nil, func(evt data.Event) {
+				assert.Equal(t, expected, evt)
+			})
+			require.NoError(t, err)
+		}
+	})
+
+	t.Run("test invalid messages", func(t *testing.T) {
+		s := newTestHandler()
+		for _, msg := range invalidMessages {
+			events := []data.Event{}
+			err := s.Handle([]byte(msg), true, nil, func(evt data.Event) {
+				events = append(events, evt)
+			})
+			assert.Error(t, err, msg)
+			require.Len(t, events, 1, msg)
+			assert.Equal(t, data.ERROR, events[0].Type)
+		}
+		assert.Equal(t, uint64(len(invalidMessages)), s.totalLogsFailed)
+	})
+
+	t.Run("test configuration", func(t *testing.T) {
+		s := New().(*syslogHandler)
+		require.NoError(t, s.Config([]byte("indexPrefix: syslog\ntimezone: Europe/Prague\n")))
+		assert.Equal(t, "syslog", s.conf.IndexPrefix)
+		assert.Equal(t, "Europe/Prague", s.location.String())
+		assert.Error(t, s.Config([]byte("timezone: Mars/Olympus\n")))
+	})
+}
@@ -0,0 +1,255 @@
+package lib
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// syslog message formats
+const (
+	RFC5424 = "rfc5424"
+	RFC3164 = "rfc3164"
+)
+
+const nilValue = "-"
+
+var (
+	errMissingPri = errors.New("message does not start with <PRI>")
+	errInvalidSD  = errors.New("invalid structured data")
+	utf8BOM       = []byte{0xef, 0xbb, 0xbf}
+)
+
+var facilities = []string{
+	"kern", "user", "mail", "daemon", "auth", "syslog", "lpr", "news",
+	"uucp", "cron", "authpriv", "ftp", "ntp", "audit", "alert", "clock",
+	"local0", "local1", "local2", "local3", "local4", "local5", "local6", "local7",
+}
+
+// FacilityName returns rsyslog name of syslog facility code
+func FacilityName(facility int) string {
+	if facility < 0 || facility >= len(facilities) {
+		return strconv.Itoa(facility)
+	}
+	return facilities[facility]
+}
+
+// Message holds parsed syslog message
+type Message struct {
+	Format    string
+	Facility  int
+	Severity  int
+	Timestamp time.Time
+	Hostname  string
+	AppName   string
+	ProcID    string
+	MsgID     string
+	// StructuredData maps SD-ID to its parameters
+	StructuredData map[string]map[string]string
+	Message        string
+}
+
+// Parse parses RFC 5424 or RFC 3164 syslog message. Time of receiving is used for messages
+// lacking timestamp, loc is used for RFC 3164 timestamps, which do not carry time zone
+func Parse(msg []byte, received time.Time, loc *time.Location) (Message, error) {
+	msg = bytes.TrimRight(msg, "\r\n\x00")
+	facility, severity, rest, err := parsePri(msg)
+	if err != nil {
+		return Message{}, err
+	}
+	// RFC 5424 has VERSION right after PRI
+	if len(rest) > 1 && rest[0] == '1' && rest[1] == ' ' {
+		m, err := parse5424(rest[2:], received)
+		m.Facility, m.Severity = facility, severity
+		return m, err
+	}
+	m := parse3164(rest, received, loc)
+	m.Facility, m.Severity = facility, severity
+	return m, nil
+}
+
+func parsePri(msg []byte) (int, int, []byte, error) {
+	if len(msg) < 3 || msg[0] != '<' {
+		return 0, 0, nil, errMissingPri
+	}
+	end := bytes.IndexByte(msg[:min(len(msg), 5)], '>')
+	if end < 2 {
+		return 0, 0, nil, errMissingPri
+	}
+	pri, err := strconv.Atoi(string(msg[1:end]))
+	if err != nil || pri > 191 || pri < 0 {
+		return 0, 0, nil, fmt.Errorf("invalid PRI %q", msg[1:end])
+	}
+	return pri / 8, pri % 8, msg[end+1:], nil
+}
+
+// nextField returns space delimited field and the rest of the message
+func nextField(msg []byte) (string, []byte) {
+	idx := bytes.IndexByte(msg, ' ')
+	if idx < 0 {
+		return string(msg), nil
+	}
+	return string(msg[:idx]), msg[idx+1:]
+}
+
+func nilable(field string) string {
+	if field == nilValue {
+		return ""
+	}
+	return field
+}
+
+func parse5424(msg []byte, received time.Time) (Message, error) {
+	m := Message{Format: RFC5424, Timestamp: received}
+	var ts string
+	ts, msg = nextField(msg)
+	if ts != nilValue {
+		t, err := time.Parse(time.RFC3339Nano, ts)
+		if err != nil {
+			return m, fmt.Errorf("invalid timestamp %q", ts)
+		}
+		m.Timestamp = t
+	}
+
+	var field string
+	field, msg = nextField(msg)
+	m.Hostname = nilable(field)
+	field, msg = nextField(msg)
+	m.AppName = nilable(field)
+	field, msg = nextField(msg)
+	m.ProcID = nilable(field)
+	field, msg = nextField(msg)
+	m.MsgID = nilable(field)
+
+	if len(msg) == 0 {
+		return m, fmt.Errorf("%w: missing STRUCTURED-DATA", errInvalidSD)
+	}
+	if msg[0] == '-' {
+		msg = msg[1:]
+	} else {
+		var err error
+		m.StructuredData, msg, err = parseSD(msg)
+		if err != nil {
+			return m, err
+		}
+	}
+	if len(msg) > 0 && msg[0] == ' ' {
+		msg = msg[1:]
+	}
+	m.Message = string(bytes.TrimPrefix(msg, utf8BOM))
+	return m, nil
+}
+
+// parseSD parses sequence of SD-ELEMENTs: [id name="value" ...]
+func parseSD(msg []byte) (map[string]map[string]string, []byte, error) {
+	sd := map[string]map[string]string{}
+	for len(msg) > 0 && msg[0] == '[' {
+		msg = msg[1:]
+		end := bytes.IndexAny(msg, " ]")
+		if end < 1 {
+			return nil, nil, errInvalidSD
+		}
+		id := string(msg[:end])
+		params := map[string]string{}
+		msg = msg[end:]
+		for len(msg) > 0 && msg[0] == ' ' {
+			msg = msg[1:]
+			eq := bytes.IndexByte(msg, '=')
+			if eq < 1 || len(msg) < eq+2 || msg[eq+1] != '"' {
+				return nil, nil, errInvalidSD
+			}
+			name := string(msg[:eq])
+			value, rest, err := parseParamValue(msg[eq+2:])
+			if err != nil {
+				return nil, nil, err
+			}
+			params[name] = value
+			msg = rest
+		}
+		if len(msg) == 0 || msg[0] != ']' {
+			return nil, nil, errInvalidSD
+		}
+		msg = msg[1:]
+		sd[id] = params
+	}
+	return sd, msg, nil
+}
+
+// parseParamValue reads quoted PARAM-VALUE with \" \\ and \] escapes, opening quote is already consumed
+func parseParamValue(msg []byte) (string, []byte, error) {
+	var value strings.Builder
+	for i := 0; i < len(msg); i++ {
+		switch msg[i] {
+		case '\\':
+			if i+1 < len(msg) && (msg[i+1] == '"' || msg[i+1] == '\\' || msg[i+1] == ']') {
+				i++
+			}
+			value.WriteByte(msg[i])
+		case '"':
+			return value.String(), msg[i+1:], nil
+		default:
+			value.WriteByte(msg[i])
+		}
+	}
+	return "", nil, errInvalidSD
+}
+
+// parse3164 parses BSD syslog message. The format is loosely defined, so the parser accepts
+// missing timestamp and hostname, as well as RFC 3339 timestamps used by rsyslog
+func parse3164(msg []byte, received time.Time, loc *time.Location) Message {
+	m := Message{Format: RFC3164, Timestamp: received}
+	if t, rest, ok := parse3164Time(msg, received, loc); ok {
+		m.Timestamp = t
+		msg = rest
+
+		// hostname is present only when followed by another field and does not look like a tag
+		host, rest := nextField(msg)
+		if rest != nil && host != "" && !strings.ContainsAny(host, ":[]") {
+			m.Hostname = host
+			msg = rest
+		}
+	}
+
+	// TAG[PID]: MSG
+	end := bytes.IndexAny(msg, ":[ ")
+	if end > 0 {
+		tag := string(msg[:end])
+		rest := msg[end:]
+		if rest[0] == '[' {
+			if closing := bytes.IndexByte(rest, ']'); closing > 0 {
+				m.ProcID = string(rest[1:closing])
+				rest = rest[closing+1:]
+			}
+		}
+		if len(rest) > 0 && rest[0] == ':' {
+			m.AppName = tag
+			msg = bytes.TrimPrefix(rest[1:], []byte(" "))
+		}
+	}
+	m.Message = string(msg)
+	return m
+}
+
+func parse3164Time(msg []byte, received time.Time, loc *time.Location) (time.Time, []byte, bool) {
+	const stampLen = len(time.Stamp)
+	if len(msg) >= stampLen {
+		t, err := time.ParseInLocation(time.Stamp, string(msg[:stampLen]), loc)
+		if err == nil {
+			// year is not transmitted, messages from the future belong to previous year
+			t = t.AddDate(received.In(loc).Year(), 0, 0)
+			if t.After(received.AddDate(0, 1, 0)) {
+				t = t.AddDate(-1, 0, 0)
+			}
+			return t, bytes.TrimPrefix(msg[stampLen:], []byte(" ")), true
+		}
+	}
+	field, rest := nextField(msg)
+	t, err := time.Parse(time.RFC3339Nano, field)
+	if err == nil {
+		return t, rest, true
+	}
+	return time.Time{}, msg, false
+}
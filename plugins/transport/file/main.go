@@ -0,0 +1,290 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/infrawatch/apputils/logging"
+	"github.com/openstack-k8s-operators/sg-core/pkg/config"
+	"github.com/openstack-k8s-operators/sg-core/pkg/data"
+	"github.com/openstack-k8s-operators/sg-core/pkg/framing"
+	"github.com/openstack-k8s-operators/sg-core/pkg/transport"
+)
+
+var (
+	appname  = "file"
+	msgCount int64
+	lastVal  int64
+)
+
+func rate() int64 {
+	rate := msgCount - lastVal
+	lastVal = msgCount
+	return rate
+}
+
+const (
+	startBeginning = "beginning"
+	startEnd       = "end"
+)
+
+type configT struct {
+	Paths              []string      `validate:"required,min=1"` // glob patterns of files to follow
+	Framing            string        // framing of records in files
+	StateFile          string        `yaml:"stateFile"` // offsets are not persisted when empty
+	StartPosition      string        `yaml:"startPosition" validate:"oneof=beginning end"`
+	PollInterval       time.Duration `yaml:"pollInterval"`
+	ScanInterval       time.Duration `yaml:"scanInterval"`
+	CheckpointInterval time.Duration `yaml:"checkpointInterval"`
+	MaxMessageSize     int           `yaml:"maxMessageSize" validate:"min=1"`
+}
+
+// File tails files matching glob patterns
+type File struct {
+	conf    configT
+	logger  *logging.Logger
+	framer  framing.Framer
+	tailers map[fileID]*tailer
+	offsets map[fileID]int64 // offsets loaded from state file
+	buf     []byte
+	// files found by the first scan start at StartPosition, files created later from the beginning
+	scanned bool
+}
+
+// Run implements type Transport
+func (f *File) Run(ctx context.Context, w transport.WriteFn, done chan bool) {
+	var err error
+	f.offsets, err = loadState(f.conf.StateFile)
+	if err != nil {
+		f.logger.Metadata(logging.Metadata{"plugin": appname, "error": err, "stateFile": f.conf.StateFile})
+		_ = f.logger.Error("failed to load state file")
+		done <- true
+		return
+	}
+	f.tailers = map[fileID]*tailer{}
+	f.buf = make([]byte, readChunkSize)
+
+	f.logger.Metadata(logging.Metadata{"plugin": appname, "paths": strings.Join(f.conf.Paths, ",")})
+	_ = f.logger.Info("following files")
+
+	write := func(record []byte) {
+		w(record)
+		msgCount++
+	}
+
+	lastScan := time.Time{}
+	lastCheckpoint := time.Now()
+	lastRate := time.Now()
+	for {
+		rescan := time.Since(lastScan) >= f.conf.ScanInterval
+		for _, t := range f.tailers {
+			if f.checkGone(t) {
+				rescan = true
+			}
+		}
+		if rescan {
+			f.scan()
+			lastScan = time.Now()
+		}
+		f.readAll(write)
+
+		if time.Since(lastCheckpoint) >= f.conf.CheckpointInterval {
+			f.checkpoint()
+			lastCheckpoint = time.Now()
+		}
+		if time.Since(lastRate) >= time.Second {
+			f.logger.Metadata(logging.Metadata{"plugin": appname})
+			_ = f.logger.Debug(fmt.Sprintf("receiving %d msg/s", rate()))
+			lastRate = time.Now()
+		}
+
+		select {
+		case <-ctx.Done():
+			goto Done
+		case <-time.After(f.conf.PollInterval):
+		}
+	}
+Done:
+	f.checkpoint()
+	for _, t := range f.tailers {
+		t.close()
+	}
+	f.logger.Metadata(logging.Metadata{"plugin": appname})
+	_ = f.logger.Info("exited")
+}
+
+// scan looks for files matching configured patterns
+func (f *File) scan() {
+	paths := []string{}
+	for _, pattern := range f.conf.Paths {
+		matches, err := filepath.Glob(pattern)
+		if err != nil {
+			continue
+		}
+		paths = append(paths, matches...)
+	}
+	sort.Strings(paths)
+
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil || !info.Mode().IsRegular() {
+			continue
+		}
+		id := fileIDOf(info)
+		if t, ok := f.tailers[id]; ok {
+			// file renamed to path which is followed as well
+			t.path = path
+			t.gone = false
+			continue
+		}
+
+		// saved offsets are used only for the first match, the inode can be reused by another file later
+		offset, ok := f.offsets[id]
+		delete(f.offsets, id)
+		switch {
+		case ok && offset <= info.Size():
+		case !f.scanned && f.conf.StartPosition == startEnd:
+			offset = info.Size()
+		default:
+			offset = 0
+		}
+		t, err := openTailer(path, id, offset)
+		if err != nil {
+			f.logger.Metadata(logging.Metadata{"plugin": appname, "error": err, "path": path})
+			_ = f.logger.Error("failed to open file")
+			continue
+		}
+		f.tailers[id] = t
+		f.logger.Metadata(logging.Metadata{"plugin": appname, "path": path, "offset": offset})
+		_ = f.logger.Info("following file")
+	}
+	f.scanned = true
+}
+
+// checkGone marks tailer whose path was removed or now refers to another file (rename rotation).
+// Returns true when the tailer was newly marked
+func (f *File) checkGone(t *tailer) bool {
+	if t.gone {
+		return false
+	}
+	info, err := os.Stat(t.path)
+	if err == nil && fileIDOf(info) == t.id {
+		return false
+	}
+	t.gone = true
+	return true
+}
+
+// readAll reads new records from all followed files. Rotated files are read first
+// to keep the order of records
+func (f *File) readAll(w func([]byte)) {
+	tailers := make([]*tailer, 0, len(f.tailers))
+	for _, t := range f.tailers {
+		tailers = append(tailers, t)
+	}
+	sort.Slice(tailers, func(i, j int) bool {
+		if tailers[i].gone != tailers[j].gone {
+			return tailers[i].gone
+		}
+		return tailers[i].path < tailers[j].path
+	})
+
+	for _, t := range tailers {
+		truncated, err := t.truncated()
+		if err == nil && truncated {
+			f.logger.Metadata(logging.Metadata{"plugin": appname, "path": t.path})
+			_ = f.logger.Info("file was truncated, reading from the beginning")
+			t.reset()
+		}
+
+		n, err := t.read(f.buf, f.framer, f.conf.MaxMessageSize, w)
+		if err != nil {
+			f.logger.Metadata(logging.Metadata{"plugin": appname, "error": err, "path": t.path, "offset": t.offset})
+			_ = f.logger.Error("skipping unreadable data")
+		}
+		if t.gone && n == 0 {
+			// rotated file did not receive any data since the last poll
+			if len(t.pending) > 0 && f.conf.Framing == framing.Newline {
+				w(t.pending)
+				t.consume(len(t.pending))
+			}
+			t.close()
+			delete(f.tailers, t.id)
+			f.logger.Metadata(logging.Metadata{"plugin": appname, "path": t.path})
+			_ = f.logger.Info("stopped following rotated file")
+		}
+	}
+}
+
+// checkpoint persists offsets of followed files
+func (f *File) checkpoint() {
+	if f.conf.StateFile == "" {
+		return
+	}
+	files := make([]fileState, 0, len(f.tailers))
+	for id, t := range f.tailers {
+		files = append(files, fileState{fileID: id, Path: t.path, Offset: t.offset})
+	}
+	sort.Slice(files, func(i, j int) bool {
+		return files[i].Path < files[j].Path
+	})
+	err := saveState(f.conf.StateFile, files)
+	if err != nil {
+		f.logger.Metadata(logging.Metadata{"plugin": appname, "error": err, "stateFile": f.conf.StateFile})
+		_ = f.logger.Error("failed to save state file")
+	}
+}
+
+// Listen ...
+func (f *File) Listen(e data.Event) {
+	f.logger.Metadata(logging.Metadata{"plugin": appname, "event": e})
+	_ = f.logger.Debug("received event")
+}
+
+// Config load configurations
+func (f *File) Config(c []byte) error {
+	f.conf = configT{
+		Framing:            framing.Newline,
+		StartPosition:      startBeginning,
+		PollInterval:       time.Second,
+		ScanInterval:       10 * time.Second,
+		CheckpointInterval: 5 * time.Second,
+		MaxMessageSize:     1 << 20,
+	}
+
+	err := config.ParseConfig(bytes.NewReader(c), &f.conf)
+	if err != nil {
+		return err
+	}
+
+	for _, pattern := range f.conf.Paths {
+		if _, err := filepath.Match(pattern, ""); err != nil {
+			return fmt.Errorf("invalid path pattern %q: %w", pattern, err)
+		}
+	}
+
+	f.conf.Framing = strings.ToLower(f.conf.Framing)
+	var ok bool
+	if f.framer, ok = framing.Get(f.conf.Framing); !ok {
+		return fmt.Errorf("unable to determine framing from configuration file. Should be one of \"%s\", received: %s",
+			strings.Join(framing.Names(), "\", \""), f.conf.Framing)
+	}
+
+	if f.conf.PollInterval <= 0 || f.conf.ScanInterval <= 0 || f.conf.CheckpointInterval <= 0 {
+		return fmt.Errorf("pollInterval, scanInterval and checkpointInterval have to be positive")
+	}
+	return nil
+}
+
+// New create new file transport
+func New(l *logging.Logger) transport.Transport {
+	return &File{
+		logger: l,
+	}
+}
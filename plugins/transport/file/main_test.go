@@ -0,0 +1,166 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path"
+	"testing"
+	"time"
+
+	"github.com/infrawatch/apputils/logging"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func appendFile(t *testing.T, name string, content string) {
+	f, err := os.OpenFile(name, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+	require.NoError(t, err)
+	_, err = f.WriteString(content)
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+}
+
+// runFile starts file transport and returns channel of received records and function stopping it
+func runFile(t *testing.T, conf string) (chan string, func()) {
+	logger, err := logging.NewLogger(logging.ERROR, os.DevNull)
+	require.NoError(t, err)
+	trans := New(logger)
+	require.NoError(t, trans.Config([]byte(conf)))
+
+	received := make(chan string, 100)
+	ctx, cancel := context.WithCancel(context.Background())
+	exited := make(chan struct{})
+	go func() {
+		trans.Run(ctx, func(record []byte) {
+			received <- string(record)
+		}, make(chan bool, 1))
+		close(exited)
+	}()
+	stop := func() {
+		cancel()
+		<-exited
+	}
+	t.Cleanup(stop)
+	return received, stop
+}
+
+func expectRecords(t *testing.T, received chan string, expected ...string) {
+	for _, exp := range expected {
+		select {
+		case record := <-received:
+			assert.Equal(t, exp, record)
+		case <-time.After(5 * time.Second):
+			t.Fatalf("record %q was not received", exp)
+		}
+	}
+	select {
+	case record := <-received:
+		t.Fatalf("unexpected record %q", record)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+func testConfig(dir string, extra string) string {
+	return fmt.Sprintf(`
+paths: [%s]
+stateFile: %s
+pollInterval: 10ms
+scanInterval: 50ms
+checkpointInterval: 10ms
+%s`, path.Join(dir, "*.log"), path.Join(dir, "state.json"), extra)
+}
+
+func TestFileTransport(t *testing.T) {
+	t.Run("test following multiple files", func(t *testing.T) {
+		dir := t.TempDir()
+		appendFile(t, path.Join(dir, "a.log"), "a1\na2\n")
+		appendFile(t, path.Join(dir, "ignored.txt"), "ignored\n")
+
+		received, _ := runFile(t, testConfig(dir, ""))
+		expectRecords(t, received, "a1", "a2")
+
+		// partial line is held until it is complete
+		appendFile(t, path.Join(dir, "a.log"), "a3 begin")
+		expectRecords(t, received)
+		appendFile(t, path.Join(dir, "a.log"), " end\n")
+		expectRecords(t, received, "a3 begin end")
+
+		appendFile(t, path.Join(dir, "b.log"), "b1\n")
+		expectRecords(t, received, "b1")
+	})
+
+	t.Run("test rename rotation", func(t *testing.T) {
+		dir := t.TempDir()
+		logPath := path.Join(dir, "app.log")
+		appendFile(t, logPath, "1\n")
+		received, _ := runFile(t, testConfig(dir, ""))
+		expectRecords(t, received, "1")
+
+		appendFile(t, logPath, "2\n3\n")
+		require.NoError(t, os.Rename(logPath, path.Join(dir, "app.log.1")))
+		appendFile(t, logPath, "4\n")
+		expectRecords(t, received, "2", "3", "4")
+
+		// unterminated last line of rotated file is not lost
+		appendFile(t, logPath, "5")
+		require.NoError(t, os.Rename(logPath, path.Join(dir, "app.log.2")))
+		expectRecords(t, received, "5")
+	})
+
+	t.Run("test copytruncate rotation", func(t *testing.T) {
+		dir := t.TempDir()
+		logPath := path.Join(dir, "app.log")
+		appendFile(t, logPath, "first line\nsecond line\n")
+		received, _ := runFile(t, testConfig(dir, ""))
+		expectRecords(t, received, "first line", "second line")
+
+		require.NoError(t, os.Truncate(logPath, 0))
+		time.Sleep(50 * time.Millisecond)
+		appendFile(t, logPath, "new\n")
+		expectRecords(t, received, "new")
+	})
+
+	t.Run("test restart resumes from checkpoint", func(t *testing.T) {
+		dir := t.TempDir()
+		logPath := path.Join(dir, "app.log")
+		appendFile(t, logPath, "1\n2\n")
+		received, stop := runFile(t, testConfig(dir, ""))
+		expectRecords(t, received, "1", "2")
+		appendFile(t, logPath, "3\npartial")
+		expectRecords(t, received, "3")
+		stop()
+
+		appendFile(t, logPath, " record\n4\n")
+		received, _ = runFile(t, testConfig(dir, ""))
+		expectRecords(t, received, "partial record", "4")
+	})
+
+	t.Run("test start position and framing", func(t *testing.T) {
+		dir := t.TempDir()
+		logPath := path.Join(dir, "dump.log")
+		appendFile(t, logPath, "11 <13>skipped")
+		received, _ := runFile(t, testConfig(dir, "startPosition: end\nframing: octet-counting"))
+		expectRecords(t, received)
+		appendFile(t, logPath, "7 <14>new")
+		expectRecords(t, received, "<14>new")
+
+		// files created after start are read from the beginning
+		appendFile(t, path.Join(dir, "other.log"), "5 <1>xy")
+		expectRecords(t, received, "<1>xy")
+	})
+
+	t.Run("test invalid configuration", func(t *testing.T) {
+		logger, err := logging.NewLogger(logging.ERROR, os.DevNull)
+		require.NoError(t, err)
+		for _, conf := range []string{
+			"stateFile: /tmp/state",
+			"paths: [/var/log/*.log]\nframing: length-le16",
+			"paths: [/var/log/*.log]\nstartPosition: middle",
+			"paths: [\"/var/log/[\"]",
+			"paths: [/var/log/*.log]\npollInterval: 0s",
+		} {
+			assert.Error(t, New(logger).Config([]byte(conf)), conf)
+		}
+	})
+}
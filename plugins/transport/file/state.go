@@ -0,0 +1,81 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"syscall"
+)
+
+// fileID identifies file independently of its path, so that renamed files are recognized
+type fileID struct {
+	Dev   uint64 `json:"dev"`
+	Inode uint64 `json:"inode"`
+}
+
+func fileIDOf(info os.FileInfo) fileID {
+	st, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return fileID{}
+	}
+	return fileID{Dev: uint64(st.Dev), Inode: uint64(st.Ino)}
+}
+
+type fileState struct {
+	fileID
+	Path   string `json:"path"`
+	Offset int64  `json:"offset"`
+}
+
+type stateT struct {
+	Files []fileState `json:"files"`
+}
+
+// loadState reads offsets saved by previous run. Missing state file means fresh start
+func loadState(path string) (map[fileID]int64, error) {
+	offsets := map[fileID]int64{}
+	if path == "" {
+		return offsets, nil
+	}
+	blob, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return offsets, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	state := stateT{}
+	err = json.Unmarshal(blob, &state)
+	if err != nil {
+		return nil, err
+	}
+	for _, fs := range state.Files {
+		offsets[fs.fileID] = fs.Offset
+	}
+	return offsets, nil
+}
+
+// saveState atomically replaces state file, so that crash while writing does not lose previous offsets
+func saveState(path string, files []fileState) error {
+	blob, err := json.Marshal(stateT{Files: files})
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	_, err = tmp.Write(blob)
+	if err == nil {
+		err = tmp.Sync()
+	}
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"errors"
+	"io"
+	"os"
+
+	"github.com/openstack-k8s-operators/sg-core/pkg/framing"
+)
+
+const readChunkSize = 65536
+
+var errRecordTooLarge = errors.New("record exceeds maxMessageSize")
+
+// tailer follows single file
+type tailer struct {
+	path    string
+	id      fileID
+	file    *os.File
+	offset  int64  // position right after the last record written to handlers
+	pending []byte // data read after offset, which do not form a complete record yet
+	gone    bool   // path no longer refers to this file, it is read until no more data arrive
+}
+
+func openTailer(path string, id fileID, offset int64) (*tailer, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	return &tailer{
+		path:   path,
+		id:     id,
+		file:   f,
+		offset: offset,
+	}, nil
+}
+
+// truncated reports whether file was truncated below already read position (copytruncate rotation)
+func (t *tailer) truncated() (bool, error) {
+	info, err := t.file.Stat()
+	if err != nil {
+		return false, err
+	}
+	return info.Size() < t.offset+int64(len(t.pending)), nil
+}
+
+// read reads newly appended data and writes complete records. Returns number of bytes read.
+// Records which can't be framed are skipped together with data read so far
+func (t *tailer) read(buf []byte, framer framing.Framer, maxSize int, w func([]byte)) (int, error) {
+	total := 0
+	for {
+		n, err := t.file.ReadAt(buf, t.offset+int64(len(t.pending)))
+		total += n
+		if n > 0 {
+			t.pending = append(t.pending, buf[:n]...)
+			consumed, ferr := framer.Split(t.pending, w)
+			t.consume(consumed)
+			if ferr != nil {
+				t.consume(len(t.pending))
+				return total, ferr
+			}
+			if len(t.pending) > maxSize {
+				t.consume(len(t.pending))
+				return total, errRecordTooLarge
+			}
+		}
+		if errors.Is(err, io.EOF) || (err == nil && n < len(buf)) {
+			return total, nil
+		}
+		if err != nil {
+			return total, err
+		}
+	}
+}
+
+func (t *tailer) consume(n int) {
+	t.offset += int64(n)
+	t.pending = append(t.pending[:0], t.pending[n:]...)
+}
+
+// reset starts reading the file from the beginning
+func (t *tailer) reset() {
+	t.offset = 0
+	t.pending = t.pending[:0]
+}
+
+func (t *tailer) close() {
+	t.file.Close()
+}
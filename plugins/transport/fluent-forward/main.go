@@ -0,0 +1,262 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/infrawatch/apputils/logging"
+	"github.com/openstack-k8s-operators/sg-core/pkg/config"
+	"github.com/openstack-k8s-operators/sg-core/pkg/data"
+	"github.com/openstack-k8s-operators/sg-core/pkg/fluent"
+	"github.com/openstack-k8s-operators/sg-core/pkg/transport"
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+const (
+	tcp  = "tcp"
+	unix = "unix"
+
+	acceptRetryDelay = 100 * time.Millisecond
+	ackTimeout       = 10 * time.Second
+)
+
+var (
+	appname = "fluent-forward"
+
+	errMessageTooLarge = errors.New("message exceeds maxMessageSize")
+)
+
+type configT struct {
+	Type           string `validate:"oneof=tcp unix"`
+	Socketaddr     string
+	Path           string
+	TLS            config.TLSConfig `yaml:"tls"`
+	MaxMessageSize int              `yaml:"maxMessageSize" validate:"min=1"`
+	IdleTimeout    time.Duration    `yaml:"idleTimeout"` // close connections which send nothing for this long
+}
+
+// limitedReader fails reads once more than n bytes were read since last reset, which bounds the memory
+// used by single message, as msgpack headers can declare sizes up to 4GiB
+type limitedReader struct {
+	r *bufio.Reader
+	n int
+}
+
+func (l *limitedReader) Read(p []byte) (int, error) {
+	if l.n <= 0 {
+		return 0, errMessageTooLarge
+	}
+	if len(p) > l.n {
+		p = p[:l.n]
+	}
+	n, err := l.r.Read(p)
+	l.n -= n
+	return n, err
+}
+
+func (l *limitedReader) ReadByte() (byte, error) {
+	if l.n <= 0 {
+		return 0, errMessageTooLarge
+	}
+	b, err := l.r.ReadByte()
+	if err == nil {
+		l.n--
+	}
+	return b, err
+}
+
+func (l *limitedReader) UnreadByte() error {
+	err := l.r.UnreadByte()
+	if err == nil {
+		l.n++
+	}
+	return err
+}
+
+// FluentForward transport receiving messages of Fluentd Forward protocol
+type FluentForward struct {
+	conf    configT
+	logger  *logging.Logger
+	mutex   sync.Mutex
+	logLock sync.Mutex
+	tlsConf *tls.Config
+}
+
+func (f *FluentForward) log(level string, msg string, meta logging.Metadata) {
+	f.logLock.Lock()
+	defer f.logLock.Unlock()
+	meta["plugin"] = appname
+	f.logger.Metadata(meta)
+	switch level {
+	case "error":
+		_ = f.logger.Error(msg)
+	case "warn":
+		_ = f.logger.Warn(msg)
+	case "debug":
+		_ = f.logger.Debug(msg)
+	default:
+		_ = f.logger.Info(msg)
+	}
+}
+
+func (f *FluentForward) listen() (net.Listener, error) {
+	if f.conf.Type == unix {
+		os.Remove(f.conf.Path)
+		return net.Listen(unix, f.conf.Path)
+	}
+	ln, err := net.Listen(tcp, f.conf.Socketaddr)
+	if err != nil {
+		return nil, err
+	}
+	if f.tlsConf != nil {
+		return tls.NewListener(ln, f.tlsConf), nil
+	}
+	return ln, nil
+}
+
+// serve reads messages from the connection until it is closed or a message cannot be decoded,
+// in which case the stream cannot be resynchronized
+func (f *FluentForward) serve(ctx context.Context, conn net.Conn, w transport.WriteFn) {
+	defer conn.Close()
+	lr := &limitedReader{r: bufio.NewReader(conn)}
+	dec := msgpack.NewDecoder(lr)
+	for {
+		if f.conf.IdleTimeout > 0 {
+			_ = conn.SetReadDeadline(time.Now().Add(f.conf.IdleTimeout))
+		}
+		lr.n = f.conf.MaxMessageSize
+		msg, err := dec.DecodeRaw()
+		if err != nil {
+			if !errors.Is(err, io.EOF) && ctx.Err() == nil {
+				f.log("warn", "closing connection after failed read", logging.Metadata{"remote": conn.RemoteAddr(), "error": err})
+			}
+			return
+		}
+
+		f.mutex.Lock()
+		w(msg)
+		f.mutex.Unlock()
+
+		// acknowledge after handlers processed the message, so that clients resend it on failure
+		opts, err := fluent.ReadOptions(msg)
+		if err != nil {
+			f.log("warn", "closing connection after invalid message", logging.Metadata{"remote": conn.RemoteAddr(), "error": err})
+			return
+		}
+		if opts.Chunk == "" {
+			continue
+		}
+		_ = conn.SetWriteDeadline(time.Now().Add(ackTimeout))
+		_, err = conn.Write(fluent.Ack(opts.Chunk))
+		if err != nil {
+			f.log("warn", "failed to send ack", logging.Metadata{"remote": conn.RemoteAddr(), "error": err})
+			return
+		}
+	}
+}
+
+// Run implements type Transport
+func (f *FluentForward) Run(ctx context.Context, w transport.WriteFn, done chan bool) {
+	ln, err := f.listen()
+	if err != nil {
+		f.log("error", "failed to listen", logging.Metadata{"error": err})
+		done <- true
+		return
+	}
+	f.log("info", "listening", logging.Metadata{"address": ln.Addr()})
+
+	var wg sync.WaitGroup
+	conns := map[net.Conn]struct{}{}
+	var connsLock sync.Mutex
+	go func() {
+		<-ctx.Done()
+		ln.Close()
+		connsLock.Lock()
+		for conn := range conns {
+			conn.Close()
+		}
+		connsLock.Unlock()
+	}()
+
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			if ctx.Err() != nil {
+				break
+			}
+			f.log("warn", "failed to accept connection", logging.Metadata{"error": err})
+			time.Sleep(acceptRetryDelay)
+			continue
+		}
+		connsLock.Lock()
+		if ctx.Err() != nil {
+			// accepted while shutting down
+			connsLock.Unlock()
+			conn.Close()
+			continue
+		}
+		conns[conn] = struct{}{}
+		connsLock.Unlock()
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			f.serve(ctx, conn, w)
+			connsLock.Lock()
+			delete(conns, conn)
+			connsLock.Unlock()
+		}()
+	}
+
+	wg.Wait()
+	if f.conf.Type == unix {
+		os.Remove(f.conf.Path)
+	}
+	f.log("info", "exited", logging.Metadata{})
+}
+
+// Listen ...
+func (f *FluentForward) Listen(e data.Event) {
+	f.log("debug", "received event", logging.Metadata{"event": e})
+}
+
+// Config load configurations
+func (f *FluentForward) Config(c []byte) error {
+	f.conf = configT{
+		Type:           tcp,
+		Socketaddr:     ":24224",
+		MaxMessageSize: 16 << 20,
+	}
+
+	err := config.ParseConfig(bytes.NewReader(c), &f.conf)
+	if err != nil {
+		return err
+	}
+	if f.conf.Type == unix && f.conf.Path == "" {
+		return fmt.Errorf("the path configuration option is required when using unix socket")
+	}
+
+	f.tlsConf, err = f.conf.TLS.ServerConfig()
+	if err != nil {
+		return err
+	}
+	if f.tlsConf != nil && f.conf.Type == unix {
+		return fmt.Errorf("TLS is only supported on tcp socket")
+	}
+	return nil
+}
+
+// New create new fluent-forward transport
+func New(l *logging.Logger) transport.Transport {
+	return &FluentForward{
+		logger: l,
+	}
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"context"
+	"net"
+	"os"
+	"path"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/infrawatch/apputils/logging"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+func TestFluentForwardTransport(t *testing.T) {
+	tmpdir, err := os.MkdirTemp(".", "fluent_test_tmp")
+	require.NoError(t, err)
+	defer os.RemoveAll(tmpdir)
+
+	logger, err := logging.NewLogger(logging.DEBUG, path.Join(tmpdir, "test.log"))
+	require.NoError(t, err)
+
+	t.Run("test messages and acks", func(t *testing.T) {
+		sockPath := path.Join(tmpdir, "fluent.sock")
+		trans := New(logger).(*FluentForward)
+		require.NoError(t, trans.Config([]byte("type: unix\npath: "+sockPath+"\nmaxMessageSize: 256")))
+
+		var lock sync.Mutex
+		received := [][]byte{}
+		ctx, cancel := context.WithCancel(context.Background())
+		exited := make(chan struct{})
+		go func() {
+			trans.Run(ctx, func(msg []byte) {
+				lock.Lock()
+				received = append(received, msg)
+				lock.Unlock()
+			}, make(chan bool, 1))
+			close(exited)
+		}()
+
+		var conn net.Conn
+		require.Eventually(t, func() bool {
+			conn, err = net.Dial("unix", sockPath)
+			return err == nil
+		}, 5*time.Second, 10*time.Millisecond)
+		defer conn.Close()
+
+		msg1, err := msgpack.Marshal([]interface{}{"app", 1700000000, map[string]string{"log": "a"}})
+		require.NoError(t, err)
+		msg2, err := msgpack.Marshal([]interface{}{"app", []interface{}{[]interface{}{1700000000, map[string]string{"log": "b"}}},
+			map[string]interface{}{"chunk": "Y2h1bmsx", "size": 1}})
+		require.NoError(t, err)
+		_, err = conn.Write(append(append([]byte{}, msg1...), msg2...))
+		require.NoError(t, err)
+
+		var ack map[string]string
+		require.NoError(t, conn.SetReadDeadline(time.Now().Add(5*time.Second)))
+		require.NoError(t, msgpack.NewDecoder(conn).Decode(&ack))
+		assert.Equal(t, map[string]string{"ack": "Y2h1bmsx"}, ack)
+		lock.Lock()
+		assert.Equal(t, [][]byte{msg1, msg2}, received)
+		lock.Unlock()
+
+		// oversized message closes the connection
+		big, err := msgpack.Marshal([]interface{}{"app", 1700000000, map[string]string{"log": string(make([]byte, 300))}})
+		require.NoError(t, err)
+		_, err = conn.Write(big)
+		require.NoError(t, err)
+		_, err = conn.Read(make([]byte, 1))
+		assert.Error(t, err)
+		lock.Lock()
+		assert.Len(t, received, 2)
+		lock.Unlock()
+
+		cancel()
+		select {
+		case <-exited:
+		case <-time.After(5 * time.Second):
+			t.Fatal("transport did not exit")
+		}
+		_, err = os.Stat(sockPath)
+		assert.True(t, os.IsNotExist(err))
+	})
+
+	t.Run("test configuration", func(t *testing.T) {
+		trans := New(logger).(*FluentForward)
+		require.NoError(t, trans.Config([]byte("idleTimeout: 1m")))
+		assert.Equal(t, ":24224", trans.conf.Socketaddr)
+		assert.Equal(t, 16<<20, trans.conf.MaxMessageSize)
+		assert.Error(t, trans.Config([]byte("type: udp")))
+		assert.Error(t, trans.Config([]byte("type: unix")))
+		assert.Error(t, trans.Config([]byte("maxMessageSize: 0")))
+		assert.Error(t, trans.Config([]byte("tls:\n  enabled: true")))
+	})
+}
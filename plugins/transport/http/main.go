@@ -0,0 +1,286 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/infrawatch/apputils/logging"
+	"github.com/klauspost/compress/snappy"
+	"github.com/openstack-k8s-operators/sg-core/pkg/config"
+	"github.com/openstack-k8s-operators/sg-core/pkg/data"
+	"github.com/openstack-k8s-operators/sg-core/pkg/httputil"
+	"github.com/openstack-k8s-operators/sg-core/pkg/transport"
+)
+
+const (
+	splitLines = "lines"
+	splitAuto  = "auto"
+)
+
+var (
+	appname  = "http"
+	msgCount int64
+	lastVal  int64
+
+	ndjsonTypes = map[string]bool{
+		"application/x-ndjson": true,
+		"application/ndjson":   true,
+		"application/jsonl":    true,
+		"application/x-jsonl":  true,
+	}
+)
+
+func rate() int64 {
+	rate := msgCount - lastVal
+	lastVal = msgCount
+	return rate
+}
+
+type configT struct {
+	Address      string `validate:"required"`
+	Path         string
+	Split        string           `validate:"oneof=none lines auto"`
+	MaxBodySize  int64            `yaml:"maxBodySize" validate:"min=1"`
+	MaxInFlight  int              `yaml:"maxInFlight" validate:"min=1"`
+	ReadTimeout  time.Duration    `yaml:"readTimeout"`
+	TLS          config.TLSConfig `yaml:"tls"`
+	Auth         httputil.AuthConfig
+	DumpMessages struct {
+		Enabled bool
+		Path    string
+	} `yaml:"dumpMessages"` // only use for debug as this is very slow
+}
+
+// HTTP transport accepting messages in POST request bodies
+type HTTP struct {
+	conf     configT
+	logger   *logging.Logger
+	dumpBuf  *bufio.Writer
+	dumpFile *os.File
+	mutex    sync.Mutex
+	inFlight httputil.Limiter
+}
+
+func (h *HTTP) splitBody(r *http.Request) bool {
+	switch h.conf.Split {
+	case splitLines:
+		return true
+	case splitAuto:
+		mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+		return err == nil && ndjsonTypes[mediaType]
+	}
+	return false
+}
+
+// readSnappy decodes body compressed with snappy block format, as sent by Prometheus remote_write
+func (h *HTTP) readSnappy(r *http.Request) ([]byte, error) {
+	compressed, err := io.ReadAll(io.LimitReader(r.Body, h.conf.MaxBodySize+1))
+	if err != nil {
+		return nil, err
+	}
+	size, err := snappy.DecodedLen(compressed)
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(compressed)) > h.conf.MaxBodySize || int64(size) > h.conf.MaxBodySize {
+		return nil, httputil.ErrBodyTooLarge
+	}
+	return snappy.Decode(nil, compressed)
+}
+
+func (h *HTTP) readBody(r *http.Request) ([]byte, error) {
+	if strings.EqualFold(r.Header.Get("Content-Encoding"), "snappy") {
+		return h.readSnappy(r)
+	}
+	return httputil.ReadBody(r, h.conf.MaxBodySize)
+}
+
+func (h *HTTP) write(w transport.WriteFn, blob []byte) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	if h.conf.DumpMessages.Enabled {
+		_, err := h.dumpBuf.Write(blob)
+		if err == nil {
+			_, err = h.dumpBuf.WriteString("\n")
+		}
+		if err != nil {
+			h.logger.Metadata(logging.Metadata{"plugin": appname, "error": err})
+			_ = h.logger.Error("writing to dump buffer")
+		}
+		h.dumpBuf.Flush()
+	}
+	w(blob)
+	msgCount++
+}
+
+func (h *HTTP) handler(w transport.WriteFn) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc(h.conf.Path, func(rw http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost && r.Method != http.MethodPut {
+			rw.Header().Set("Allow", "POST, PUT")
+			http.Error(rw, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		if !h.conf.Auth.Authorized(r) {
+			h.conf.Auth.Challenge(rw)
+			http.Error(rw, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		if !h.inFlight.TryAcquire() {
+			rw.Header().Set("Retry-After", "1")
+			http.Error(rw, "too many requests", http.StatusTooManyRequests)
+			return
+		}
+		defer h.inFlight.Release()
+
+		blob, err := h.readBody(r)
+		if err != nil {
+			switch {
+			case errors.Is(err, httputil.ErrUnsupportedEncoding):
+				http.Error(rw, err.Error(), http.StatusUnsupportedMediaType)
+			case errors.Is(err, httputil.ErrBodyTooLarge):
+				http.Error(rw, err.Error(), http.StatusRequestEntityTooLarge)
+			default:
+				http.Error(rw, err.Error(), http.StatusBadRequest)
+			}
+			return
+		}
+
+		if h.splitBody(r) {
+			for _, line := range bytes.Split(blob, []byte("\n")) {
+				line = bytes.TrimSpace(line)
+				if len(line) > 0 {
+					h.write(w, line)
+				}
+			}
+		} else if len(blob) > 0 {
+			h.write(w, blob)
+		}
+		rw.WriteHeader(http.StatusNoContent)
+	})
+	return mux
+}
+
+// Run implements type Transport
+func (h *HTTP) Run(ctx context.Context, w transport.WriteFn, done chan bool) {
+	tlsConf, err := h.conf.TLS.ServerConfig()
+	if err != nil {
+		h.logger.Metadata(logging.Metadata{"plugin": appname, "error": err})
+		_ = h.logger.Error("failed to load TLS configuration")
+		done <- true
+		return
+	}
+
+	server := &http.Server{
+		Addr:              h.conf.Address,
+		Handler:           h.handler(w),
+		TLSConfig:         tlsConf,
+		ReadHeaderTimeout: h.conf.ReadTimeout,
+		ReadTimeout:       h.conf.ReadTimeout,
+	}
+
+	go func() {
+		var err error
+		if tlsConf != nil {
+			err = server.ListenAndServeTLS("", "")
+		} else {
+			err = server.ListenAndServe()
+		}
+		if err != nil && !errors.Is(err, http.ErrServerClosed) {
+			h.logger.Metadata(logging.Metadata{"plugin": appname, "error": err})
+			_ = h.logger.Error("server failed")
+			done <- true
+		}
+	}()
+
+	h.logger.Metadata(logging.Metadata{"plugin": appname, "address": h.conf.Address, "path": h.conf.Path})
+	_ = h.logger.Info("listening")
+
+	for {
+		select {
+		case <-ctx.Done():
+			goto done
+		case <-time.After(time.Second):
+			_ = h.logger.Debug(fmt.Sprintf("receiving %d msg/s", rate()))
+		}
+	}
+done:
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	_ = server.Shutdown(shutdownCtx)
+	cancel()
+	if h.dumpFile != nil {
+		h.dumpFile.Close()
+	}
+	h.logger.Metadata(logging.Metadata{"plugin": appname})
+	_ = h.logger.Info("exited")
+}
+
+// Listen ...
+func (h *HTTP) Listen(e data.Event) {
+	h.logger.Metadata(logging.Metadata{"plugin": appname, "event": e})
+	_ = h.logger.Debug("received event")
+}
+
+// Config load configurations
+func (h *HTTP) Config(c []byte) error {
+	h.conf = configT{
+		DumpMessages: struct {
+			Enabled bool
+			Path    string
+		}{
+			Path: "/dev/stdout",
+		},
+		Path:        "/",
+		Split:       splitAuto,
+		MaxBodySize: 10 << 20,
+		MaxInFlight: 64,
+		ReadTimeout: 30 * time.Second,
+		Auth:        httputil.AuthConfig{Type: "none"},
+	}
+
+	err := config.ParseConfig(bytes.NewReader(c), &h.conf)
+	if err != nil {
+		return err
+	}
+
+	err = h.conf.Auth.Validate()
+	if err != nil {
+		return err
+	}
+
+	_, err = h.conf.TLS.ServerConfig()
+	if err != nil {
+		return err
+	}
+
+	h.inFlight = httputil.NewLimiter(h.conf.MaxInFlight)
+
+	if h.conf.DumpMessages.Enabled {
+		h.dumpFile, err = os.OpenFile(h.conf.DumpMessages.Path, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0666)
+		if err != nil {
+			return err
+		}
+
+		h.dumpBuf = bufio.NewWriter(h.dumpFile)
+	}
+
+	return nil
+}
+
+// New create new http transport
+func New(l *logging.Logger) transport.Transport {
+	return &HTTP{
+		logger: l,
+	}
+}
@@ -0,0 +1,194 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path"
+	"testing"
+
+	"github.com/infrawatch/apputils/logging"
+	"github.com/klauspost/compress/snappy"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestTransport(t *testing.T, logger *logging.Logger, conf string) *HTTP {
+	trans := New(logger).(*HTTP)
+	require.NoError(t, trans.Config([]byte(conf)))
+	return trans
+}
+
+func TestHTTPTransport(t *testing.T) {
+	tmpdir, err := os.MkdirTemp(".", "http_test_tmp")
+	require.NoError(t, err)
+	defer os.RemoveAll(tmpdir)
+
+	logpath := path.Join(tmpdir, "test.log")
+	logger, err := logging.NewLogger(logging.DEBUG, logpath)
+	require.NoError(t, err)
+
+	collectdBody := `[{"values":[1],"dstypes":["gauge"],"dsnames":["value"],"host":"h","plugin":"cpu","type":"percent"}]`
+
+	t.Run("test plain and gzip bodies", func(t *testing.T) {
+		trans := newTestTransport(t, logger, `
+address: 127.0.0.1:0
+path: /collectd
+`)
+		received := []string{}
+		srv := trans.handler(func(b []byte) { received = append(received, string(b)) })
+
+		rec := httptest.NewRecorder()
+		srv.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/collectd", bytes.NewBufferString(collectdBody)))
+		assert.Equal(t, http.StatusNoContent, rec.Code)
+
+		var gz bytes.Buffer
+		zw := gzip.NewWriter(&gz)
+		_, err := zw.Write([]byte(collectdBody))
+		require.NoError(t, err)
+		require.NoError(t, zw.Close())
+		req := httptest.NewRequest(http.MethodPost, "/collectd", &gz)
+		req.Header.Set("Content-Encoding", "gzip")
+		rec = httptest.NewRecorder()
+		srv.ServeHTTP(rec, req)
+		assert.Equal(t, http.StatusNoContent, rec.Code)
+
+		req = httptest.NewRequest(http.MethodPost, "/collectd", bytes.NewReader(snappy.Encode(nil, []byte(collectdBody))))
+		req.Header.Set("Content-Encoding", "snappy")
+		rec = httptest.NewRecorder()
+		srv.ServeHTTP(rec, req)
+		assert.Equal(t, http.StatusNoContent, rec.Code)
+
+		req = httptest.NewRequest(http.MethodPost, "/collectd", bytes.NewBufferString(collectdBody))
+		req.Header.Set("Content-Encoding", "snappy")
+		rec = httptest.NewRecorder()
+		srv.ServeHTTP(rec, req)
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+
+		assert.Equal(t, []string{collectdBody, collectdBody, collectdBody}, received)
+
+		req = httptest.NewRequest(http.MethodPost, "/collectd", bytes.NewBufferString(collectdBody))
+		req.Header.Set("Content-Encoding", "br")
+		rec = httptest.NewRecorder()
+		srv.ServeHTTP(rec, req)
+		assert.Equal(t, http.StatusUnsupportedMediaType, rec.Code)
+
+		rec = httptest.NewRecorder()
+		srv.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/collectd", nil))
+		assert.Equal(t, http.StatusMethodNotAllowed, rec.Code)
+
+		rec = httptest.NewRecorder()
+		srv.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/other", bytes.NewBufferString(collectdBody)))
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+	})
+
+	t.Run("test ndjson bodies are split by lines", func(t *testing.T) {
+		trans := newTestTransport(t, logger, `address: 127.0.0.1:0`)
+		received := []string{}
+		srv := trans.handler(func(b []byte) { received = append(received, string(b)) })
+
+		body := "{\"message\":\"one\"}\n\n{\"message\":\"two\"}\n"
+		req := httptest.NewRequest(http.MethodPost, "/", bytes.NewBufferString(body))
+		req.Header.Set("Content-Type", "application/x-ndjson")
+		rec := httptest.NewRecorder()
+		srv.ServeHTTP(rec, req)
+		assert.Equal(t, http.StatusNoContent, rec.Code)
+		assert.Equal(t, []string{`{"message":"one"}`, `{"message":"two"}`}, received)
+
+		// without ndjson content type the body is passed as a whole
+		received = []string{}
+		srv.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/", bytes.NewBufferString(body)))
+		assert.Equal(t, []string{body}, received)
+	})
+
+	t.Run("test authentication", func(t *testing.T) {
+		trans := newTestTransport(t, logger, `
+address: 127.0.0.1:0
+auth:
+  type: basic
+  username: collectd
+  password: secret
+`)
+		srv := trans.handler(func([]byte) {})
+		req := httptest.NewRequest(http.MethodPost, "/", bytes.NewBufferString(collectdBody))
+		rec := httptest.NewRecorder()
+		srv.ServeHTTP(rec, req)
+		assert.Equal(t, http.StatusUnauthorized, rec.Code)
+
+		req.SetBasicAuth("collectd", "secret")
+		rec = httptest.NewRecorder()
+		srv.ServeHTTP(rec, req)
+		assert.Equal(t, http.StatusNoContent, rec.Code)
+
+		trans = newTestTransport(t, logger, `
+address: 127.0.0.1:0
+auth:
+  type: bearer
+  token: abc
+`)
+		srv = trans.handler(func([]byte) {})
+		req = httptest.NewRequest(http.MethodPost, "/", bytes.NewBufferString(collectdBody))
+		req.Header.Set("Authorization", "Bearer xyz")
+		rec = httptest.NewRecorder()
+		srv.ServeHTTP(rec, req)
+		assert.Equal(t, http.StatusUnauthorized, rec.Code)
+
+		req.Header.Set("Authorization", "Bearer abc")
+		rec = httptest.NewRecorder()
+		srv.ServeHTTP(rec, req)
+		assert.Equal(t, http.StatusNoContent, rec.Code)
+	})
+
+	t.Run("test overload and body limits", func(t *testing.T) {
+		trans := newTestTransport(t, logger, `
+address: 127.0.0.1:0
+maxInFlight: 1
+maxBodySize: 16
+`)
+		srv := trans.handler(func([]byte) {})
+
+		rec := httptest.NewRecorder()
+		srv.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/", bytes.NewBufferString(collectdBody)))
+		assert.Equal(t, http.StatusRequestEntityTooLarge, rec.Code)
+
+		// decompressed size is limited as well
+		req := httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(snappy.Encode(nil, make([]byte, 1024))))
+		req.Header.Set("Content-Encoding", "snappy")
+		rec = httptest.NewRecorder()
+		srv.ServeHTTP(rec, req)
+		assert.Equal(t, http.StatusRequestEntityTooLarge, rec.Code)
+
+		// occupy the only slot
+		trans.inFlight <- struct{}{}
+		rec = httptest.NewRecorder()
+		srv.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/", bytes.NewBufferString("{}")))
+		assert.Equal(t, http.StatusTooManyRequests, rec.Code)
+		assert.Equal(t, "1", rec.Header().Get("Retry-After"))
+		<-trans.inFlight
+	})
+
+	t.Run("test configuration", func(t *testing.T) {
+		trans := New(logger).(*HTTP)
+		assert.Error(t, trans.Config([]byte(`path: /`)))
+		assert.Error(t, trans.Config([]byte(`
+address: :8080
+auth:
+  type: bearer
+`)))
+		assert.Error(t, trans.Config([]byte(`
+address: :8080
+split: words
+`)))
+		assert.Error(t, trans.Config([]byte(`
+address: :8080
+maxBodySize: 0
+`)))
+		assert.Error(t, trans.Config([]byte(`
+address: :8080
+tls:
+  enabled: true
+`)))
+	})
+}
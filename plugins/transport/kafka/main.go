@@ -0,0 +1,303 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/infrawatch/apputils/logging"
+	"github.com/openstack-k8s-operators/sg-core/pkg/config"
+	"github.com/openstack-k8s-operators/sg-core/pkg/data"
+	"github.com/openstack-k8s-operators/sg-core/pkg/transport"
+	"github.com/segmentio/kafka-go"
+	"github.com/segmentio/kafka-go/sasl"
+	"github.com/segmentio/kafka-go/sasl/plain"
+	"github.com/segmentio/kafka-go/sasl/scram"
+)
+
+var (
+	appname  = "kafka"
+	msgCount int64
+	lastVal  int64
+)
+
+// delays between fetch retries after errors, doubled after each consecutive error
+const (
+	minFetchBackoff = 100 * time.Millisecond
+	maxFetchBackoff = 30 * time.Second
+)
+
+func rate() int64 {
+	rate := msgCount - lastVal
+	lastVal = msgCount
+	return rate
+}
+
+type saslConfig struct {
+	Mechanism string
+	Username  string
+	Password  string
+}
+
+type configT struct {
+	Brokers        []string         `validate:"required,min=1"`
+	Topics         []string         `validate:"required,min=1"`
+	GroupID        string           `yaml:"groupID" validate:"required"`
+	ClientID       string           `yaml:"clientID"`
+	StartOffset    string           `yaml:"startOffset" validate:"oneof=earliest latest"`
+	MinBytes       int              `yaml:"minBytes"`
+	MaxBytes       int              `yaml:"maxBytes"`
+	MaxWait        time.Duration    `yaml:"maxWait"`
+	SessionTimeout time.Duration    `yaml:"sessionTimeout"`
+	TLS            config.TLSConfig `yaml:"tls"`
+	SASL           saslConfig
+	DumpMessages   struct {
+		Enabled bool
+		Path    string
+	} `yaml:"dumpMessages"` // only use for debug as this is very slow
+}
+
+// messageReader is the subset of kafka.Reader used by the transport. It allows
+// replacing the consumer with an in-process fake in tests
+type messageReader interface {
+	FetchMessage(context.Context) (kafka.Message, error)
+	CommitMessages(context.Context, ...kafka.Message) error
+	Close() error
+}
+
+// Kafka basic struct
+type Kafka struct {
+	conf      configT
+	logger    *logging.Logger
+	reader    messageReader
+	dumpBuf   *bufio.Writer
+	dumpFile  *os.File
+	newReader func(kafka.ReaderConfig) messageReader
+	// fetch retry delays, replaceable in tests
+	minBackoff time.Duration
+	maxBackoff time.Duration
+}
+
+// Run implements type Transport
+func (k *Kafka) Run(ctx context.Context, w transport.WriteFn, done chan bool) {
+	if k.reader == nil {
+		rc, err := k.readerConfig()
+		if err != nil {
+			k.logger.Metadata(logging.Metadata{"plugin": appname, "error": err})
+			_ = k.logger.Error("failed to create consumer")
+			done <- true
+			return
+		}
+		k.reader = k.newReader(rc)
+	}
+	defer k.reader.Close()
+
+	k.logger.Metadata(logging.Metadata{
+		"plugin":  appname,
+		"brokers": strings.Join(k.conf.Brokers, ","),
+		"topics":  strings.Join(k.conf.Topics, ","),
+		"group":   k.conf.GroupID,
+	})
+	_ = k.logger.Info("listening")
+
+	go func() {
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(time.Second):
+				_ = k.logger.Debug(fmt.Sprintf("receiving %d msg/s", rate()))
+			}
+		}
+	}()
+
+	backoff := time.Duration(0)
+	for {
+		msg, err := k.reader.FetchMessage(ctx)
+		if err != nil {
+			if ctx.Err() != nil {
+				break
+			}
+			if errors.Is(err, kafka.ErrGroupClosed) {
+				k.logger.Metadata(logging.Metadata{"plugin": appname, "error": err})
+				_ = k.logger.Error("failed to fetch message")
+				done <- true
+				break
+			}
+			// persistent broker or authentication errors must not turn into busy loop
+			backoff = min(max(2*backoff, k.minBackoff), k.maxBackoff)
+			k.logger.Metadata(logging.Metadata{"plugin": appname, "error": err, "retry": backoff})
+			_ = k.logger.Error("failed to fetch message")
+			select {
+			case <-ctx.Done():
+			case <-time.After(backoff):
+			}
+			continue
+		}
+		backoff = 0
+
+		if k.conf.DumpMessages.Enabled {
+			k.dump(msg.Value)
+		}
+
+		w(msg.Value)
+		msgCount++
+
+		// offsets are committed only after handlers processed the record, so
+		// records in flight during a crash are redelivered rather than lost
+		err = k.reader.CommitMessages(ctx, msg)
+		if err != nil && ctx.Err() == nil {
+			k.logger.Metadata(logging.Metadata{
+				"plugin":    appname,
+				"error":     err,
+				"topic":     msg.Topic,
+				"partition": msg.Partition,
+				"offset":    msg.Offset,
+			})
+			_ = k.logger.Error("failed to commit offset")
+		}
+	}
+
+	if k.dumpFile != nil {
+		k.dumpFile.Close()
+	}
+	k.logger.Metadata(logging.Metadata{"plugin": appname})
+	_ = k.logger.Info("exited")
+}
+
+func (k *Kafka) dump(msg []byte) {
+	_, err := k.dumpBuf.Write(msg)
+	if err == nil {
+		_, err = k.dumpBuf.WriteString("\n")
+	}
+	if err != nil {
+		k.logger.Metadata(logging.Metadata{"plugin": appname, "error": err})
+		_ = k.logger.Error("writing to dump buffer")
+	}
+	k.dumpBuf.Flush()
+}
+
+func (k *Kafka) readerConfig() (kafka.ReaderConfig, error) {
+	dialer := &kafka.Dialer{
+		ClientID:  k.conf.ClientID,
+		Timeout:   10 * time.Second,
+		DualStack: true,
+	}
+
+	tlsConf, err := k.conf.TLS.ClientConfig()
+	if err != nil {
+		return kafka.ReaderConfig{}, err
+	}
+	dialer.TLS = tlsConf
+
+	dialer.SASLMechanism, err = k.saslMechanism()
+	if err != nil {
+		return kafka.ReaderConfig{}, err
+	}
+
+	startOffset := kafka.LastOffset
+	if k.conf.StartOffset == "earliest" {
+		startOffset = kafka.FirstOffset
+	}
+
+	return kafka.ReaderConfig{
+		Brokers:        k.conf.Brokers,
+		GroupID:        k.conf.GroupID,
+		GroupTopics:    k.conf.Topics,
+		Dialer:         dialer,
+		MinBytes:       k.conf.MinBytes,
+		MaxBytes:       k.conf.MaxBytes,
+		MaxWait:        k.conf.MaxWait,
+		SessionTimeout: k.conf.SessionTimeout,
+		StartOffset:    startOffset,
+		// zero interval makes CommitMessages synchronous
+		CommitInterval: 0,
+	}, nil
+}
+
+func (k *Kafka) saslMechanism() (sasl.Mechanism, error) {
+	switch k.conf.SASL.Mechanism {
+	case "plain":
+		return plain.Mechanism{
+			Username: k.conf.SASL.Username,
+			Password: k.conf.SASL.Password,
+		}, nil
+	case "scram-sha-256":
+		return scram.Mechanism(scram.SHA256, k.conf.SASL.Username, k.conf.SASL.Password)
+	case "scram-sha-512":
+		return scram.Mechanism(scram.SHA512, k.conf.SASL.Username, k.conf.SASL.Password)
+	}
+	return nil, nil
+}
+
+// Listen ...
+func (k *Kafka) Listen(e data.Event) {
+	k.logger.Metadata(logging.Metadata{"plugin": appname, "event": e})
+	_ = k.logger.Debug("received event")
+}
+
+// Config load configurations
+func (k *Kafka) Config(c []byte) error {
+	k.conf = configT{
+		DumpMessages: struct {
+			Enabled bool
+			Path    string
+		}{
+			Path: "/dev/stdout",
+		},
+		StartOffset: "latest",
+		MinBytes:    1,
+		MaxBytes:    10e6,
+		MaxWait:     time.Second,
+	}
+
+	err := config.ParseConfig(bytes.NewReader(c), &k.conf)
+	if err != nil {
+		return err
+	}
+
+	k.conf.SASL.Mechanism = strings.ToLower(k.conf.SASL.Mechanism)
+	switch k.conf.SASL.Mechanism {
+	case "", "plain", "scram-sha-256", "scram-sha-512":
+	default:
+		return fmt.Errorf("unable to determine sasl mechanism from configuration file. Should be one of \"plain\", \"scram-sha-256\" or \"scram-sha-512\", received: %s",
+			k.conf.SASL.Mechanism)
+	}
+	if k.conf.SASL.Mechanism != "" && k.conf.SASL.Username == "" {
+		return fmt.Errorf("the sasl.username configuration option is required when using sasl mechanism %s", k.conf.SASL.Mechanism)
+	}
+
+	// validate TLS and SASL settings early so misconfiguration fails on startup
+	_, err = k.readerConfig()
+	if err != nil {
+		return err
+	}
+
+	if k.conf.DumpMessages.Enabled {
+		k.dumpFile, err = os.OpenFile(k.conf.DumpMessages.Path, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0666)
+		if err != nil {
+			return err
+		}
+
+		k.dumpBuf = bufio.NewWriter(k.dumpFile)
+	}
+
+	return nil
+}
+
+// New create new kafka transport
+func New(l *logging.Logger) transport.Transport {
+	return &Kafka{
+		logger: l,
+		newReader: func(rc kafka.ReaderConfig) messageReader {
+			return kafka.NewReader(rc)
+		},
+		minBackoff: minFetchBackoff,
+		maxBackoff: maxFetchBackoff,
+	}
+}
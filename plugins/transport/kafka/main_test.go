@@ -0,0 +1,193 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/infrawatch/apputils/logging"
+	"github.com/segmentio/kafka-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeReader serves preloaded records and records committed offsets
+type fakeReader struct {
+	sync.Mutex
+	records   chan kafka.Message
+	committed []int64
+	// handled is read at commit time to verify the record had been handled
+	handled   *int
+	handledAt []int
+}
+
+func (f *fakeReader) FetchMessage(ctx context.Context) (kafka.Message, error) {
+	select {
+	case <-ctx.Done():
+		return kafka.Message{}, ctx.Err()
+	case msg := <-f.records:
+		return msg, nil
+	}
+}
+
+func (f *fakeReader) CommitMessages(_ context.Context, msgs ...kafka.Message) error {
+	f.Lock()
+	defer f.Unlock()
+	for _, m := range msgs {
+		f.committed = append(f.committed, m.Offset)
+		f.handledAt = append(f.handledAt, *f.handled)
+	}
+	return nil
+}
+
+func (f *fakeReader) Close() error {
+	return nil
+}
+
+// failingReader fails every fetch, like a reader with unreachable broker or rejected credentials
+type failingReader struct {
+	fetches atomic.Int64
+}
+
+func (f *failingReader) FetchMessage(context.Context) (kafka.Message, error) {
+	f.fetches.Add(1)
+	return kafka.Message{}, errors.New("SASL authentication failed")
+}
+
+func (f *failingReader) CommitMessages(context.Context, ...kafka.Message) error {
+	return nil
+}
+
+func (f *failingReader) Close() error {
+	return nil
+}
+
+func TestKafkaTransport(t *testing.T) {
+	tmpdir, err := os.MkdirTemp(".", "kafka_test_tmp")
+	require.NoError(t, err)
+	defer os.RemoveAll(tmpdir)
+
+	logpath := path.Join(tmpdir, "test.log")
+	logger, err := logging.NewLogger(logging.DEBUG, logpath)
+	require.NoError(t, err)
+
+	t.Run("test records are handled before offsets are committed", func(t *testing.T) {
+		handled := 0
+		reader := &fakeReader{
+			records: make(chan kafka.Message, 3),
+			handled: &handled,
+		}
+		for i, v := range []string{"first", "second", "third"} {
+			reader.records <- kafka.Message{Topic: "collectd", Offset: int64(i), Value: []byte(v)}
+		}
+
+		trans := New(logger).(*Kafka)
+		trans.reader = reader
+
+		ctx, cancel := context.WithCancel(context.Background())
+		received := []string{}
+		finished := make(chan struct{})
+		go func() {
+			trans.Run(ctx, func(msg []byte) {
+				received = append(received, string(msg))
+				handled++
+				if handled == 3 {
+					cancel()
+				}
+			}, make(chan bool))
+			close(finished)
+		}()
+		<-finished
+
+		assert.Equal(t, []string{"first", "second", "third"}, received)
+		assert.Equal(t, []int64{0, 1}, reader.committed[:2])
+		for i, h := range reader.handledAt {
+			assert.Equal(t, i+1, h)
+		}
+	})
+
+	t.Run("test fetch errors are retried with backoff", func(t *testing.T) {
+		reader := &failingReader{}
+		trans := New(logger).(*Kafka)
+		trans.reader = reader
+		trans.minBackoff = 10 * time.Millisecond
+		trans.maxBackoff = 40 * time.Millisecond
+
+		ctx, cancel := context.WithCancel(context.Background())
+		finished := make(chan struct{})
+		go func() {
+			trans.Run(ctx, func([]byte) {}, make(chan bool))
+			close(finished)
+		}()
+
+		// backoff of 10, 20, 40, 40... ms allows about ten fetches in 300ms
+		time.Sleep(300 * time.Millisecond)
+		fetches := reader.fetches.Load()
+		assert.GreaterOrEqual(t, fetches, int64(3))
+		assert.LessOrEqual(t, fetches, int64(15))
+
+		cancel()
+		<-finished
+
+		// shutdown is not delayed by backoff
+		reader = &failingReader{}
+		trans = New(logger).(*Kafka)
+		trans.reader = reader
+		trans.minBackoff = time.Hour
+		ctx, cancel = context.WithCancel(context.Background())
+		finished = make(chan struct{})
+		go func() {
+			trans.Run(ctx, func([]byte) {}, make(chan bool))
+			close(finished)
+		}()
+		require.Eventually(t, func() bool { return reader.fetches.Load() == 1 }, time.Second, time.Millisecond)
+		cancel()
+		select {
+		case <-finished:
+		case <-time.After(time.Second):
+			t.Fatal("Run did not return after cancelling context")
+		}
+	})
+
+	t.Run("test configuration", func(t *testing.T) {
+		trans := New(logger).(*Kafka)
+		err := trans.Config([]byte(`
+brokers: [localhost:9092]
+topics: [collectd, ceilometer]
+groupID: sg-core
+startOffset: earliest
+sasl:
+  mechanism: SCRAM-SHA-512
+  username: sg
+  password: secret
+`))
+		require.NoError(t, err)
+		rc, err := trans.readerConfig()
+		require.NoError(t, err)
+		assert.Equal(t, []string{"collectd", "ceilometer"}, rc.GroupTopics)
+		assert.Equal(t, kafka.FirstOffset, rc.StartOffset)
+		assert.Equal(t, "SCRAM-SHA-512", rc.Dialer.SASLMechanism.Name())
+		assert.Nil(t, rc.Dialer.TLS)
+
+		err = trans.Config([]byte(`
+brokers: [localhost:9092]
+topics: [collectd]
+`))
+		assert.Error(t, err)
+
+		err = trans.Config([]byte(`
+brokers: [localhost:9092]
+topics: [collectd]
+groupID: sg-core
+tls:
+  enabled: true
+  caFile: /nonexistent/ca.pem
+`))
+		assert.Error(t, err)
+	})
+}
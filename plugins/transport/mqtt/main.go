@@ -0,0 +1,233 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+	"github.com/infrawatch/apputils/logging"
+	"github.com/openstack-k8s-operators/sg-core/pkg/config"
+	"github.com/openstack-k8s-operators/sg-core/pkg/data"
+	"github.com/openstack-k8s-operators/sg-core/pkg/transport"
+)
+
+var (
+	appname  = "mqtt"
+	msgCount int64
+	lastVal  int64
+)
+
+func rate() int64 {
+	rate := msgCount - lastVal
+	lastVal = msgCount
+	return rate
+}
+
+type configT struct {
+	Brokers              []string `validate:"required,min=1"`
+	ClientID             string   `yaml:"clientID"`
+	Topics               []string `validate:"required,min=1"`
+	QoS                  byte     `yaml:"qos" validate:"max=2"`
+	CleanSession         bool     `yaml:"cleanSession"`
+	Username             string
+	Password             string
+	KeepAlive            time.Duration    `yaml:"keepAlive"`
+	ConnectTimeout       time.Duration    `yaml:"connectTimeout"`
+	MaxReconnectInterval time.Duration    `yaml:"maxReconnectInterval"`
+	TLS                  config.TLSConfig `yaml:"tls"`
+	DumpMessages         struct {
+		Enabled bool
+		Path    string
+	} `yaml:"dumpMessages"` // only use for debug as this is very slow
+}
+
+// MQTT basic struct
+type MQTT struct {
+	conf      configT
+	logger    *logging.Logger
+	dumpBuf   *bufio.Writer
+	dumpFile  *os.File
+	mutex     sync.Mutex
+	newClient func(*mqtt.ClientOptions) mqtt.Client
+}
+
+// onMessage delivers received message to handlers and acknowledges it afterwards,
+// so that QoS 1 and 2 messages are redelivered by the broker if sg-core dies mid-processing
+func (m *MQTT) onMessage(w transport.WriteFn) mqtt.MessageHandler {
+	return func(_ mqtt.Client, msg mqtt.Message) {
+		m.mutex.Lock()
+		defer m.mutex.Unlock()
+		if m.conf.DumpMessages.Enabled {
+			m.dump(msg.Payload())
+		}
+		w(msg.Payload())
+		msgCount++
+		msg.Ack()
+	}
+}
+
+func (m *MQTT) dump(msg []byte) {
+	_, err := m.dumpBuf.Write(msg)
+	if err == nil {
+		_, err = m.dumpBuf.WriteString("\n")
+	}
+	if err != nil {
+		m.logger.Metadata(logging.Metadata{"plugin": appname, "error": err})
+		_ = m.logger.Error("writing to dump buffer")
+	}
+	m.dumpBuf.Flush()
+}
+
+func (m *MQTT) clientOptions(w transport.WriteFn) (*mqtt.ClientOptions, error) {
+	opts := mqtt.NewClientOptions()
+	for _, broker := range m.conf.Brokers {
+		opts.AddBroker(broker)
+	}
+	opts.SetClientID(m.conf.ClientID)
+	opts.SetUsername(m.conf.Username)
+	opts.SetPassword(m.conf.Password)
+	opts.SetCleanSession(m.conf.CleanSession)
+	opts.SetKeepAlive(m.conf.KeepAlive)
+	opts.SetConnectTimeout(m.conf.ConnectTimeout)
+	opts.SetMaxReconnectInterval(m.conf.MaxReconnectInterval)
+	opts.SetAutoReconnect(true)
+	opts.SetConnectRetry(true)
+	opts.SetOrderMatters(true)
+	opts.SetAutoAckDisabled(true)
+
+	tlsConf, err := m.conf.TLS.ClientConfig()
+	if err != nil {
+		return nil, err
+	}
+	if tlsConf != nil {
+		opts.SetTLSConfig(tlsConf)
+	}
+
+	filters := make(map[string]byte, len(m.conf.Topics))
+	for _, topic := range m.conf.Topics {
+		filters[topic] = m.conf.QoS
+	}
+	handler := m.onMessage(w)
+	// (re)subscribe on every connection. With a persistent session the broker
+	// keeps subscriptions, but subscribing again is harmless and covers the case
+	// when the session expired on the broker side
+	opts.SetOnConnectHandler(func(c mqtt.Client) {
+		m.logger.Metadata(logging.Metadata{"plugin": appname, "topics": strings.Join(m.conf.Topics, ",")})
+		_ = m.logger.Info("connected, subscribing")
+		token := c.SubscribeMultiple(filters, handler)
+		token.Wait()
+		if token.Error() != nil {
+			m.logger.Metadata(logging.Metadata{"plugin": appname, "error": token.Error()})
+			_ = m.logger.Error("failed to subscribe")
+		}
+	})
+	opts.SetConnectionLostHandler(func(_ mqtt.Client, err error) {
+		m.logger.Metadata(logging.Metadata{"plugin": appname, "error": err})
+		_ = m.logger.Warn("connection lost, reconnecting")
+	})
+	return opts, nil
+}
+
+// Run implements type Transport
+func (m *MQTT) Run(ctx context.Context, w transport.WriteFn, done chan bool) {
+	opts, err := m.clientOptions(w)
+	if err != nil {
+		m.logger.Metadata(logging.Metadata{"plugin": appname, "error": err})
+		_ = m.logger.Error("failed to create client")
+		done <- true
+		return
+	}
+
+	client := m.newClient(opts)
+	// with ConnectRetry enabled the token completes only once a connection succeeds,
+	// so it is waited for together with shutdown instead of blocking on it
+	token := client.Connect()
+	connecting := token.Done()
+
+	m.logger.Metadata(logging.Metadata{"plugin": appname, "brokers": strings.Join(m.conf.Brokers, ",")})
+	_ = m.logger.Info("listening")
+
+	for {
+		select {
+		case <-ctx.Done():
+			goto done
+		case <-connecting:
+			connecting = nil
+			if token.Error() != nil {
+				m.logger.Metadata(logging.Metadata{"plugin": appname, "error": token.Error()})
+				_ = m.logger.Error("failed to connect")
+			}
+		case <-time.After(time.Second):
+			_ = m.logger.Debug(fmt.Sprintf("receiving %d msg/s", rate()))
+		}
+	}
+done:
+	client.Disconnect(250)
+	if m.dumpFile != nil {
+		m.dumpFile.Close()
+	}
+	m.logger.Metadata(logging.Metadata{"plugin": appname})
+	_ = m.logger.Info("exited")
+}
+
+// Listen ...
+func (m *MQTT) Listen(e data.Event) {
+	m.logger.Metadata(logging.Metadata{"plugin": appname, "event": e})
+	_ = m.logger.Debug("received event")
+}
+
+// Config load configurations
+func (m *MQTT) Config(c []byte) error {
+	m.conf = configT{
+		DumpMessages: struct {
+			Enabled bool
+			Path    string
+		}{
+			Path: "/dev/stdout",
+		},
+		QoS:                  1,
+		CleanSession:         true,
+		KeepAlive:            30 * time.Second,
+		ConnectTimeout:       30 * time.Second,
+		MaxReconnectInterval: time.Minute,
+	}
+
+	err := config.ParseConfig(bytes.NewReader(c), &m.conf)
+	if err != nil {
+		return err
+	}
+
+	if !m.conf.CleanSession && m.conf.ClientID == "" {
+		return fmt.Errorf("the clientID configuration option is required when using persistent session")
+	}
+
+	_, err = m.conf.TLS.ClientConfig()
+	if err != nil {
+		return err
+	}
+
+	if m.conf.DumpMessages.Enabled {
+		m.dumpFile, err = os.OpenFile(m.conf.DumpMessages.Path, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0666)
+		if err != nil {
+			return err
+		}
+
+		m.dumpBuf = bufio.NewWriter(m.dumpFile)
+	}
+
+	return nil
+}
+
+// New create new mqtt transport
+func New(l *logging.Logger) transport.Transport {
+	return &MQTT{
+		logger:    l,
+		newClient: mqtt.NewClient,
+	}
+}
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"context"
+	"net"
+	"os"
+	"path"
+	"testing"
+	"time"
+
+	"github.com/infrawatch/apputils/logging"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeMessage struct {
+	topic   string
+	payload []byte
+	acked   bool
+	// written is set by the test WriteFn, to verify ack happens after handling
+	written         *bool
+	ackedAfterWrite bool
+}
+
+func (f *fakeMessage) Duplicate() bool   { return false }
+func (f *fakeMessage) Qos() byte         { return 1 }
+func (f *fakeMessage) Retained() bool    { return false }
+func (f *fakeMessage) Topic() string     { return f.topic }
+func (f *fakeMessage) MessageID() uint16 { return 1 }
+func (f *fakeMessage) Payload() []byte   { return f.payload }
+func (f *fakeMessage) Ack() {
+	f.acked = true
+	f.ackedAfterWrite = *f.written
+}
+
+func TestMQTTTransport(t *testing.T) {
+	tmpdir, err := os.MkdirTemp(".", "mqtt_test_tmp")
+	require.NoError(t, err)
+	defer os.RemoveAll(tmpdir)
+
+	logpath := path.Join(tmpdir, "test.log")
+	logger, err := logging.NewLogger(logging.DEBUG, logpath)
+	require.NoError(t, err)
+
+	t.Run("test message is acknowledged after handling", func(t *testing.T) {
+		trans := New(logger).(*MQTT)
+		require.NoError(t, trans.Config([]byte(`
+brokers: [tcp://localhost:1883]
+topics: [collectd/+/metrics]
+`)))
+		written := false
+		msg := &fakeMessage{
+			topic:   "collectd/compute-0/metrics",
+			payload: []byte(`[{"values": [1]}]`),
+			written: &written,
+		}
+		var received []byte
+		trans.onMessage(func(b []byte) {
+			received = b
+			written = true
+		})(nil, msg)
+
+		assert.Equal(t, msg.payload, received)
+		assert.True(t, msg.acked)
+		assert.True(t, msg.ackedAfterWrite)
+	})
+
+	t.Run("test configuration", func(t *testing.T) {
+		trans := New(logger).(*MQTT)
+		require.NoError(t, trans.Config([]byte(`
+brokers: [tcp://broker0:1883, tcp://broker1:1883]
+topics: [collectd/#, sensubility/+]
+clientID: sg-core-edge
+qos: 2
+cleanSession: false
+`)))
+		opts, err := trans.clientOptions(func([]byte) {})
+		require.NoError(t, err)
+		assert.Len(t, opts.Servers, 2)
+		assert.Equal(t, "sg-core-edge", opts.ClientID)
+		assert.False(t, opts.CleanSession)
+		assert.True(t, opts.AutoReconnect)
+		assert.True(t, opts.AutoAckDisabled)
+		assert.Equal(t, byte(2), trans.conf.QoS)
+
+		// persistent session requires stable client ID
+		assert.Error(t, trans.Config([]byte(`
+brokers: [tcp://localhost:1883]
+topics: [collectd/#]
+cleanSession: false
+`)))
+
+		assert.Error(t, trans.Config([]byte(`
+brokers: [tcp://localhost:1883]
+topics: [collectd/#]
+qos: 3
+`)))
+
+		assert.Error(t, trans.Config([]byte(`
+brokers: [tcp://localhost:1883]
+`)))
+	})
+
+	t.Run("test shutdown while broker is unreachable", func(t *testing.T) {
+		// closed listener leaves port on which connections are refused
+		l, err := net.Listen("tcp", "127.0.0.1:0")
+		require.NoError(t, err)
+		addr := l.Addr().String()
+		require.NoError(t, l.Close())
+
+		trans := New(logger).(*MQTT)
+		require.NoError(t, trans.Config([]byte(`
+brokers: [tcp://`+addr+`]
+topics: [collectd/#]
+connectTimeout: 1s
+`)))
+		ctx, cancel := context.WithCancel(context.Background())
+		done := make(chan bool, 1)
+		exited := make(chan struct{})
+		go func() {
+			trans.Run(ctx, func([]byte) {}, done)
+			close(exited)
+		}()
+
+		time.Sleep(100 * time.Millisecond)
+		cancel()
+		select {
+		case <-exited:
+		case <-time.After(5 * time.Second):
+			t.Fatal("Run did not return after cancelling context")
+		}
+	})
+}
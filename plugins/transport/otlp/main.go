@@ -0,0 +1,224 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"mime"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/infrawatch/apputils/logging"
+	"github.com/openstack-k8s-operators/sg-core/pkg/config"
+	"github.com/openstack-k8s-operators/sg-core/pkg/data"
+	"github.com/openstack-k8s-operators/sg-core/pkg/httputil"
+	"github.com/openstack-k8s-operators/sg-core/pkg/otlp"
+	"github.com/openstack-k8s-operators/sg-core/pkg/transport"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+const (
+	contentTypeProtobuf = "application/x-protobuf"
+	contentTypeJSON     = "application/json"
+)
+
+var appname = "otlp"
+
+type configT struct {
+	Address     string           `validate:"required"`
+	MetricsPath string           `yaml:"metricsPath"`
+	LogsPath    string           `yaml:"logsPath"`
+	MaxBodySize int64            `yaml:"maxBodySize" validate:"min=1"`
+	MaxInFlight int              `yaml:"maxInFlight" validate:"min=1"`
+	ReadTimeout time.Duration    `yaml:"readTimeout"`
+	TLS         config.TLSConfig `yaml:"tls"`
+	Auth        httputil.AuthConfig
+}
+
+// OTLP transport receiving OpenTelemetry Protocol requests over HTTP
+type OTLP struct {
+	conf     configT
+	logger   *logging.Logger
+	mutex    sync.Mutex
+	inFlight httputil.Limiter
+}
+
+// writeStatus responds with google.rpc.Status message in encoding of request
+func writeStatus(rw http.ResponseWriter, encoding otlp.Encoding, status int, message string) {
+	// gRPC codes of INVALID_ARGUMENT and UNAVAILABLE
+	code := 3
+	if status == http.StatusTooManyRequests || status == http.StatusServiceUnavailable {
+		code = 14
+	}
+	var body []byte
+	if encoding == otlp.JSON {
+		rw.Header().Set("Content-Type", contentTypeJSON)
+		body, _ = json.Marshal(map[string]interface{}{"code": code, "message": message})
+	} else {
+		rw.Header().Set("Content-Type", contentTypeProtobuf)
+		body = protowire.AppendVarint(protowire.AppendTag(nil, 1, protowire.VarintType), uint64(code))
+		body = protowire.AppendString(protowire.AppendTag(body, 2, protowire.BytesType), message)
+	}
+	rw.WriteHeader(status)
+	_, _ = rw.Write(body)
+}
+
+func (o *OTLP) receive(w transport.WriteFn, signal otlp.Signal) http.HandlerFunc {
+	return func(rw http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			rw.Header().Set("Allow", "POST")
+			http.Error(rw, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		var encoding otlp.Encoding
+		mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+		switch mediaType {
+		case contentTypeProtobuf:
+			encoding = otlp.Protobuf
+		case contentTypeJSON:
+			encoding = otlp.JSON
+		default:
+			http.Error(rw, "unsupported content type", http.StatusUnsupportedMediaType)
+			return
+		}
+		if !o.conf.Auth.Authorized(r) {
+			o.conf.Auth.Challenge(rw)
+			writeStatus(rw, encoding, http.StatusUnauthorized, "unauthorized")
+			return
+		}
+
+		if !o.inFlight.TryAcquire() {
+			rw.Header().Set("Retry-After", "1")
+			writeStatus(rw, encoding, http.StatusTooManyRequests, "too many requests")
+			return
+		}
+		defer o.inFlight.Release()
+
+		blob, err := httputil.ReadBody(r, o.conf.MaxBodySize)
+		if err != nil {
+			switch {
+			case errors.Is(err, httputil.ErrUnsupportedEncoding):
+				http.Error(rw, err.Error(), http.StatusUnsupportedMediaType)
+			case errors.Is(err, httputil.ErrBodyTooLarge):
+				writeStatus(rw, encoding, http.StatusRequestEntityTooLarge, err.Error())
+			default:
+				writeStatus(rw, encoding, http.StatusBadRequest, err.Error())
+			}
+			return
+		}
+
+		o.mutex.Lock()
+		w(otlp.Wrap(signal, encoding, blob))
+		o.mutex.Unlock()
+
+		// empty Export*ServiceResponse means full success
+		if encoding == otlp.JSON {
+			rw.Header().Set("Content-Type", contentTypeJSON)
+			rw.WriteHeader(http.StatusOK)
+			_, _ = rw.Write([]byte("{}"))
+			return
+		}
+		rw.Header().Set("Content-Type", contentTypeProtobuf)
+		rw.WriteHeader(http.StatusOK)
+	}
+}
+
+func (o *OTLP) handler(w transport.WriteFn) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc(o.conf.MetricsPath, o.receive(w, otlp.Metrics))
+	mux.HandleFunc(o.conf.LogsPath, o.receive(w, otlp.Logs))
+	return mux
+}
+
+// Run implements type Transport
+func (o *OTLP) Run(ctx context.Context, w transport.WriteFn, done chan bool) {
+	tlsConf, err := o.conf.TLS.ServerConfig()
+	if err != nil {
+		o.logger.Metadata(logging.Metadata{"plugin": appname, "error": err})
+		_ = o.logger.Error("failed to load TLS configuration")
+		done <- true
+		return
+	}
+
+	server := &http.Server{
+		Addr:              o.conf.Address,
+		Handler:           o.handler(w),
+		TLSConfig:         tlsConf,
+		ReadHeaderTimeout: o.conf.ReadTimeout,
+		ReadTimeout:       o.conf.ReadTimeout,
+	}
+
+	go func() {
+		var err error
+		if tlsConf != nil {
+			err = server.ListenAndServeTLS("", "")
+		} else {
+			err = server.ListenAndServe()
+		}
+		if err != nil && !errors.Is(err, http.ErrServerClosed) {
+			o.logger.Metadata(logging.Metadata{"plugin": appname, "error": err})
+			_ = o.logger.Error("server failed")
+			done <- true
+		}
+	}()
+
+	o.logger.Metadata(logging.Metadata{"plugin": appname, "address": o.conf.Address})
+	_ = o.logger.Info("listening")
+
+	<-ctx.Done()
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	_ = server.Shutdown(shutdownCtx)
+	cancel()
+	o.logger.Metadata(logging.Metadata{"plugin": appname})
+	_ = o.logger.Info("exited")
+}
+
+// Listen ...
+func (o *OTLP) Listen(e data.Event) {
+	o.logger.Metadata(logging.Metadata{"plugin": appname, "event": e})
+	_ = o.logger.Debug("received event")
+}
+
+// Config load configurations
+func (o *OTLP) Config(c []byte) error {
+	o.conf = configT{
+		Address:     ":4318",
+		MetricsPath: "/v1/metrics",
+		LogsPath:    "/v1/logs",
+		MaxBodySize: 10 << 20,
+		MaxInFlight: 64,
+		ReadTimeout: 30 * time.Second,
+		Auth:        httputil.AuthConfig{Type: "none"},
+	}
+
+	err := config.ParseConfig(bytes.NewReader(c), &o.conf)
+	if err != nil {
+		return err
+	}
+	if o.conf.MetricsPath == o.conf.LogsPath {
+		return fmt.Errorf("metricsPath and logsPath have to differ")
+	}
+
+	err = o.conf.Auth.Validate()
+	if err != nil {
+		return err
+	}
+
+	_, err = o.conf.TLS.ServerConfig()
+	if err != nil {
+		return err
+	}
+
+	o.inFlight = httputil.NewLimiter(o.conf.MaxInFlight)
+	return nil
+}
+
+// New create new otlp transport
+func New(l *logging.Logger) transport.Transport {
+	return &OTLP{
+		logger: l,
+	}
+}
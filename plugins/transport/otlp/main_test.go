@@ -0,0 +1,120 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path"
+	"testing"
+	"time"
+
+	"github.com/infrawatch/apputils/logging"
+	"github.com/openstack-k8s-operators/sg-core/pkg/otlp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestTransport(t *testing.T, logger *logging.Logger, conf string) *OTLP {
+	trans := New(logger).(*OTLP)
+	require.NoError(t, trans.Config([]byte(conf)))
+	return trans
+}
+
+func post(srv http.Handler, path string, contentType string, body []byte, headers ...string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, path, bytes.NewReader(body))
+	req.Header.Set("Content-Type", contentType)
+	for i := 0; i+1 < len(headers); i += 2 {
+		req.Header.Set(headers[i], headers[i+1])
+	}
+	rec := httptest.NewRecorder()
+	srv.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestOTLPTransport(t *testing.T) {
+	tmpdir, err := os.MkdirTemp(".", "otlp_test_tmp")
+	require.NoError(t, err)
+	defer os.RemoveAll(tmpdir)
+
+	logger, err := logging.NewLogger(logging.DEBUG, path.Join(tmpdir, "test.log"))
+	require.NoError(t, err)
+
+	t.Run("test signals and encodings", func(t *testing.T) {
+		trans := newTestTransport(t, logger, "address: 127.0.0.1:0")
+		received := [][]byte{}
+		srv := trans.handler(func(b []byte) { received = append(received, b) })
+
+		rec := post(srv, "/v1/metrics", "application/x-protobuf", []byte{0x0a, 0x00})
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, "application/x-protobuf", rec.Header().Get("Content-Type"))
+		assert.Empty(t, rec.Body.Bytes())
+
+		var gz bytes.Buffer
+		zw := gzip.NewWriter(&gz)
+		_, err := zw.Write([]byte(`{"resourceLogs":[]}`))
+		require.NoError(t, err)
+		require.NoError(t, zw.Close())
+		rec = post(srv, "/v1/logs", "application/json; charset=utf-8", gz.Bytes(), "Content-Encoding", "gzip")
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, "{}", rec.Body.String())
+
+		require.Len(t, received, 2)
+		signal, encoding, payload, ok := otlp.Unwrap(received[0])
+		assert.True(t, ok)
+		assert.Equal(t, otlp.Metrics, signal)
+		assert.Equal(t, otlp.Protobuf, encoding)
+		assert.Equal(t, []byte{0x0a, 0x00}, payload)
+		signal, encoding, payload, ok = otlp.Unwrap(received[1])
+		assert.True(t, ok)
+		assert.Equal(t, otlp.Logs, signal)
+		assert.Equal(t, otlp.JSON, encoding)
+		assert.Equal(t, `{"resourceLogs":[]}`, string(payload))
+
+		assert.Equal(t, http.StatusNotFound, post(srv, "/v1/traces", "application/json", []byte("{}")).Code)
+		assert.Equal(t, http.StatusUnsupportedMediaType, post(srv, "/v1/logs", "text/plain", []byte("{}")).Code)
+		assert.Equal(t, http.StatusUnsupportedMediaType, post(srv, "/v1/logs", "application/json", []byte("{}"), "Content-Encoding", "br").Code)
+		rec = post(srv, "/v1/logs", "application/json", []byte("{}"), "Content-Encoding", "gzip")
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+		assert.Contains(t, rec.Body.String(), `"code":3`)
+
+		rec = httptest.NewRecorder()
+		srv.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/v1/metrics", nil))
+		assert.Equal(t, http.StatusMethodNotAllowed, rec.Code)
+		assert.Len(t, received, 2)
+	})
+
+	t.Run("test authentication and limits", func(t *testing.T) {
+		trans := newTestTransport(t, logger, `
+address: 127.0.0.1:0
+maxInFlight: 1
+maxBodySize: 8
+auth:
+  type: bearer
+  token: abc
+`)
+		srv := trans.handler(func([]byte) {})
+		assert.Equal(t, http.StatusUnauthorized, post(srv, "/v1/metrics", "application/x-protobuf", nil).Code)
+		assert.Equal(t, http.StatusOK, post(srv, "/v1/metrics", "application/x-protobuf", nil, "Authorization", "Bearer abc").Code)
+		assert.Equal(t, http.StatusRequestEntityTooLarge,
+			post(srv, "/v1/metrics", "application/x-protobuf", make([]byte, 9), "Authorization", "Bearer abc").Code)
+
+		trans.inFlight <- struct{}{}
+		rec := post(srv, "/v1/metrics", "application/x-protobuf", nil, "Authorization", "Bearer abc")
+		assert.Equal(t, http.StatusTooManyRequests, rec.Code)
+		assert.Equal(t, "1", rec.Header().Get("Retry-After"))
+		<-trans.inFlight
+	})
+
+	t.Run("test configuration", func(t *testing.T) {
+		trans := New(logger).(*OTLP)
+		require.NoError(t, trans.Config([]byte("maxInFlight: 4\nreadTimeout: 5s")))
+		assert.Equal(t, ":4318", trans.conf.Address)
+		assert.Equal(t, 5*time.Second, trans.conf.ReadTimeout)
+		assert.Error(t, trans.Config([]byte("maxBodySize: 0")))
+		assert.Error(t, trans.Config([]byte("logsPath: /v1/metrics")))
+		assert.Error(t, trans.Config([]byte("auth:\n  type: basic")))
+		assert.Error(t, trans.Config([]byte("tls:\n  enabled: true")))
+	})
+}
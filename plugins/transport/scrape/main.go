@@ -0,0 +1,201 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/infrawatch/apputils/logging"
+	"github.com/openstack-k8s-operators/sg-core/pkg/bus"
+	"github.com/openstack-k8s-operators/sg-core/pkg/config"
+	"github.com/openstack-k8s-operators/sg-core/pkg/data"
+	"github.com/openstack-k8s-operators/sg-core/pkg/transport"
+)
+
+const (
+	defaultAccept = "application/openmetrics-text;version=1.0.0,text/plain;version=0.0.4;q=0.5,*/*;q=0.1"
+)
+
+var appname = "scrape"
+
+type configT struct {
+	Interval    time.Duration // default scrape interval of targets
+	Timeout     time.Duration // default scrape timeout of targets
+	MaxBodySize int64         `yaml:"maxBodySize" validate:"min=1"`
+	Accept      string
+	Targets     []targetConfig `validate:"min=1,dive"`
+}
+
+// Scrape transport periodically fetching messages from HTTP targets
+type Scrape struct {
+	conf    configT
+	logger  *logging.Logger
+	targets []*target
+	mpf     bus.MetricPublishFunc
+	mutex   sync.Mutex
+	logLock sync.Mutex // logger is shared by scrape loops
+}
+
+func (s *Scrape) warn(t *target, err error) {
+	s.logLock.Lock()
+	defer s.logLock.Unlock()
+	s.logger.Metadata(logging.Metadata{"plugin": appname, "target": t.conf.Name, "error": err})
+	_ = s.logger.Warn("scrape failed")
+}
+
+func (s *Scrape) scrapeLoop(ctx context.Context, t *target, wm transport.WriteWithMetadataFn) {
+	ticker := time.NewTicker(t.conf.Interval)
+	defer ticker.Stop()
+	for {
+		blob, err := t.scrape(ctx, s.conf.Accept, s.conf.MaxBodySize)
+		if ctx.Err() != nil {
+			return
+		}
+		if err != nil {
+			s.warn(t, err)
+		} else if len(blob) > 0 {
+			// handlers are not required to be safe for concurrent use
+			s.mutex.Lock()
+			wm(blob, t.metadata)
+			s.mutex.Unlock()
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (s *Scrape) publishStats() {
+	if s.mpf == nil {
+		return
+	}
+	for _, t := range s.targets {
+		st := t.snapshot()
+		labelKeys := []string{"source", "target"}
+		labelVals := []string{"SG", t.conf.Name}
+		up := 0.0
+		if st.up {
+			up = 1.0
+		}
+		s.mpf("sg_total_scrape_up", 0, data.GAUGE, 0, up, labelKeys, labelVals)
+		s.mpf("sg_total_scrape_duration_seconds", 0, data.GAUGE, 0, st.duration.Seconds(), labelKeys, labelVals)
+		s.mpf("sg_total_scrape_count", 0, data.COUNTER, 0, float64(st.scrapes), labelKeys, labelVals)
+		s.mpf("sg_total_scrape_failure_count", 0, data.COUNTER, 0, float64(st.failures), labelKeys, labelVals)
+		s.mpf("sg_total_scrape_received_bytes", 0, data.COUNTER, 0, float64(st.bytes), labelKeys, labelVals)
+	}
+}
+
+// SetMetricPublishFunc implements type transport.MetricPublisher
+func (s *Scrape) SetMetricPublishFunc(mpf bus.MetricPublishFunc) {
+	s.mpf = mpf
+}
+
+// Run implements type Transport
+func (s *Scrape) Run(ctx context.Context, w transport.WriteFn, done chan bool) {
+	s.RunWithMetadata(ctx, func(blob []byte, _ transport.Metadata) {
+		w(blob)
+	}, done)
+}
+
+// RunWithMetadata implements type transport.MetadataTransport
+func (s *Scrape) RunWithMetadata(ctx context.Context, wm transport.WriteWithMetadataFn, _ chan bool) {
+	wg := sync.WaitGroup{}
+	for _, t := range s.targets {
+		wg.Add(1)
+		go func(t *target) {
+			defer wg.Done()
+			s.scrapeLoop(ctx, t, wm)
+		}(t)
+	}
+	s.logLock.Lock()
+	s.logger.Metadata(logging.Metadata{"plugin": appname, "targets": len(s.targets)})
+	_ = s.logger.Info("scraping")
+	s.logLock.Unlock()
+
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			wg.Wait()
+			s.logger.Metadata(logging.Metadata{"plugin": appname})
+			_ = s.logger.Info("exited")
+			return
+		case <-ticker.C:
+			s.publishStats()
+		}
+	}
+}
+
+// Listen ...
+func (s *Scrape) Listen(e data.Event) {
+	s.logger.Metadata(logging.Metadata{"plugin": appname, "event": e})
+	_ = s.logger.Debug("received event")
+}
+
+// Config load configurations
+func (s *Scrape) Config(c []byte) error {
+	s.conf = configT{
+		Interval:    30 * time.Second,
+		Timeout:     10 * time.Second,
+		MaxBodySize: 10 << 20,
+		Accept:      defaultAccept,
+	}
+	err := config.ParseConfig(bytes.NewReader(c), &s.conf)
+	if err != nil {
+		return err
+	}
+
+	s.targets = make([]*target, 0, len(s.conf.Targets))
+	names := map[string]bool{}
+	for _, tc := range s.conf.Targets {
+		if names[tc.Name] {
+			return fmt.Errorf("duplicate scrape target %s", tc.Name)
+		}
+		names[tc.Name] = true
+
+		if tc.Interval == 0 {
+			tc.Interval = s.conf.Interval
+		}
+		if tc.Timeout == 0 {
+			tc.Timeout = min(s.conf.Timeout, tc.Interval)
+		}
+		if tc.Interval <= 0 || tc.Timeout <= 0 {
+			return fmt.Errorf("interval and timeout of scrape target %s have to be positive", tc.Name)
+		}
+		if tc.Timeout > tc.Interval {
+			return fmt.Errorf("timeout of scrape target %s is longer than its interval", tc.Name)
+		}
+		u, err := url.Parse(tc.URL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+			return fmt.Errorf("invalid URL of scrape target %s", tc.Name)
+		}
+		// token file replaces token of bearer authentication
+		if tc.Auth.Type != "bearer" || tc.Auth.TokenFile == "" {
+			err = tc.Auth.Validate()
+			if err != nil {
+				return err
+			}
+		}
+
+		t, err := newTarget(tc)
+		if err != nil {
+			return err
+		}
+		s.targets = append(s.targets, t)
+	}
+	return nil
+}
+
+// New create new scrape transport
+func New(l *logging.Logger) transport.Transport {
+	return &Scrape{
+		logger: l,
+	}
+}
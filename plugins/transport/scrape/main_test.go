@@ -0,0 +1,163 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/infrawatch/apputils/logging"
+	"github.com/openstack-k8s-operators/sg-core/pkg/data"
+	"github.com/openstack-k8s-operators/sg-core/pkg/transport"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const exposition = "# TYPE up gauge\nup 1\n"
+
+func newTestTransport(t *testing.T, logger *logging.Logger, conf string) *Scrape {
+	trans := New(logger).(*Scrape)
+	require.NoError(t, trans.Config([]byte(conf)))
+	return trans
+}
+
+func TestScrapeTransport(t *testing.T) {
+	tmpdir, err := os.MkdirTemp(".", "scrape_test_tmp")
+	require.NoError(t, err)
+	defer os.RemoveAll(tmpdir)
+
+	logpath := path.Join(tmpdir, "test.log")
+	logger, err := logging.NewLogger(logging.DEBUG, logpath)
+	require.NoError(t, err)
+
+	srv := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/basic":
+			user, pass, ok := r.BasicAuth()
+			if !ok || user != "sg" || pass != "secret" {
+				rw.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+		case "/bearer":
+			if r.Header.Get("Authorization") != "Bearer rotated" || r.Header.Get("X-Tenant") != "infra" {
+				rw.WriteHeader(http.StatusForbidden)
+				return
+			}
+		case "/large":
+			_, _ = rw.Write(make([]byte, 2048))
+			return
+		default:
+			rw.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		assert.Contains(t, r.Header.Get("Accept"), "application/openmetrics-text")
+		assert.NotEmpty(t, r.Header.Get("X-Prometheus-Scrape-Timeout-Seconds"))
+		_, _ = rw.Write([]byte(exposition))
+	}))
+	defer srv.Close()
+
+	tokenFile := path.Join(tmpdir, "token")
+	require.NoError(t, os.WriteFile(tokenFile, []byte("rotated\n"), 0600))
+
+	t.Run("test scraping of targets", func(t *testing.T) {
+		trans := newTestTransport(t, logger, `
+interval: 50ms
+timeout: 2s
+maxBodySize: 1024
+targets:
+  - name: basic
+    url: `+srv.URL+`/basic
+    auth:
+      type: basic
+      username: sg
+      password: secret
+    labels:
+      instance: node-1
+  - name: bearer
+    url: `+srv.URL+`/bearer
+    interval: 1m
+    timeout: 2s
+    headers:
+      X-Tenant: infra
+    auth:
+      type: bearer
+      tokenFile: `+tokenFile+`
+  - name: broken
+    url: `+srv.URL+`/broken
+    timeout: 50ms
+  - name: large
+    url: `+srv.URL+`/large
+    timeout: 50ms
+`)
+		published := map[string]float64{}
+		var statsLock sync.Mutex
+		trans.SetMetricPublishFunc(func(name string, _ float64, _ data.MetricType, _ time.Duration, value float64, _ []string, labelVals []string) {
+			statsLock.Lock()
+			published[name+"/"+labelVals[1]] = value
+			statsLock.Unlock()
+		})
+
+		type message struct {
+			blob string
+			meta transport.Metadata
+		}
+		received := make(chan message, 100)
+		ctx, cancel := context.WithCancel(context.Background())
+		finished := make(chan struct{})
+		go func() {
+			trans.RunWithMetadata(ctx, func(blob []byte, meta transport.Metadata) {
+				received <- message{string(blob), meta}
+			}, make(chan bool))
+			close(finished)
+		}()
+
+		byMeta := map[string]int{}
+		for len(byMeta) < 2 || byMeta["node-1"] < 2 {
+			select {
+			case m := <-received:
+				assert.Equal(t, exposition, m.blob)
+				byMeta[m.meta["instance"]]++
+			case <-time.After(5 * time.Second):
+				require.FailNow(t, "targets were not scraped", "%v", byMeta)
+			}
+		}
+		// target with long interval is scraped only once on start
+		assert.Equal(t, 1, byMeta[""])
+
+		require.Eventually(t, func() bool {
+			statsLock.Lock()
+			defer statsLock.Unlock()
+			return published["sg_total_scrape_failure_count/large"] > 0
+		}, 5*time.Second, 50*time.Millisecond)
+		cancel()
+		<-finished
+
+		statsLock.Lock()
+		defer statsLock.Unlock()
+		assert.Equal(t, 1.0, published["sg_total_scrape_up/basic"])
+		assert.Equal(t, 1.0, published["sg_total_scrape_up/bearer"])
+		assert.Equal(t, 0.0, published["sg_total_scrape_up/broken"])
+		assert.Equal(t, 0.0, published["sg_total_scrape_up/large"])
+		assert.Equal(t, float64(len(exposition)), published["sg_total_scrape_received_bytes/bearer"])
+		assert.Equal(t, published["sg_total_scrape_count/broken"], published["sg_total_scrape_failure_count/broken"])
+	})
+
+	t.Run("test invalid configuration", func(t *testing.T) {
+		for _, conf := range []string{
+			"targets: []",
+			"targets:\n  - url: http://localhost:9100/metrics",
+			"targets:\n  - name: a\n    url: ftp://localhost/metrics",
+			"targets:\n  - name: a\n    url: http://localhost:9100/metrics\n    interval: 5s\n    timeout: 10s",
+			"targets:\n  - name: a\n    url: http://localhost:9100/metrics\n  - name: a\n    url: http://localhost:9101/metrics",
+			"targets:\n  - name: a\n    url: http://localhost:9100/metrics\n    auth:\n      type: bearer",
+			"targets:\n  - name: a\n    url: http://localhost:9100/metrics\n    auth:\n      type: digest",
+			"maxBodySize: 0\ntargets:\n  - name: a\n    url: http://localhost:9100/metrics",
+		} {
+			assert.Error(t, New(logger).Config([]byte(conf)), conf)
+		}
+	})
+}
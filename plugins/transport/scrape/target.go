@@ -0,0 +1,158 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/openstack-k8s-operators/sg-core/pkg/config"
+	"github.com/openstack-k8s-operators/sg-core/pkg/httputil"
+	"github.com/openstack-k8s-operators/sg-core/pkg/transport"
+)
+
+var errBodyTooLarge = errors.New("response body too large")
+
+type authConfig struct {
+	httputil.AuthConfig `yaml:",inline"`
+	TokenFile           string `yaml:"tokenFile"` // read before every scrape, so that rotated tokens are used
+}
+
+type targetConfig struct {
+	Name     string `validate:"required"`
+	URL      string `validate:"required"`
+	Interval time.Duration
+	Timeout  time.Duration
+	TLS      config.TLSConfig `yaml:"tls"`
+	Auth     authConfig
+	Headers  map[string]string
+	Labels   map[string]string // attached to all metrics and events produced from scraped messages
+}
+
+type targetStats struct {
+	up       bool
+	duration time.Duration
+	scrapes  uint64
+	failures uint64
+	bytes    uint64
+}
+
+// target is single scraped endpoint
+type target struct {
+	conf     targetConfig
+	client   *http.Client
+	metadata transport.Metadata
+	mutex    sync.Mutex
+	stats    targetStats
+}
+
+func newTarget(conf targetConfig) (*target, error) {
+	tlsConf, err := conf.TLS.ClientConfig()
+	if err != nil {
+		return nil, fmt.Errorf("scrape target %s: %w", conf.Name, err)
+	}
+	t := &target{
+		conf: conf,
+		client: &http.Client{
+			Transport: &http.Transport{
+				Proxy:           http.ProxyFromEnvironment,
+				TLSClientConfig: tlsConf,
+				MaxIdleConns:    1,
+			},
+		},
+	}
+	if len(conf.Labels) > 0 {
+		t.metadata = transport.Metadata{}
+		for k, v := range conf.Labels {
+			t.metadata[k] = v
+		}
+	}
+	return t, nil
+}
+
+func (t *target) request(ctx context.Context, accept string) (*http.Request, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, t.conf.URL, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", accept)
+	req.Header.Set("User-Agent", "sg-core")
+	req.Header.Set("X-Prometheus-Scrape-Timeout-Seconds", strconv.FormatFloat(t.conf.Timeout.Seconds(), 'f', -1, 64))
+	for k, v := range t.conf.Headers {
+		req.Header.Set(k, v)
+	}
+
+	switch t.conf.Auth.Type {
+	case "basic":
+		req.SetBasicAuth(t.conf.Auth.Username, t.conf.Auth.Password)
+	case "bearer":
+		token := t.conf.Auth.Token
+		if t.conf.Auth.TokenFile != "" {
+			raw, err := os.ReadFile(t.conf.Auth.TokenFile)
+			if err != nil {
+				return nil, err
+			}
+			token = strings.TrimSpace(string(raw))
+		}
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	return req, nil
+}
+
+func (t *target) fetch(ctx context.Context, accept string, maxBodySize int64) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(ctx, t.conf.Timeout)
+	defer cancel()
+	req, err := t.request(ctx, accept)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := t.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		// drain a bit of body so that connection can be reused
+		_, _ = io.CopyN(io.Discard, resp.Body, 4096)
+		return nil, fmt.Errorf("server returned HTTP status %s", resp.Status)
+	}
+	// gzip encoded responses are decompressed by http.Transport
+	blob, err := io.ReadAll(io.LimitReader(resp.Body, maxBodySize+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(blob)) > maxBodySize {
+		return nil, errBodyTooLarge
+	}
+	return blob, nil
+}
+
+// scrape fetches target and records result in stats
+func (t *target) scrape(ctx context.Context, accept string, maxBodySize int64) ([]byte, error) {
+	start := time.Now()
+	blob, err := t.fetch(ctx, accept, maxBodySize)
+
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	t.stats.duration = time.Since(start)
+	t.stats.scrapes++
+	t.stats.up = err == nil
+	if err != nil {
+		t.stats.failures++
+		return nil, err
+	}
+	t.stats.bytes += uint64(len(blob))
+	return blob, nil
+}
+
+func (t *target) snapshot() targetStats {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	return t.stats
+}
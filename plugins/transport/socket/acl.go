@@ -0,0 +1,143 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+
+	"github.com/openstack-k8s-operators/sg-core/pkg/transport"
+)
+
+type accessConfig struct {
+	AllowCIDRs []string `yaml:"allowCidrs"` // only peers from these networks are accepted, when set
+	DenyCIDRs  []string `yaml:"denyCidrs"`  // takes precedence over allowCidrs
+	AllowUIDs  []int    `yaml:"allowUids"`
+	AllowGIDs  []int    `yaml:"allowGids"`
+}
+
+// peer identifies sender of received data
+type peer struct {
+	ip       net.IP // nil for unix sockets
+	uid, gid int    // -1 when credentials are not known
+}
+
+var unknownPeer = peer{uid: -1, gid: -1}
+
+func (p peer) String() string {
+	if p.ip != nil {
+		return p.ip.String()
+	}
+	return fmt.Sprintf("uid=%d gid=%d", p.uid, p.gid)
+}
+
+// metadata returns peer identity attached as labels when peerLabels is enabled
+func (p peer) metadata() transport.Metadata {
+	if p.ip != nil {
+		return transport.Metadata{"peer_address": p.ip.String()}
+	}
+	if p.uid == -1 {
+		return nil
+	}
+	return transport.Metadata{
+		"peer_uid": strconv.Itoa(p.uid),
+		"peer_gid": strconv.Itoa(p.gid),
+	}
+}
+
+// accessList decides which peers are allowed to send data
+type accessList struct {
+	allow []*net.IPNet
+	deny  []*net.IPNet
+	uids  map[int]bool
+	gids  map[int]bool
+}
+
+func parseCIDRs(cidrs []string) ([]*net.IPNet, error) {
+	nets := make([]*net.IPNet, 0, len(cidrs))
+	for _, cidr := range cidrs {
+		_, ipnet, err := net.ParseCIDR(cidr)
+		if err != nil {
+			// single address is accepted as well
+			ip := net.ParseIP(cidr)
+			if ip == nil {
+				return nil, fmt.Errorf("invalid CIDR %q", cidr)
+			}
+			bits := 8 * len(ip)
+			if ip.To4() != nil {
+				ip, bits = ip.To4(), 32
+			}
+			ipnet = &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)}
+		}
+		nets = append(nets, ipnet)
+	}
+	return nets, nil
+}
+
+func newAccessList(conf accessConfig) (*accessList, error) {
+	al := &accessList{
+		uids: map[int]bool{},
+		gids: map[int]bool{},
+	}
+	var err error
+	if al.allow, err = parseCIDRs(conf.AllowCIDRs); err != nil {
+		return nil, err
+	}
+	if al.deny, err = parseCIDRs(conf.DenyCIDRs); err != nil {
+		return nil, err
+	}
+	for _, uid := range conf.AllowUIDs {
+		al.uids[uid] = true
+	}
+	for _, gid := range conf.AllowGIDs {
+		al.gids[gid] = true
+	}
+	return al, nil
+}
+
+func (al *accessList) hasCIDRs() bool {
+	return len(al.allow) > 0 || len(al.deny) > 0
+}
+
+func (al *accessList) hasCredentials() bool {
+	return len(al.uids) > 0 || len(al.gids) > 0
+}
+
+func containsIP(nets []*net.IPNet, ip net.IP) bool {
+	for _, n := range nets {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// permits reports whether data from the peer should be accepted
+func (al *accessList) permits(p peer) bool {
+	if al == nil {
+		return true
+	}
+	if p.ip != nil {
+		if containsIP(al.deny, p.ip) {
+			return false
+		}
+		if len(al.allow) > 0 && !containsIP(al.allow, p.ip) {
+			return false
+		}
+	}
+	if al.hasCredentials() {
+		return (p.uid != -1 && al.uids[p.uid]) || (p.gid != -1 && al.gids[p.gid])
+	}
+	return true
+}
+
+// addrPeer returns peer of IP connection or datagram
+func addrPeer(addr net.Addr) peer {
+	p := unknownPeer
+	switch a := addr.(type) {
+	case *net.TCPAddr:
+		p.ip = a.IP
+	case *net.UDPAddr:
+		p.ip = a.IP
+	}
+	return p
+}
@@ -0,0 +1,214 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"os"
+	"path"
+	"testing"
+	"time"
+
+	"github.com/infrawatch/apputils/logging"
+	"github.com/openstack-k8s-operators/sg-core/pkg/transport"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type receivedMsg struct {
+	msg  string
+	meta transport.Metadata
+}
+
+func TestAccessList(t *testing.T) {
+	al, err := newAccessList(accessConfig{
+		AllowCIDRs: []string{"10.0.0.0/8", "2001:db8::/32", "192.168.1.1"},
+		DenyCIDRs:  []string{"10.1.0.0/16"},
+	})
+	require.NoError(t, err)
+
+	for ip, expected := range map[string]bool{
+		"10.0.0.1":         true,
+		"::ffff:10.2.3.4":  true,
+		"10.1.2.3":         false,
+		"2001:db8::1":      true,
+		"192.168.1.1":      true,
+		"192.168.1.2":      false,
+		"2001:db9::1":      false,
+		"::ffff:10.1.0.10": false,
+	} {
+		assert.Equal(t, expected, al.permits(peer{ip: net.ParseIP(ip), uid: -1, gid: -1}), ip)
+	}
+
+	al, err = newAccessList(accessConfig{AllowUIDs: []int{0}, AllowGIDs: []int{100}})
+	require.NoError(t, err)
+	assert.True(t, al.permits(peer{uid: 0, gid: 0}))
+	assert.True(t, al.permits(peer{uid: 1000, gid: 100}))
+	assert.False(t, al.permits(peer{uid: 1000, gid: 1000}))
+	assert.False(t, al.permits(unknownPeer))
+
+	var nilList *accessList
+	assert.True(t, nilList.permits(unknownPeer))
+
+	_, err = newAccessList(accessConfig{DenyCIDRs: []string{"10.0.0.0/33"}})
+	assert.Error(t, err)
+}
+
+func runACLSocket(t *testing.T, conf string) (chan receivedMsg, *Socket) {
+	logger, err := logging.NewLogger(logging.ERROR, os.DevNull)
+	require.NoError(t, err)
+	trans := New(logger).(*Socket)
+	require.NoError(t, trans.Config([]byte(conf)))
+
+	received := make(chan receivedMsg, 4)
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	go trans.RunWithMetadata(ctx, func(msg []byte, meta transport.Metadata) {
+		received <- receivedMsg{string(msg), meta}
+	}, make(chan bool, 1))
+	return received, trans
+}
+
+func dialRetry(t *testing.T, network, addr string) net.Conn {
+	var conn net.Conn
+	var err error
+	for retries := 0; retries < 10; retries++ {
+		conn, err = net.Dial(network, addr)
+		if err == nil {
+			break
+		}
+		time.Sleep(250 * time.Millisecond)
+	}
+	require.NoError(t, err)
+	return conn
+}
+
+func expectMsg(t *testing.T, received chan receivedMsg) receivedMsg {
+	select {
+	case rm := <-received:
+		return rm
+	case <-time.After(5 * time.Second):
+		t.Fatal("message was not received")
+	}
+	return receivedMsg{}
+}
+
+func TestPeerAccessControl(t *testing.T) {
+	t.Run("test udp peers", func(t *testing.T) {
+		received, trans := runACLSocket(t, `
+type: udp
+socketaddr: 127.0.0.1:8645
+peerLabels: true
+access:
+    allowCidrs: [127.0.0.0/8]
+    denyCidrs: [127.0.0.2]
+`)
+		time.Sleep(250 * time.Millisecond)
+		denied, err := net.DialUDP("udp", &net.UDPAddr{IP: net.ParseIP("127.0.0.2")}, &net.UDPAddr{IP: net.ParseIP("127.0.0.1"), Port: 8645})
+		require.NoError(t, err)
+		defer denied.Close()
+		_, err = denied.Write([]byte("denied"))
+		require.NoError(t, err)
+
+		allowed := dialRetry(t, "udp", "127.0.0.1:8645")
+		defer allowed.Close()
+		_, err = allowed.Write([]byte("allowed"))
+		require.NoError(t, err)
+
+		rm := expectMsg(t, received)
+		assert.Equal(t, "allowed", rm.msg)
+		assert.Equal(t, transport.Metadata{"peer_address": "127.0.0.1"}, rm.meta)
+		assert.Equal(t, int64(1), trans.stats.rejected.Load())
+	})
+
+	t.Run("test tcp peers", func(t *testing.T) {
+		received, trans := runACLSocket(t, `
+type: tcp
+socketaddr: 127.0.0.1:8646
+framing: newline
+access:
+    denyCidrs: [127.0.0.0/8]
+`)
+		conn := dialRetry(t, "tcp", "127.0.0.1:8646")
+		defer conn.Close()
+		_, _ = conn.Write([]byte("denied\n"))
+
+		// rejected connection is closed by server
+		require.NoError(t, conn.SetReadDeadline(time.Now().Add(5*time.Second)))
+		_, err := conn.Read(make([]byte, 1))
+		assert.Error(t, err)
+		assert.Equal(t, int64(1), trans.stats.rejected.Load())
+		assert.Equal(t, 0, len(received))
+	})
+
+	t.Run("test unix stream credentials", func(t *testing.T) {
+		tmpdir, err := os.MkdirTemp(".", "socket_test_tmp")
+		require.NoError(t, err)
+		defer os.RemoveAll(tmpdir)
+
+		allowedPath := path.Join(tmpdir, "allowed.sock")
+		received, _ := runACLSocket(t, fmt.Sprintf(`
+type: unixstream
+path: %s
+framing: newline
+peerLabels: true
+access:
+    allowUids: [%d]
+`, allowedPath, os.Getuid()))
+		conn := dialRetry(t, "unix", allowedPath)
+		defer conn.Close()
+		_, err = conn.Write([]byte("allowed\n"))
+		require.NoError(t, err)
+		rm := expectMsg(t, received)
+		assert.Equal(t, "allowed", rm.msg)
+		assert.Equal(t, transport.Metadata{"peer_uid": fmt.Sprint(os.Getuid()), "peer_gid": fmt.Sprint(os.Getgid())}, rm.meta)
+
+		deniedPath := path.Join(tmpdir, "denied.sock")
+		_, trans := runACLSocket(t, fmt.Sprintf(`
+type: unixstream
+path: %s
+access:
+    allowUids: [%d]
+`, deniedPath, os.Getuid()+1))
+		conn = dialRetry(t, "unix", deniedPath)
+		defer conn.Close()
+		require.NoError(t, conn.SetReadDeadline(time.Now().Add(5*time.Second)))
+		_, err = conn.Read(make([]byte, 1))
+		assert.Error(t, err)
+		assert.Equal(t, int64(1), trans.stats.rejected.Load())
+	})
+
+	t.Run("test unix datagram credentials", func(t *testing.T) {
+		tmpdir, err := os.MkdirTemp(".", "socket_test_tmp")
+		require.NoError(t, err)
+		defer os.RemoveAll(tmpdir)
+
+		sktpath := path.Join(tmpdir, "dgram.sock")
+		received, _ := runACLSocket(t, fmt.Sprintf(`
+type: unix
+path: %s
+peerLabels: true
+access:
+    allowGids: [%d]
+`, sktpath, os.Getgid()))
+		conn := dialRetry(t, "unixgram", sktpath)
+		defer conn.Close()
+		_, err = conn.Write([]byte("allowed"))
+		require.NoError(t, err)
+		rm := expectMsg(t, received)
+		assert.Equal(t, "allowed", rm.msg)
+		assert.Equal(t, fmt.Sprint(os.Getgid()), rm.meta["peer_gid"])
+	})
+
+	t.Run("test invalid configuration", func(t *testing.T) {
+		logger, err := logging.NewLogger(logging.ERROR, os.DevNull)
+		require.NoError(t, err)
+		for _, conf := range []string{
+			"type: unix\npath: /tmp/x\naccess:\n    allowCidrs: [10.0.0.0/8]",
+			"type: tcp\nsocketaddr: 127.0.0.1:0\naccess:\n    allowUids: [0]",
+			"type: udp\nsocketaddr: 127.0.0.1:0\naccess:\n    denyCidrs: [nonsense]",
+		} {
+			assert.Error(t, New(logger).Config([]byte(conf)), conf)
+		}
+	})
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+// first file descriptor passed by systemd, see sd_listen_fds(3)
+const listenFDsStart = 3
+
+type listenFD struct {
+	fd   int
+	name string
+}
+
+// systemdListenFDs returns file descriptors passed to the process by systemd socket activation
+func systemdListenFDs() ([]listenFD, error) {
+	pid, err := strconv.Atoi(os.Getenv("LISTEN_PID"))
+	if err != nil || pid != os.Getpid() {
+		return nil, fmt.Errorf("no sockets were passed by systemd (LISTEN_PID is not set to the pid of this process)")
+	}
+	count, err := strconv.Atoi(os.Getenv("LISTEN_FDS"))
+	if err != nil || count < 1 {
+		return nil, fmt.Errorf("no sockets were passed by systemd (LISTEN_FDS=%q)", os.Getenv("LISTEN_FDS"))
+	}
+	names := strings.Split(os.Getenv("LISTEN_FDNAMES"), ":")
+
+	fds := make([]listenFD, 0, count)
+	for i := 0; i < count; i++ {
+		lfd := listenFD{fd: listenFDsStart + i}
+		if i < len(names) {
+			lfd.name = names[i]
+		}
+		fds = append(fds, lfd)
+	}
+	return fds, nil
+}
+
+// matchesType reports whether socket behind fd can serve given socket type
+func matchesType(fd int, socketType string) bool {
+	soType, err := syscall.GetsockoptInt(fd, syscall.SOL_SOCKET, syscall.SO_TYPE)
+	if err != nil {
+		return false
+	}
+	sa, err := syscall.Getsockname(fd)
+	if err != nil {
+		return false
+	}
+	_, isUnix := sa.(*syscall.SockaddrUnix)
+
+	switch socketType {
+	case tcp:
+		return soType == syscall.SOCK_STREAM && !isUnix
+	case udp:
+		return soType == syscall.SOCK_DGRAM && !isUnix
+	case unixStream:
+		return soType == syscall.SOCK_STREAM && isUnix
+	default:
+		return soType == syscall.SOCK_DGRAM && isUnix
+	}
+}
+
+// activatedFile finds socket inherited from systemd matching configured type and
+// listenFdName. File descriptors not matching are left untouched for other plugin instances
+func (s *Socket) activatedFile() (*os.File, error) {
+	fds, err := systemdListenFDs()
+	if err != nil {
+		return nil, err
+	}
+	for _, lfd := range fds {
+		if s.conf.ListenFDName != "" && lfd.name != s.conf.ListenFDName {
+			continue
+		}
+		if !matchesType(lfd.fd, s.conf.Type) {
+			continue
+		}
+		syscall.CloseOnExec(lfd.fd)
+		return os.NewFile(uintptr(lfd.fd), lfd.name), nil
+	}
+	return nil, fmt.Errorf("none of %d sockets passed by systemd matches type %s and name %q", len(fds), s.conf.Type, s.conf.ListenFDName)
+}
+
+// activatedListener returns stream listener inherited from systemd
+func (s *Socket) activatedListener() (net.Listener, error) {
+	f, err := s.activatedFile()
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return net.FileListener(f)
+}
+
+// activatedConn returns datagram socket inherited from systemd
+func (s *Socket) activatedConn() (net.Conn, error) {
+	f, err := s.activatedFile()
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	pc, err := net.FilePacketConn(f)
+	if err != nil {
+		return nil, err
+	}
+	return pc.(net.Conn), nil
+}
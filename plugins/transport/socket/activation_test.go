@@ -0,0 +1,168 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"os"
+	"path"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/infrawatch/apputils/logging"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// passFD simulates systemd socket activation of given file under given name.
+// Lower descriptors get empty names, so that only the passed one can match
+func passFD(t *testing.T, f *os.File, name string) {
+	fd := int(f.Fd())
+	names := make([]string, fd-listenFDsStart+1)
+	names[len(names)-1] = name
+	t.Setenv("LISTEN_PID", fmt.Sprint(os.Getpid()))
+	t.Setenv("LISTEN_FDS", fmt.Sprint(len(names)))
+	t.Setenv("LISTEN_FDNAMES", strings.Join(names, ":"))
+}
+
+func TestSystemdActivation(t *testing.T) {
+	logger, err := logging.NewLogger(logging.ERROR, os.DevNull)
+	require.NoError(t, err)
+
+	t.Run("test inherited tcp listener", func(t *testing.T) {
+		ln, err := net.Listen("tcp", "127.0.0.1:0")
+		require.NoError(t, err)
+		f, err := ln.(*net.TCPListener).File()
+		require.NoError(t, err)
+		addr := ln.Addr().String()
+		ln.Close()
+		passFD(t, f, "sg-tcp")
+
+		trans := New(logger).(*Socket)
+		require.NoError(t, trans.Config([]byte(`
+type: tcp
+framing: newline
+systemdActivation: true
+listenFdName: sg-tcp
+`)))
+
+		received := make(chan string, 1)
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		go trans.Run(ctx, func(msg []byte) {
+			received <- string(msg)
+		}, make(chan bool))
+
+		var conn net.Conn
+		for retries := 0; retries < 10; retries++ {
+			conn, err = net.Dial("tcp", addr)
+			if err == nil {
+				break
+			}
+			time.Sleep(250 * time.Millisecond)
+		}
+		require.NoError(t, err)
+		defer conn.Close()
+		_, err = conn.Write([]byte("activated\n"))
+		require.NoError(t, err)
+
+		select {
+		case msg := <-received:
+			assert.Equal(t, "activated", msg)
+		case <-time.After(5 * time.Second):
+			t.Fatal("message was not received")
+		}
+	})
+
+	t.Run("test socket type and name have to match", func(t *testing.T) {
+		pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+		require.NoError(t, err)
+		defer pc.Close()
+		f, err := pc.(*net.UDPConn).File()
+		require.NoError(t, err)
+		defer f.Close()
+		passFD(t, f, "sg-udp")
+
+		s := newTestSocket(t, configT{Type: tcp, ListenFDName: "sg-udp"})
+		_, err = s.activatedFile()
+		assert.Error(t, err)
+
+		s = newTestSocket(t, configT{Type: udp, ListenFDName: "other"})
+		_, err = s.activatedFile()
+		assert.Error(t, err)
+
+		s = newTestSocket(t, configT{Type: udp, ListenFDName: "sg-udp"})
+		conn, err := s.activatedConn()
+		require.NoError(t, err)
+		conn.Close()
+	})
+
+	t.Run("test missing activation environment", func(t *testing.T) {
+		t.Setenv("LISTEN_PID", "1")
+		t.Setenv("LISTEN_FDS", "1")
+		_, err := systemdListenFDs()
+		assert.Error(t, err)
+	})
+}
+
+func TestUnixStreamSocket(t *testing.T) {
+	tmpdir, err := os.MkdirTemp(".", "socket_test_tmp")
+	require.NoError(t, err)
+	defer os.RemoveAll(tmpdir)
+
+	logger, err := logging.NewLogger(logging.ERROR, os.DevNull)
+	require.NoError(t, err)
+
+	sktpath := path.Join(tmpdir, "stream.sock")
+	trans := New(logger).(*Socket)
+	require.NoError(t, trans.Config([]byte(fmt.Sprintf(`
+type: unixstream
+path: %s
+framing: newline
+socketMode: "0600"
+socketGroup: "%d"
+`, sktpath, os.Getgid()))))
+
+	received := make(chan string, 2)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go trans.Run(ctx, func(msg []byte) {
+		received <- string(msg)
+	}, make(chan bool))
+
+	var conn net.Conn
+	for retries := 0; retries < 10; retries++ {
+		conn, err = net.Dial("unix", sktpath)
+		if err == nil {
+			break
+		}
+		time.Sleep(250 * time.Millisecond)
+	}
+	require.NoError(t, err)
+	defer conn.Close()
+
+	info, err := os.Stat(sktpath)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+
+	_, err = conn.Write([]byte("first\nsecond\n"))
+	require.NoError(t, err)
+	for _, expected := range []string{"first", "second"} {
+		select {
+		case msg := <-received:
+			assert.Equal(t, expected, msg)
+		case <-time.After(5 * time.Second):
+			t.Fatal("message was not received")
+		}
+	}
+
+	for _, conf := range []string{
+		"type: unixstream\npath: /tmp/x\nsocketMode: rw-rw----",
+		"type: tcp\nsocketaddr: 127.0.0.1:0\nsocketMode: \"0660\"",
+		"type: unixstream\npath: /tmp/x\nsocketOwner: no-such-user-sg",
+		"type: unixstream",
+	} {
+		assert.Error(t, New(logger).Config([]byte(conf)), conf)
+	}
+}
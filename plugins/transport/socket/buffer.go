@@ -0,0 +1,135 @@
+package main
+
+import (
+	"errors"
+	"sync"
+)
+
+var errMessageTooLarge = errors.New("message exceeds maxMessageSize")
+
+// bufferPool hands out read buffers of fixed size, so that short-lived stream
+// connections do not allocate a new buffer each time
+type bufferPool struct {
+	size int
+	pool sync.Pool
+}
+
+func newBufferPool(size int) *bufferPool {
+	bp := &bufferPool{size: size}
+	bp.pool.New = func() interface{} {
+		buf := make([]byte, size)
+		return &buf
+	}
+	return bp
+}
+
+func (bp *bufferPool) get() []byte {
+	return *(bp.pool.Get().(*[]byte))
+}
+
+func (bp *bufferPool) put(buf []byte) {
+	// buffers grown for large messages are left to garbage collector
+	if cap(buf) != bp.size {
+		return
+	}
+	buf = buf[:bp.size]
+	bp.pool.Put(&buf)
+}
+
+// streamBuffer keeps received stream data between reads. Data are appended at
+// the write cursor and framed messages are consumed from the read cursor, so
+// complete messages are never copied. When the tail of the buffer is used up,
+// the remaining partial frame is moved to the front. The buffer grows only if
+// a single frame does not fit in it, up to the configured limit, and is
+// swapped back for a pooled one once the unread data fit in it again, so that
+// a single large frame does not pin memory for the rest of the connection.
+//
+// A ring buffer is not used on purpose: framers and handlers need each frame
+// as one contiguous slice, so a frame wrapping around the end of a ring would
+// have to be copied together anyway. Compaction copies only the partial frame
+// left at the end, at most once per filled buffer, which costs no more than
+// joining wrapped frames and keeps the framing code oblivious of the buffer
+type streamBuffer struct {
+	buf  []byte
+	r, w int
+	max  int
+	pool *bufferPool
+}
+
+func newStreamBuffer(pool *bufferPool, max int) *streamBuffer {
+	return &streamBuffer{
+		buf:  pool.get(),
+		max:  max,
+		pool: pool,
+	}
+}
+
+// free returns space available for the next read
+func (sb *streamBuffer) free() ([]byte, error) {
+	if sb.w == len(sb.buf) {
+		if sb.shrink() {
+			return sb.buf[sb.w:], nil
+		}
+		if sb.r > 0 {
+			n := copy(sb.buf, sb.buf[sb.r:sb.w])
+			sb.r, sb.w = 0, n
+		} else {
+			err := sb.grow()
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+	return sb.buf[sb.w:], nil
+}
+
+func (sb *streamBuffer) grow() error {
+	if len(sb.buf) >= sb.max {
+		return errMessageTooLarge
+	}
+	size := len(sb.buf) * 2
+	if size > sb.max {
+		size = sb.max
+	}
+	buf := make([]byte, size)
+	copy(buf, sb.buf[sb.r:sb.w])
+	sb.pool.put(sb.buf)
+	sb.buf, sb.w, sb.r = buf, sb.w-sb.r, 0
+	return nil
+}
+
+// written marks n bytes after the write cursor as received
+func (sb *streamBuffer) written(n int) {
+	sb.w += n
+}
+
+// unread returns received data which were not consumed yet
+func (sb *streamBuffer) unread() []byte {
+	return sb.buf[sb.r:sb.w]
+}
+
+// consume marks n bytes of unread data as processed
+func (sb *streamBuffer) consume(n int) {
+	sb.r += n
+	if sb.r == sb.w {
+		sb.r, sb.w = 0, 0
+		sb.shrink()
+	}
+}
+
+// shrink moves unread data to a pooled buffer if the buffer was grown and the
+// data fit in the pooled size
+func (sb *streamBuffer) shrink() bool {
+	if len(sb.buf) <= sb.pool.size || sb.w-sb.r >= sb.pool.size {
+		return false
+	}
+	buf := sb.pool.get()
+	n := copy(buf, sb.buf[sb.r:sb.w])
+	sb.buf, sb.r, sb.w = buf, 0, n
+	return true
+}
+
+func (sb *streamBuffer) release() {
+	sb.pool.put(sb.buf)
+	sb.buf = nil
+}
@@ -0,0 +1,267 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"net"
+	"os"
+	"path"
+	"testing"
+	"time"
+
+	"github.com/infrawatch/apputils/logging"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// chunkConn is an in-memory net.Conn serving prepared chunks on each Read
+type chunkConn struct {
+	net.Conn
+	chunks [][]byte
+}
+
+func (c *chunkConn) Read(b []byte) (int, error) {
+	if len(c.chunks) == 0 {
+		return 0, io.EOF
+	}
+	n := copy(b, c.chunks[0])
+	c.chunks[0] = c.chunks[0][n:]
+	if len(c.chunks[0]) == 0 {
+		c.chunks = c.chunks[1:]
+	}
+	return n, nil
+}
+
+func (c *chunkConn) Close() error {
+	return nil
+}
+
+func (c *chunkConn) RemoteAddr() net.Addr {
+	return &net.TCPAddr{}
+}
+
+// splitStream cuts stream to chunks of given size
+func splitStream(stream []byte, size int) [][]byte {
+	chunks := [][]byte{}
+	for len(stream) > size {
+		chunks = append(chunks, stream[:size])
+		stream = stream[size:]
+	}
+	return append(chunks, stream)
+}
+
+func newTestSocket(t testing.TB, conf configT) *Socket {
+	logger, err := logging.NewLogger(logging.ERROR, os.DevNull)
+	require.NoError(t, err)
+	s := &Socket{
+		conf:   conf,
+		logger: &logWrapper{l: logger},
+	}
+	s.init()
+	return s
+}
+
+func TestStreamBuffer(t *testing.T) {
+	t.Run("test partial frame is moved to front", func(t *testing.T) {
+		sb := newStreamBuffer(newBufferPool(8), 64)
+		free, err := sb.free()
+		require.NoError(t, err)
+		sb.written(copy(free, "abcdefgh"))
+		sb.consume(6)
+
+		free, err = sb.free()
+		require.NoError(t, err)
+		assert.Equal(t, 6, len(free))
+		assert.Equal(t, "gh", string(sb.unread()))
+		assert.Equal(t, 8, len(sb.buf))
+	})
+
+	t.Run("test buffer grows up to limit", func(t *testing.T) {
+		sb := newStreamBuffer(newBufferPool(8), 20)
+		for _, expected := range []int{8, 16, 20} {
+			free, err := sb.free()
+			require.NoError(t, err)
+			sb.written(len(free))
+			assert.Equal(t, expected, len(sb.unread()))
+		}
+		_, err := sb.free()
+		assert.ErrorIs(t, err, errMessageTooLarge)
+		sb.release()
+	})
+
+	t.Run("test buffer shrinks after large frame", func(t *testing.T) {
+		sb := newStreamBuffer(newBufferPool(8), 64)
+		for i := 0; i < 4; i++ {
+			free, err := sb.free()
+			require.NoError(t, err)
+			sb.written(copy(free, "01234567"))
+		}
+		assert.Equal(t, 32, len(sb.buf))
+
+		// partial frame left after the large one is moved to pooled buffer
+		sb.consume(29)
+		free, err := sb.free()
+		require.NoError(t, err)
+		assert.Equal(t, 8, len(sb.buf))
+		assert.Equal(t, 5, len(free))
+		assert.Equal(t, "567", string(sb.unread()))
+
+		// buffer is swapped back as soon as it is drained
+		sb.written(copy(free, "0123456789abcdef"))
+		free, err = sb.free()
+		require.NoError(t, err)
+		sb.written(copy(free, "01234567"))
+		assert.Equal(t, 16, len(sb.buf))
+		sb.consume(16)
+		assert.Equal(t, 8, len(sb.buf))
+		assert.Empty(t, sb.unread())
+		sb.release()
+	})
+
+	t.Run("test pool ignores grown buffers", func(t *testing.T) {
+		bp := newBufferPool(8)
+		bp.put(make([]byte, 16))
+		assert.Equal(t, 8, len(bp.get()))
+	})
+}
+
+func TestReceiveStream(t *testing.T) {
+	stream := join(le64(3), []byte("one"), le64(300), bytes.Repeat([]byte("x"), 300), le64(5), []byte("three"))
+
+	t.Run("test frames split across reads", func(t *testing.T) {
+		s := newTestSocket(t, configT{Type: tcp, BufferSize: 16, MaxMessageSize: 1024})
+		received := []string{}
+		s.ReceiveData(nil, &chunkConn{chunks: splitStream(stream, 7)}, func(msg []byte) {
+			received = append(received, string(msg))
+		})
+		assert.Equal(t, []string{"one", string(bytes.Repeat([]byte("x"), 300)), "three"}, received)
+		assert.Equal(t, int64(3), s.stats.received.Load())
+		assert.Equal(t, int64(0), s.stats.dropped.Load())
+	})
+
+	t.Run("test oversized frame closes connection", func(t *testing.T) {
+		s := newTestSocket(t, configT{Type: tcp, BufferSize: 16, MaxMessageSize: 64})
+		received := []string{}
+		s.ReceiveData(nil, &chunkConn{chunks: splitStream(stream, 7)}, func(msg []byte) {
+			received = append(received, string(msg))
+		})
+		assert.Equal(t, []string{"one"}, received)
+		assert.Equal(t, int64(1), s.stats.dropped.Load())
+	})
+}
+
+func TestTruncatedDatagram(t *testing.T) {
+	tmpdir, err := os.MkdirTemp(".", "socket_test_tmp")
+	require.NoError(t, err)
+	defer os.RemoveAll(tmpdir)
+
+	sktpath := path.Join(tmpdir, "socket")
+	s := newTestSocket(t, configT{Type: unix, Path: sktpath, BufferSize: 8})
+	pc, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: sktpath, Net: "unixgram"})
+	require.NoError(t, err)
+
+	received := make(chan string, 2)
+	done := make(chan bool, 1)
+	go s.ReceiveData(done, pc, func(msg []byte) {
+		received <- string(msg)
+	})
+
+	client, err := net.DialUnix("unixgram", nil, &net.UnixAddr{Name: sktpath, Net: "unixgram"})
+	require.NoError(t, err)
+	defer client.Close()
+	for _, msg := range []string{"too large message", "short"} {
+		_, err = client.Write([]byte(msg))
+		require.NoError(t, err)
+	}
+
+	select {
+	case msg := <-received:
+		assert.Equal(t, "short", msg)
+	case <-time.After(5 * time.Second):
+		t.Fatal("message was not received")
+	}
+	assert.Equal(t, int64(1), s.stats.truncated.Load())
+	pc.Close()
+	<-done
+}
+
+// legacyWriteTCPMsg and legacyReceive are copies of the stream handling which preceded
+// streamBuffer, without logging and message dumps. Leftover data are prepended to the whole
+// read buffer on every read, so the buffer is reallocated and grows with each partial frame
+func legacyWriteTCPMsg(w func([]byte), msgBuffer []byte, n int) (int64, error) {
+	var pos int64
+	var length int64
+	reader := bytes.NewReader(msgBuffer[:n])
+	for pos+8 < int64(n) {
+		_, err := reader.Seek(pos, io.SeekStart)
+		if err != nil {
+			return pos, err
+		}
+		err = binary.Read(reader, binary.LittleEndian, &length)
+		if err != nil {
+			return pos, err
+		}
+
+		if pos+8+length > int64(n) ||
+			pos+8+length < 0 {
+			break
+		}
+		w(msgBuffer[pos+8 : pos+8+length])
+		pos += 8 + length
+	}
+	return pos, nil
+}
+
+func legacyReceive(pc net.Conn, w func([]byte)) {
+	msgBuffer := make([]byte, defaultBufferSize)
+	var remainingMsg []byte
+	for {
+		n, err := pc.Read(msgBuffer)
+		if err != nil || n < 1 {
+			return
+		}
+		msgBuffer = append(remainingMsg, msgBuffer...)
+		n += len(remainingMsg)
+
+		parsed, err := legacyWriteTCPMsg(w, msgBuffer, n)
+		if err != nil {
+			return
+		}
+		remainingMsg = make([]byte, int64(n)-parsed)
+		copy(remainingMsg, msgBuffer[parsed:n])
+	}
+}
+
+func benchStream() []byte {
+	msg := bytes.Repeat([]byte("m"), 512)
+	stream := []byte{}
+	for i := 0; i < 1000; i++ {
+		stream = append(stream, le64(len(msg))...)
+		stream = append(stream, msg...)
+	}
+	return stream
+}
+
+func BenchmarkReceiveStream(b *testing.B) {
+	stream := benchStream()
+	s := newTestSocket(b, configT{Type: tcp})
+	b.SetBytes(int64(len(stream)))
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		s.ReceiveData(nil, &chunkConn{chunks: splitStream(stream, 1500)}, func([]byte) {})
+	}
+}
+
+func BenchmarkReceiveStreamLegacy(b *testing.B) {
+	stream := benchStream()
+	received := 0
+	b.SetBytes(int64(len(stream)))
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		legacyReceive(&chunkConn{chunks: splitStream(stream, 1500)}, func([]byte) { received++ })
+	}
+	if received != 1000*b.N {
+		b.Fatalf("received %d messages, expected %d", received, 1000*b.N)
+	}
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// connection gets this long to finish reading already received data on shutdown
+const shutdownTimeout = 5 * time.Second
+
+// connStats tracks single stream connection
+type connStats struct {
+	id       uint64
+	peer     string
+	bytes    atomic.Int64
+	messages atomic.Int64
+
+	conn    net.Conn
+	mutex   sync.Mutex
+	closing bool
+}
+
+// setReadDeadline sets deadline for the next read unless the connection is being shut down
+func (cs *connStats) setReadDeadline(t time.Time) bool {
+	cs.mutex.Lock()
+	defer cs.mutex.Unlock()
+	if cs.closing {
+		return false
+	}
+	_ = cs.conn.SetReadDeadline(t)
+	return true
+}
+
+// interrupt unblocks pending read, so that the connection is closed after processing received data
+func (cs *connStats) interrupt() {
+	cs.mutex.Lock()
+	defer cs.mutex.Unlock()
+	cs.closing = true
+	_ = cs.conn.SetReadDeadline(time.Unix(1, 0))
+}
+
+func (cs *connStats) isClosing() bool {
+	cs.mutex.Lock()
+	defer cs.mutex.Unlock()
+	return cs.closing
+}
+
+// connManager keeps track of open stream connections
+type connManager struct {
+	max     int
+	lastID  uint64
+	mutex   sync.Mutex
+	conns   map[uint64]*connStats
+	closed  bool
+	wg      sync.WaitGroup
+	refused atomic.Int64 // connections over maxConnections
+}
+
+func newConnManager(max int) *connManager {
+	return &connManager{
+		max:   max,
+		conns: map[uint64]*connStats{},
+	}
+}
+
+// add registers new connection. Returns nil if the connection limit is reached
+// or the manager is shutting down
+func (cm *connManager) add(pc net.Conn, peer string) *connStats {
+	cm.mutex.Lock()
+	defer cm.mutex.Unlock()
+	if cm.closed {
+		return nil
+	}
+	if cm.max > 0 && len(cm.conns) >= cm.max {
+		cm.refused.Add(1)
+		return nil
+	}
+	cm.lastID++
+	cs := &connStats{
+		id:   cm.lastID,
+		peer: peer,
+		conn: pc,
+	}
+	cm.conns[cs.id] = cs
+	cm.wg.Add(1)
+	return cs
+}
+
+// remove closes connection and stops tracking it
+func (cm *connManager) remove(cs *connStats) {
+	cs.conn.Close()
+	cm.mutex.Lock()
+	delete(cm.conns, cs.id)
+	cm.mutex.Unlock()
+	cm.wg.Done()
+}
+
+// list returns currently open connections
+func (cm *connManager) list() []*connStats {
+	cm.mutex.Lock()
+	defer cm.mutex.Unlock()
+	conns := make([]*connStats, 0, len(cm.conns))
+	for _, cs := range cm.conns {
+		conns = append(conns, cs)
+	}
+	return conns
+}
+
+// shutdown interrupts all connections and waits for them to finish. Connections
+// still open after timeout are closed forcibly
+func (cm *connManager) shutdown(timeout time.Duration) {
+	cm.mutex.Lock()
+	cm.closed = true
+	cm.mutex.Unlock()
+	for _, cs := range cm.list() {
+		cs.interrupt()
+	}
+	finished := make(chan struct{})
+	go func() {
+		cm.wg.Wait()
+		close(finished)
+	}()
+	select {
+	case <-finished:
+	case <-time.After(timeout):
+		for _, cs := range cm.list() {
+			cs.conn.Close()
+		}
+	}
+}
@@ -0,0 +1,180 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/infrawatch/apputils/logging"
+	"github.com/openstack-k8s-operators/sg-core/pkg/bus"
+	"github.com/openstack-k8s-operators/sg-core/pkg/data"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// expectClosed verifies server closes the connection within a few seconds
+func expectClosed(t *testing.T, conn net.Conn) {
+	require.NoError(t, conn.SetReadDeadline(time.Now().Add(5*time.Second)))
+	_, err := conn.Read(make([]byte, 1))
+	assert.Error(t, err)
+	var nerr net.Error
+	if errors.As(err, &nerr) {
+		assert.False(t, nerr.Timeout(), "connection was not closed by server")
+	}
+}
+
+func runConnSocket(t *testing.T, conf string, mpf bus.MetricPublishFunc) (*Socket, chan string, context.CancelFunc, chan struct{}) {
+	logger, err := logging.NewLogger(logging.ERROR, os.DevNull)
+	require.NoError(t, err)
+	trans := New(logger).(*Socket)
+	require.NoError(t, trans.Config([]byte(conf)))
+	trans.SetMetricPublishFunc(mpf)
+
+	received := make(chan string, 16)
+	ctx, cancel := context.WithCancel(context.Background())
+	exited := make(chan struct{})
+	go func() {
+		trans.Run(ctx, func(msg []byte) {
+			received <- string(msg)
+		}, make(chan bool))
+		close(exited)
+	}()
+	t.Cleanup(cancel)
+	return trans, received, cancel, exited
+}
+
+func TestConnectionManagement(t *testing.T) {
+	t.Run("test connection limit", func(t *testing.T) {
+		trans, received, _, _ := runConnSocket(t, `
+type: tcp
+socketaddr: 127.0.0.1:8647
+framing: newline
+maxConnections: 1
+`, nil)
+		first := dialRetry(t, "tcp", "127.0.0.1:8647")
+		defer first.Close()
+		_, err := first.Write([]byte("first\n"))
+		require.NoError(t, err)
+		assert.Equal(t, "first", expectString(t, received))
+
+		second, err := net.Dial("tcp", "127.0.0.1:8647")
+		require.NoError(t, err)
+		defer second.Close()
+		expectClosed(t, second)
+		assert.Equal(t, int64(1), trans.conns.refused.Load())
+	})
+
+	t.Run("test idle and read timeouts", func(t *testing.T) {
+		_, received, _, _ := runConnSocket(t, `
+type: tcp
+socketaddr: 127.0.0.1:8648
+framing: newline
+idleTimeout: 300ms
+readTimeout: 200ms
+`, nil)
+		idle := dialRetry(t, "tcp", "127.0.0.1:8648")
+		defer idle.Close()
+		_, err := idle.Write([]byte("idle\n"))
+		require.NoError(t, err)
+		assert.Equal(t, "idle", expectString(t, received))
+		expectClosed(t, idle)
+
+		// partial frame is not completed in time
+		slow, err := net.Dial("tcp", "127.0.0.1:8648")
+		require.NoError(t, err)
+		defer slow.Close()
+		_, err = slow.Write([]byte("incompl"))
+		require.NoError(t, err)
+		expectClosed(t, slow)
+		assert.Equal(t, 0, len(received))
+	})
+
+	t.Run("test graceful shutdown", func(t *testing.T) {
+		_, received, cancel, exited := runConnSocket(t, `
+type: tcp
+socketaddr: 127.0.0.1:8649
+framing: newline
+`, nil)
+		conn := dialRetry(t, "tcp", "127.0.0.1:8649")
+		defer conn.Close()
+		_, err := conn.Write([]byte("before shutdown\n"))
+		require.NoError(t, err)
+		assert.Equal(t, "before shutdown", expectString(t, received))
+
+		cancel()
+		expectClosed(t, conn)
+		select {
+		case <-exited:
+		case <-time.After(10 * time.Second):
+			t.Fatal("transport did not exit")
+		}
+
+		// listener is closed, so the address can be bound again
+		ln, err := net.Listen("tcp", "127.0.0.1:8649")
+		require.NoError(t, err)
+		ln.Close()
+	})
+
+	// connStats runs transport with given configuration, sends two messages and returns published metrics
+	connStats := func(t *testing.T, conf string, addr string) (net.Conn, map[string]float64, map[string][]string) {
+		metrics := map[string]float64{}
+		labels := map[string][]string{}
+		mutex := sync.Mutex{}
+		trans, received, _, _ := runConnSocket(t, conf, func(name string, _ float64, _ data.MetricType, _ time.Duration, value float64, _ []string, labelVals []string) {
+			mutex.Lock()
+			defer mutex.Unlock()
+			metrics[name] = value
+			labels[name] = labelVals
+		})
+		conn := dialRetry(t, "tcp", addr)
+		t.Cleanup(func() { conn.Close() })
+		_, err := conn.Write([]byte("one\ntwo\n"))
+		require.NoError(t, err)
+		expectString(t, received)
+		expectString(t, received)
+		trans.publishStats()
+
+		mutex.Lock()
+		defer mutex.Unlock()
+		return conn, metrics, labels
+	}
+
+	t.Run("test connection stats", func(t *testing.T) {
+		_, metrics, labels := connStats(t, `
+type: tcp
+socketaddr: 127.0.0.1:8650
+framing: newline
+`, "127.0.0.1:8650")
+		assert.Equal(t, float64(1), metrics["sg_total_socket_conn_count"])
+		assert.Equal(t, float64(8), metrics["sg_total_socket_received_bytes"])
+		assert.Equal(t, []string{"SG", "127.0.0.1:8650"}, labels["sg_total_socket_received_bytes"])
+		assert.NotContains(t, metrics, "sg_total_socket_conn_received_bytes")
+		assert.NotContains(t, metrics, "sg_total_socket_conn_msg_received_count")
+	})
+
+	t.Run("test per-connection stats", func(t *testing.T) {
+		conn, metrics, labels := connStats(t, `
+type: tcp
+socketaddr: 127.0.0.1:8651
+framing: newline
+connectionMetrics: true
+`, "127.0.0.1:8651")
+		assert.Equal(t, float64(8), metrics["sg_total_socket_conn_received_bytes"])
+		assert.Equal(t, float64(2), metrics["sg_total_socket_conn_msg_received_count"])
+		assert.Equal(t, []string{"SG", "127.0.0.1:8651", "1", conn.LocalAddr().String()}, labels["sg_total_socket_conn_received_bytes"])
+	})
+}
+
+func expectString(t *testing.T, received chan string) string {
+	select {
+	case msg := <-received:
+		return msg
+	case <-time.After(5 * time.Second):
+		t.Fatal("message was not received")
+	}
+	return ""
+}
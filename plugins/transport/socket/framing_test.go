@@ -0,0 +1,86 @@
+package main
+
+import (
+	"context"
+	"encoding/binary"
+	"net"
+	"os"
+	"path"
+	"testing"
+	"time"
+
+	"github.com/infrawatch/apputils/logging"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func le64(n int) []byte {
+	b := make([]byte, 8)
+	binary.LittleEndian.PutUint64(b, uint64(n))
+	return b
+}
+
+func join(parts ...[]byte) []byte {
+	out := []byte{}
+	for _, p := range parts {
+		out = append(out, p...)
+	}
+	return out
+}
+
+func TestNewlineFramedTCPSocket(t *testing.T) {
+	tmpdir, err := os.MkdirTemp(".", "socket_test_tmp")
+	require.NoError(t, err)
+	defer os.RemoveAll(tmpdir)
+
+	logpath := path.Join(tmpdir, "test.log")
+	logger, err := logging.NewLogger(logging.DEBUG, logpath)
+	require.NoError(t, err)
+
+	trans := New(logger).(*Socket)
+	require.NoError(t, trans.Config([]byte(`
+type: tcp
+socketaddr: 127.0.0.1:8644
+framing: newline
+`)))
+
+	received := make(chan string, 3)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go trans.Run(ctx, func(msg []byte) {
+		received <- string(msg)
+	}, make(chan bool))
+
+	var conn net.Conn
+	for retries := 0; retries < 10; retries++ {
+		conn, err = net.Dial("tcp", "127.0.0.1:8644")
+		if err == nil {
+			break
+		}
+		time.Sleep(250 * time.Millisecond)
+	}
+	require.NoError(t, err)
+	defer conn.Close()
+
+	// split a line across writes to verify partial frames are kept
+	_, err = conn.Write([]byte("{\"message\":\"one\"}\n{\"mess"))
+	require.NoError(t, err)
+	time.Sleep(100 * time.Millisecond)
+	_, err = conn.Write([]byte("age\":\"two\"}\n"))
+	require.NoError(t, err)
+
+	for _, expected := range []string{`{"message":"one"}`, `{"message":"two"}`} {
+		select {
+		case msg := <-received:
+			assert.Equal(t, expected, msg)
+		case <-time.After(5 * time.Second):
+			t.Fatal("message was not received")
+		}
+	}
+
+	assert.Error(t, New(logger).Config([]byte(`
+type: tcp
+socketaddr: 127.0.0.1:8644
+framing: length-le16
+`)))
+}
@@ -4,45 +4,80 @@ import (
 	"bufio"
 	"bytes"
 	"context"
-	"encoding/binary"
+	"crypto/tls"
+	"errors"
 	"fmt"
 	"io"
 	"net"
 	"os"
+	"os/user"
+	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
+	"syscall"
 	"time"
 
 	"github.com/infrawatch/apputils/logging"
+	"github.com/openstack-k8s-operators/sg-core/pkg/bus"
 	"github.com/openstack-k8s-operators/sg-core/pkg/config"
 	"github.com/openstack-k8s-operators/sg-core/pkg/data"
+	"github.com/openstack-k8s-operators/sg-core/pkg/framing"
 	"github.com/openstack-k8s-operators/sg-core/pkg/transport"
 )
 
 const (
-	maxBufferSize = 65535
-	udp           = "udp"
-	unix          = "unix"
-	tcp           = "tcp"
-	msgLengthSize = 8
-)
+	udp        = "udp"
+	unix       = "unix"
+	unixStream = "unixstream"
+	tcp        = "tcp"
+
+	defaultBufferSize     = 65535
+	defaultMaxMessageSize = 64 << 20
 
-var (
-	msgCount int64
-	lastVal  int64
+	handshakeTimeout = 10 * time.Second
+	acceptRetryDelay = 100 * time.Millisecond
 )
 
-func rate() int64 {
-	rate := msgCount - lastVal
-	lastVal = msgCount
+// socketStats counts messages passing the transport
+type socketStats struct {
+	received  atomic.Int64
+	dropped   atomic.Int64 // malformed or oversized stream frames
+	truncated atomic.Int64 // datagrams larger than read buffer
+	rejected  atomic.Int64 // datagrams and connections from peers denied by access rules
+	bytes     atomic.Int64 // bytes received over stream connections
+	lastVal   int64
+}
+
+func (ss *socketStats) rate() int64 {
+	received := ss.received.Load()
+	rate := received - ss.lastVal
+	ss.lastVal = received
 	return rate
 }
 
 type configT struct {
-	Path         string `validate:"required_without=Socketaddr"`
-	Type         string
-	Socketaddr   string `validate:"required_without=Path"`
-	DumpMessages struct {
+	Path               string
+	Type               string
+	Socketaddr         string
+	SocketMode         string           `yaml:"socketMode"`  // permissions of unix socket file, in octal
+	SocketOwner        string           `yaml:"socketOwner"` // user name or uid owning unix socket file
+	SocketGroup        string           `yaml:"socketGroup"` // group name or gid owning unix socket file
+	SystemdActivation  bool             `yaml:"systemdActivation"`
+	ListenFDName       string           `yaml:"listenFdName"` // selects socket passed by systemd by its FileDescriptorName
+	Framing            string           // framing of messages in stream sockets
+	BufferSize         int              `yaml:"bufferSize" validate:"min=0"`        // size of read buffer, datagrams larger than that are dropped
+	MaxMessageSize     int              `yaml:"maxMessageSize" validate:"min=0"`    // maximum size of stream frame including its header
+	ReceiveBufferSize  int              `yaml:"receiveBufferSize" validate:"min=0"` // SO_RCVBUF, system default is used when zero
+	MaxConnections     int              `yaml:"maxConnections" validate:"min=0"`    // limit of concurrent stream connections, unlimited when zero
+	IdleTimeout        time.Duration    `yaml:"idleTimeout"`                        // close stream connections which send nothing for this long
+	ReadTimeout        time.Duration    `yaml:"readTimeout"`                        // maximum time to receive whole frame once it started
+	TLS                config.TLSConfig `yaml:"tls"`
+	CertReloadInterval time.Duration    `yaml:"certReloadInterval"`
+	Access             accessConfig     `yaml:"access"`
+	PeerLabels         bool             `yaml:"peerLabels"`        // attach peer identity as labels to produced metrics and events
+	ConnectionMetrics  bool             `yaml:"connectionMetrics"` // publish internal metrics of each stream connection
+	DumpMessages       struct {
 		Enabled bool
 		Path    string
 	} `yaml:"dumpMessages"` // only use for debug as this is very slow
@@ -78,9 +113,32 @@ type Socket struct {
 	dumpBuf  *bufio.Writer
 	dumpFile *os.File
 	mutex    sync.Mutex
+	certs    *certReloader
+	framer   framing.Framer
+	buffers  *bufferPool
+	stats    socketStats
+	mpf      bus.MetricPublishFunc
+	access   *accessList
+	conns    *connManager
+	// socket file attributes resolved from configuration, -1 leaves uid or gid unchanged
+	chown    bool
+	uid, gid int
+	mode     os.FileMode
+	// lastCertCheck is only accessed from the Run loop
+	lastCertCheck time.Time
 }
 
-func (s *Socket) initUnixSocket() *net.UnixConn {
+func (s *Socket) initUnixSocket() net.Conn {
+	if s.conf.SystemdActivation {
+		pc, err := s.activatedConn()
+		if err != nil {
+			s.logger.Errorf(err, "failed to inherit unix socket from systemd")
+			return nil
+		}
+		s.logger.Infof("socket listening on %s inherited from systemd", pc.LocalAddr())
+		return pc
+	}
+
 	var laddr net.UnixAddr
 	laddr.Name = s.conf.Path
 	laddr.Net = "unixgram"
@@ -99,12 +157,81 @@ func (s *Socket) initUnixSocket() *net.UnixConn {
 	}
 	skt.Close()
 
+	err = s.setSocketFileAttrs()
+	if err != nil {
+		s.logger.Errorf(err, "failed to set permissions of %s", laddr.Name)
+		pc.Close()
+		return nil
+	}
+
 	s.logger.Infof("socket listening on %s", laddr.Name)
 
 	return pc
 }
 
-func (s *Socket) initUDPSocket() *net.UDPConn {
+func (s *Socket) initUnixStreamSocket() net.Listener {
+	if s.conf.SystemdActivation {
+		return s.initActivatedListener()
+	}
+
+	os.Remove(s.conf.Path)
+	ln, err := net.ListenUnix("unix", &net.UnixAddr{Name: s.conf.Path, Net: "unix"})
+	if err != nil {
+		s.logger.Errorf(err, "failed to bind unix socket %s", s.conf.Path)
+		return nil
+	}
+
+	err = s.setSocketFileAttrs()
+	if err != nil {
+		s.logger.Errorf(err, "failed to set permissions of %s", s.conf.Path)
+		ln.Close()
+		return nil
+	}
+
+	s.logger.Infof("socket listening on %s", s.conf.Path)
+
+	return ln
+}
+
+// setSocketFileAttrs applies configured mode and ownership to unix socket file
+func (s *Socket) setSocketFileAttrs() error {
+	if s.mode != 0 {
+		err := os.Chmod(s.conf.Path, s.mode)
+		if err != nil {
+			return err
+		}
+	}
+	if s.chown {
+		return os.Chown(s.conf.Path, s.uid, s.gid)
+	}
+	return nil
+}
+
+func (s *Socket) initActivatedListener() net.Listener {
+	ln, err := s.activatedListener()
+	if err != nil {
+		s.logger.Errorf(err, "failed to inherit %s socket from systemd", s.conf.Type)
+		return nil
+	}
+	if s.certs != nil {
+		s.logger.Infof("socket listening on %s inherited from systemd with TLS", ln.Addr())
+		return tls.NewListener(ln, s.certs.listenerConfig())
+	}
+	s.logger.Infof("socket listening on %s inherited from systemd", ln.Addr())
+	return ln
+}
+
+func (s *Socket) initUDPSocket() net.Conn {
+	if s.conf.SystemdActivation {
+		pc, err := s.activatedConn()
+		if err != nil {
+			s.logger.Errorf(err, "failed to inherit udp socket from systemd")
+			return nil
+		}
+		s.logger.Infof("socket listening on %s inherited from systemd", pc.LocalAddr())
+		return pc
+	}
+
 	addr, err := net.ResolveUDPAddr(udp, s.conf.Socketaddr)
 	if err != nil {
 		s.logger.Errorf(err, "failed to resolve udp address: %s", s.conf.Socketaddr)
@@ -121,7 +248,11 @@ func (s *Socket) initUDPSocket() *net.UDPConn {
 	return pc
 }
 
-func (s *Socket) initTCPSocket() *net.TCPListener {
+func (s *Socket) initTCPSocket() net.Listener {
+	if s.conf.SystemdActivation {
+		return s.initActivatedListener()
+	}
+
 	addr, err := net.ResolveTCPAddr(tcp, s.conf.Socketaddr)
 	if err != nil {
 		s.logger.Errorf(err, "failed to resolve tcp address: %s", s.conf.Socketaddr)
@@ -133,150 +264,486 @@ func (s *Socket) initTCPSocket() *net.TCPListener {
 		return nil
 	}
 
+	if s.certs != nil {
+		s.logger.Infof("socket listening on %s with TLS", s.conf.Socketaddr)
+		return tls.NewListener(pc, s.certs.listenerConfig())
+	}
+
 	s.logger.Infof("socket listening on %s", s.conf.Socketaddr)
 
 	return pc
 }
 
-func (s *Socket) WriteTCPMsg(w transport.WriteFn, msgBuffer []byte, n int) (int64, error) {
-	var pos int64
-	var length int64
-	reader := bytes.NewReader(msgBuffer[:n])
-	for pos+msgLengthSize < int64(n) {
-		_, err := reader.Seek(pos, io.SeekStart)
-		if err != nil {
-			return pos, err
-		}
-		err = binary.Read(reader, binary.LittleEndian, &length)
-		if err != nil {
-			return pos, err
-		}
+// connMetadata completes TLS handshake, if the connection is encrypted, and returns peer identity
+func (s *Socket) connMetadata(ctx context.Context, pc net.Conn) (transport.Metadata, error) {
+	tc, ok := pc.(*tls.Conn)
+	if !ok {
+		return nil, nil
+	}
+	hctx, cancel := context.WithTimeout(ctx, handshakeTimeout)
+	defer cancel()
+	err := tc.HandshakeContext(hctx)
+	if err != nil {
+		return nil, err
+	}
+	return peerMetadata(tc.ConnectionState()), nil
+}
 
-		if pos+msgLengthSize+length > int64(n) ||
-			pos+msgLengthSize+length < 0 {
-			break
-		}
+// WriteTCPMsg splits stream buffer into messages according to configured framing and writes them to handlers.
+// Returns number of bytes consumed from the buffer
+func (s *Socket) WriteTCPMsg(w transport.WriteFn, msgBuffer []byte, n int) (int64, error) {
+	pos, err := s.framer.Split(msgBuffer[:n], func(msg []byte) {
 		s.mutex.Lock()
-		w(msgBuffer[pos+msgLengthSize : pos+msgLengthSize+length])
-		msgCount++
+		w(msg)
+		s.stats.received.Add(1)
 		s.mutex.Unlock()
-		pos += msgLengthSize + length
+	})
+	return int64(pos), err
+}
+
+func (s *Socket) dump(blob []byte) {
+	_, err := s.dumpBuf.Write(blob)
+	if err != nil {
+		s.logger.Errorf(err, "writing to dump buffer")
+	}
+	_, err = s.dumpBuf.WriteString("\n")
+	if err != nil {
+		s.logger.Errorf(err, "writing to dump buffer")
+	}
+	s.dumpBuf.Flush()
+}
+
+// ReceiveData reads messages from the connection until it is closed
+func (s *Socket) ReceiveData(done chan bool, pc net.Conn, w transport.WriteFn) {
+	if s.isStream() {
+		defer pc.Close()
+		s.receiveStream(pc, w, nil)
+		return
 	}
-	return pos, nil
+	s.serveDatagrams(done, pc, func(blob []byte, _ transport.Metadata) {
+		w(blob)
+	})
 }
 
-func (s *Socket) ReceiveData(maxBuffSize int64, done chan bool, pc net.Conn, w transport.WriteFn) {
+// serveDatagrams reads datagrams until the socket is closed
+func (s *Socket) serveDatagrams(done chan bool, pc net.Conn, wm transport.WriteWithMetadataFn) {
 	defer pc.Close()
-	msgBuffer := make([]byte, maxBuffSize)
-	var remainingMsg []byte
+	s.receiveDatagrams(pc, wm)
+	done <- true
+}
+
+// datagramReader reads single datagram and reports whether it was truncated
+type datagramReader interface {
+	ReadMsgUnix(b, oob []byte) (n, oobn, flags int, addr *net.UnixAddr, err error)
+}
+
+// readDatagram reads single datagram and reports its sender and whether it was truncated
+func readDatagram(pc net.Conn, buf []byte, oob []byte) (int, peer, bool, error) {
+	var n, oobn, flags int
+	var err error
+	p := unknownPeer
+	switch c := pc.(type) {
+	case *net.UDPConn:
+		var addr *net.UDPAddr
+		n, _, flags, addr, err = c.ReadMsgUDP(buf, nil)
+		if addr != nil {
+			p.ip = addr.IP
+		}
+	case datagramReader:
+		n, oobn, flags, _, err = c.ReadMsgUnix(buf, oob)
+		if oobn > 0 {
+			p.uid, p.gid, _ = parseCredentials(oob[:oobn])
+		}
+	default:
+		n, err = pc.Read(buf)
+	}
+	return n, p, flags&syscall.MSG_TRUNC != 0, err
+}
+
+func (s *Socket) receiveDatagrams(pc net.Conn, wm transport.WriteWithMetadataFn) {
+	buf := s.buffers.get()
+	defer s.buffers.put(buf)
+	var oob []byte
+	if s.needsCredentials() {
+		oob = make([]byte, credentialsOOBSize)
+	}
 	for {
-		n, err := pc.Read(msgBuffer)
+		n, p, truncated, err := readDatagram(pc, buf, oob)
 		if err != nil || n < 1 {
 			if err != nil {
 				s.logger.Errorf(err, "reading from socket failed")
 			}
-			if s.conf.Type != tcp {
-				done <- true
-			}
 			return
 		}
-		msgBuffer = append(remainingMsg, msgBuffer...)
+		if !s.access.permits(p) {
+			s.stats.rejected.Add(1)
+			s.logger.Debugf("rejected datagram from %s", p)
+			continue
+		}
+		if truncated {
+			s.stats.truncated.Add(1)
+			s.logger.Warnf("dropping datagram larger than read buffer of %d bytes", len(buf))
+			continue
+		}
+
+		if s.conf.DumpMessages.Enabled {
+			s.dump(buf[:n])
+		}
 
-		// whole buffer was used, so we are potentially handling larger message
-		if n == len(msgBuffer) {
-			s.logger.Warnf("full read buffer used")
+		var meta transport.Metadata
+		if s.conf.PeerLabels {
+			meta = p.metadata()
 		}
+		wm(buf[:n], meta)
+		s.stats.received.Add(1)
+	}
+}
 
-		n += len(remainingMsg)
+// receiveStream reads framed messages from stream connection. Connection tracked by
+// connection manager is passed in cs, otherwise cs is nil
+func (s *Socket) receiveStream(pc net.Conn, w transport.WriteFn, cs *connStats) {
+	sb := newStreamBuffer(s.buffers, s.conf.MaxMessageSize)
+	defer sb.release()
+	if cs != nil {
+		write := w
+		w = func(blob []byte) {
+			write(blob)
+			cs.messages.Add(1)
+		}
+	}
+	var frameStart time.Time
+	for {
+		free, err := sb.free()
+		if err != nil {
+			s.stats.dropped.Add(1)
+			s.logger.Errorf(err, "closing connection from %s", pc.RemoteAddr())
+			return
+		}
 
-		if s.conf.DumpMessages.Enabled {
-			_, err := s.dumpBuf.Write(msgBuffer[:n])
-			if err != nil {
-				s.logger.Errorf(err, "writing to dump buffer")
-			}
-			_, err = s.dumpBuf.WriteString("\n")
-			if err != nil {
-				s.logger.Errorf(err, "writing to dump buffer")
+		var deadline time.Time
+		switch {
+		case len(sb.unread()) > 0 && s.conf.ReadTimeout > 0:
+			deadline = frameStart.Add(s.conf.ReadTimeout)
+		case s.conf.IdleTimeout > 0:
+			deadline = time.Now().Add(s.conf.IdleTimeout)
+		}
+		if cs != nil {
+			if !cs.setReadDeadline(deadline) {
+				return
 			}
-			s.dumpBuf.Flush()
+		} else if s.conf.ReadTimeout > 0 || s.conf.IdleTimeout > 0 {
+			_ = pc.SetReadDeadline(deadline)
 		}
 
-		if s.conf.Type == tcp {
-			parsed, err := s.WriteTCPMsg(w, msgBuffer, n)
-			if err != nil {
-				s.logger.Errorf(err, "error, while parsing messages")
+		n, err := pc.Read(free)
+		if n > 0 {
+			s.stats.bytes.Add(int64(n))
+			if cs != nil {
+				cs.bytes.Add(int64(n))
+			}
+			if s.conf.DumpMessages.Enabled {
+				s.dump(free[:n])
+			}
+			if len(sb.unread()) == 0 {
+				frameStart = time.Now()
+			}
+			sb.written(n)
+			unread := sb.unread()
+			parsed, perr := s.WriteTCPMsg(w, unread, len(unread))
+			sb.consume(int(parsed))
+			if parsed > 0 {
+				frameStart = time.Now()
+			}
+			if perr != nil {
+				s.stats.dropped.Add(1)
+				s.logger.Errorf(perr, "error, while parsing messages")
 				return
 			}
-			remainingMsg = make([]byte, int64(n)-parsed)
-			copy(remainingMsg, msgBuffer[parsed:n])
-		} else {
-			w(msgBuffer[:n])
-			msgCount++
+		}
+		if err != nil {
+			var nerr net.Error
+			switch {
+			case errors.Is(err, io.EOF):
+			case cs != nil && cs.isClosing():
+			case errors.As(err, &nerr) && nerr.Timeout():
+				if len(sb.unread()) > 0 {
+					s.stats.dropped.Add(1)
+				}
+				s.logger.Infof("closing connection from %s after read timeout", pc.RemoteAddr())
+			default:
+				s.logger.Errorf(err, "reading from socket failed")
+			}
+			return
 		}
 	}
 }
 
+func (s *Socket) publishStats() {
+	if s.mpf == nil {
+		return
+	}
+	socket := s.conf.Socketaddr
+	if s.conf.Type == unix || s.conf.Type == unixStream {
+		socket = s.conf.Path
+	}
+	for name, value := range map[string]int64{
+		"sg_total_socket_msg_received_count":  s.stats.received.Load(),
+		"sg_total_socket_msg_dropped_count":   s.stats.dropped.Load(),
+		"sg_total_socket_msg_truncated_count": s.stats.truncated.Load(),
+		"sg_total_socket_rejected_count":      s.stats.rejected.Load(),
+	} {
+		s.mpf(
+			name,
+			0,
+			data.COUNTER,
+			0,
+			float64(value),
+			[]string{"source", "socket"},
+			[]string{"SG", socket},
+		)
+	}
+	if s.conns == nil {
+		return
+	}
+
+	conns := s.conns.list()
+	s.mpf("sg_total_socket_conn_count", 0, data.GAUGE, 0, float64(len(conns)),
+		[]string{"source", "socket"}, []string{"SG", socket})
+	s.mpf("sg_total_socket_conn_refused_count", 0, data.COUNTER, 0, float64(s.conns.refused.Load()),
+		[]string{"source", "socket"}, []string{"SG", socket})
+	s.mpf("sg_total_socket_received_bytes", 0, data.COUNTER, 0, float64(s.stats.bytes.Load()),
+		[]string{"source", "socket"}, []string{"SG", socket})
+	// series of each connection are kept by metrics storage, so they are opt-in
+	if !s.conf.ConnectionMetrics {
+		return
+	}
+	for _, cs := range conns {
+		labelKeys := []string{"source", "socket", "conn", "peer"}
+		labelVals := []string{"SG", socket, strconv.FormatUint(cs.id, 10), cs.peer}
+		s.mpf("sg_total_socket_conn_received_bytes", 0, data.COUNTER, 0, float64(cs.bytes.Load()), labelKeys, labelVals)
+		s.mpf("sg_total_socket_conn_msg_received_count", 0, data.COUNTER, 0, float64(cs.messages.Load()), labelKeys, labelVals)
+	}
+}
+
+// SetMetricPublishFunc implements type transport.MetricPublisher
+func (s *Socket) SetMetricPublishFunc(mpf bus.MetricPublishFunc) {
+	s.mpf = mpf
+}
+
+// needsCredentials reports whether credentials of unix socket peers have to be retrieved
+func (s *Socket) needsCredentials() bool {
+	if s.conf.Type == udp || s.conf.Type == tcp {
+		return false
+	}
+	return s.conf.PeerLabels || (s.access != nil && s.access.hasCredentials())
+}
+
+// connPeer returns identity of peer connected to stream socket
+func (s *Socket) connPeer(pc net.Conn) peer {
+	if s.conf.Type == tcp {
+		return addrPeer(pc.RemoteAddr())
+	}
+	p := unknownPeer
+	if !s.needsCredentials() {
+		return p
+	}
+	sc, ok := pc.(syscall.Conn)
+	if !ok {
+		return p
+	}
+	var err error
+	p.uid, p.gid, err = peerCredentials(sc)
+	if err != nil {
+		s.logger.Errorf(err, "failed to retrieve credentials of unix socket peer")
+	}
+	return p
+}
+
+// isStream reports whether configured socket type is connection oriented
+func (s *Socket) isStream() bool {
+	return s.conf.Type == tcp || s.conf.Type == unixStream
+}
+
+// init sets up runtime state which is not provided by configuration
+func (s *Socket) init() {
+	if s.framer == nil {
+		s.framer, _ = framing.Get(framing.LengthLE64)
+	}
+	if s.conf.BufferSize == 0 {
+		s.conf.BufferSize = defaultBufferSize
+	}
+	if s.conf.MaxMessageSize == 0 {
+		s.conf.MaxMessageSize = defaultMaxMessageSize
+	}
+	if s.buffers == nil {
+		s.buffers = newBufferPool(s.conf.BufferSize)
+	}
+}
+
+func (s *Socket) setReceiveBuffer(pc net.Conn) {
+	if s.conf.ReceiveBufferSize == 0 {
+		return
+	}
+	rb, ok := pc.(interface{ SetReadBuffer(int) error })
+	if !ok {
+		return
+	}
+	err := rb.SetReadBuffer(s.conf.ReceiveBufferSize)
+	if err != nil {
+		s.logger.Errorf(err, "failed to set socket receive buffer size")
+	}
+}
+
 // Run implements type Transport
 func (s *Socket) Run(ctx context.Context, w transport.WriteFn, done chan bool) {
-	var pc net.Conn
+	s.RunWithMetadata(ctx, func(blob []byte, _ transport.Metadata) {
+		w(blob)
+	}, done)
+}
+
+// RunWithMetadata implements type transport.MetadataTransport
+func (s *Socket) RunWithMetadata(ctx context.Context, wm transport.WriteWithMetadataFn, done chan bool) {
+	s.init()
+	var listener net.Listener
 	switch s.conf.Type {
 	case udp:
-		pc = s.initUDPSocket()
-		if pc == (*net.UDPConn)(nil) {
+		pc := s.initUDPSocket()
+		if pc == nil {
 			s.logger.Errorf(nil, "Failed to initialize socket transport plugin with type: "+s.conf.Type)
 			return
 		}
-		go s.ReceiveData(maxBufferSize, done, pc, w)
+		s.setReceiveBuffer(pc)
+		go s.serveDatagrams(done, pc, wm)
 
-	case tcp:
-		TCPSocket := s.initTCPSocket()
-		if TCPSocket == nil {
+	case tcp, unixStream:
+		if s.conf.Type == tcp {
+			listener = s.initTCPSocket()
+		} else {
+			listener = s.initUnixStreamSocket()
+		}
+		if listener == nil {
 			s.logger.Errorf(nil, "Failed to initialize socket transport plugin with type: "+s.conf.Type)
 			return
 		}
-		go func() {
-			for {
-				pc, err := TCPSocket.AcceptTCP()
-				if err != nil {
-					select {
-					case <-ctx.Done():
-						break
-					default:
-						s.logger.Errorf(err, "failed to accept TCP connection")
-						continue
-					}
-				}
-				go s.ReceiveData(maxBufferSize, done, pc, w)
-			}
-		}()
+		s.conns = newConnManager(s.conf.MaxConnections)
+		go s.serveStream(ctx, listener, wm)
 	case unix:
 		fallthrough
 	default:
-		pc = s.initUnixSocket()
-		if pc == (*net.UnixConn)(nil) {
+		pc := s.initUnixSocket()
+		if pc == nil {
 			s.logger.Errorf(nil, "Failed to initialize socket transport plugin with type: "+s.conf.Type)
 			return
 		}
-		go s.ReceiveData(maxBufferSize, done, pc, w)
+		if s.needsCredentials() {
+			err := enablePassCredentials(pc.(syscall.Conn))
+			if err != nil {
+				s.logger.Errorf(err, "failed to enable receiving of sender credentials")
+				pc.Close()
+				return
+			}
+		}
+		s.setReceiveBuffer(pc)
+		go s.serveDatagrams(done, pc, wm)
 	}
 
 	for {
 		select {
 		case <-ctx.Done():
 			goto Done
-		default:
-			time.Sleep(time.Second)
-			s.logger.Debugf("receiving %d msg/s", rate())
+		case <-time.After(time.Second):
+			s.logger.Debugf("receiving %d msg/s", s.stats.rate())
+			s.publishStats()
+			s.reloadCerts()
 		}
 	}
 Done:
-	if s.conf.Type == unix {
+	if listener != nil {
+		listener.Close()
+		s.conns.shutdown(shutdownTimeout)
+	}
+	// sockets inherited from systemd are owned by the socket unit
+	if (s.conf.Type == unix || s.conf.Type == unixStream) && !s.conf.SystemdActivation {
 		os.Remove(s.conf.Path)
 	}
 	s.dumpFile.Close()
 	s.logger.Infof("exited")
 }
 
+// serveStream accepts connections on stream listener and reads messages from each of them
+// until the listener is closed
+func (s *Socket) serveStream(ctx context.Context, listener net.Listener, wm transport.WriteWithMetadataFn) {
+	for {
+		pc, err := listener.Accept()
+		if err != nil {
+			if ctx.Err() != nil || errors.Is(err, net.ErrClosed) {
+				return
+			}
+			s.logger.Errorf(err, "failed to accept %s connection", s.conf.Type)
+			time.Sleep(acceptRetryDelay)
+			continue
+		}
+		p := s.connPeer(pc)
+		if !s.access.permits(p) {
+			s.stats.rejected.Add(1)
+			s.logger.Warnf("rejected %s connection from %s", s.conf.Type, p)
+			pc.Close()
+			continue
+		}
+		peerID := p.String()
+		if p.ip != nil {
+			peerID = pc.RemoteAddr().String()
+		}
+		cs := s.conns.add(pc, peerID)
+		if cs == nil {
+			s.logger.Warnf("refused %s connection from %s, limit of %d connections reached", s.conf.Type, peerID, s.conf.MaxConnections)
+			pc.Close()
+			continue
+		}
+		go func(pc net.Conn) {
+			defer s.conns.remove(cs)
+			meta, err := s.connMetadata(ctx, pc)
+			if err != nil {
+				s.logger.Errorf(err, "TLS handshake with %s failed", pc.RemoteAddr())
+				return
+			}
+			if meta != nil {
+				s.logger.Infof("accepted TLS connection from %s, client certificate subject: %s", pc.RemoteAddr(), meta["tls_subject"])
+			}
+			if !s.conf.PeerLabels {
+				meta = nil
+			} else if pmeta := p.metadata(); pmeta != nil {
+				if meta == nil {
+					meta = transport.Metadata{}
+				}
+				for k, v := range pmeta {
+					meta[k] = v
+				}
+			}
+			s.setReceiveBuffer(pc)
+			s.receiveStream(pc, func(blob []byte) {
+				wm(blob, meta)
+			}, cs)
+		}(pc)
+	}
+}
+
+// reloadCerts picks up renewed certificates once per certReloadInterval
+func (s *Socket) reloadCerts() {
+	if s.certs == nil || time.Since(s.lastCertCheck) < s.conf.CertReloadInterval {
+		return
+	}
+	s.lastCertCheck = time.Now()
+	reloaded, err := s.certs.reload()
+	if err != nil {
+		s.logger.Errorf(err, "failed to reload TLS certificates, keeping previous ones")
+		return
+	}
+	if reloaded {
+		s.logger.Infof("reloaded TLS certificates")
+	}
+}
+
 // Listen ...
 func (s *Socket) Listen(e data.Event) {
 	fmt.Printf("received event: %v\n", e)
@@ -291,7 +758,11 @@ func (s *Socket) Config(c []byte) error {
 		}{
 			Path: "/dev/stdout",
 		},
-		Type: unix,
+		Type:               unix,
+		Framing:            framing.LengthLE64,
+		BufferSize:         defaultBufferSize,
+		MaxMessageSize:     defaultMaxMessageSize,
+		CertReloadInterval: time.Minute,
 	}
 
 	err := config.ParseConfig(bytes.NewReader(c), &s.conf)
@@ -309,19 +780,109 @@ func (s *Socket) Config(c []byte) error {
 	}
 
 	s.conf.Type = strings.ToLower(s.conf.Type)
-	if s.conf.Type != unix && s.conf.Type != udp && s.conf.Type != tcp {
-		return fmt.Errorf("unable to determine socket type from configuration file. Should be one of \"unix\", \"udp\" or \"tcp\", received: %s",
+	if s.conf.Type != unix && s.conf.Type != unixStream && s.conf.Type != udp && s.conf.Type != tcp {
+		return fmt.Errorf("unable to determine socket type from configuration file. Should be one of \"unix\", \"unixstream\", \"udp\" or \"tcp\", received: %s",
 			s.conf.Type)
 	}
 
-	if s.conf.Type == unix && s.conf.Path == "" {
-		return fmt.Errorf("the path configuration option is required when using unix socket type")
+	if (s.conf.Type == unix || s.conf.Type == unixStream) && s.conf.Path == "" && !s.conf.SystemdActivation {
+		return fmt.Errorf("the path configuration option is required when using unix or unixstream socket type")
 	}
 
-	if (s.conf.Type == udp || s.conf.Type == tcp) && s.conf.Socketaddr == "" {
+	if (s.conf.Type == udp || s.conf.Type == tcp) && s.conf.Socketaddr == "" && !s.conf.SystemdActivation {
 		return fmt.Errorf("the socketaddr configuration option is required when using udp or tcp socket type")
 	}
 
+	err = s.parseSocketFileAttrs()
+	if err != nil {
+		return err
+	}
+
+	s.access, err = newAccessList(s.conf.Access)
+	if err != nil {
+		return err
+	}
+	isUnix := s.conf.Type == unix || s.conf.Type == unixStream
+	if s.access.hasCIDRs() && isUnix {
+		return fmt.Errorf("the allowCidrs and denyCidrs options are supported only with udp and tcp socket types")
+	}
+	if s.access.hasCredentials() && !isUnix {
+		return fmt.Errorf("the allowUids and allowGids options are supported only with unix and unixstream socket types")
+	}
+	if !s.access.hasCIDRs() && !s.access.hasCredentials() {
+		s.access = nil
+	}
+
+	if (s.conf.MaxConnections > 0 || s.conf.IdleTimeout > 0 || s.conf.ReadTimeout > 0) && !s.isStream() {
+		return fmt.Errorf("the maxConnections, idleTimeout and readTimeout options are supported only with tcp and unixstream socket types")
+	}
+	if s.conf.IdleTimeout < 0 || s.conf.ReadTimeout < 0 {
+		return fmt.Errorf("idleTimeout and readTimeout can't be negative")
+	}
+
+	s.conf.Framing = strings.ToLower(s.conf.Framing)
+	var ok bool
+	if s.framer, ok = framing.Get(s.conf.Framing); !ok {
+		return fmt.Errorf("unable to determine framing from configuration file. Should be one of \"%s\", received: %s",
+			strings.Join(framing.Names(), "\", \""), s.conf.Framing)
+	}
+
+	if s.conf.TLS.Enabled {
+		if s.conf.Type != tcp {
+			return fmt.Errorf("the tls configuration option is supported only with tcp socket type")
+		}
+		s.certs, err = newCertReloader(s.conf.TLS)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// parseSocketFileAttrs resolves socketMode, socketOwner and socketGroup options
+func (s *Socket) parseSocketFileAttrs() error {
+	s.chown, s.uid, s.gid, s.mode = false, -1, -1, 0
+	if s.conf.SocketMode == "" && s.conf.SocketOwner == "" && s.conf.SocketGroup == "" {
+		return nil
+	}
+	if (s.conf.Type != unix && s.conf.Type != unixStream) || s.conf.SystemdActivation {
+		return fmt.Errorf("the socketMode, socketOwner and socketGroup options are supported only with unix socket types created by sg-core")
+	}
+
+	if s.conf.SocketMode != "" {
+		mode, err := strconv.ParseUint(s.conf.SocketMode, 8, 32)
+		if err != nil || mode > 0777 {
+			return fmt.Errorf("invalid socketMode %q, expected octal permissions like 0660", s.conf.SocketMode)
+		}
+		s.mode = os.FileMode(mode)
+	}
+
+	if s.conf.SocketOwner != "" {
+		uid, err := strconv.Atoi(s.conf.SocketOwner)
+		if err != nil {
+			u, lerr := user.Lookup(s.conf.SocketOwner)
+			if lerr != nil {
+				return fmt.Errorf("invalid socketOwner: %w", lerr)
+			}
+			uid, _ = strconv.Atoi(u.Uid)
+		}
+		s.uid = uid
+		s.chown = true
+	}
+
+	if s.conf.SocketGroup != "" {
+		gid, err := strconv.Atoi(s.conf.SocketGroup)
+		if err != nil {
+			g, lerr := user.LookupGroup(s.conf.SocketGroup)
+			if lerr != nil {
+				return fmt.Errorf("invalid socketGroup: %w", lerr)
+			}
+			gid, _ = strconv.Atoi(g.Gid)
+		}
+		s.gid = gid
+		s.chown = true
+	}
 	return nil
 }
 
@@ -169,13 +169,17 @@ func TestTcpSocketTransport(t *testing.T) {
 		// verify transport
 		ctx, cancel := context.WithCancel(context.Background())
 		wg := sync.WaitGroup{}
-		go trans.Run(ctx, func(mess []byte) {
-			wg.Add(1)
-			strmsg := string(mess)
-			assert.Equal(t, regularBuffSize+len(addition), len(strmsg))   // we received whole message
-			assert.Equal(t, addition, strmsg[len(strmsg)-len(addition):]) // and the out-of-band part is correct
-			wg.Done()
-		}, make(chan bool))
+		exited := make(chan struct{})
+		go func() {
+			trans.Run(ctx, func(mess []byte) {
+				wg.Add(1)
+				strmsg := string(mess)
+				assert.Equal(t, regularBuffSize+len(addition), len(strmsg))   // we received whole message
+				assert.Equal(t, addition, strmsg[len(strmsg)-len(addition):]) // and the out-of-band part is correct
+				wg.Done()
+			}, make(chan bool))
+			close(exited)
+		}()
 
 		// write to socket
 		wskt, err := net.Dial("tcp", "127.0.0.1:8642")
@@ -193,6 +197,8 @@ func TestTcpSocketTransport(t *testing.T) {
 		cancel()
 		wg.Wait()
 		wskt.Close()
+		// listener is released when transport exits
+		<-exited
 	})
 
 	t.Run("test large message transport multiple connections", func(t *testing.T) {
@@ -210,13 +216,17 @@ func TestTcpSocketTransport(t *testing.T) {
 		// verify transport
 		ctx, cancel := context.WithCancel(context.Background())
 		wg := sync.WaitGroup{}
-		go trans.Run(ctx, func(mess []byte) {
-			wg.Add(1)
-			strmsg := string(mess)
-			assert.Equal(t, regularBuffSize+len(addition), len(strmsg))   // we received whole message
-			assert.Equal(t, addition, strmsg[len(strmsg)-len(addition):]) // and the out-of-band part is correct
-			wg.Done()
-		}, make(chan bool))
+		exited := make(chan struct{})
+		go func() {
+			trans.Run(ctx, func(mess []byte) {
+				wg.Add(1)
+				strmsg := string(mess)
+				assert.Equal(t, regularBuffSize+len(addition), len(strmsg))   // we received whole message
+				assert.Equal(t, addition, strmsg[len(strmsg)-len(addition):]) // and the out-of-band part is correct
+				wg.Done()
+			}, make(chan bool))
+			close(exited)
+		}()
 
 		// write to socket
 		wskt1, err := net.Dial("tcp", "127.0.0.1:8642")
@@ -242,5 +252,6 @@ func TestTcpSocketTransport(t *testing.T) {
 		wg.Wait()
 		wskt1.Close()
 		wskt2.Close()
+		<-exited
 	})
 }
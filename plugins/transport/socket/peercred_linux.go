@@ -0,0 +1,68 @@
+//go:build linux
+
+package main
+
+import (
+	"errors"
+	"syscall"
+)
+
+// size of out-of-band buffer needed to receive SCM_CREDENTIALS
+var credentialsOOBSize = syscall.CmsgSpace(syscall.SizeofUcred)
+
+var errNoCredentials = errors.New("datagram carries no sender credentials")
+
+// peerCredentials returns uid and gid of process connected to unix stream socket (SO_PEERCRED)
+func peerCredentials(c syscall.Conn) (int, int, error) {
+	rc, err := c.SyscallConn()
+	if err != nil {
+		return -1, -1, err
+	}
+	var ucred *syscall.Ucred
+	var serr error
+	err = rc.Control(func(fd uintptr) {
+		ucred, serr = syscall.GetsockoptUcred(int(fd), syscall.SOL_SOCKET, syscall.SO_PEERCRED)
+	})
+	if err != nil {
+		return -1, -1, err
+	}
+	if serr != nil {
+		return -1, -1, serr
+	}
+	return int(ucred.Uid), int(ucred.Gid), nil
+}
+
+// enablePassCredentials makes kernel attach sender credentials to each datagram (SO_PASSCRED)
+func enablePassCredentials(c syscall.Conn) error {
+	rc, err := c.SyscallConn()
+	if err != nil {
+		return err
+	}
+	var serr error
+	err = rc.Control(func(fd uintptr) {
+		serr = syscall.SetsockoptInt(int(fd), syscall.SOL_SOCKET, syscall.SO_PASSCRED, 1)
+	})
+	if err != nil {
+		return err
+	}
+	return serr
+}
+
+// parseCredentials extracts uid and gid from SCM_CREDENTIALS control message
+func parseCredentials(oob []byte) (int, int, error) {
+	msgs, err := syscall.ParseSocketControlMessage(oob)
+	if err != nil {
+		return -1, -1, err
+	}
+	for i := range msgs {
+		if msgs[i].Header.Level != syscall.SOL_SOCKET || msgs[i].Header.Type != syscall.SCM_CREDENTIALS {
+			continue
+		}
+		ucred, err := syscall.ParseUnixCredentials(&msgs[i])
+		if err != nil {
+			return -1, -1, err
+		}
+		return int(ucred.Uid), int(ucred.Gid), nil
+	}
+	return -1, -1, errNoCredentials
+}
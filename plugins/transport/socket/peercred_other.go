@@ -0,0 +1,24 @@
+//go:build !linux
+
+package main
+
+import (
+	"errors"
+	"syscall"
+)
+
+var credentialsOOBSize = 0
+
+var errNoCredentials = errors.New("peer credentials are supported only on linux")
+
+func peerCredentials(syscall.Conn) (int, int, error) {
+	return -1, -1, errNoCredentials
+}
+
+func enablePassCredentials(syscall.Conn) error {
+	return errNoCredentials
+}
+
+func parseCredentials([]byte) (int, int, error) {
+	return -1, -1, errNoCredentials
+}
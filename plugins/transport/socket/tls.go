@@ -0,0 +1,101 @@
+package main
+
+import (
+	"crypto/tls"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/openstack-k8s-operators/sg-core/pkg/config"
+	"github.com/openstack-k8s-operators/sg-core/pkg/transport"
+)
+
+// certReloader serves TLS configuration for the TCP listener and reloads it
+// whenever certificate, key or CA file changes on disk
+type certReloader struct {
+	conf     config.TLSConfig
+	mutex    sync.RWMutex
+	tlsConf  *tls.Config
+	modTimes map[string]time.Time
+}
+
+func newCertReloader(conf config.TLSConfig) (*certReloader, error) {
+	cr := &certReloader{conf: conf}
+	_, err := cr.reload()
+	if err != nil {
+		return nil, err
+	}
+	return cr, nil
+}
+
+func (cr *certReloader) files() []string {
+	files := []string{cr.conf.CertFile, cr.conf.KeyFile}
+	if cr.conf.CAFile != "" {
+		files = append(files, cr.conf.CAFile)
+	}
+	return files
+}
+
+// reload loads TLS configuration again if any of the files changed. Returns true if
+// new configuration is in use. On failure the previous configuration is kept
+func (cr *certReloader) reload() (bool, error) {
+	modTimes := make(map[string]time.Time)
+	changed := false
+	for _, f := range cr.files() {
+		info, err := os.Stat(f)
+		if err != nil {
+			return false, err
+		}
+		modTimes[f] = info.ModTime()
+		if !cr.modTimes[f].Equal(info.ModTime()) {
+			changed = true
+		}
+	}
+	if !changed {
+		return false, nil
+	}
+
+	tlsConf, err := cr.conf.ServerConfig()
+	if err != nil {
+		return false, err
+	}
+
+	cr.mutex.Lock()
+	cr.tlsConf = tlsConf
+	cr.modTimes = modTimes
+	cr.mutex.Unlock()
+	return true, nil
+}
+
+func (cr *certReloader) getConfigForClient(*tls.ClientHelloInfo) (*tls.Config, error) {
+	cr.mutex.RLock()
+	defer cr.mutex.RUnlock()
+	return cr.tlsConf, nil
+}
+
+// listenerConfig returns configuration for tls.NewListener which always
+// resolves to the most recently loaded configuration
+func (cr *certReloader) listenerConfig() *tls.Config {
+	return &tls.Config{
+		MinVersion:         tls.VersionTLS12,
+		GetConfigForClient: cr.getConfigForClient,
+	}
+}
+
+// peerMetadata returns subject details of verified client certificate
+func peerMetadata(state tls.ConnectionState) transport.Metadata {
+	if len(state.PeerCertificates) == 0 {
+		return nil
+	}
+	subject := state.PeerCertificates[0].Subject
+	// all keys are always set so that labels stay consistent across peers
+	meta := transport.Metadata{
+		"tls_subject":      subject.String(),
+		"tls_common_name":  subject.CommonName,
+		"tls_organization": "",
+	}
+	if len(subject.Organization) > 0 {
+		meta["tls_organization"] = subject.Organization[0]
+	}
+	return meta
+}
@@ -0,0 +1,224 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/binary"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"os"
+	"path"
+	"testing"
+	"time"
+
+	"github.com/infrawatch/apputils/logging"
+	"github.com/openstack-k8s-operators/sg-core/pkg/config"
+	"github.com/openstack-k8s-operators/sg-core/pkg/transport"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type testCert struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+	der  []byte
+}
+
+func genCert(t *testing.T, cn string, parent *testCert, isCA bool) *testCert {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	serial, err := rand.Int(rand.Reader, big.NewInt(1<<62))
+	require.NoError(t, err)
+	tmpl := &x509.Certificate{
+		SerialNumber: serial,
+		Subject:      pkix.Name{CommonName: cn, Organization: []string{"sg-core"}},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+		IsCA:         isCA,
+
+		BasicConstraintsValid: true,
+	}
+	signer, signerKey := tmpl, key
+	if parent != nil {
+		signer, signerKey = parent.cert, parent.key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, signer, &key.PublicKey, signerKey)
+	require.NoError(t, err)
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+	return &testCert{cert: cert, key: key, der: der}
+}
+
+func (tc *testCert) write(t *testing.T, certPath, keyPath string) {
+	require.NoError(t, os.WriteFile(certPath, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: tc.der}), 0600))
+	if keyPath != "" {
+		keyDer, err := x509.MarshalECPrivateKey(tc.key)
+		require.NoError(t, err)
+		require.NoError(t, os.WriteFile(keyPath, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer}), 0600))
+	}
+}
+
+func (tc *testCert) tlsCert() tls.Certificate {
+	return tls.Certificate{Certificate: [][]byte{tc.der}, PrivateKey: tc.key}
+}
+
+func TestTLSSocketTransport(t *testing.T) {
+	tmpdir, err := os.MkdirTemp(".", "socket_test_tmp")
+	require.NoError(t, err)
+	defer os.RemoveAll(tmpdir)
+
+	logpath := path.Join(tmpdir, "test.log")
+	logger, err := logging.NewLogger(logging.DEBUG, logpath)
+	require.NoError(t, err)
+
+	ca := genCert(t, "sg-core-ca", nil, true)
+	server := genCert(t, "sg-core", ca, false)
+	client := genCert(t, "compute-0", ca, false)
+
+	caPath := path.Join(tmpdir, "ca.pem")
+	certPath := path.Join(tmpdir, "server.pem")
+	keyPath := path.Join(tmpdir, "server.key")
+	ca.write(t, caPath, "")
+	server.write(t, certPath, keyPath)
+
+	tlsConf := config.TLSConfig{
+		Enabled:  true,
+		CertFile: certPath,
+		KeyFile:  keyPath,
+		CAFile:   caPath,
+	}
+
+	roots := x509.NewCertPool()
+	roots.AddCert(ca.cert)
+
+	t.Run("test mutual TLS with peer labels", func(t *testing.T) {
+		certs, err := newCertReloader(tlsConf)
+		require.NoError(t, err)
+		trans := Socket{
+			conf: configT{
+				Socketaddr: "127.0.0.1:8643",
+				Type:       "tcp",
+				TLS:        tlsConf,
+				PeerLabels: true,
+			},
+			logger: &logWrapper{
+				l: logger,
+			},
+			certs: certs,
+		}
+
+		msg := []byte("wubba lubba dub dub")
+		received := make(chan transport.Metadata, 1)
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		go trans.RunWithMetadata(ctx, func(mess []byte, meta transport.Metadata) {
+			assert.Equal(t, msg, mess)
+			received <- meta
+		}, make(chan bool))
+
+		// connection without client certificate is refused
+		var conn *tls.Conn
+		for retries := 0; retries < 10; retries++ {
+			conn, err = tls.Dial("tcp", "127.0.0.1:8643", &tls.Config{RootCAs: roots, MinVersion: tls.VersionTLS12})
+			if err == nil {
+				break
+			}
+			time.Sleep(250 * time.Millisecond)
+		}
+		if err == nil {
+			// with TLS 1.3 client certificate is verified after client handshake completes
+			_, err = conn.Write([]byte{0})
+			if err == nil {
+				_, err = conn.Read(make([]byte, 1))
+			}
+			conn.Close()
+		}
+		assert.Error(t, err)
+
+		conn, err = tls.Dial("tcp", "127.0.0.1:8643", &tls.Config{
+			RootCAs:      roots,
+			Certificates: []tls.Certificate{client.tlsCert()},
+			MinVersion:   tls.VersionTLS12,
+		})
+		require.NoError(t, err)
+		defer conn.Close()
+
+		length := new(bytes.Buffer)
+		require.NoError(t, binary.Write(length, binary.LittleEndian, uint64(len(msg))))
+		_, err = conn.Write(append(length.Bytes(), msg...))
+		require.NoError(t, err)
+
+		select {
+		case meta := <-received:
+			assert.Equal(t, "compute-0", meta["tls_common_name"])
+			assert.Equal(t, "sg-core", meta["tls_organization"])
+			assert.Equal(t, "CN=compute-0,O=sg-core", meta["tls_subject"])
+		case <-time.After(5 * time.Second):
+			t.Fatal("message was not received")
+		}
+	})
+
+	t.Run("test certificate reload", func(t *testing.T) {
+		certs, err := newCertReloader(tlsConf)
+		require.NoError(t, err)
+
+		reloaded, err := certs.reload()
+		require.NoError(t, err)
+		assert.False(t, reloaded)
+
+		renewed := genCert(t, "sg-core-renewed", ca, false)
+		renewed.write(t, certPath, keyPath)
+		// make sure modification time differs even on filesystems with coarse timestamps
+		future := time.Now().Add(time.Minute)
+		require.NoError(t, os.Chtimes(certPath, future, future))
+
+		reloaded, err = certs.reload()
+		require.NoError(t, err)
+		assert.True(t, reloaded)
+
+		conf, err := certs.getConfigForClient(nil)
+		require.NoError(t, err)
+		leaf, err := x509.ParseCertificate(conf.Certificates[0].Certificate[0])
+		require.NoError(t, err)
+		assert.Equal(t, "sg-core-renewed", leaf.Subject.CommonName)
+
+		// broken files keep previous configuration in place
+		require.NoError(t, os.WriteFile(keyPath, []byte("garbage"), 0600))
+		require.NoError(t, os.Chtimes(keyPath, future.Add(time.Minute), future.Add(time.Minute)))
+		_, err = certs.reload()
+		assert.Error(t, err)
+		conf, err = certs.getConfigForClient(nil)
+		require.NoError(t, err)
+		leaf, err = x509.ParseCertificate(conf.Certificates[0].Certificate[0])
+		require.NoError(t, err)
+		assert.Equal(t, "sg-core-renewed", leaf.Subject.CommonName)
+	})
+
+	t.Run("test TLS configuration", func(t *testing.T) {
+		trans := New(logger).(*Socket)
+		assert.Error(t, trans.Config([]byte(`
+type: udp
+socketaddr: 127.0.0.1:8643
+tls:
+  enabled: true
+  certFile: /nonexistent
+  keyFile: /nonexistent
+`)))
+		assert.Error(t, trans.Config([]byte(`
+type: tcp
+socketaddr: 127.0.0.1:8643
+tls:
+  enabled: true
+`)))
+	})
+}